@@ -0,0 +1,8 @@
+/*
+Package analysis computes summaries of FXT traces, such as how busy each thread is
+
+The analyses read a whole trace with an fxt.Reader, and return plain structs that the caller can print
+or compare. Durations are converted to time.Duration using the tick rate from the trace's initialization
+record, or nanoseconds if it doesn't have one
+*/
+package analysis
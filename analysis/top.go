@@ -0,0 +1,111 @@
+package analysis
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+// Span is a single duration event, from either a begin / end pair or a complete event
+type Span struct {
+	Thread   fxt.Thread
+	Category string
+	Name     string
+	// Timestamp is when the span began, in the trace's ticks
+	Timestamp uint64
+	// Start is when the span began, relative to the start of the trace
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// NameTotal is the time spent in all the duration events with the same category and name
+type NameTotal struct {
+	Category string
+	Name     string
+	Count    int
+	// Total is the sum of the durations. Recursive spans with the same name are counted once per level
+	Total   time.Duration
+	Longest time.Duration
+}
+
+// TopReport is the longest duration events in a trace, and the names with the most time spent in them
+type TopReport struct {
+	// Longest is sorted from the longest span to the shortest
+	Longest []Span
+	// Hottest is sorted from the most total time to the least
+	Hottest []NameTotal
+}
+
+// Top finds the `k` longest duration events in the trace, and the `k` event names with the most cumulative time
+//
+// Duration begin events without a matching end event are treated as lasting until the end of the trace
+func Top(r io.Reader, k int) (*TopReport, error) {
+	t, err := readTrace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	type nameKey struct {
+		category string
+		name     string
+	}
+	totals := map[nameKey]*NameTotal{}
+
+	report := &TopReport{}
+	for _, s := range t.spans {
+		if s.end < s.begin {
+			continue
+		}
+
+		span := Span{
+			Thread:    s.thread,
+			Category:  s.category,
+			Name:      s.name,
+			Timestamp: s.begin,
+			Start:     t.duration(s.begin - t.start),
+			Duration:  t.duration(s.end - s.begin),
+		}
+		report.Longest = append(report.Longest, span)
+
+		key := nameKey{s.category, s.name}
+		total, ok := totals[key]
+		if !ok {
+			total = &NameTotal{Category: s.category, Name: s.name}
+			totals[key] = total
+		}
+		total.Count++
+		total.Total += span.Duration
+		if span.Duration > total.Longest {
+			total.Longest = span.Duration
+		}
+	}
+
+	// The spans are already in order of their timestamps, so a stable sort breaks ties by the earliest
+	sort.SliceStable(report.Longest, func(i, j int) bool {
+		return report.Longest[i].Duration > report.Longest[j].Duration
+	})
+	if len(report.Longest) > k {
+		report.Longest = report.Longest[:k]
+	}
+
+	for _, total := range totals {
+		report.Hottest = append(report.Hottest, *total)
+	}
+	sort.Slice(report.Hottest, func(i, j int) bool {
+		a, b := report.Hottest[i], report.Hottest[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Name < b.Name
+	})
+	if len(report.Hottest) > k {
+		report.Hottest = report.Hottest[:k]
+	}
+
+	return report, nil
+}
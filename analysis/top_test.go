@@ -0,0 +1,38 @@
+package analysis_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/analysis"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTop(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksMicroseconds))
+	require.NoError(t, writer.AddInstantEvent("cat", "start", 1, 2, 1000))
+	require.NoError(t, writer.AddDurationCompleteEvent("db", "query", 1, 2, 1100, 1400))
+	require.NoError(t, writer.AddDurationBeginEvent("http", "request", 1, 3, 1200))
+	require.NoError(t, writer.AddDurationCompleteEvent("db", "query", 1, 3, 1300, 1550))
+	require.NoError(t, writer.AddDurationEndEvent("http", "request", 1, 3, 1700))
+	require.NoError(t, writer.AddDurationCompleteEvent("cache", "get", 1, 2, 1800, 1810))
+
+	report, err := analysis.Top(&buffer, 2)
+	require.NoError(t, err)
+
+	require.Equal(t, []analysis.Span{
+		{Thread: fxt.Thread{ProcessId: 1, ThreadId: 3}, Category: "http", Name: "request", Timestamp: 1200, Start: 200 * time.Microsecond, Duration: 500 * time.Microsecond},
+		{Thread: fxt.Thread{ProcessId: 1, ThreadId: 2}, Category: "db", Name: "query", Timestamp: 1100, Start: 100 * time.Microsecond, Duration: 300 * time.Microsecond},
+	}, report.Longest)
+
+	require.Equal(t, []analysis.NameTotal{
+		{Category: "db", Name: "query", Count: 2, Total: 550 * time.Microsecond, Longest: 300 * time.Microsecond},
+		{Category: "http", Name: "request", Count: 1, Total: 500 * time.Microsecond, Longest: 500 * time.Microsecond},
+	}, report.Hottest)
+}
@@ -0,0 +1,252 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+// span is the time between the beginning and the end of a duration event
+type span struct {
+	thread   fxt.Thread
+	category string
+	name     string
+	begin    uint64
+	end      uint64
+}
+
+// interval is a range of ticks
+type interval struct {
+	begin uint64
+	end   uint64
+}
+
+// trace holds what the analyses need from a trace, after reading it once
+type trace struct {
+	tickRate fxt.TickRate
+	// start and end are the first and last timestamps of the events and scheduling records
+	start uint64
+	end   uint64
+
+	spans []span
+	// threadNames is the name of each thread ID, from the kernel object records
+	threadNames map[fxt.KernelObjectID]string
+	// running is when each thread ID was running on a CPU, from the context switch records
+	running       map[fxt.KernelObjectID][]interval
+	hasScheduling bool
+	hasTimestamp  bool
+}
+
+// openSpan is a duration begin event waiting for its end event
+type openSpan struct {
+	category string
+	name     string
+	begin    uint64
+}
+
+// runningThread is the thread on a CPU, and since when
+type runningThread struct {
+	tid   fxt.KernelObjectID
+	since uint64
+}
+
+// readTrace reads every record of the trace in `r`
+//
+// Duration begin and end events are paired up per thread, and any left open are closed at the end of the trace
+func readTrace(r io.Reader) (*trace, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &trace{
+		tickRate:    fxt.TicksNanoseconds,
+		threadNames: map[fxt.KernelObjectID]string{},
+		running:     map[fxt.KernelObjectID][]interval{},
+	}
+	stacks := map[fxt.Thread][]openSpan{}
+	cpus := map[uint16]runningThread{}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+
+		switch v := decoded.(type) {
+		case *fxt.InitializationRecord:
+			if v.TicksPerSecond != 0 {
+				t.tickRate = v.TicksPerSecond
+			}
+		case *fxt.KernelObjectRecord:
+			if v.Type == fxt.KernelObjectTypeThread {
+				t.threadNames[v.Koid] = resolveString(reader, v.Name)
+			}
+		case *fxt.DurationBeginEvent:
+			t.addTimestamp(v.Timestamp)
+			thread := resolveThread(reader, v.Thread)
+			stacks[thread] = append(stacks[thread], openSpan{
+				category: resolveString(reader, v.Category),
+				name:     resolveString(reader, v.Name),
+				begin:    v.Timestamp,
+			})
+		case *fxt.DurationEndEvent:
+			t.addTimestamp(v.Timestamp)
+			thread := resolveThread(reader, v.Thread)
+			stack := stacks[thread]
+			if len(stack) == 0 {
+				// The begin event was before the start of the trace
+				continue
+			}
+			open := stack[len(stack)-1]
+			stacks[thread] = stack[:len(stack)-1]
+			t.spans = append(t.spans, span{thread: thread, category: open.category, name: open.name, begin: open.begin, end: v.Timestamp})
+		case *fxt.DurationCompleteEvent:
+			t.addTimestamp(v.Timestamp)
+			t.addTimestamp(v.EndTimestamp)
+			t.spans = append(t.spans, span{
+				thread:   resolveThread(reader, v.Thread),
+				category: resolveString(reader, v.Category),
+				name:     resolveString(reader, v.Name),
+				begin:    v.Timestamp,
+				end:      v.EndTimestamp,
+			})
+		case *fxt.ContextSwitchRecord:
+			t.addTimestamp(v.Timestamp)
+			t.hasScheduling = true
+			if current, ok := cpus[v.CPU]; ok && current.tid != 0 {
+				t.running[current.tid] = append(t.running[current.tid], interval{current.since, v.Timestamp})
+			}
+			cpus[v.CPU] = runningThread{tid: v.IncomingThreadId, since: v.Timestamp}
+		case *fxt.ThreadWakeupRecord:
+			t.addTimestamp(v.Timestamp)
+		default:
+			if decoded == nil {
+				continue
+			}
+			if event := eventOf(decoded); event != nil {
+				t.addTimestamp(event.Timestamp)
+			}
+		}
+	}
+
+	// Whatever is still open runs until the end of the trace
+	for thread, stack := range stacks {
+		for _, open := range stack {
+			t.spans = append(t.spans, span{thread: thread, category: open.category, name: open.name, begin: open.begin, end: t.end})
+		}
+	}
+	for _, current := range cpus {
+		if current.tid != 0 {
+			t.running[current.tid] = append(t.running[current.tid], interval{current.since, t.end})
+		}
+	}
+
+	// Map iteration order is random, so put the spans back in a stable order
+	sort.SliceStable(t.spans, func(i, j int) bool {
+		return t.spans[i].begin < t.spans[j].begin
+	})
+
+	return t, nil
+}
+
+func (t *trace) addTimestamp(timestamp uint64) {
+	if !t.hasTimestamp || timestamp < t.start {
+		t.start = timestamp
+	}
+	if !t.hasTimestamp || timestamp > t.end {
+		t.end = timestamp
+	}
+	t.hasTimestamp = true
+}
+
+// duration converts a number of ticks to a time.Duration
+func (t *trace) duration(ticks uint64) time.Duration {
+	return t.tickRate.ToDuration(ticks)
+}
+
+// eventOf returns the EventRecord of the event record structs that aren't handled separately
+func eventOf(decoded interface{}) *fxt.EventRecord {
+	switch v := decoded.(type) {
+	case *fxt.InstantEvent:
+		return &v.EventRecord
+	case *fxt.CounterEvent:
+		return &v.EventRecord
+	case *fxt.AsyncBeginEvent:
+		return &v.EventRecord
+	case *fxt.AsyncInstantEvent:
+		return &v.EventRecord
+	case *fxt.AsyncEndEvent:
+		return &v.EventRecord
+	case *fxt.FlowBeginEvent:
+		return &v.EventRecord
+	case *fxt.FlowStepEvent:
+		return &v.EventRecord
+	case *fxt.FlowEndEvent:
+		return &v.EventRecord
+	case *fxt.LargeBlobEventRecord:
+		return &v.EventRecord
+	}
+	return nil
+}
+
+func resolveString(reader *fxt.Reader, ref fxt.StringRef) string {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	str, _ := reader.LookupString(ref.Index)
+	return str
+}
+
+func resolveThread(reader *fxt.Reader, ref fxt.ThreadRef) fxt.Thread {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	thread, _ := reader.LookupThread(uint16(ref.Index))
+	return thread
+}
+
+// coveredTicks returns the number of ticks covered by at least one of the intervals
+func coveredTicks(intervals []interval) uint64 {
+	sorted := append([]interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].begin < sorted[j].begin
+	})
+
+	var covered uint64
+	var current interval
+	started := false
+	for _, next := range sorted {
+		if next.end < next.begin {
+			// The timestamps are out of order, so there's no sensible duration
+			continue
+		}
+		if started && next.begin <= current.end {
+			if next.end > current.end {
+				current.end = next.end
+			}
+			continue
+		}
+		if started {
+			covered += current.end - current.begin
+		}
+		current = next
+		started = true
+	}
+	if started {
+		covered += current.end - current.begin
+	}
+	return covered
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+// ThreadUtilization is how busy a thread was over the course of a trace
+type ThreadUtilization struct {
+	Thread fxt.Thread
+	// Name is the thread's name from its kernel object record, if it has one
+	Name string
+
+	// Busy is the time covered by at least one of the thread's duration events
+	Busy         time.Duration
+	BusyFraction float64
+
+	// Running is the time the thread was running on a CPU, according to the context switch records
+	// It's only set if the trace has context switch records
+	Running         time.Duration
+	RunningFraction float64
+}
+
+// UtilizationReport is the utilization of every thread in a trace
+type UtilizationReport struct {
+	// Duration is the time between the first and last timestamps in the trace
+	Duration time.Duration
+	// HasScheduling is whether the trace has context switch records, and so whether the Running times are set
+	HasScheduling bool
+	// Threads is sorted from the busiest thread to the least busy
+	Threads []ThreadUtilization
+}
+
+// Utilization computes the fraction of the trace each thread spent in duration events, and running on a CPU
+//
+// Nested duration events are only counted once. Threads in the context switch records are matched to the
+// threads of events by thread ID. Threads that only appear in context switch records have a process ID of 0
+func Utilization(r io.Reader) (*UtilizationReport, error) {
+	t, err := readTrace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := map[fxt.Thread][]interval{}
+	for _, s := range t.spans {
+		busy[s.thread] = append(busy[s.thread], interval{s.begin, s.end})
+	}
+
+	threads := map[fxt.Thread]bool{}
+	tids := map[fxt.KernelObjectID]bool{}
+	for thread := range busy {
+		threads[thread] = true
+		tids[thread.ThreadId] = true
+	}
+	for tid := range t.running {
+		if !tids[tid] {
+			threads[fxt.Thread{ThreadId: tid}] = true
+		}
+	}
+
+	traceTicks := t.end - t.start
+	fraction := func(ticks uint64) float64 {
+		if traceTicks == 0 {
+			return 0
+		}
+		return float64(ticks) / float64(traceTicks)
+	}
+
+	report := &UtilizationReport{
+		Duration:      t.duration(traceTicks),
+		HasScheduling: t.hasScheduling,
+	}
+	for thread := range threads {
+		busyTicks := coveredTicks(busy[thread])
+		utilization := ThreadUtilization{
+			Thread:       thread,
+			Name:         t.threadNames[thread.ThreadId],
+			Busy:         t.duration(busyTicks),
+			BusyFraction: fraction(busyTicks),
+		}
+		if t.hasScheduling {
+			runningTicks := coveredTicks(t.running[thread.ThreadId])
+			utilization.Running = t.duration(runningTicks)
+			utilization.RunningFraction = fraction(runningTicks)
+		}
+		report.Threads = append(report.Threads, utilization)
+	}
+
+	sort.Slice(report.Threads, func(i, j int) bool {
+		a, b := report.Threads[i], report.Threads[j]
+		if a.BusyFraction != b.BusyFraction {
+			return a.BusyFraction > b.BusyFraction
+		}
+		if a.RunningFraction != b.RunningFraction {
+			return a.RunningFraction > b.RunningFraction
+		}
+		if a.Thread.ProcessId != b.Thread.ProcessId {
+			return a.Thread.ProcessId < b.Thread.ProcessId
+		}
+		return a.Thread.ThreadId < b.Thread.ThreadId
+	})
+
+	return report, nil
+}
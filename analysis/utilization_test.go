@@ -0,0 +1,73 @@
+package analysis_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/analysis"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUtilization(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksMicroseconds))
+	require.NoError(t, writer.SetThreadName(1, 2, "main"))
+
+	// Thread 2 is busy from 0 to 600, with a nested event that isn't counted twice
+	require.NoError(t, writer.AddDurationBeginEvent("cat", "outer", 1, 2, 0))
+	require.NoError(t, writer.AddDurationCompleteEvent("cat", "inner", 1, 2, 100, 200))
+	require.NoError(t, writer.AddDurationEndEvent("cat", "outer", 1, 2, 400))
+	require.NoError(t, writer.AddDurationCompleteEvent("cat", "later", 1, 2, 500, 600))
+	// Thread 3 is busy from 800 until the end of the trace, because its end event is missing
+	require.NoError(t, writer.AddDurationBeginEvent("cat", "unfinished", 1, 3, 800))
+
+	// Thread 2 runs from 0 to 500, and thread 4 runs from 500 until the end of the trace
+	require.NoError(t, writer.AddContextSwitchRecord(0, 0, 0, 2, 0))
+	require.NoError(t, writer.AddContextSwitchRecord(0, 3, 2, 4, 500))
+	require.NoError(t, writer.AddInstantEvent("cat", "end", 1, 3, 1000))
+
+	report, err := analysis.Utilization(&buffer)
+	require.NoError(t, err)
+	require.Equal(t, time.Millisecond, report.Duration)
+	require.True(t, report.HasScheduling)
+
+	require.Equal(t, []analysis.ThreadUtilization{
+		{
+			Thread:          fxt.Thread{ProcessId: 1, ThreadId: 2},
+			Name:            "main",
+			Busy:            500 * time.Microsecond,
+			BusyFraction:    0.5,
+			Running:         500 * time.Microsecond,
+			RunningFraction: 0.5,
+		},
+		{
+			Thread:       fxt.Thread{ProcessId: 1, ThreadId: 3},
+			Busy:         200 * time.Microsecond,
+			BusyFraction: 0.2,
+		},
+		{
+			Thread:          fxt.Thread{ThreadId: 4},
+			Running:         500 * time.Microsecond,
+			RunningFraction: 0.5,
+		},
+	}, report.Threads)
+}
+
+func TestUtilizationWithoutScheduling(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddDurationCompleteEvent("cat", "name", 1, 2, 100, 300))
+
+	report, err := analysis.Utilization(&buffer)
+	require.NoError(t, err)
+	require.False(t, report.HasScheduling)
+	require.Len(t, report.Threads, 1)
+	require.Equal(t, 1.0, report.Threads[0].BusyFraction)
+	require.Zero(t, report.Threads[0].Running)
+}
@@ -0,0 +1,158 @@
+package fxt
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// NewAppendWriter opens the existing FXT file at `filePath` to add more records to the end of it.
+// If the file doesn't exist or is empty, a new trace is started, like with NewWriter
+//
+// The string and thread tables, and the provider info and initialization records, are reconstructed
+// from the contents of the file, so new records keep referring to the existing table entries. This allows
+// a process that restarts part way through a capture to continue writing to the same trace
+//
+// The format version of the existing trace is kept. If WithFormatVersion is given, it must match
+//
+// The previous timestamps aren't reconstructed, so WithTimestampCheck and WithTimestampNormalization
+// only apply to the records written by this Writer
+//
+// If the file ends part way through a record, because the process writing it crashed, the partial record is
+// cut off, and reported to the warning handler, so the new records follow the last complete one
+//
+// With WithChecksums, the records after the last checksum record in the file start the first chunk checksummed
+// by the Writer, so the chunk spanning the restart still verifies
+func NewAppendWriter(filePath string, options ...WriterOption) (*Writer, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dest file %s - %w", filePath, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat dest file %s - %w", filePath, err)
+	}
+	if info.Size() == 0 {
+		writer, err := newWriter(file, file, filePath, options)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return writer, nil
+	}
+
+	// Leave the version unset, so it can be taken from the existing trace
+	writer := &Writer{
+		warningHandler: defaultWarningHandler,
+	}
+	for _, option := range options {
+		option(writer)
+	}
+	writer.resetState(file, file)
+
+	end, err := writer.loadState(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read existing trace %s - %w", filePath, err)
+	}
+	if end < info.Size() {
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate the partial record at the end of %s - %w", filePath, err)
+		}
+		writer.warn(fmt.Errorf("cut off the %d byte partial record at the end of %s", info.Size()-end, filePath))
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek to the end of %s - %w", filePath, err)
+	}
+	writer.startFlusher()
+	writer.startSignalHandlers()
+
+	return writer, nil
+}
+
+// loadState reads an existing trace, and sets up the tables and metadata of the Writer to continue it
+// It returns the offset the complete records end at
+func (w *Writer) loadState(r io.Reader) (int64, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	reader.AllowTruncatedTail()
+
+	if w.formatVersion == 0 {
+		w.formatVersion = reader.Version()
+	} else if w.formatVersion != reader.Version() {
+		return 0, fmt.Errorf("the trace is format version %s, but the Writer was configured for %s", reader.Version(), w.formatVersion)
+	}
+	if w.checksums != nil {
+		w.checksums.crc = crc32.Update(0, castagnoli, magicNumberRecords[w.formatVersion])
+		w.checksums.size = uint64(len(magicNumberRecords[w.formatVersion]))
+	}
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if w.checksums != nil {
+			if isChecksumRecord(record) {
+				w.checksums.crc = 0
+				w.checksums.size = 0
+			} else {
+				w.checksums.crc = crc32.Update(w.checksums.crc, castagnoli, record.Data)
+				w.checksums.size += uint64(len(record.Data))
+			}
+		}
+
+		header := record.Header()
+		switch record.Type {
+		case RecordTypeInitialization:
+			if len(record.Data) < 2*8 {
+				return 0, fmt.Errorf("invalid initialization record at offset %d - record is too small", record.Offset)
+			}
+			w.tickRate = TickRate(record.Word(1))
+		case RecordTypeMetadata:
+			if metadataType((header>>16)&0xF) != metadataTypeProviderInfo {
+				continue
+			}
+
+			nameLen := int((header >> 52) & 0xFF)
+			if len(record.Data) < 8+nameLen {
+				return 0, fmt.Errorf("invalid provider info record at offset %d - name length %d exceeds the record size", record.Offset, nameLen)
+			}
+			w.providers = append(w.providers, providerInfo{
+				id:   uint32((header >> 20) & 0xFFFFFFFF),
+				name: string(record.Data[8 : 8+nameLen]),
+			})
+		}
+	}
+
+	for index, str := range reader.stringTable {
+		w.stringTable[str] = index
+		w.stringBytes += len(str)
+		w.useString(index, str)
+		if index >= w.nextStringIndex {
+			w.nextStringIndex = index + 1
+		}
+	}
+	for index, thread := range reader.threadTable {
+		w.threadTable[thread] = index
+		if index >= w.nextThreadIndex {
+			w.nextThreadIndex = index + 1
+		}
+	}
+
+	end, _ := reader.Truncated()
+	return end, nil
+}
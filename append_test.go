@@ -0,0 +1,108 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendWriter(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writeFirstHalf := func(writer *fxt.Writer) {
+		require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+		require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+		require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 100))
+	}
+	writeSecondHalf := func(writer *fxt.Writer) {
+		// Reuses the existing strings and thread, and adds new ones
+		require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 200))
+		require.NoError(t, writer.AddInstantEvent("Category", "Other", 3, 5, 300))
+	}
+
+	// Write the whole trace in one go
+	expectedPath := filepath.Join(tempDir, "expected.fxt")
+	writer, err := fxt.NewWriter(expectedPath)
+	require.NoError(t, err)
+	writeFirstHalf(writer)
+	writeSecondHalf(writer)
+	require.NoError(t, writer.Close())
+
+	// And in two parts. The append writer creates the file if it doesn't exist
+	appendedPath := filepath.Join(tempDir, "appended.fxt")
+	writer, err = fxt.NewAppendWriter(appendedPath)
+	require.NoError(t, err)
+	writeFirstHalf(writer)
+	require.NoError(t, writer.Close())
+
+	writer, err = fxt.NewAppendWriter(appendedPath)
+	require.NoError(t, err)
+	require.Equal(t, fxt.TicksNanoseconds, writer.TickRate())
+	writeSecondHalf(writer)
+	require.NoError(t, writer.Close())
+
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	appended, err := os.ReadFile(appendedPath)
+	require.NoError(t, err)
+	require.Equal(t, expected, appended)
+
+	// Files that aren't traces are rejected, rather than appended to
+	invalidPath := filepath.Join(tempDir, "invalid.fxt")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("not a trace"), 0666))
+	_, err = fxt.NewAppendWriter(invalidPath)
+	require.Error(t, err)
+}
+
+func TestAppendWriterTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crashed.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("Category", "Before", 3, 4, 100))
+	require.NoError(t, writer.Close())
+
+	// The process crashed part way through writing a record
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0666)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{0x34, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x01, 0x02})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	var warnings []error
+	writer, err = fxt.NewAppendWriter(path, fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	require.ErrorContains(t, warnings[0], "partial record")
+	require.NoError(t, writer.AddInstantEvent("Category", "After", 3, 4, 200))
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	reader, err := fxt.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, event.Name)
+	}
+	require.Equal(t, []string{"Before", "After"}, names)
+}
@@ -0,0 +1,142 @@
+package fxt
+
+import "fmt"
+
+// String returns the name of the argument type
+func (t ArgumentType) String() string {
+	switch t {
+	case ArgumentTypeNull:
+		return "null"
+	case ArgumentTypeInt32:
+		return "int32"
+	case ArgumentTypeUint32:
+		return "uint32"
+	case ArgumentTypeInt64:
+		return "int64"
+	case ArgumentTypeUint64:
+		return "uint64"
+	case ArgumentTypeDouble:
+		return "double"
+	case ArgumentTypeString:
+		return "string"
+	case ArgumentTypePointer:
+		return "pointer"
+	case ArgumentTypeKOID:
+		return "koid"
+	case ArgumentTypeBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("invalid(%d)", int(t))
+	}
+}
+
+// argumentTypeOf returns the type of an argument value, or -1 if it isn't one of the types of Argument.Value
+// Strings are treated as string arguments, for ResolvedArgument
+func argumentTypeOf(value interface{}) ArgumentType {
+	switch value.(type) {
+	case nil:
+		return ArgumentTypeNull
+	case int32:
+		return ArgumentTypeInt32
+	case uint32:
+		return ArgumentTypeUint32
+	case int64:
+		return ArgumentTypeInt64
+	case uint64:
+		return ArgumentTypeUint64
+	case float64:
+		return ArgumentTypeDouble
+	case StringRef, string:
+		return ArgumentTypeString
+	case uintptr:
+		return ArgumentTypePointer
+	case KernelObjectID:
+		return ArgumentTypeKOID
+	case bool:
+		return ArgumentTypeBool
+	default:
+		return -1
+	}
+}
+
+// Type returns the type of the argument's value
+// Values that aren't one of the types Argument.Value can hold return an invalid ArgumentType
+func (a Argument) Type() ArgumentType {
+	if _, ok := a.Value.(string); ok {
+		return -1
+	}
+	return argumentTypeOf(a.Value)
+}
+
+// IsNull reports whether the argument is a null argument
+func (a Argument) IsNull() bool {
+	return a.Value == nil
+}
+
+// Int32 returns the value of an int32 argument
+func (a Argument) Int32() (int32, bool) {
+	v, ok := a.Value.(int32)
+	return v, ok
+}
+
+// Uint32 returns the value of a uint32 argument
+func (a Argument) Uint32() (uint32, bool) {
+	v, ok := a.Value.(uint32)
+	return v, ok
+}
+
+// Int64 returns the value of an int64 argument
+func (a Argument) Int64() (int64, bool) {
+	v, ok := a.Value.(int64)
+	return v, ok
+}
+
+// Uint64 returns the value of a uint64 argument
+func (a Argument) Uint64() (uint64, bool) {
+	v, ok := a.Value.(uint64)
+	return v, ok
+}
+
+// Double returns the value of a double argument
+func (a Argument) Double() (float64, bool) {
+	v, ok := a.Value.(float64)
+	return v, ok
+}
+
+// Str returns the value of a string argument. Use Reader.ResolveString to look it up in the string table
+func (a Argument) Str() (StringRef, bool) {
+	v, ok := a.Value.(StringRef)
+	return v, ok
+}
+
+// Pointer returns the value of a pointer argument
+func (a Argument) Pointer() (uintptr, bool) {
+	v, ok := a.Value.(uintptr)
+	return v, ok
+}
+
+// KOID returns the value of a kernel object ID argument
+func (a Argument) KOID() (KernelObjectID, bool) {
+	v, ok := a.Value.(KernelObjectID)
+	return v, ok
+}
+
+// Bool returns the value of a bool argument
+func (a Argument) Bool() (bool, bool) {
+	v, ok := a.Value.(bool)
+	return v, ok
+}
+
+// Type returns the type of the argument's value. It's the same as the type of the Argument it was resolved from
+func (a ResolvedArgument) Type() ArgumentType {
+	if _, ok := a.Value.(StringRef); ok {
+		return -1
+	}
+	return argumentTypeOf(a.Value)
+}
+
+// Str returns the value of a string argument
+func (a ResolvedArgument) Str() (string, bool) {
+	v, ok := a.Value.(string)
+	return v, ok
+}
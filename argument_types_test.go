@@ -0,0 +1,76 @@
+package fxt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgumentTypes(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEventWithArgs("category", "name", 1, 2, 100, map[string]interface{}{
+		"a-null":    nil,
+		"b-int32":   int32(-1),
+		"c-uint32":  uint32(2),
+		"d-int64":   int64(-3),
+		"e-uint64":  uint64(4),
+		"f-double":  5.5,
+		"g-string":  "value",
+		"h-pointer": uintptr(0x1000),
+		"i-koid":    fxt.KernelObjectID(6),
+		"j-bool":    true,
+	}))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+
+	arguments := event.Event.Arguments
+	types := make([]fxt.ArgumentType, len(arguments))
+	for i, argument := range arguments {
+		types[i] = argument.Type()
+		// The resolved arguments have the same types
+		require.Equal(t, types[i], event.Arguments[i].Type())
+	}
+	require.Equal(t, []fxt.ArgumentType{
+		fxt.ArgumentTypeNull, fxt.ArgumentTypeInt32, fxt.ArgumentTypeUint32, fxt.ArgumentTypeInt64, fxt.ArgumentTypeUint64,
+		fxt.ArgumentTypeDouble, fxt.ArgumentTypeString, fxt.ArgumentTypePointer, fxt.ArgumentTypeKOID, fxt.ArgumentTypeBool,
+	}, types)
+
+	require.True(t, arguments[0].IsNull())
+	int32Value, ok := arguments[1].Int32()
+	require.True(t, ok)
+	require.Equal(t, int32(-1), int32Value)
+	uint32Value, _ := arguments[2].Uint32()
+	require.Equal(t, uint32(2), uint32Value)
+	int64Value, _ := arguments[3].Int64()
+	require.Equal(t, int64(-3), int64Value)
+	uint64Value, _ := arguments[4].Uint64()
+	require.Equal(t, uint64(4), uint64Value)
+	doubleValue, _ := arguments[5].Double()
+	require.Equal(t, 5.5, doubleValue)
+	str, ok := arguments[6].Str()
+	require.True(t, ok)
+	require.Equal(t, "value", reader.ResolveString(str))
+	resolved, _ := event.Arguments[6].Str()
+	require.Equal(t, "value", resolved)
+	pointer, _ := arguments[7].Pointer()
+	require.Equal(t, uintptr(0x1000), pointer)
+	koid, _ := arguments[8].KOID()
+	require.Equal(t, fxt.KernelObjectID(6), koid)
+	boolValue, _ := arguments[9].Bool()
+	require.True(t, boolValue)
+
+	// The accessors for the other types don't match
+	_, ok = arguments[1].Uint64()
+	require.False(t, ok)
+	require.False(t, arguments[1].IsNull())
+	require.Equal(t, "koid", fxt.ArgumentTypeKOID.String())
+}
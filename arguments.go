@@ -0,0 +1,198 @@
+package fxt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// ArgumentMarshaler is implemented by types that control how they're written as argument values
+//
+// MarshalFXTArgument returns the value to write in place of the receiver. It can be any value accepted
+// as an argument, other than another ArgumentMarshaler
+type ArgumentMarshaler interface {
+	MarshalFXTArgument() (interface{}, error)
+}
+
+// normalizeArgumentValue converts an argument value to one of the types that map directly onto an FXT argument type:
+// nil, int32, uint32, int64, uint64, float64, string, uintptr, KernelObjectID, or bool
+//
+// Other Go types are converted as follows:
+//   - int8 and int16 become int32, and int becomes int64
+//   - uint8 and uint16 become uint32, and uint becomes uint64
+//   - float32 becomes float64
+//   - time.Duration becomes an int64 number of nanoseconds
+//   - time.Time becomes an RFC 3339 string, with nanosecond precision
+//   - []byte becomes a hex string
+//   - ArgumentMarshalers are replaced by the value they marshal to
+//   - fmt.Stringers become strings
+//   - Named types (e.g. enums) are converted based on their underlying type
+//   - nil pointers become nil
+//   - Structs, maps, slices, arrays, and pointers to them become compact JSON strings
+func normalizeArgumentValue(value interface{}) (interface{}, error) {
+	if marshaler, ok := value.(ArgumentMarshaler); ok {
+		if isNilPointer(value) {
+			return nil, nil
+		}
+
+		marshaled, err := marshaler.MarshalFXTArgument()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %T - %w", value, err)
+		}
+		if _, ok := marshaled.(ArgumentMarshaler); ok {
+			return nil, fmt.Errorf("%T marshaled to another ArgumentMarshaler", value)
+		}
+		value = marshaled
+	}
+
+	switch v := value.(type) {
+	case nil, int32, uint32, int64, uint64, float64, string, uintptr, KernelObjectID, bool:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int32(v), nil
+	case int16:
+		return int32(v), nil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint32(v), nil
+	case uint16:
+		return uint32(v), nil
+	case float32:
+		return float64(v), nil
+	case time.Duration:
+		return int64(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return hex.EncodeToString(v), nil
+	}
+
+	if isNilPointer(value) {
+		return nil, nil
+	}
+
+	if stringer, ok := value.(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+
+	// Named types
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return int32(reflected.Int()), nil
+	case reflect.Int, reflect.Int64:
+		return reflected.Int(), nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return uint32(reflected.Uint()), nil
+	case reflect.Uint, reflect.Uint64:
+		return reflected.Uint(), nil
+	case reflect.Uintptr:
+		return uintptr(reflected.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return reflected.Float(), nil
+	case reflect.String:
+		return reflected.String(), nil
+	case reflect.Bool:
+		return reflected.Bool(), nil
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer:
+		return marshalArgumentJSON(value)
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// isNilPointer reports whether value is a typed nil pointer, which can't have methods called on it safely
+func isNilPointer(value interface{}) bool {
+	reflected := reflect.ValueOf(value)
+	return reflected.Kind() == reflect.Pointer && reflected.IsNil()
+}
+
+// marshalArgumentJSON encodes a structured argument value as a compact JSON string
+func marshalArgumentJSON(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %T as JSON - %w", value, err)
+	}
+
+	return string(encoded), nil
+}
+
+// LongStringPolicy controls how string argument values longer than MaxStringLength bytes are written.
+// These don't fit in a string record, so they can't be referenced from the string table
+type LongStringPolicy int
+
+const (
+	// LongStringError fails the event with an error. This is the default
+	LongStringError LongStringPolicy = iota
+	// LongStringTruncate truncates the value to MaxStringLength bytes, ending with an ellipsis
+	LongStringTruncate
+	// LongStringBlob writes the value to a data blob record, and replaces the argument value with the
+	// name of the blob. The value must be at most MaxBlobSize bytes
+	LongStringBlob
+	// LongStringInline writes the value inline in the argument, instead of referencing the string table.
+	// The value must be at most MaxInlineStringLength bytes, and the whole event must still fit in a record
+	LongStringInline
+)
+
+// MaxInlineStringLength is the longest string, in bytes, that can be written inline in an argument
+// An argument's size field is 12 bits of 8 byte words, one of which is the argument header
+const MaxInlineStringLength = (0xFFF - 1) * 8
+
+// inlineStringRefFlag marks a string reference as an inline string. The lower 15 bits are the string length
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#string-references
+const inlineStringRefFlag = 0x8000
+
+// inlineString is a string argument value that's written inline, rather than added to the string table
+type inlineString string
+
+const ellipsis = "\u2026"
+
+// handleLongString applies the Writer's LongStringPolicy to a string argument value that doesn't fit
+// in a string record. It returns the value to write in its place
+func (w *Writer) handleLongString(key string, value string) (interface{}, error) {
+	switch w.longStringPolicy {
+	case LongStringTruncate:
+		return truncateString(value, MaxStringLength-len(ellipsis)) + ellipsis, nil
+	case LongStringBlob:
+		if len(value) > MaxBlobSize {
+			return nil, fmt.Errorf("string value is %d bytes, which exceeds the maximum blob size of %d", len(value), MaxBlobSize)
+		}
+
+		name := fmt.Sprintf("%s#%d", key, w.nextSpilledStringId)
+		w.nextSpilledStringId++
+		if err := w.addBlobRecord(name, []byte(value), BlobTypeData); err != nil {
+			return nil, err
+		}
+
+		return name, nil
+	case LongStringInline:
+		if len(value) > MaxInlineStringLength {
+			return nil, fmt.Errorf("string value is %d bytes, which exceeds the maximum inline string length of %d", len(value), MaxInlineStringLength)
+		}
+
+		return inlineString(value), nil
+	default:
+		return nil, fmt.Errorf("string value is %d bytes, which exceeds the maximum string length of %d", len(value), MaxStringLength)
+	}
+}
+
+// truncateString shortens `value` to at most `maxLength` bytes, without splitting a UTF-8 sequence
+func truncateString(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+
+	return value[:end]
+}
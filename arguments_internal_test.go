@@ -0,0 +1,159 @@
+package fxt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEnum uint8
+
+type testPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type testStringerEnum uint8
+
+func (e testStringerEnum) String() string {
+	return [...]string{"Idle", "Busy"}[e]
+}
+
+type testMarshaler struct {
+	id    int
+	inner interface{}
+	err   error
+}
+
+func (m *testMarshaler) MarshalFXTArgument() (interface{}, error) {
+	if m.inner != nil || m.err != nil {
+		return m.inner, m.err
+	}
+	return m.id, nil
+}
+
+func TestNormalizeArgumentValue(t *testing.T) {
+	timestamp := time.Date(2023, 4, 5, 6, 7, 8, 9, time.UTC)
+
+	testCases := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{int32(-1), int32(-1)},
+		{KernelObjectID(5), KernelObjectID(5)},
+		{uintptr(0x1234), uintptr(0x1234)},
+		{int(-7), int64(-7)},
+		{int8(-8), int32(-8)},
+		{int16(-16), int32(-16)},
+		{uint(7), uint64(7)},
+		{uint8(8), uint32(8)},
+		{uint16(16), uint32(16)},
+		{float32(0.5), float64(0.5)},
+		{3 * time.Millisecond, int64(3000000)},
+		{timestamp, "2023-04-05T06:07:08.000000009Z"},
+		{[]byte{0xde, 0xad, 0xbe, 0xef}, "deadbeef"},
+		{testEnum(3), uint32(3)},
+		{testStringerEnum(1), "Busy"},
+		{&testMarshaler{id: 42}, int64(42)},
+		{(*testMarshaler)(nil), nil},
+		{testPoint{X: 1, Y: 2}, `{"x":1,"y":2}`},
+		{&testPoint{X: 3}, `{"x":3,"y":0}`},
+		{map[string]int{"a": 1}, `{"a":1}`},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{[2]int{4, 5}, `[4,5]`},
+	}
+
+	for _, testCase := range testCases {
+		normalized, err := normalizeArgumentValue(testCase.value)
+		require.NoError(t, err)
+		require.Equal(t, testCase.expected, normalized, "%T", testCase.value)
+	}
+
+	_, err := normalizeArgumentValue(make(chan int))
+	require.Error(t, err)
+
+	_, err = normalizeArgumentValue(&testMarshaler{err: fmt.Errorf("boom")})
+	require.Error(t, err)
+
+	_, err = normalizeArgumentValue(&testMarshaler{inner: &testMarshaler{id: 1}})
+	require.Error(t, err)
+
+}
+
+func TestTruncateString(t *testing.T) {
+	require.Equal(t, "short", truncateString("short", 10))
+	require.Equal(t, "abc", truncateString("abcdef", 3))
+	// "é" is two bytes, so it can't be split
+	require.Equal(t, "a", truncateString("aé", 2))
+}
+
+func TestLongStringPolicy(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	longString := strings.Repeat("x", MaxStringLength+1)
+
+	testCases := []struct {
+		policy    LongStringPolicy
+		expectErr bool
+	}{
+		{LongStringError, true},
+		{LongStringTruncate, false},
+		{LongStringBlob, false},
+		{LongStringInline, false},
+	}
+
+	for _, testCase := range testCases {
+		filePath := filepath.Join(tempDir, fmt.Sprintf("test%d.fxt", testCase.policy))
+		writer, err := NewWriter(filePath, WithLongStringPolicy(testCase.policy))
+		require.NoError(t, err)
+
+		err = writer.AddInstantEventWithArgs("Category", "Event", 1, 2, 100, map[string]interface{}{
+			"long": longString,
+			// Structured values go through the same policy
+			"object": make([]int, MaxStringLength),
+		})
+		if testCase.expectErr {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, writer.Close())
+
+		data, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+
+		switch testCase.policy {
+		case LongStringTruncate:
+			require.Contains(t, string(data), longString[:MaxStringLength-len(ellipsis)]+ellipsis)
+			require.NotContains(t, string(data), longString)
+		case LongStringBlob, LongStringInline:
+			require.True(t, bytes.Contains(data, []byte(longString)))
+		}
+	}
+
+	// Inline strings still have to fit in the record
+	writer, err := NewWriter(filepath.Join(tempDir, "inline.fxt"), WithLongStringPolicy(LongStringInline))
+	require.NoError(t, err)
+
+	err = writer.AddInstantEventWithArgs("Category", "Event", 1, 2, 100, map[string]interface{}{
+		"a": strings.Repeat("a", MaxInlineStringLength),
+		"b": strings.Repeat("b", MaxInlineStringLength),
+	})
+	require.Error(t, err)
+
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,144 @@
+package fxt
+
+import (
+	"fmt"
+	"sync"
+)
+
+// AsyncGroup writes a named group of async operations of the same kind, like the frames of a renderer or the
+// requests handled by a server
+//
+// Each operation is named after the group and a label, like "Frame 12" or "Request 3f2a", and gets a
+// correlation ID derived from its category and name with CorrelationIdFromString. All its events share the
+// group's category, and the end event repeats the begin event's name, so viewers group them onto one async
+// track per operation. Deriving the ID means other processes that know the label, like the other side of a
+// request, can add events to the same operation
+//
+// The labels of the operations that are open at the same time must be unique. All methods are safe to call
+// from multiple goroutines
+type AsyncGroup struct {
+	writer    TraceWriter
+	category  string
+	name      string
+	processId KernelObjectID
+	threadId  KernelObjectID
+
+	mu sync.Mutex
+	// open is the operations that haven't ended, in the order they began
+	open []*AsyncOperation
+}
+
+// AsyncOperation is one async operation of an AsyncGroup, between its begin and end events
+type AsyncOperation struct {
+	group         *AsyncGroup
+	name          string
+	correlationId uint64
+	ended         bool
+}
+
+// NewAsyncGroup creates an AsyncGroup whose events are written to `w` in `category`, on thread `threadId`
+// of process `processId`
+func NewAsyncGroup(w TraceWriter, category string, name string, processId KernelObjectID, threadId KernelObjectID) *AsyncGroup {
+	return &AsyncGroup{
+		writer:    w,
+		category:  category,
+		name:      name,
+		processId: processId,
+		threadId:  threadId,
+	}
+}
+
+// Begin writes the async begin event of a new operation, named after the group and `label`
+func (g *AsyncGroup) Begin(label string, timestamp uint64, arguments map[string]interface{}) (*AsyncOperation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	name := g.name + " " + label
+	operation := &AsyncOperation{
+		group:         g,
+		name:          name,
+		correlationId: CorrelationIdFromString(g.category + "/" + name),
+	}
+	for _, open := range g.open {
+		if open.name == name {
+			return nil, fmt.Errorf("async operation %s/%s is already open", g.category, name)
+		}
+	}
+
+	if err := g.writer.AddAsyncBeginEventWithArgs(g.category, name, g.processId, g.threadId, timestamp, operation.correlationId, arguments); err != nil {
+		return nil, err
+	}
+	g.open = append(g.open, operation)
+	return operation, nil
+}
+
+// Open returns the number of operations of the group that haven't ended
+func (g *AsyncGroup) Open() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.open)
+}
+
+// Close writes end events at `timestamp` for the operations that are still open, in the order they began
+func (g *AsyncGroup) Close(timestamp uint64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for len(g.open) > 0 {
+		if err := g.end(g.open[0], timestamp, nil); err != nil {
+			return fmt.Errorf("failed to end %s/%s - %w", g.category, g.open[0].name, err)
+		}
+	}
+	return nil
+}
+
+// end writes the end event of an open operation, and removes it from the open ones. The group's lock must be held
+func (g *AsyncGroup) end(operation *AsyncOperation, timestamp uint64, arguments map[string]interface{}) error {
+	if err := g.writer.AddAsyncEndEventWithArgs(g.category, operation.name, g.processId, g.threadId, timestamp, operation.correlationId, arguments); err != nil {
+		return err
+	}
+
+	operation.ended = true
+	for i, open := range g.open {
+		if open == operation {
+			g.open = append(g.open[:i], g.open[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the operation, which is the group's name followed by its label
+func (o *AsyncOperation) Name() string {
+	return o.name
+}
+
+// CorrelationId returns the correlation ID the operation's events are written with
+func (o *AsyncOperation) CorrelationId() uint64 {
+	return o.correlationId
+}
+
+// Instant writes an async instant event named `name` within the operation
+func (o *AsyncOperation) Instant(name string, timestamp uint64, arguments map[string]interface{}) error {
+	g := o.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if o.ended {
+		return fmt.Errorf("async operation %s/%s has already ended", g.category, o.name)
+	}
+	return g.writer.AddAsyncInstantEventWithArgs(g.category, name, g.processId, g.threadId, timestamp, o.correlationId, arguments)
+}
+
+// End writes the async end event of the operation
+func (o *AsyncOperation) End(timestamp uint64, arguments map[string]interface{}) error {
+	g := o.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if o.ended {
+		return fmt.Errorf("async operation %s/%s has already ended", g.category, o.name)
+	}
+	return g.end(o, timestamp, arguments)
+}
@@ -0,0 +1,76 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncGroup(t *testing.T) {
+	var buffer bytes.Buffer
+	var warnings []error
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithAsyncCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+
+	frames := fxt.NewAsyncGroup(writer, "render", "Frame", 1, 2)
+	first, err := frames.Begin("1", 10, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Frame 1", first.Name())
+	require.Equal(t, fxt.CorrelationIdFromString("render/Frame 1"), first.CorrelationId())
+	_, err = frames.Begin("1", 11, nil)
+	require.Error(t, err)
+
+	second, err := frames.Begin("2", 12, map[string]interface{}{"vsync": true})
+	require.NoError(t, err)
+	require.NotEqual(t, first.CorrelationId(), second.CorrelationId())
+	require.Equal(t, 2, frames.Open())
+
+	require.NoError(t, first.Instant("Present", 13, nil))
+	require.NoError(t, first.End(14, nil))
+	require.Error(t, first.End(15, nil))
+	require.Error(t, first.Instant("Late", 15, nil))
+
+	// The label can be reused once the operation has ended
+	again, err := frames.Begin("1", 16, nil)
+	require.NoError(t, err)
+	require.Equal(t, first.CorrelationId(), again.CorrelationId())
+
+	require.NoError(t, frames.Close(20))
+	require.Equal(t, 0, frames.Open())
+	require.NoError(t, writer.Close())
+	require.Empty(t, warnings)
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var events []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "render", event.Category)
+
+		switch event.Decoded.(type) {
+		case *fxt.AsyncBeginEvent:
+			events = append(events, fmt.Sprintf("+%s@%d", event.Name, event.Timestamp))
+		case *fxt.AsyncInstantEvent:
+			events = append(events, fmt.Sprintf("%s@%d", event.Name, event.Timestamp))
+		case *fxt.AsyncEndEvent:
+			events = append(events, fmt.Sprintf("-%s@%d", event.Name, event.Timestamp))
+		}
+	}
+
+	require.Equal(t, []string{
+		"+Frame 1@10", "+Frame 2@12", "Present@13", "-Frame 1@14",
+		"+Frame 1@16", "-Frame 2@20", "-Frame 1@20",
+	}, events)
+}
@@ -0,0 +1,130 @@
+package fxt
+
+import (
+	"fmt"
+)
+
+// IsValid reports whether the blob type fits in a blob record
+// Zero is not a valid blob type
+func (t BlobType) IsValid() bool {
+	return t > 0 && t <= MaxBlobType
+}
+
+// String returns the name of the blob types defined by the spec or this package, or the numeric value for
+// custom types
+func (t BlobType) String() string {
+	switch t {
+	case BlobTypeData:
+		return "data"
+	case BlobTypeLastBranch:
+		return "last branch"
+	case BlobTypePerfetto:
+		return "perfetto"
+	case BlobTypeStack:
+		return "stack"
+	case BlobTypeSymbols:
+		return "symbols"
+	case BlobTypeChecksum:
+		return "checksum"
+	case BlobTypeContinuation:
+		return "continuation"
+	default:
+		return fmt.Sprintf("custom(%d)", int(t))
+	}
+}
+
+// AddLargeBlobRecord adds a large blob record without metadata to the file
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#in-band-large-blob-record-no-metadata-blob-format-1
+//
+// Unlike AddBlobRecord, the payload size is effectively unlimited. The name should identify the type of data in the blob
+func (w *Writer) AddLargeBlobRecord(category string, name string, data []byte) error {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	categoryRef, err := w.getOrCreateStringRef(category)
+	if err != nil {
+		return err
+	}
+
+	nameRef, err := w.getOrCreateStringRef(name)
+	if err != nil {
+		return err
+	}
+
+	return w.writeRecord(LargeBlobRecord{
+		Category: categoryRef,
+		Name:     nameRef,
+		Data:     data,
+	}.appendRecord(w.scratch[:0]))
+}
+
+// AddLargeBlobEventRecord adds a large blob record with metadata to the file. This attaches the blob to
+// a specific thread and point in time, for example a screenshot or a core snippet captured by an event
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#in-band-large-blob-record-with-metadata-blob-format-0
+//
+// If strings and/or process/thread IDs aren't already in the string / thread tables respectively,
+// string and thread records will be automatically created
+func (w *Writer) AddLargeBlobEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte) error {
+	return w.AddLargeBlobEventRecordWithArgs(category, name, processId, threadId, timestamp, data, map[string]interface{}{})
+}
+
+// AddLargeBlobEventRecordWithArgs is the same as AddLargeBlobEventRecord, but it allows you to additionally include
+// arguments within the record
+func (w *Writer) AddLargeBlobEventRecordWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte, arguments map[string]interface{}) error {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err = w.prepareTimestamp(processId, threadId, timestamp)
+	if err != nil {
+		return err
+	}
+
+	categoryRef, err := w.getOrCreateStringRef(category)
+	if err != nil {
+		return err
+	}
+
+	nameRef, err := w.getOrCreateStringRef(name)
+	if err != nil {
+		return err
+	}
+
+	threadRef, err := w.getOrCreateThreadRef(processId, threadId)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the argument keys (and string values) are in the string table
+	args, err := w.prepareArguments(arguments)
+	if err != nil {
+		return err
+	}
+
+	return w.writeRecord(LargeBlobEventRecord{
+		EventRecord: EventRecord{
+			Category:  categoryRef,
+			Name:      nameRef,
+			Thread:    threadRef,
+			Timestamp: timestamp,
+			Arguments: args,
+		},
+		Data: data,
+	}.appendRecord(w.scratch[:0]))
+}
@@ -0,0 +1,159 @@
+package fxt_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobTypes(t *testing.T) {
+	require.True(t, fxt.BlobTypeData.IsValid())
+	require.True(t, fxt.BlobTypePerfetto.IsValid())
+	require.True(t, fxt.BlobType(0x80).IsValid())
+	require.True(t, fxt.MaxBlobType.IsValid())
+	require.False(t, fxt.BlobType(0).IsValid())
+	require.False(t, fxt.BlobType(0x100).IsValid())
+
+	require.Equal(t, "perfetto", fxt.BlobTypePerfetto.String())
+	require.Equal(t, "stack", fxt.BlobTypeStack.String())
+	require.Equal(t, "custom(128)", fxt.BlobType(0x80).String())
+}
+
+func TestAddBlobRecordValidation(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	// Custom blob types are allowed
+	err = writer.AddBlobRecord("Custom", []byte("payload"), fxt.BlobType(0x80))
+	require.NoError(t, err)
+
+	err = writer.AddBlobRecord("Invalid", []byte("payload"), fxt.BlobType(0))
+	require.Error(t, err)
+
+	err = writer.AddBlobRecord("Invalid", []byte("payload"), fxt.BlobType(0x100))
+	require.Error(t, err)
+
+	// The largest blob fits, but one byte more doesn't
+	err = writer.AddBlobRecord("Largest", make([]byte, fxt.MaxBlobSize), fxt.BlobTypeData)
+	require.NoError(t, err)
+
+	err = writer.AddBlobRecord("TooLarge", make([]byte, fxt.MaxBlobSize+1), fxt.BlobTypeData)
+	require.Error(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestAddLargeBlobRecords(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	// Larger than a regular blob record can hold
+	payload := make([]byte, fxt.MaxBlobSize*2+3)
+
+	err = writer.AddLargeBlobRecord("Artifacts", "core.snippet", payload)
+	require.NoError(t, err)
+
+	err = writer.AddLargeBlobEventRecord("Artifacts", "screenshot.png", 3, 45, 1000, payload)
+	require.NoError(t, err)
+
+	err = writer.AddLargeBlobEventRecordWithArgs("Artifacts", "screenshot.png", 3, 45, 2000, []byte("tiny"), map[string]interface{}{"width": uint32(640), "format": "png"})
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+	require.Greater(t, info.Size(), int64(2*len(payload)))
+}
+
+func TestReaderBlobs(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	// Two records of the same name and type are one blob split in two
+	first := bytes.Repeat([]byte{1}, 100)
+	second := bytes.Repeat([]byte{2}, 50)
+	require.NoError(t, writer.AddBlobRecord("data", first, fxt.BlobTypeData))
+	require.NoError(t, writer.AddInstantEvent("category", "event", 1, 2, 100))
+	require.NoError(t, writer.AddBlobRecord("data", second, fxt.BlobTypeData))
+	require.NoError(t, writer.AddBlobRecord("data", []byte("other"), fxt.BlobTypePerfetto))
+	require.NoError(t, writer.AddLargeBlobRecord("Artifacts", "core.snippet", []byte("core")))
+	require.NoError(t, writer.AddLargeBlobEventRecordWithArgs("Artifacts", "screenshot.png", 3, 45, 1000, []byte("png"), map[string]interface{}{"width": uint32(640)}))
+	require.NoError(t, writer.AddLargeBlobEventRecord("Artifacts", "screenshot.png", 3, 45, 2000, []byte("png")))
+	require.NoError(t, writer.Close())
+	trace := buffer.Bytes()
+
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+	blobs, err := reader.Blobs()
+	require.NoError(t, err)
+	require.Len(t, blobs, 5)
+
+	require.Equal(t, "data", blobs[0].Name)
+	require.Equal(t, fxt.BlobTypeData, blobs[0].BlobType)
+	require.Equal(t, 2, blobs[0].Records)
+	require.Equal(t, append(append([]byte(nil), first...), second...), blobs[0].Data)
+	require.Equal(t, fxt.BlobTypePerfetto, blobs[1].BlobType)
+	require.Equal(t, []byte("other"), blobs[1].Data)
+
+	require.Equal(t, fxt.RecordTypeLargeBlob, blobs[2].Type)
+	require.Equal(t, "Artifacts", blobs[2].Category)
+	require.Equal(t, "core.snippet", blobs[2].Name)
+	require.Nil(t, blobs[2].Event)
+
+	// Large blobs with metadata aren't reassembled, and keep their event
+	require.Equal(t, []byte("png"), blobs[3].Data)
+	require.Equal(t, fxt.Thread{ProcessId: 3, ThreadId: 45}, blobs[3].Event.Thread)
+	require.Equal(t, uint64(1000), blobs[3].Event.Timestamp)
+	require.Equal(t, []fxt.ResolvedArgument{{Key: "width", Value: uint32(640)}}, blobs[3].Event.Arguments)
+	require.Equal(t, uint64(2000), blobs[4].Event.Timestamp)
+
+	// Reassembling the chunks doesn't write into the trace
+	reader, err = fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+	again, err := reader.Blobs()
+	require.NoError(t, err)
+	require.Equal(t, blobs[0].Data, again[0].Data)
+}
@@ -0,0 +1,92 @@
+package fxt
+
+import (
+	"errors"
+	"io"
+)
+
+// Blob is the payload of a blob or large blob record, with its name and category looked up in the string table
+type Blob struct {
+	// Offset is the position in the trace of the first record of the blob, in bytes
+	Offset int64
+	// Records is the number of records the blob was reassembled from
+	Records int
+	// Type is RecordTypeBlob or RecordTypeLargeBlob
+	Type RecordType
+	// BlobType is the type of the payload of blob records. It's zero for large blobs
+	BlobType BlobType
+	// Category is the category of large blobs. Blob records don't have one
+	Category string
+	Name     string
+	// Event is the event a large blob with metadata is attached to, with its thread, timestamp, and arguments
+	Event *ResolvedEvent
+	Data  []byte
+}
+
+// ResolveBlob returns the blob in a blob or large blob record, and nil for other records
+//
+// The payload isn't copied, so the Data of the Blob is a sub-slice of the record's Data. The string table
+// changes as the trace is read, so this must be called before the next call to Next
+func (r *Reader) ResolveBlob(record *Record) (*Blob, error) {
+	if record.Type != RecordTypeBlob && record.Type != RecordTypeLargeBlob {
+		return nil, nil
+	}
+
+	fields, payload, err := record.blob()
+	if err != nil {
+		return nil, err
+	}
+	blob := &Blob{
+		Offset:   record.Offset,
+		Records:  1,
+		Type:     record.Type,
+		BlobType: fields.blobType,
+		Category: r.ResolveString(fields.category),
+		Name:     r.ResolveString(fields.name),
+		Data:     payload,
+	}
+	if fields.event != nil {
+		blob.Event = resolveEvent(r, &LargeBlobEventRecord{EventRecord: *fields.event, Data: payload})
+	}
+	return blob, nil
+}
+
+// Blobs reads the rest of the trace, and returns the blobs in it in order
+//
+// Payloads too big for one record are split across several, like the Perfetto traces written by
+// AddPerfettoBlob, so consecutive blobs of the same type, category, and name are reassembled into one.
+// Large blobs with metadata are always separate, since each is attached to its own event
+func (r *Reader) Blobs() ([]*Blob, error) {
+	var blobs []*Blob
+	for {
+		record, err := r.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return blobs, nil
+			}
+			return nil, err
+		}
+
+		blob, err := r.ResolveBlob(record)
+		if err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+		if blob == nil {
+			continue
+		}
+
+		if n := len(blobs); n > 0 && blobs[n-1].continuedBy(blob) {
+			last := blobs[n-1]
+			// The capacity is capped, so the first append copies the payload rather than writing into the trace
+			last.Data = append(last.Data[:len(last.Data):len(last.Data)], blob.Data...)
+			last.Records++
+			continue
+		}
+		blobs = append(blobs, blob)
+	}
+}
+
+// continuedBy reports whether `next` holds the next chunk of the payload of `b`
+func (b *Blob) continuedBy(next *Blob) bool {
+	return b.Event == nil && next.Event == nil && b.Type == next.Type && b.BlobType == next.BlobType && b.Category == next.Category && b.Name == next.Name
+}
@@ -0,0 +1,57 @@
+package fxt
+
+import (
+	"errors"
+	"runtime/debug"
+)
+
+// BuildInfoBlobName is the name of the large blob record RecordBuildInfo writes the full build info to
+const BuildInfoBlobName = "go.buildinfo"
+
+// buildSettings are the build settings RecordBuildInfo writes as arguments, when they're set
+var buildSettings = []string{"vcs", "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH", "CGO_ENABLED", "-tags"}
+
+// RecordBuildInfo writes how the running binary was built, from debug.ReadBuildInfo, so a trace can always be
+// matched back to the binary that produced it. It's meant to be called once, at the start of the trace
+//
+// It writes a "Build Info" instant event in the "Metadata" category, with the arguments:
+//   - "path" and "version", the main module's path and version
+//   - "go_version", the Go release the binary was built with
+//   - the build settings "vcs", "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH", "CGO_ENABLED",
+//     and "-tags", when they're set
+//
+// followed by a large blob record named BuildInfoBlobName, in the same category, with the full build info,
+// including the dependencies, in the format of debug.BuildInfo.String and `go version -m`
+func (w *Writer) RecordBuildInfo(processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return errors.New("build info is not available in binaries built without module support")
+	}
+
+	if err := w.AddInstantEventWithArgs("Metadata", "Build Info", processId, threadId, timestamp, buildInfoArguments(info)); err != nil {
+		return err
+	}
+	return w.AddLargeBlobRecord("Metadata", BuildInfoBlobName, []byte(info.String()))
+}
+
+// buildInfoArguments returns the arguments of the event written by RecordBuildInfo
+func buildInfoArguments(info *debug.BuildInfo) map[string]interface{} {
+	arguments := map[string]interface{}{
+		"go_version": info.GoVersion,
+	}
+	if info.Main.Path != "" {
+		arguments["path"] = info.Main.Path
+	}
+	if info.Main.Version != "" {
+		arguments["version"] = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		for _, key := range buildSettings {
+			if setting.Key == key && setting.Value != "" {
+				arguments[key] = setting.Value
+			}
+		}
+	}
+
+	return arguments
+}
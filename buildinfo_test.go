@@ -0,0 +1,57 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime/debug"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordBuildInfo(t *testing.T) {
+	info, ok := debug.ReadBuildInfo()
+	require.True(t, ok)
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.RecordBuildInfo(1, 2, 100))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var event *fxt.ResolvedEvent
+	var blob []byte
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if large, ok := decoded.(*fxt.LargeBlobRecord); ok {
+			require.Equal(t, fxt.BuildInfoBlobName, reader.ResolveString(large.Name))
+			blob = large.Data
+		}
+		if resolved, err := reader.Resolve(record); err == nil && resolved != nil {
+			event = resolved
+		}
+	}
+
+	require.NotNil(t, event)
+	require.Equal(t, "Metadata", event.Category)
+	require.Equal(t, "Build Info", event.Name)
+	arguments := map[string]interface{}{}
+	for _, argument := range event.Arguments {
+		arguments[argument.Key] = argument.Value
+	}
+	require.Equal(t, info.GoVersion, arguments["go_version"])
+
+	require.Equal(t, info.String(), string(blob))
+}
@@ -0,0 +1,399 @@
+// Package bundle reads and writes trace bundles: zip files holding an FXT trace, along with a JSON manifest and
+// the files that go with the trace, like symbol files, logs, or profiles. A capture can then be handed around as a
+// single self-contained file
+//
+// The trace is stored as TraceFileName, and the manifest as ManifestFileName. The manifest lists every other file
+// in the bundle, with its kind, size, and SHA-256, and holds the free-form metadata of the capture, like the
+// version of the service it was taken from
+package bundle
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+const (
+	// TraceFileName is the name of the trace in a bundle
+	TraceFileName = "trace.fxt"
+	// ManifestFileName is the name of the manifest in a bundle
+	ManifestFileName = "manifest.json"
+	// ManifestVersion is the version of the manifest format written by Writer
+	ManifestVersion = 1
+)
+
+// The kinds of the files in a bundle
+const (
+	KindTrace    = "trace"
+	KindSymbols  = "symbols"
+	KindArtifact = "artifact"
+)
+
+// Manifest describes the contents of a bundle
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// FormatVersion is the FXT format version of the trace, like "v1"
+	FormatVersion string            `json:"format_version"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	// Files are the files in the bundle, starting with the trace
+	Files []File `json:"files"`
+}
+
+// File is an entry of the manifest, describing a file in the bundle
+type File struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Writer writes a trace bundle. It's an fxt.Writer writing the bundle's trace, so events are added to it as usual,
+// and the other files of the bundle are added with AddSymbols and AddArtifact
+//
+// The trace is written to the zip file as it's traced, and the other files are kept in memory, to be written after
+// it by Close. Reset and ResetTo aren't supported, since a bundle holds a single trace
+type Writer struct {
+	*fxt.Writer
+
+	dst io.Writer
+	// closer is the file opened by Create, if any
+	closer io.Closer
+	zip    *zip.Writer
+	trace  *hashingWriter
+
+	created  time.Time
+	metadata map[string]string
+	files    []attachment
+	closed   bool
+}
+
+type attachment struct {
+	name string
+	kind string
+	data []byte
+}
+
+// Create creates a bundle file at `filePath`. The trace is configured with `options`, like fxt.NewWriter
+func Create(filePath string, options ...fxt.WriterOption) (*Writer, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dest file %s - %w", filePath, err)
+	}
+
+	writer, err := NewWriter(file, options...)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer.closer = file
+
+	return writer, nil
+}
+
+// NewWriter creates a Writer, which writes a bundle to `dst`. Close doesn't close `dst`
+func NewWriter(dst io.Writer, options ...fxt.WriterOption) (*Writer, error) {
+	archive := zip.NewWriter(dst)
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     TraceFileName,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s - %w", TraceFileName, err)
+	}
+
+	trace := &hashingWriter{out: entry, hash: sha256.New()}
+	writer, err := fxt.NewWriterTo(trace, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		Writer:   writer,
+		dst:      dst,
+		zip:      archive,
+		trace:    trace,
+		created:  time.Now(),
+		metadata: map[string]string{},
+	}, nil
+}
+
+// SetMetadata sets a metadata entry of the manifest
+func (w *Writer) SetMetadata(key string, value string) {
+	w.metadata[key] = value
+}
+
+// AddSymbols adds a symbol file, like a Breakpad .sym file or the output of `go tool nm`, to the bundle as
+// "symbols/<name>"
+func (w *Writer) AddSymbols(name string, data []byte) error {
+	return w.attach(path.Join("symbols", name), KindSymbols, data)
+}
+
+// AddArtifact adds a file captured with the trace, like a log or a profile, to the bundle as "artifacts/<name>"
+func (w *Writer) AddArtifact(name string, data []byte) error {
+	return w.attach(path.Join("artifacts", name), KindArtifact, data)
+}
+
+func (w *Writer) attach(name string, kind string, data []byte) error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	for _, file := range w.files {
+		if file.name == name {
+			return fmt.Errorf("the bundle already has a file named %s", name)
+		}
+	}
+	w.files = append(w.files, attachment{name: name, kind: kind, data: data})
+	return nil
+}
+
+// Close closes the trace, writes the files added to the bundle and the manifest after it, and finishes the zip
+// file. Bundles created with Create close their file
+func (w *Writer) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	err := w.finish()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (w *Writer) finish() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+
+	manifest := Manifest{
+		Version:       ManifestVersion,
+		Created:       w.created,
+		FormatVersion: w.FormatVersion().String(),
+		Files: []File{{
+			Name:   TraceFileName,
+			Kind:   KindTrace,
+			Size:   w.trace.size,
+			SHA256: hex.EncodeToString(w.trace.hash.Sum(nil)),
+		}},
+	}
+	if len(w.metadata) != 0 {
+		manifest.Metadata = w.metadata
+	}
+
+	for _, file := range w.files {
+		if err := w.writeFile(file.name, file.data); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(file.data)
+		manifest.Files = append(manifest.Files, File{
+			Name:   file.name,
+			Kind:   file.kind,
+			Size:   int64(len(file.data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the manifest - %w", err)
+	}
+	if err := w.writeFile(ManifestFileName, data); err != nil {
+		return err
+	}
+
+	if err := w.zip.Close(); err != nil {
+		return fmt.Errorf("failed to finish the bundle - %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) writeFile(name string, data []byte) error {
+	entry, err := w.zip.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s - %w", name, err)
+	}
+	if _, err := entry.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s - %w", name, err)
+	}
+	return nil
+}
+
+// hashingWriter hashes and counts the bytes of the trace, for its manifest entry
+type hashingWriter struct {
+	out  io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func (h *hashingWriter) Write(data []byte) (int, error) {
+	n, err := h.out.Write(data)
+	h.hash.Write(data[:n])
+	h.size += int64(n)
+	return n, err
+}
+
+// Reader reads a trace bundle
+type Reader struct {
+	zip      *zip.Reader
+	manifest Manifest
+	// closer is the file opened by Open, if any
+	closer io.Closer
+}
+
+// Open opens the bundle file at `filePath`
+func Open(filePath string) (*Reader, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle %s - %w", filePath, err)
+	}
+
+	reader, err := newReader(&archive.Reader)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	reader.closer = archive
+
+	return reader, nil
+}
+
+// NewReader creates a Reader for the bundle of `size` bytes in `r`
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle - %w", err)
+	}
+	return newReader(archive)
+}
+
+func newReader(archive *zip.Reader) (*Reader, error) {
+	reader := &Reader{zip: archive}
+
+	data, err := reader.readEntry(ManifestFileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reader.manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest - %w", err)
+	}
+	if reader.manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d - the latest supported is %d", reader.manifest.Version, ManifestVersion)
+	}
+
+	return reader, nil
+}
+
+// Manifest returns the bundle's manifest
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Trace returns an fxt.Reader for the bundle's trace. The trace is decompressed as it's read, and the returned
+// closer must be closed once it's done with
+func (r *Reader) Trace() (*fxt.Reader, io.Closer, error) {
+	entry, err := r.zip.Open(TraceFileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s - %w", TraceFileName, err)
+	}
+
+	reader, err := fxt.NewReader(entry)
+	if err != nil {
+		entry.Close()
+		return nil, nil, err
+	}
+	return reader, entry, nil
+}
+
+// ReadFile returns the contents of the file named `name`, like "symbols/server.sym", and checks them against the
+// size and SHA-256 in the manifest
+func (r *Reader) ReadFile(name string) ([]byte, error) {
+	file, ok := r.file(name)
+	if !ok {
+		return nil, fmt.Errorf("the manifest doesn't list a file named %s", name)
+	}
+
+	data, err := r.readEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != file.Size || hex.EncodeToString(sum[:]) != file.SHA256 {
+		return nil, fmt.Errorf("%s doesn't match the manifest - it was changed or corrupted", name)
+	}
+	return data, nil
+}
+
+// Verify checks every file listed in the manifest, including the trace, against its size and SHA-256
+func (r *Reader) Verify() error {
+	for _, file := range r.manifest.Files {
+		entry, err := r.zip.Open(file.Name)
+		if err != nil {
+			return fmt.Errorf("failed to open %s - %w", file.Name, err)
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, entry)
+		entry.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s - %w", file.Name, err)
+		}
+		if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return fmt.Errorf("%s doesn't match the manifest - it was changed or corrupted", file.Name)
+		}
+	}
+	return nil
+}
+
+// Close closes the bundle file opened by Open
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	closer := r.closer
+	r.closer = nil
+	return closer.Close()
+}
+
+func (r *Reader) file(name string) (File, bool) {
+	for _, file := range r.manifest.Files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+	return File{}, false
+}
+
+func (r *Reader) readEntry(name string) ([]byte, error) {
+	entry, err := r.zip.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("the bundle has no %s - %w", name, err)
+		}
+		return nil, fmt.Errorf("failed to open %s - %w", name, err)
+	}
+	defer entry.Close()
+
+	data, err := io.ReadAll(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s - %w", name, err)
+	}
+	return data, nil
+}
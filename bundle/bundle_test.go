@@ -0,0 +1,98 @@
+package bundle_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/bundle"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.zip")
+	writer, err := bundle.Create(path)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, uint64(i)))
+	}
+	writer.SetMetadata("service", "frontend")
+	require.NoError(t, writer.AddSymbols("frontend.sym", []byte("MODULE Linux x86_64 0 frontend\n")))
+	require.NoError(t, writer.AddArtifact("server.log", []byte("started\n")))
+	require.Error(t, writer.AddArtifact("server.log", nil))
+	require.NoError(t, writer.Close())
+
+	reader, err := bundle.Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NoError(t, reader.Verify())
+
+	manifest := reader.Manifest()
+	require.Equal(t, bundle.ManifestVersion, manifest.Version)
+	require.Equal(t, fxt.LatestFormatVersion.String(), manifest.FormatVersion)
+	require.Equal(t, map[string]string{"service": "frontend"}, manifest.Metadata)
+	require.Len(t, manifest.Files, 3)
+	require.Equal(t, bundle.TraceFileName, manifest.Files[0].Name)
+	require.Equal(t, bundle.KindTrace, manifest.Files[0].Kind)
+	require.Equal(t, "symbols/frontend.sym", manifest.Files[1].Name)
+	require.Equal(t, bundle.KindSymbols, manifest.Files[1].Kind)
+	require.Equal(t, "artifacts/server.log", manifest.Files[2].Name)
+
+	data, err := reader.ReadFile("artifacts/server.log")
+	require.NoError(t, err)
+	require.Equal(t, "started\n", string(data))
+
+	trace, closer, err := reader.Trace()
+	require.NoError(t, err)
+	defer closer.Close()
+	events := 0
+	for {
+		_, err := trace.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		events++
+	}
+	require.Equal(t, 100, events)
+}
+
+func TestBundleCorrupted(t *testing.T) {
+	var original bytes.Buffer
+	writer, err := bundle.NewWriter(&original)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+	require.NoError(t, writer.AddArtifact("notes.txt", []byte("original")))
+	require.NoError(t, writer.Close())
+
+	// Rewrite the bundle with a different artifact, but the original manifest
+	reader, err := zip.NewReader(bytes.NewReader(original.Bytes()), int64(original.Len()))
+	require.NoError(t, err)
+	var rewritten bytes.Buffer
+	archive := zip.NewWriter(&rewritten)
+	for _, file := range reader.File {
+		entry, err := archive.Create(file.Name)
+		require.NoError(t, err)
+		if file.Name == "artifacts/notes.txt" {
+			_, err = entry.Write([]byte("modified"))
+			require.NoError(t, err)
+			continue
+		}
+		contents, err := file.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(entry, contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+
+	bundleReader, err := bundle.NewReader(bytes.NewReader(rewritten.Bytes()), int64(rewritten.Len()))
+	require.NoError(t, err)
+	require.Error(t, bundleReader.Verify())
+	_, err = bundleReader.ReadFile("artifacts/notes.txt")
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package fxt
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// SourceArgumentKey is the argument the location of the code that wrote an event is attached as, like
+// "/src/server/handler.go:42"
+const SourceArgumentKey = "source"
+
+// fxtPackagePrefix prefixes the names of the functions in this package, which are skipped when looking for the
+// code that wrote an event
+const fxtPackagePrefix = "github.com/richiesams/fxt."
+
+// WithCallerLocations makes the Writer attach the file and line of the code that wrote each instant, duration
+// begin, and duration complete event, as the SourceArgumentKey argument. This makes it trivial to jump from a
+// slow slice in the viewer to the code that emitted it
+//
+// The location is the first caller outside of this package, so events written through EventBuilder, or the
+// package-level functions like BeginDuration, point at their callers too. Looking it up walks the stack and
+// copies the event's arguments, which adds noticeable overhead to every event. To only attach it to some events,
+// use the WithCaller methods instead, like AddInstantEventWithCaller
+func WithCallerLocations() WriterOption {
+	return func(w *Writer) {
+		w.callerLocations = true
+	}
+}
+
+// AddInstantEventWithCaller is the same as AddInstantEventWithArgs, but it attaches the file and line of its
+// caller as the SourceArgumentKey argument. `arguments` can be nil
+func (w *Writer) AddInstantEventWithCaller(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	return w.AddInstantEventWithArgs(category, name, processId, threadId, timestamp, withCallerLocation(arguments))
+}
+
+// AddDurationBeginEventWithCaller is the same as AddDurationBeginEventWithArgs, but it attaches the file and line
+// of its caller as the SourceArgumentKey argument. `arguments` can be nil
+func (w *Writer) AddDurationBeginEventWithCaller(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	return w.AddDurationBeginEventWithArgs(category, name, processId, threadId, timestamp, withCallerLocation(arguments))
+}
+
+// AddDurationCompleteEventWithCaller is the same as AddDurationCompleteEventWithArgs, but it attaches the file
+// and line of its caller as the SourceArgumentKey argument. `arguments` can be nil
+func (w *Writer) AddDurationCompleteEventWithCaller(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
+	return w.AddDurationCompleteEventWithArgs(category, name, processId, threadId, beginTimestamp, endTimestamp, withCallerLocation(arguments))
+}
+
+// withCallerLocation returns a copy of `arguments` with the location of the first caller outside of this
+// package added. The caller's map is left untouched
+func withCallerLocation(arguments map[string]interface{}) map[string]interface{} {
+	location, ok := callerLocation()
+	if !ok {
+		return arguments
+	}
+
+	withLocation := make(map[string]interface{}, len(arguments)+1)
+	for key, value := range arguments {
+		withLocation[key] = value
+	}
+	withLocation[SourceArgumentKey] = location
+	return withLocation
+}
+
+// callerLocation returns the "file:line" of the first caller outside of this package
+func callerLocation() (string, bool) {
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, fxtPackagePrefix) {
+			return frame.File + ":" + strconv.Itoa(frame.Line), frame.File != ""
+		}
+		if !more {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// eventSources returns the source argument of each event in the trace, keyed by the event's name
+func eventSources(t *testing.T, data []byte) map[string]interface{} {
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+
+	sources := map[string]interface{}{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			return sources
+		}
+		require.NoError(t, err)
+		for _, argument := range event.Arguments {
+			if argument.Key == fxt.SourceArgumentKey {
+				sources[event.Name] = argument.Value
+			}
+		}
+	}
+}
+
+// here returns the location of its caller, like the source argument
+func here() string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func TestWithCallerLocations(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithCallerLocations())
+	require.NoError(t, err)
+
+	arguments := map[string]interface{}{"key": "value"}
+	instant, err := here(), writer.AddInstantEventWithArgs("category", "instant", 1, 2, 100, arguments)
+	require.NoError(t, err)
+	begin, err := here(), writer.AddDurationBeginEvent("category", "begin", 1, 2, 200)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddDurationEndEvent("category", "begin", 1, 2, 300))
+	complete, err := here(), writer.Event("category", "complete").Thread(1, 2).At(400).Complete(500)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// The caller's arguments are left untouched
+	require.Equal(t, map[string]interface{}{"key": "value"}, arguments)
+
+	require.Equal(t, map[string]interface{}{
+		"instant":  instant,
+		"begin":    begin,
+		"complete": complete,
+	}, eventSources(t, buffer.Bytes()))
+}
+
+func TestWithCaller(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddInstantEvent("category", "plain", 1, 2, 100))
+	instant, err := here(), writer.AddInstantEventWithCaller("category", "instant", 1, 2, 200, nil)
+	require.NoError(t, err)
+	begin, err := here(), writer.AddDurationBeginEventWithCaller("category", "begin", 1, 2, 300, nil)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddDurationEndEvent("category", "begin", 1, 2, 400))
+	complete, err := here(), writer.AddDurationCompleteEventWithCaller("category", "complete", 1, 2, 500, 600, map[string]interface{}{"key": "value"})
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.Equal(t, map[string]interface{}{
+		"instant":  instant,
+		"begin":    begin,
+		"complete": complete,
+	}, eventSources(t, buffer.Bytes()))
+}
@@ -0,0 +1,98 @@
+package fxt
+
+import (
+	"runtime"
+)
+
+// maxStackDepth is the number of frames CaptureStack captures
+const maxStackDepth = 64
+
+// CaptureStack returns the program counters of the calling goroutine's stack, innermost first, like
+// runtime.Callers. `skip` is the number of frames to skip above the caller of CaptureStack, so zero starts the
+// stack at the function calling it. At most 64 frames are captured
+func CaptureStack(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// AddStack writes `stack`, captured with CaptureStack or runtime.Callers in this process, to the trace, and
+// returns its ID, to attach to events as the StackArgumentKey argument
+//
+// Each stack is written once per trace, in a blob record of BlobTypeStack. The first time a PC is seen, its
+// function, file, and line are written to a blob record of BlobTypeSymbols, so viewers and the analysis package
+// can show the stacks of chosen events without the binary. The ID is a hash of the PCs, so the same stack has
+// the same ID in every trace written by the process
+func (w *Writer) AddStack(stack []uintptr) (uint64, error) {
+	id := stackId(stack)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.stacks[id]; ok {
+		return id, nil
+	}
+
+	if err := w.addSymbols(stack); err != nil {
+		return 0, err
+	}
+	if err := w.addBlobRecord(StackBlobName, appendStack(nil, id, stack), BlobTypeStack); err != nil {
+		return 0, err
+	}
+	w.stacks[id] = struct{}{}
+	return id, nil
+}
+
+// AddInstantEventWithStack is the same as AddInstantEventWithArgs, but it captures the caller's stack, and
+// attaches it as the StackArgumentKey argument. `arguments` can be nil
+func (w *Writer) AddInstantEventWithStack(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	arguments, err := w.withStack(arguments)
+	if err != nil {
+		return err
+	}
+	return w.AddInstantEventWithArgs(category, name, processId, threadId, timestamp, arguments)
+}
+
+// AddDurationBeginEventWithStack is the same as AddDurationBeginEventWithArgs, but it captures the caller's
+// stack, and attaches it as the StackArgumentKey argument. `arguments` can be nil
+func (w *Writer) AddDurationBeginEventWithStack(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	arguments, err := w.withStack(arguments)
+	if err != nil {
+		return err
+	}
+	return w.AddDurationBeginEventWithArgs(category, name, processId, threadId, timestamp, arguments)
+}
+
+// withStack writes the stack of the caller of the WithStack method, and returns a copy of `arguments` with its ID
+func (w *Writer) withStack(arguments map[string]interface{}) (map[string]interface{}, error) {
+	id, err := w.AddStack(CaptureStack(2))
+	if err != nil {
+		return nil, err
+	}
+
+	withStack := make(map[string]interface{}, len(arguments)+1)
+	for key, value := range arguments {
+		withStack[key] = value
+	}
+	withStack[StackArgumentKey] = id
+	return withStack, nil
+}
+
+// addSymbols writes the symbols of the PCs in `stack` that haven't been written yet. The Writer's lock must be held
+func (w *Writer) addSymbols(stack []uintptr) error {
+	var symbols []Symbol
+	for _, pc := range stack {
+		if _, ok := w.symbolized[uint64(pc)]; !ok {
+			symbols = append(symbols, symbolize(pc))
+		}
+	}
+	return w.writeSymbols(symbols)
+}
@@ -0,0 +1,70 @@
+package fxt_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddInstantEventWithStack(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, writer.AddInstantEventWithStack("category", "instant", 1, 2, uint64(100+i), map[string]interface{}{"i": int64(i)}))
+	}
+	stackId, err := writer.AddStack(fxt.CaptureStack(0))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var eventStacks []interface{}
+	var stacks []uint64
+	var symbols []byte
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok {
+			switch blob.Type {
+			case fxt.BlobTypeStack:
+				require.Equal(t, fxt.StackBlobName, reader.ResolveString(blob.Name))
+				stacks = append(stacks, binary.LittleEndian.Uint64(blob.Data))
+			case fxt.BlobTypeSymbols:
+				require.Equal(t, fxt.SymbolsBlobName, reader.ResolveString(blob.Name))
+				symbols = append(symbols, blob.Data...)
+			}
+		}
+		if event, err := reader.Resolve(record); err == nil && event != nil {
+			for _, argument := range event.Arguments {
+				if argument.Key == fxt.StackArgumentKey {
+					eventStacks = append(eventStacks, argument.Value)
+				}
+			}
+		}
+	}
+
+	// Both events were written from the same line, so they share a stack, which is only written once
+	require.Len(t, eventStacks, 2)
+	require.Equal(t, eventStacks[0], eventStacks[1])
+	require.Len(t, stacks, 2)
+	require.Equal(t, eventStacks[0], stacks[0])
+	require.Equal(t, stackId, stacks[1])
+	require.NotEqual(t, stacks[0], stacks[1])
+
+	require.True(t, bytes.Contains(symbols, []byte("fxt_test.TestAddInstantEventWithStack")))
+	require.True(t, bytes.Contains(symbols, []byte("callstack_test.go")))
+}
@@ -0,0 +1,224 @@
+package fxt
+
+var _ TraceWriter = (*CategoryFilter)(nil)
+
+// CategoryFilter is a TraceWriter that only passes through the events in a set of categories, so a trace can be
+// limited to the subsystems of interest without changing the code that writes the events
+//
+// Process and thread names, and blob records without a category, are always passed through
+type CategoryFilter struct {
+	TraceWriter
+
+	categories map[string]struct{}
+}
+
+// NewCategoryFilter creates a CategoryFilter that writes the events in `categories` to `w`
+func NewCategoryFilter(w TraceWriter, categories ...string) *CategoryFilter {
+	f := &CategoryFilter{
+		TraceWriter: w,
+		categories:  make(map[string]struct{}, len(categories)),
+	}
+	for _, category := range categories {
+		f.categories[category] = struct{}{}
+	}
+
+	return f
+}
+
+// Enabled reports whether events in `category` are passed through
+func (f *CategoryFilter) Enabled(category string) bool {
+	_, ok := f.categories[category]
+	return ok
+}
+
+// AddInstantEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddInstantEvent(category, name, processId, threadId, timestamp)
+}
+
+// AddInstantEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddInstantEventWithArgs(category, name, processId, threadId, timestamp, arguments)
+}
+
+// AddCounterEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddCounterEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}, counterId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddCounterEvent(category, name, processId, threadId, timestamp, arguments, counterId)
+}
+
+// AddDurationBeginEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationBeginEvent(category, name, processId, threadId, timestamp)
+}
+
+// AddDurationBeginEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationBeginEventWithArgs(category, name, processId, threadId, timestamp, arguments)
+}
+
+// AddDurationEndEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationEndEvent(category, name, processId, threadId, timestamp)
+}
+
+// AddDurationEndEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationEndEventWithArgs(category, name, processId, threadId, timestamp, arguments)
+}
+
+// AddDurationCompleteEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationCompleteEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationCompleteEvent(category, name, processId, threadId, beginTimestamp, endTimestamp)
+}
+
+// AddDurationCompleteEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddDurationCompleteEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddDurationCompleteEventWithArgs(category, name, processId, threadId, beginTimestamp, endTimestamp, arguments)
+}
+
+// AddAsyncBeginEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncBeginEvent(category, name, processId, threadId, timestamp, asyncCorrelationId)
+}
+
+// AddAsyncBeginEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncBeginEventWithArgs(category, name, processId, threadId, timestamp, asyncCorrelationId, arguments)
+}
+
+// AddAsyncInstantEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncInstantEvent(category, name, processId, threadId, timestamp, asyncCorrelationId)
+}
+
+// AddAsyncInstantEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncInstantEventWithArgs(category, name, processId, threadId, timestamp, asyncCorrelationId, arguments)
+}
+
+// AddAsyncEndEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncEndEvent(category, name, processId, threadId, timestamp, asyncCorrelationId)
+}
+
+// AddAsyncEndEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddAsyncEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddAsyncEndEventWithArgs(category, name, processId, threadId, timestamp, asyncCorrelationId, arguments)
+}
+
+// AddFlowBeginEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowBeginEvent(category, name, processId, threadId, timestamp, flowCorrelationId)
+}
+
+// AddFlowBeginEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowBeginEventWithArgs(category, name, processId, threadId, timestamp, flowCorrelationId, arguments)
+}
+
+// AddFlowStepEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowStepEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowStepEvent(category, name, processId, threadId, timestamp, flowCorrelationId)
+}
+
+// AddFlowStepEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowStepEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowStepEventWithArgs(category, name, processId, threadId, timestamp, flowCorrelationId, arguments)
+}
+
+// AddFlowEndEvent writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowEndEvent(category, name, processId, threadId, timestamp, flowCorrelationId)
+}
+
+// AddFlowEndEventWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddFlowEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddFlowEndEventWithArgs(category, name, processId, threadId, timestamp, flowCorrelationId, arguments)
+}
+
+// AddLargeBlobRecord writes the blob if its category is enabled
+func (f *CategoryFilter) AddLargeBlobRecord(category string, name string, data []byte) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddLargeBlobRecord(category, name, data)
+}
+
+// AddLargeBlobEventRecord writes the event if its category is enabled
+func (f *CategoryFilter) AddLargeBlobEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddLargeBlobEventRecord(category, name, processId, threadId, timestamp, data)
+}
+
+// AddLargeBlobEventRecordWithArgs writes the event if its category is enabled
+func (f *CategoryFilter) AddLargeBlobEventRecordWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte, arguments map[string]interface{}) error {
+	if !f.Enabled(category) {
+		return nil
+	}
+	return f.TraceWriter.AddLargeBlobEventRecordWithArgs(category, name, processId, threadId, timestamp, data, arguments)
+}
@@ -0,0 +1,43 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryFilter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	filter := fxt.NewCategoryFilter(writer, "net")
+	require.True(t, filter.Enabled("net"))
+	require.False(t, filter.Enabled("ui"))
+
+	require.NoError(t, filter.SetThreadName(1, 2, "main"))
+	require.NoError(t, filter.AddInstantEvent("net", "connect", 1, 2, 100))
+	require.NoError(t, filter.AddCounterEvent("ui", "frames", 1, 2, 200, map[string]interface{}{"fps": 60}, 0))
+	require.NoError(t, filter.AddAsyncBeginEvent("net", "request", 1, 2, 300, 7))
+	require.NoError(t, filter.AddAsyncEndEvent("ui", "animation", 1, 2, 400, 8))
+	require.NoError(t, filter.AddLargeBlobRecord("ui", "screenshot", []byte{1, 2, 3}))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, event.Name)
+	}
+	require.Equal(t, []string{"connect", "request"}, names)
+}
@@ -0,0 +1,231 @@
+package fxt
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CgroupSampler periodically reads the CPU and memory usage of a process's cgroup, and writes them to a Writer
+// as counter events. Inside a container, the host's CPU and memory usage are misleading, since the container is
+// limited to a share of them. Its cgroup's usage and limits show how close it is to being throttled or killed
+//
+// Up to three counters are written each sample:
+//   - "Container CPU" with the cgroup's "usage", "user", and "system" CPU time as a percentage of one CPU, and its
+//     "limit" if it has one
+//   - "Container Throttling" with the percentage of scheduling periods the cgroup was throttled in, and how many
+//     milliseconds per second it was throttled for, when the cpu controller is enabled
+//   - "Container Memory" with the cgroup's "current_bytes", and its "limit_bytes" if it has one, when the memory
+//     controller is enabled
+//
+// CPU usage and throttling are measured between samples, so they aren't written by the first sample.
+// The cgroup is the one of the process the events are attributed to, which is the current process unless
+// overridden with WithSamplerThread
+//
+// It's supported on Linux with cgroup v2, where it reads cpu.stat, cpu.max, memory.current, and memory.max.
+// Sampling returns an error on other platforms, and for processes that aren't in a cgroup v2 hierarchy
+type CgroupSampler struct {
+	*Sampler
+
+	writer *Writer
+
+	lastSampleTime time.Time
+	last           cgroupStats
+}
+
+// cgroupStats is a snapshot of a cgroup's CPU and memory usage
+type cgroupStats struct {
+	usageUsec  uint64
+	userUsec   uint64
+	systemUsec uint64
+
+	// hasThrottling is set when the cpu controller is enabled, which counts the periods the cgroup was throttled in
+	hasThrottling    bool
+	periods          uint64
+	throttledPeriods uint64
+	throttledUsec    uint64
+	// cpuLimit is the number of CPUs the cgroup may use, or 0 when it's unlimited
+	cpuLimit float64
+
+	// hasMemory is set when the memory controller is enabled. memoryLimit is 0 when it's unlimited
+	hasMemory     bool
+	memoryCurrent uint64
+	memoryLimit   uint64
+}
+
+// NewCgroupSampler creates a CgroupSampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "Container" category unless overridden with WithSamplerCategory
+func NewCgroupSampler(writer *Writer, options ...SamplerOption) *CgroupSampler {
+	cgroupSampler := &CgroupSampler{
+		writer: writer,
+	}
+	cgroupSampler.Sampler = &Sampler{
+		config: newSamplerConfig("Container", 0, options),
+		sample: cgroupSampler.sample,
+	}
+
+	return cgroupSampler
+}
+
+func (s *CgroupSampler) sample(timestamp uint64) error {
+	stats, err := readCgroupStats(s.config.processId)
+	if err != nil {
+		return fmt.Errorf("failed to read cgroup usage - %w", err)
+	}
+	now := time.Now()
+
+	config := s.config
+	if !s.lastSampleTime.IsZero() {
+		elapsedUsec := float64(now.Sub(s.lastSampleTime).Microseconds())
+		if elapsedUsec > 0 {
+			err := s.writer.AddCounterEvent(config.category, "Container CPU", config.processId, config.threadId, timestamp, cgroupCPUUtilization(s.last, stats, elapsedUsec), 0)
+			if err != nil {
+				return err
+			}
+
+			if stats.hasThrottling && s.last.hasThrottling {
+				throttled := 0.0
+				if stats.periods > s.last.periods && stats.throttledPeriods >= s.last.throttledPeriods {
+					throttled = float64(stats.throttledPeriods-s.last.throttledPeriods) / float64(stats.periods-s.last.periods) * 100
+				}
+				throttledMs := 0.0
+				if stats.throttledUsec >= s.last.throttledUsec {
+					throttledMs = float64(stats.throttledUsec-s.last.throttledUsec) / 1000 / (elapsedUsec / 1e6)
+				}
+
+				err := s.writer.AddCounterEvent(config.category, "Container Throttling", config.processId, config.threadId, timestamp, map[string]interface{}{
+					"throttled_percent":    throttled,
+					"throttled_ms_per_sec": throttledMs,
+				}, 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if stats.hasMemory {
+		memory := map[string]interface{}{
+			"current_bytes": stats.memoryCurrent,
+		}
+		if stats.memoryLimit > 0 {
+			memory["limit_bytes"] = stats.memoryLimit
+		}
+		err := s.writer.AddCounterEvent(config.category, "Container Memory", config.processId, config.threadId, timestamp, memory, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.lastSampleTime = now
+	s.last = stats
+
+	return nil
+}
+
+// cgroupCPUUtilization returns the cgroup's CPU usage between the snapshots as a percentage of one CPU
+func cgroupCPUUtilization(last cgroupStats, current cgroupStats, elapsedUsec float64) map[string]interface{} {
+	percent := func(lastUsec uint64, currentUsec uint64) float64 {
+		if currentUsec < lastUsec {
+			return 0
+		}
+		return float64(currentUsec-lastUsec) / elapsedUsec * 100
+	}
+
+	utilization := map[string]interface{}{
+		"usage":  percent(last.usageUsec, current.usageUsec),
+		"user":   percent(last.userUsec, current.userUsec),
+		"system": percent(last.systemUsec, current.systemUsec),
+	}
+	if current.cpuLimit > 0 {
+		utilization["limit"] = current.cpuLimit * 100
+	}
+
+	return utilization
+}
+
+// parseCgroupCPUStat parses a cgroup's cpu.stat, which has a "name value" line for each counter. The throttling
+// counters are only there when the cpu controller is enabled
+func parseCgroupCPUStat(data string) (cgroupStats, error) {
+	var stats cgroupStats
+	counters := map[string]*uint64{
+		"usage_usec":     &stats.usageUsec,
+		"user_usec":      &stats.userUsec,
+		"system_usec":    &stats.systemUsec,
+		"nr_periods":     &stats.periods,
+		"nr_throttled":   &stats.throttledPeriods,
+		"throttled_usec": &stats.throttledUsec,
+	}
+
+	hasUsage := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		counter, ok := counters[fields[0]]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return cgroupStats{}, fmt.Errorf("invalid %s - %w", fields[0], err)
+		}
+		*counter = value
+
+		switch fields[0] {
+		case "usage_usec":
+			hasUsage = true
+		case "nr_periods":
+			stats.hasThrottling = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cgroupStats{}, err
+	}
+	if !hasUsage {
+		return cgroupStats{}, fmt.Errorf("no usage_usec")
+	}
+
+	return stats, nil
+}
+
+// parseCgroupCPUMax parses a cgroup's cpu.max, which is "$MAX $PERIOD", and returns the number of CPUs the cgroup
+// may use. $MAX is "max" when it's unlimited, which is returned as 0
+func parseCgroupCPUMax(data string) (float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("expected 2 fields, got %d", len(fields))
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+
+	quota, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quota - %w", err)
+	}
+	period, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid period - %w", err)
+	}
+	if period == 0 {
+		return 0, fmt.Errorf("invalid period 0")
+	}
+
+	return float64(quota) / float64(period), nil
+}
+
+// parseCgroupBytes parses a cgroup memory file with a single number of bytes, like memory.current. The limit
+// files are "max" when they're unlimited, which is returned as 0
+func parseCgroupBytes(data string) (uint64, error) {
+	value := strings.TrimSpace(data)
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
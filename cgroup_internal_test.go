@@ -0,0 +1,80 @@
+package fxt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCgroupCPUStat(t *testing.T) {
+	stats, err := parseCgroupCPUStat(`usage_usec 1000
+user_usec 600
+system_usec 400
+nice_usec 0
+nr_periods 50
+nr_throttled 5
+throttled_usec 2500
+`)
+	require.NoError(t, err)
+	require.Equal(t, cgroupStats{
+		usageUsec:        1000,
+		userUsec:         600,
+		systemUsec:       400,
+		hasThrottling:    true,
+		periods:          50,
+		throttledPeriods: 5,
+		throttledUsec:    2500,
+	}, stats)
+
+	// Without the cpu controller, there are no throttling counters
+	stats, err = parseCgroupCPUStat("usage_usec 1000\nuser_usec 600\nsystem_usec 400\n")
+	require.NoError(t, err)
+	require.False(t, stats.hasThrottling)
+
+	_, err = parseCgroupCPUStat("user_usec 600\n")
+	require.Error(t, err)
+	_, err = parseCgroupCPUStat("usage_usec x\n")
+	require.Error(t, err)
+}
+
+func TestParseCgroupLimits(t *testing.T) {
+	limit, err := parseCgroupCPUMax("150000 100000\n")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, limit)
+
+	limit, err = parseCgroupCPUMax("max 100000\n")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, limit)
+
+	_, err = parseCgroupCPUMax("150000 0\n")
+	require.Error(t, err)
+	_, err = parseCgroupCPUMax("150000\n")
+	require.Error(t, err)
+
+	bytes, err := parseCgroupBytes("1048576\n")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1048576), bytes)
+
+	bytes, err = parseCgroupBytes("max\n")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), bytes)
+}
+
+func TestCgroupCPUUtilization(t *testing.T) {
+	last := cgroupStats{usageUsec: 1000, userUsec: 600, systemUsec: 400}
+	current := cgroupStats{usageUsec: 151000, userUsec: 100600, systemUsec: 50400, cpuLimit: 2}
+
+	// 150ms of CPU time in 100ms is one and a half CPUs
+	require.Equal(t, map[string]interface{}{
+		"usage":  150.0,
+		"user":   100.0,
+		"system": 50.0,
+		"limit":  200.0,
+	}, cgroupCPUUtilization(last, current, 100000))
+
+	require.Equal(t, map[string]interface{}{
+		"usage":  0.0,
+		"user":   0.0,
+		"system": 0.0,
+	}, cgroupCPUUtilization(current, last, 100000))
+}
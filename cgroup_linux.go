@@ -0,0 +1,103 @@
+package fxt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func readCgroupStats(processId KernelObjectID) (cgroupStats, error) {
+	dir, err := cgroupDir(processId)
+	if err != nil {
+		return cgroupStats{}, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return cgroupStats{}, err
+	}
+	stats, err := parseCgroupCPUStat(string(data))
+	if err != nil {
+		return cgroupStats{}, fmt.Errorf("failed to parse cpu.stat - %w", err)
+	}
+
+	// The other files only exist when their controller is enabled for the cgroup, and the root cgroup has none
+	data, err = readCgroupFile(dir, "cpu.max")
+	if err != nil {
+		return cgroupStats{}, err
+	}
+	if data != nil {
+		stats.cpuLimit, err = parseCgroupCPUMax(string(data))
+		if err != nil {
+			return cgroupStats{}, fmt.Errorf("failed to parse cpu.max - %w", err)
+		}
+	}
+
+	data, err = readCgroupFile(dir, "memory.current")
+	if err != nil {
+		return cgroupStats{}, err
+	}
+	if data != nil {
+		stats.hasMemory = true
+		stats.memoryCurrent, err = parseCgroupBytes(string(data))
+		if err != nil {
+			return cgroupStats{}, fmt.Errorf("failed to parse memory.current - %w", err)
+		}
+
+		data, err = readCgroupFile(dir, "memory.max")
+		if err != nil {
+			return cgroupStats{}, err
+		}
+		if data != nil {
+			stats.memoryLimit, err = parseCgroupBytes(string(data))
+			if err != nil {
+				return cgroupStats{}, fmt.Errorf("failed to parse memory.max - %w", err)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// readCgroupFile reads a file of a cgroup, returning nil if it doesn't exist
+func readCgroupFile(dir string, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// cgroupDir returns the directory of a process's cgroup v2
+func cgroupDir(processId KernelObjectID) (string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", processId))
+	if err != nil {
+		return "", err
+	}
+	path, err := parseProcPidCgroup(string(data))
+	if err != nil {
+		return "", err
+	}
+
+	data, err = os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	mountPoint, root, err := parseCgroup2Mount(string(data))
+	if err != nil {
+		return "", err
+	}
+
+	// Only part of the hierarchy can be mounted, like in a container without a cgroup namespace
+	if root != "/" {
+		if path != root && !strings.HasPrefix(path, root+"/") {
+			return "", fmt.Errorf("cgroup %s is outside of the mounted hierarchy %s", path, root)
+		}
+		path = strings.TrimPrefix(path, root)
+	}
+
+	return filepath.Join(mountPoint, path), nil
+}
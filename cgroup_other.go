@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fxt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readCgroupStats(processId KernelObjectID) (cgroupStats, error) {
+	return cgroupStats{}, fmt.Errorf("cgroup sampling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,52 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupSampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	sampler := fxt.NewCgroupSampler(writer)
+	err = sampler.Sample()
+	if runtime.GOOS != "linux" {
+		require.Error(t, err)
+		return
+	}
+	if err != nil {
+		t.Skipf("cgroup v2 is not available - %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	counters := map[string]int{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "Container", event.Category)
+		counters[event.Name]++
+	}
+
+	// The CPU usage is only written once there are two samples, and memory with every sample
+	require.Equal(t, 1, counters["Container CPU"])
+	require.Contains(t, []int{0, 1}, counters["Container Throttling"])
+	require.Contains(t, []int{0, 2}, counters["Container Memory"])
+}
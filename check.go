@@ -0,0 +1,157 @@
+package fxt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Diagnostic is a problem found in a trace by Check
+type Diagnostic struct {
+	// Offset is the position of the problem in the trace, in bytes. It's the start of the word that's wrong
+	// when that's known, or the start of the record otherwise
+	Offset int64
+	// RecordOffset is the position of the start of the record with the problem
+	RecordOffset int64
+	Type         RecordType
+	Err          error
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("offset %d: %s record at offset %d: %v", d.Offset, d.Type, d.RecordOffset, d.Err)
+}
+
+// Check reads the whole FXT trace in `r`, and returns every problem it finds
+//
+// On top of the checks Reader.Next does, each record is decoded and encoded again. Any difference between
+// the two means the record has non-zero padding, reserved bits set, or header or argument fields that are
+// out of range, and the diagnostic points at the first word that differs
+//
+// Problems with the trace are returned as diagnostics. The error is only set if reading `r` fails
+func Check(r io.Reader) ([]Diagnostic, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return []Diagnostic{{Err: err}}, nil
+	}
+	return reader.Check()
+}
+
+// Check reads the rest of the trace, and returns every problem it finds, like the Check function
+// Use Stats afterwards for the number of records that were checked
+func (r *Reader) Check() ([]Diagnostic, error) {
+	var diagnostics []Diagnostic
+	for {
+		record, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var corruptErr *CorruptRecordError
+			if !errors.As(err, &corruptErr) {
+				return nil, err
+			}
+			diagnostics = append(diagnostics, Diagnostic{Offset: corruptErr.Offset, RecordOffset: corruptErr.Offset, Type: corruptErr.Type, Err: corruptErr.Err})
+
+			// The Reader skips records that it could read, but that aren't valid. Otherwise, the
+			// position of the following records is unknown, so stop
+			if r.Offset() <= corruptErr.Offset || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			continue
+		}
+
+		if word, err := checkRecord(record); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Offset: record.Offset + int64(word)*8, RecordOffset: record.Offset, Type: record.Type, Err: err})
+		}
+	}
+
+	return diagnostics, nil
+}
+
+// checkRecord decodes a record and encodes it again, and returns an error if they're different, along
+// with the index of the word that's wrong
+func checkRecord(record *Record) (int, error) {
+	if record.Type > RecordTypeLog && record.Type != RecordTypeLargeBlob {
+		return 0, fmt.Errorf("reserved record type %d", int(record.Type))
+	}
+
+	decoded, err := decodeRecord(record.Type, record.Data)
+	if err != nil {
+		return 0, err
+	}
+	if decoded == nil {
+		if record.Type == RecordTypeMetadata || record.Type == RecordTypeLog {
+			// Trace info and log records aren't decoded, so there's nothing more to check
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unknown record subtype in header %#016x", record.Header())
+	}
+
+	encoded, err := decoded.appendRecord(nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid field - %w", err)
+	}
+	if len(encoded) != len(record.Data) {
+		return 0, fmt.Errorf("record is %d words, but its contents need %d", len(record.Data)/8, len(encoded)/8)
+	}
+	if bytes.Equal(encoded, record.Data) {
+		return 0, nil
+	}
+
+	for i := 0; i < len(encoded); i += 8 {
+		if !bytes.Equal(encoded[i:i+8], record.Data[i:i+8]) {
+			word := i / 8
+			return word, fmt.Errorf("unexpected data in word %d%s - found %#016x, expected %#016x (non-zero padding, reserved bits, or out of range fields)", word, describeWord(decoded, word), record.Word(word), binary.LittleEndian.Uint64(encoded[i:]))
+		}
+	}
+	return 0, nil
+}
+
+// describeWord names the argument that word `word` of a record is part of, if it's in one
+func describeWord(decoded recordAppender, word int) string {
+	var arguments []Argument
+	switch v := decoded.(type) {
+	case eventRecorder:
+		arguments = v.event().Arguments
+	case *UserspaceObjectRecord:
+		arguments = v.Arguments
+	case *KernelObjectRecord:
+		arguments = v.Arguments
+	case *ContextSwitchRecord:
+		arguments = v.Arguments
+	case *ThreadWakeupRecord:
+		arguments = v.Arguments
+	}
+	if len(arguments) == 0 {
+		return ""
+	}
+
+	// The arguments are encoded back to back, so find them in the encoded record to get where each one starts
+	encoded, err := decoded.appendRecord(nil)
+	if err != nil {
+		return ""
+	}
+	encodedArguments, err := appendArguments(nil, arguments)
+	if err != nil {
+		return ""
+	}
+	start := bytes.Index(encoded, encodedArguments)
+	if start < 0 || start%8 != 0 {
+		return ""
+	}
+
+	offset := start / 8
+	for i, argument := range arguments {
+		size, err := argument.sizeInWords()
+		if err != nil {
+			return ""
+		}
+		if word >= offset && word < offset+size {
+			return fmt.Sprintf(" (argument %d)", i)
+		}
+		offset += size
+	}
+	return ""
+}
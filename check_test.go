@@ -0,0 +1,56 @@
+package fxt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	magic, err := fxt.MagicNumberRecord(fxt.LatestFormatVersion)
+	require.NoError(t, err)
+	event, err := fxt.InstantEvent{EventRecord: fxt.EventRecord{
+		Category:  fxt.StringRef{Inline: "category"},
+		Name:      fxt.StringRef{Inline: "event"},
+		Thread:    fxt.ThreadRef{Inline: fxt.Thread{ProcessId: 1, ThreadId: 2}},
+		Timestamp: 100,
+		Arguments: []fxt.Argument{{Key: fxt.StringRef{Inline: "key"}, Value: fxt.StringRef{Inline: "value"}}},
+	}}.MarshalBinary()
+	require.NoError(t, err)
+	trace := append(append([]byte(nil), magic...), event...)
+
+	diagnostics, err := fxt.Check(bytes.NewReader(trace))
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	// Non-zero padding after the string value of the argument, in the last word of the event
+	corrupt := append([]byte(nil), trace...)
+	corrupt[len(corrupt)-1] = 0xFF
+	diagnostics, err = fxt.Check(bytes.NewReader(corrupt))
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, int64(len(magic)), diagnostics[0].RecordOffset)
+	require.Equal(t, int64(len(corrupt)-8), diagnostics[0].Offset)
+	require.Equal(t, fxt.RecordTypeEvent, diagnostics[0].Type)
+	require.Contains(t, diagnostics[0].Err.Error(), "argument 0")
+
+	// An undefined reference, and a record that runs past the end of the trace
+	undefined, err := fxt.BlobRecord{Name: fxt.StringRef{Index: 3}, Type: 1}.MarshalBinary()
+	require.NoError(t, err)
+	corrupt = append(append([]byte(nil), trace...), undefined...)
+	corrupt = append(corrupt, event[:16]...)
+	diagnostics, err = fxt.Check(bytes.NewReader(corrupt))
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+	require.Equal(t, int64(len(trace)), diagnostics[0].Offset)
+	require.Equal(t, fxt.RecordTypeBlob, diagnostics[0].Type)
+	require.Equal(t, int64(len(trace)+len(undefined)), diagnostics[1].Offset)
+
+	// Traces without the magic number record can't be checked any further
+	diagnostics, err = fxt.Check(bytes.NewReader([]byte("not a trace")))
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 1)
+}
@@ -0,0 +1,202 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// With WithChecksums, the trace is split into chunks, each ended by a checksum blob record, so corruption of a
+// large trace in transit or on disk can be detected, and narrowed down to the chunk it's in. A checksum blob,
+// of BlobTypeChecksum and named ChecksumBlobName with an inline name, holds:
+//   - the number of bytes in the chunk, as 8 little endian bytes
+//   - the CRC-32C (Castagnoli) of the chunk, as 4 little endian bytes
+//   - flags, as 4 little endian bytes. ChecksumFlagFooter marks the checksum written by Close, which ends the trace
+//
+// The first chunk starts at the magic number record, and each of the others right after the previous checksum
+// record. A chunk covers whole records, and doesn't include the checksum record ending it
+const (
+	// BlobTypeChecksum is the checksum of a chunk of the trace, in a blob record named ChecksumBlobName
+	BlobTypeChecksum BlobType = 0xF2
+	// ChecksumBlobName is the name of the blob records holding checksums
+	ChecksumBlobName = "fxt.checksum"
+	// ChecksumFlagFooter is set on the checksum written when the trace is closed
+	ChecksumFlagFooter uint32 = 1
+	// DefaultChecksumInterval is the size of the chunks checksummed by WithChecksums, if none is given
+	DefaultChecksumInterval = 1 << 20
+)
+
+// checksumSize is the size of the payload of a checksum blob
+const checksumSize = 16
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrChecksumMismatch is wrapped by the *CorruptRecordError returned for a checksum record that doesn't
+	// match the chunk before it, when the Reader verifies checksums
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrMissingFooter is wrapped by the *CorruptRecordError returned at the end of a trace with checksums,
+	// but without a footer, when the Reader verifies checksums. The end of the trace was lost
+	ErrMissingFooter = errors.New("trace ends without its checksum footer")
+)
+
+// WithChecksums makes the Writer end every `interval` bytes of the trace with a checksum blob record, and end
+// the trace with a footer checksum when it's closed or reset. Readers verify them with VerifyChecksums. An
+// interval of zero uses DefaultChecksumInterval
+//
+// A chunk is ended at the first record boundary after `interval` bytes, so chunks can be a little larger.
+// Checksumming is done as records are written to the destination, so it adds a CRC-32C pass over the trace,
+// and a 40 byte record per chunk
+func WithChecksums(interval int) WriterOption {
+	return func(w *Writer) {
+		if interval <= 0 {
+			interval = DefaultChecksumInterval
+		}
+		w.checksumInterval = interval
+	}
+}
+
+// checksumWriter checksums the bytes written to the trace, and writes a checksum record after each chunk. It's
+// below the pipeline, so it sees every record, however it was written, and each Write is whole records
+type checksumWriter struct {
+	out      io.Writer
+	interval int
+	crc      uint32
+	size     uint64
+	scratch  []byte
+}
+
+func (c *checksumWriter) Write(data []byte) (int, error) {
+	n, err := c.out.Write(data)
+	c.crc = crc32.Update(c.crc, castagnoli, data[:n])
+	c.size += uint64(n)
+	if err != nil {
+		return n, err
+	}
+
+	if c.size >= uint64(c.interval) {
+		if err := c.writeChecksum(0); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// writeChecksum ends the current chunk with a checksum record
+func (c *checksumWriter) writeChecksum(flags uint32) error {
+	payload := make([]byte, 0, checksumSize)
+	payload = binary.LittleEndian.AppendUint64(payload, c.size)
+	payload = binary.LittleEndian.AppendUint32(payload, c.crc)
+	payload = binary.LittleEndian.AppendUint32(payload, flags)
+
+	record, err := BlobRecord{
+		Name: StringRef{Inline: ChecksumBlobName},
+		Type: BlobTypeChecksum,
+		Data: payload,
+	}.appendRecord(c.scratch[:0])
+	if err != nil {
+		return fmt.Errorf("failed to encode checksum record - %w", err)
+	}
+	c.scratch = record
+
+	if _, err := c.out.Write(record); err != nil {
+		return fmt.Errorf("failed to write checksum record - %w", err)
+	}
+	c.crc = 0
+	c.size = 0
+	return nil
+}
+
+// checksummedOutput wraps the destination in a checksumWriter, if the Writer writes checksums
+func (w *Writer) checksummedOutput() {
+	w.checksums = nil
+	if w.checksumInterval != 0 {
+		w.checksums = &checksumWriter{out: w.out, interval: w.checksumInterval}
+		w.out = w.checksums
+	}
+}
+
+// addFooter flushes the trace, and ends it with the footer checksum, if the Writer writes checksums. The
+// Writer's lock must be held
+func (w *Writer) addFooter() error {
+	if w.checksums == nil {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.checksums.writeChecksum(ChecksumFlagFooter)
+}
+
+// Checksum is the payload of a checksum blob record
+type Checksum struct {
+	// Size is the number of bytes in the chunk
+	Size uint64
+	// CRC is the CRC-32C of the chunk
+	CRC   uint32
+	Flags uint32
+}
+
+// ParseChecksum decodes the payload of a checksum blob
+func ParseChecksum(data []byte) (Checksum, error) {
+	if len(data) < checksumSize {
+		return Checksum{}, fmt.Errorf("checksum blob of %d bytes is shorter than %d", len(data), checksumSize)
+	}
+	return Checksum{
+		Size:  binary.LittleEndian.Uint64(data),
+		CRC:   binary.LittleEndian.Uint32(data[8:]),
+		Flags: binary.LittleEndian.Uint32(data[12:]),
+	}, nil
+}
+
+// VerifyChecksums makes the Reader check the checksum records written by WithChecksums against the records
+// before them. It must be called before reading any records
+//
+// A checksum that doesn't match is reported as a *CorruptRecordError wrapping ErrChecksumMismatch, at the
+// checksum record. If the trace has checksums, but doesn't end with a footer, the end of the trace is reported
+// as a *CorruptRecordError wrapping ErrMissingFooter, once, before io.EOF. Traces without checksums read as usual
+func (r *Reader) VerifyChecksums() {
+	r.verifyChecksums = true
+	r.checksum = crc32.Update(0, castagnoli, magicNumberRecords[r.version])
+	r.checksumSize = uint64(len(magicNumberRecords[r.version]))
+}
+
+// verifyChecksum adds a record to the current chunk, or checks the chunk against a checksum record
+func (r *Reader) verifyChecksum(record *Record) error {
+	if !isChecksumRecord(record) {
+		r.checksum = crc32.Update(r.checksum, castagnoli, record.Data)
+		r.checksumSize += uint64(len(record.Data))
+		r.footer = false
+		return nil
+	}
+
+	_, payload, err := record.blob()
+	if err != nil {
+		return err
+	}
+	checksum, err := ParseChecksum(payload)
+	if err != nil {
+		return err
+	}
+	if checksum.Size != r.checksumSize || checksum.CRC != r.checksum {
+		return fmt.Errorf("%w - chunk of %d bytes has checksum 0x%08x, but the record has 0x%08x for %d bytes", ErrChecksumMismatch, r.checksumSize, r.checksum, checksum.CRC, checksum.Size)
+	}
+
+	r.checksum = 0
+	r.checksumSize = 0
+	r.hasChecksums = true
+	r.footer = checksum.Flags&ChecksumFlagFooter != 0
+	return nil
+}
+
+// isChecksumRecord reports whether `record` is a checksum blob. Other tools may use the same blob type, so the
+// name has to match as well
+func isChecksumRecord(record *Record) bool {
+	if record.Type != RecordTypeBlob || BlobType((record.Header()>>48)&0xFF) != BlobTypeChecksum {
+		return false
+	}
+	fields, _, err := record.blob()
+	return err == nil && fields.name.Index == 0 && fields.name.Inline == ChecksumBlobName
+}
@@ -0,0 +1,160 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readChecksums reads a trace, verifying its checksums, and returns the checksums and the first error
+func readChecksums(t *testing.T, data []byte) ([]fxt.Checksum, error) {
+	t.Helper()
+
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	reader.VerifyChecksums()
+
+	var checksums []fxt.Checksum
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return checksums, nil
+		}
+		if err != nil {
+			return checksums, err
+		}
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok && blob.Type == fxt.BlobTypeChecksum {
+			checksum, err := fxt.ParseChecksum(blob.Data)
+			require.NoError(t, err)
+			checksums = append(checksums, checksum)
+		}
+	}
+}
+
+func writeChecksummedTrace(t *testing.T, options ...fxt.WriterOption) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, append([]fxt.WriterOption{fxt.WithChecksums(256)}, options...)...)
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, writer.AddInstantEventWithArgs("category", "instant", 1, 2, uint64(i), map[string]interface{}{"i": i}))
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func TestChecksums(t *testing.T) {
+	data := writeChecksummedTrace(t)
+
+	checksums, err := readChecksums(t, data)
+	require.NoError(t, err)
+	require.Greater(t, len(checksums), 2)
+	for _, checksum := range checksums[:len(checksums)-1] {
+		require.GreaterOrEqual(t, checksum.Size, uint64(256))
+		require.Zero(t, checksum.Flags)
+	}
+	require.Equal(t, fxt.ChecksumFlagFooter, checksums[len(checksums)-1].Flags)
+}
+
+func TestChecksumsWithPipeline(t *testing.T) {
+	data := writeChecksummedTrace(t, fxt.WithPipeline(2))
+
+	checksums, err := readChecksums(t, data)
+	require.NoError(t, err)
+	require.Greater(t, len(checksums), 2)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	data := writeChecksummedTrace(t)
+
+	// Flip a bit in the timestamp of an event in the first chunk
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var record *fxt.Record
+	for record == nil || record.Type != fxt.RecordTypeEvent {
+		record, err = reader.Next()
+		require.NoError(t, err)
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[record.Offset+8] ^= 1
+
+	_, err = readChecksums(t, corrupted)
+	require.ErrorIs(t, err, fxt.ErrChecksumMismatch)
+	var corrupt *fxt.CorruptRecordError
+	require.ErrorAs(t, err, &corrupt)
+	require.Equal(t, fxt.RecordTypeBlob, corrupt.Type)
+}
+
+func TestChecksumMissingFooter(t *testing.T) {
+	data := writeChecksummedTrace(t)
+
+	// Cut off the footer. It has a header word, the inline name padded to 16 bytes, and the 16 byte checksum
+	_, err := readChecksums(t, data[:len(data)-40])
+	require.ErrorIs(t, err, fxt.ErrMissingFooter)
+
+	// Traces without checksums don't need a footer
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+	require.NoError(t, writer.Close())
+	checksums, err := readChecksums(t, buffer.Bytes())
+	require.NoError(t, err)
+	require.Empty(t, checksums)
+}
+
+func TestChecksumsReset(t *testing.T) {
+	var first, second bytes.Buffer
+	writer, err := fxt.NewWriterTo(&first, fxt.WithChecksums(0))
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+	require.NoError(t, writer.ResetTo(&second))
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 200))
+	require.NoError(t, writer.Close())
+
+	for _, data := range [][]byte{first.Bytes(), second.Bytes()} {
+		checksums, err := readChecksums(t, data)
+		require.NoError(t, err)
+		require.Len(t, checksums, 1)
+		require.Equal(t, fxt.ChecksumFlagFooter, checksums[0].Flags)
+	}
+}
+
+func TestAppendWriterChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+
+	writer, err := fxt.NewWriter(path, fxt.WithChecksums(128))
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, uint64(i)))
+	}
+	require.NoError(t, writer.Flush())
+	// Simulate a crash, by copying the file before it's closed, so it has no footer
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, os.WriteFile(path, data, 0666))
+
+	writer, err = fxt.NewAppendWriter(path, fxt.WithChecksums(128))
+	require.NoError(t, err)
+	for i := 20; i < 40; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, uint64(i)))
+	}
+	require.NoError(t, writer.Close())
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	checksums, err := readChecksums(t, data)
+	require.NoError(t, err)
+	require.Equal(t, fxt.ChecksumFlagFooter, checksums[len(checksums)-1].Flags)
+}
@@ -0,0 +1,106 @@
+package fxt
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Clock returns the current time as a timestamp in ticks
+//
+// The tick rate must match the value passed to AddInitializationRecord
+type Clock func() uint64
+
+// WallClock is a Clock which returns the number of nanoseconds elapsed since the Unix epoch
+// It should be paired with an initialization record of TicksNanoseconds
+func WallClock() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// TickRate is the number of timestamp ticks per second, as written in the initialization record
+type TickRate uint64
+
+const (
+	// TicksNanoseconds is the tick rate of timestamps in nanoseconds. It's the rate of WallClock
+	TicksNanoseconds TickRate = 1_000_000_000
+	// TicksMicroseconds is the tick rate of timestamps in microseconds
+	TicksMicroseconds TickRate = 1_000_000
+	// TicksMilliseconds is the tick rate of timestamps in milliseconds
+	TicksMilliseconds TickRate = 1_000
+)
+
+// TSCTickRate returns the tick rate for timestamps read from a CPU's time stamp counter
+// running at `frequencyHz`
+func TSCTickRate(frequencyHz uint64) TickRate {
+	return TickRate(frequencyHz)
+}
+
+// FromNanoseconds converts a number of nanoseconds to ticks. It saturates at math.MaxUint64
+func (r TickRate) FromNanoseconds(nanoseconds uint64) uint64 {
+	return ConvertTicks(nanoseconds, TicksNanoseconds, r)
+}
+
+// ToNanoseconds converts a number of ticks to nanoseconds. It saturates at math.MaxUint64
+func (r TickRate) ToNanoseconds(ticks uint64) uint64 {
+	return ConvertTicks(ticks, r, TicksNanoseconds)
+}
+
+// FromDuration converts a duration to ticks. Negative durations are converted to zero
+func (r TickRate) FromDuration(duration time.Duration) uint64 {
+	if duration < 0 {
+		return 0
+	}
+	return r.FromNanoseconds(uint64(duration))
+}
+
+// ToDuration converts a number of ticks to a duration. It saturates at the maximum duration
+func (r TickRate) ToDuration(ticks uint64) time.Duration {
+	nanoseconds := r.ToNanoseconds(ticks)
+	if nanoseconds > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(nanoseconds)
+}
+
+// FromTime converts a time to the number of ticks elapsed since the Unix epoch
+// Times before the epoch are converted to zero
+func (r TickRate) FromTime(t time.Time) uint64 {
+	nanoseconds := t.UnixNano()
+	if nanoseconds < 0 {
+		return 0
+	}
+	return r.FromNanoseconds(uint64(nanoseconds))
+}
+
+// ToTime converts a number of ticks elapsed since the Unix epoch to a time
+func (r TickRate) ToTime(ticks uint64) time.Time {
+	return time.Unix(0, int64(r.ToDuration(ticks)))
+}
+
+// WallClock returns a Clock which returns the number of ticks elapsed since the Unix epoch
+func (r TickRate) WallClock() Clock {
+	if r == TicksNanoseconds {
+		return WallClock
+	}
+	return func() uint64 {
+		return r.FromTime(time.Now())
+	}
+}
+
+// ConvertTicks converts a number of ticks from one tick rate to another, without losing precision
+// to intermediate overflow. It saturates at math.MaxUint64
+func ConvertTicks(ticks uint64, from TickRate, to TickRate) uint64 {
+	if from == to {
+		return ticks
+	}
+	if from == 0 {
+		return math.MaxUint64
+	}
+
+	hi, lo := bits.Mul64(ticks, uint64(to))
+	if hi >= uint64(from) {
+		return math.MaxUint64
+	}
+	quotient, _ := bits.Div64(hi, lo, uint64(from))
+	return quotient
+}
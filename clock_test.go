@@ -0,0 +1,36 @@
+package fxt_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickRate(t *testing.T) {
+	require.Equal(t, uint64(1500), fxt.TicksMicroseconds.FromDuration(1500*time.Microsecond))
+	require.Equal(t, uint64(0), fxt.TicksMicroseconds.FromDuration(-time.Second))
+	require.Equal(t, 3*time.Millisecond, fxt.TicksMilliseconds.ToDuration(3))
+
+	// A 3GHz TSC
+	tsc := fxt.TSCTickRate(3_000_000_000)
+	require.Equal(t, uint64(3000), tsc.FromNanoseconds(1000))
+	require.Equal(t, uint64(1000), tsc.ToNanoseconds(3000))
+
+	// Intermediate products don't overflow
+	require.Equal(t, uint64(math.MaxUint64/3), fxt.ConvertTicks(math.MaxUint64, tsc, fxt.TicksNanoseconds))
+	require.Equal(t, uint64(math.MaxUint64), fxt.ConvertTicks(math.MaxUint64, fxt.TicksNanoseconds, tsc))
+	require.Equal(t, time.Duration(math.MaxInt64), fxt.TicksNanoseconds.ToDuration(math.MaxUint64))
+
+	timestamp := time.Date(2023, 4, 5, 6, 7, 8, 9000, time.UTC)
+	ticks := fxt.TicksMicroseconds.FromTime(timestamp)
+	require.Equal(t, uint64(timestamp.UnixMicro()), ticks)
+	require.True(t, timestamp.Equal(fxt.TicksMicroseconds.ToTime(ticks)))
+
+	before := uint64(time.Now().UnixMilli())
+	now := fxt.TicksMilliseconds.WallClock()()
+	require.GreaterOrEqual(t, now, before)
+}
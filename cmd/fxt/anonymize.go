@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/richiesams/fxt"
+)
+
+// builtinPatterns are the kinds of sensitive data fxt anonymize knows how to find
+var builtinPatterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	// Absolute Unix and Windows paths, with at least two components so lone slashes in names are left alone
+	"path":  regexp.MustCompile(`(?:\b[A-Za-z]:|\B)(?:[/\\][^/\\\s?#"']+){2,}`),
+	"query": regexp.MustCompile(`\?[^\s#"']+`),
+}
+
+// stringListFlag is a flag that can be given more than once
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// anonymizer replaces the parts of strings that match any of its patterns
+type anonymizer struct {
+	patterns []*regexp.Regexp
+	// replace returns the replacement for a matching part of a string
+	replace func(match string) string
+
+	// rewritten caches the result for each string, so that each one is only matched once
+	rewritten map[string]string
+	changed   int
+}
+
+func newAnonymizer(patterns []*regexp.Regexp, mode string, salt string) (*anonymizer, error) {
+	a := &anonymizer{
+		patterns:  patterns,
+		rewritten: map[string]string{},
+	}
+
+	switch mode {
+	case "hash":
+		// Hashing keeps equal values equal, so they can still be correlated across the trace
+		a.replace = func(match string) string {
+			sum := sha256.Sum256([]byte(salt + match))
+			return "anon-" + hex.EncodeToString(sum[:6])
+		}
+	case "redact":
+		a.replace = func(match string) string {
+			return "<redacted>"
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode `%s` - expected hash or redact", mode)
+	}
+
+	return a, nil
+}
+
+// rewrite returns `str` with every match of the anonymizer's patterns replaced
+func (a *anonymizer) rewrite(str string) string {
+	if rewritten, ok := a.rewritten[str]; ok {
+		return rewritten
+	}
+
+	rewritten := str
+	for _, pattern := range a.patterns {
+		rewritten = pattern.ReplaceAllStringFunc(rewritten, a.replace)
+	}
+	a.rewritten[str] = rewritten
+	if rewritten != str {
+		a.changed++
+	}
+	return rewritten
+}
+
+func runAnonymize(args []string, stdout io.Writer, stderr io.Writer) int {
+	names := make([]string, 0, len(builtinPatterns))
+	for name := range builtinPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flags := newFlagSet("anonymize", "input.fxt", stderr)
+	output := flags.String("o", "anonymized.fxt", "the FXT file to write")
+	mode := flags.String("mode", "hash", "how to replace the sensitive parts of strings: hash or redact")
+	salt := flags.String("salt", "", "a secret added to values before hashing them, so they can't be guessed by hashing likely values")
+	builtins := flags.String("builtin", strings.Join(names, ","), "the comma separated built in patterns to use, out of "+strings.Join(names, ", "))
+	var custom stringListFlag
+	flags.Var(&custom, "pattern", "an extra regular expression to anonymize. Can be given more than once")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var patterns []*regexp.Regexp
+	for _, name := range strings.Split(*builtins, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		pattern, ok := builtinPatterns[name]
+		if !ok {
+			return fail(stderr, "anonymize", fmt.Errorf("unknown built in pattern `%s`", name))
+		}
+		patterns = append(patterns, pattern)
+	}
+	for _, expr := range custom {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return fail(stderr, "anonymize", fmt.Errorf("invalid -pattern - %w", err))
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	a, err := newAnonymizer(patterns, *mode, *salt)
+	if err != nil {
+		return fail(stderr, "anonymize", err)
+	}
+
+	dropped, err := anonymizeFile(flags.Arg(0), *output, a)
+	if err != nil {
+		return fail(stderr, "anonymize", err)
+	}
+	fmt.Fprintf(stdout, "anonymized %d strings\n", a.changed)
+	if dropped != 0 {
+		fmt.Fprintf(stdout, "dropped %d records that couldn't be decoded\n", dropped)
+	}
+	return 0
+}
+
+func anonymizeFile(inputPath string, outputPath string, a *anonymizer) (int, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s - %w", inputPath, err)
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s - %w", outputPath, err)
+	}
+	defer output.Close()
+
+	buffered := bufio.NewWriter(output)
+	dropped, err := anonymizeTrace(input, buffered, a)
+	if err != nil {
+		return 0, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write %s - %w", outputPath, err)
+	}
+	return dropped, output.Close()
+}
+
+// anonymizeTrace copies the trace in `r` to `w`, passing every string through the anonymizer
+//
+// Records that can't be decoded, such as log records, may hold strings that can't be found, so they're
+// dropped rather than copied as is. It returns the number of records dropped
+func anonymizeTrace(r io.Reader, w io.Writer, a *anonymizer) (int, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	tables, err := newTableWriter(w, reader)
+	if err != nil {
+		return 0, err
+	}
+	tables.rewrite = a.rewrite
+
+	dropped := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return dropped, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if record.Type == fxt.RecordTypeString || record.Type == fxt.RecordTypeThread {
+			continue
+		}
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+		if decoded == nil {
+			dropped++
+			continue
+		}
+
+		// The provider name is the only string that isn't a string reference
+		if provider, ok := decoded.(*fxt.ProviderInfoRecord); ok {
+			provider.Name = a.rewrite(provider.Name)
+		}
+		if err := tables.writeDecoded(decoded); err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymizeTrace(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddInstantEventWithArgs("http", "request", 1, 2, 100, map[string]interface{}{
+		"user": "someone@example.com",
+		"url":  "/api/users?id=1234",
+		"file": "open /home/someone/secrets.txt",
+	}))
+	require.NoError(t, writer.AddInstantEventWithArgs("http", "request", 1, 2, 200, map[string]interface{}{
+		"user": "someone@example.com",
+	}))
+
+	patterns := []*regexp.Regexp{builtinPatterns["email"], builtinPatterns["path"], builtinPatterns["query"]}
+	anonymize := func(mode string) []byte {
+		a, err := newAnonymizer(patterns, mode, "salt")
+		require.NoError(t, err)
+
+		var output bytes.Buffer
+		dropped, err := anonymizeTrace(bytes.NewReader(buffer.Bytes()), &output, a)
+		require.NoError(t, err)
+		require.Equal(t, 0, dropped)
+		require.Equal(t, 3, a.changed)
+		return output.Bytes()
+	}
+
+	for _, mode := range []string{"hash", "redact"} {
+		anonymized := anonymize(mode)
+		require.Equal(t, []string{"http/request@2", "http/request@2"}, readEvents(t, anonymized))
+		require.NotContains(t, string(anonymized), "someone")
+		require.NotContains(t, string(anonymized), "1234")
+		require.Contains(t, string(anonymized), "open ")
+		require.Contains(t, string(anonymized), "Provider")
+	}
+
+	// Hashing keeps equal values equal
+	hashed := anonymize("hash")
+	require.Regexp(t, `anon-[0-9a-f]{12}`, string(hashed))
+	require.Equal(t, hashed, anonymize("hash"))
+	require.Contains(t, string(anonymize("redact")), "<redacted>")
+
+	_, err = newAnonymizer(patterns, "scramble", "")
+	require.Error(t, err)
+}
+
+func TestAnonymizePatterns(t *testing.T) {
+	redact := func(name string, str string) string {
+		return builtinPatterns[name].ReplaceAllString(str, "X")
+	}
+
+	require.Equal(t, "mail X now", redact("email", "mail a.b+c@mail.example.org now"))
+	require.Equal(t, "open X", redact("path", "open /usr/local/bin"))
+	require.Equal(t, "open X", redact("path", `open C:\Users\someone`))
+	require.Equal(t, "http/request", redact("path", "http/request"))
+	require.Equal(t, "/apiX", redact("query", "/api?a=1&b=2"))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/richiesams/fxt"
+)
+
+func runBlobs(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "extract") {
+		fmt.Fprintln(stderr, "Usage: fxt blobs list trace.fxt")
+		fmt.Fprintln(stderr, "       fxt blobs extract [flags] trace.fxt")
+		return 2
+	}
+
+	flags := newFlagSet("blobs "+args[0], "trace.fxt", stderr)
+	output := flags.String("o", "blobs", "the directory to write the blobs to, for extract")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	reader, err := fxt.NewMmapReader(flags.Arg(0))
+	if err != nil {
+		return fail(stderr, "blobs", err)
+	}
+	defer reader.Close()
+
+	blobs, err := reader.Blobs()
+	if err != nil {
+		return fail(stderr, "blobs", err)
+	}
+
+	if args[0] == "list" {
+		printBlobs(stdout, blobs)
+		return 0
+	}
+	if err := extractBlobs(*output, blobs); err != nil {
+		return fail(stderr, "blobs", err)
+	}
+	fmt.Fprintf(stdout, "extracted %d blobs to %s\n", len(blobs), *output)
+	return 0
+}
+
+// printBlobs prints a table of the blobs, with the file names extract would write them to
+func printBlobs(w io.Writer, blobs []*fxt.Blob) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "OFFSET\tTYPE\tCATEGORY\tNAME\tRECORDS\tBYTES\tFILE")
+	for i, blob := range blobs {
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", blob.Offset, blobTypeName(blob), blob.Category, blob.Name, blob.Records, len(blob.Data), blobFileName(i, blob))
+	}
+	table.Flush()
+}
+
+// extractBlobs writes the payload of each blob to its own file in `dir`
+func extractBlobs(dir string, blobs []*fxt.Blob) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("failed to create %s - %w", dir, err)
+	}
+
+	for i, blob := range blobs {
+		path := filepath.Join(dir, blobFileName(i, blob))
+		if err := os.WriteFile(path, blob.Data, 0666); err != nil {
+			return fmt.Errorf("failed to write %s - %w", path, err)
+		}
+	}
+	return nil
+}
+
+func blobTypeName(blob *fxt.Blob) string {
+	if blob.Type == fxt.RecordTypeLargeBlob {
+		return "large blob"
+	}
+	return blob.BlobType.String()
+}
+
+// blobFileName is the name of the file the `index`th blob is extracted to. The blob's name is kept, so
+// files like screenshots keep their extension, but anything that could leave the output directory is replaced
+func blobFileName(index int, blob *fxt.Blob) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, blob.Name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "blob"
+	}
+	if blob.Type == fxt.RecordTypeBlob && blob.BlobType == fxt.BlobTypePerfetto && filepath.Ext(name) == "" {
+		name += ".perfetto-trace"
+	}
+	return fmt.Sprintf("%03d-%s", index, name)
+}
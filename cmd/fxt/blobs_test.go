@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobsCommand(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddBlobRecord("../escape", []byte("data"), fxt.BlobTypeData))
+	require.NoError(t, writer.AddLargeBlobEventRecord("Artifacts", "screenshot.png", 3, 4, 100, []byte("png")))
+	require.NoError(t, writer.Close())
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"blobs", "list", path}, &stdout, &stderr), stderr.String())
+	require.Contains(t, stdout.String(), "screenshot.png")
+	require.Contains(t, stdout.String(), "001-screenshot.png")
+
+	output := filepath.Join(tempDir, "blobs")
+	stdout.Reset()
+	require.Equal(t, 0, run([]string{"blobs", "extract", "-o", output, path}, &stdout, &stderr), stderr.String())
+	require.Contains(t, stdout.String(), "extracted 2 blobs")
+
+	// Names can't write outside the output directory
+	data, err := os.ReadFile(filepath.Join(output, "000-_escape"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), data)
+	data, err = os.ReadFile(filepath.Join(output, "001-screenshot.png"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("png"), data)
+
+	require.Equal(t, 2, run([]string{"blobs", path}, &stdout, &stderr))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+)
+
+func runCompile(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("compile", "description.yaml", stderr)
+	output := flags.String("o", "trace.fxt", "the FXT file to write")
+	tickRate := flags.Uint64("tick-rate", uint64(fxt.TicksNanoseconds), "the number of ticks per second of the timestamps in the description")
+	providerName := flags.String("provider", "fxt compile", "the name of the provider the trace is attributed to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := compile(flags.Arg(0), *output, fxt.TickRate(*tickRate), *providerName); err != nil {
+		return fail(stderr, "compile", err)
+	}
+	return 0
+}
+
+func compile(descriptionPath string, outputPath string, tickRate fxt.TickRate, providerName string) error {
+	description, err := os.Open(descriptionPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s - %w", descriptionPath, err)
+	}
+	defer description.Close()
+
+	writer, err := fxt.NewWriter(outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.AddProviderInfoRecord(1, providerName); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.AddProviderSectionRecord(1); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.AddTickRateRecord(tickRate); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := convert.FromDescription(description, writer); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to compile %s - %w", descriptionPath, err)
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tempDir := t.TempDir()
+	descriptionPath := filepath.Join(tempDir, "trace.yaml")
+	outputPath := filepath.Join(tempDir, "trace.fxt")
+	require.NoError(t, os.WriteFile(descriptionPath, []byte(`
+processes:
+  - pid: 1
+    threads:
+      - tid: 2
+        spans: [{category: c, name: n, begin: 0, end: 10}]
+`), 0666))
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"compile", "-o", outputPath, "-tick-rate", "1000", descriptionPath}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	file, err := os.Open(outputPath)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := fxt.NewReader(file)
+	require.NoError(t, err)
+
+	// Provider info, provider section, then the initialization record
+	var record *fxt.Record
+	for i := 0; i < 3; i++ {
+		record, err = reader.Next()
+		require.NoError(t, err)
+	}
+	decoded, err := record.Decode()
+	require.NoError(t, err)
+	require.Equal(t, &fxt.InitializationRecord{TicksPerSecond: 1000}, decoded)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 2, run([]string{"nope"}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "unknown command")
+}
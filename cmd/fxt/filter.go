@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/richiesams/fxt"
+)
+
+// recordFilter decides which records are kept by fxt filter
+// Unset fields match everything
+type recordFilter struct {
+	category *regexp.Regexp
+	name     *regexp.Regexp
+	pids     map[fxt.KernelObjectID]bool
+	tids     map[fxt.KernelObjectID]bool
+	from     uint64
+	to       uint64
+}
+
+func runFilter(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("filter", "input.fxt", stderr)
+	output := flags.String("o", "filtered.fxt", "the FXT file to write")
+	category := flags.String("category", "", "only keep events with a category matching this regular expression")
+	name := flags.String("name", "", "only keep events with a name matching this regular expression")
+	pids := flags.String("pid", "", "only keep events from these comma separated process IDs")
+	tids := flags.String("tid", "", "only keep events from these comma separated thread IDs")
+	from := flags.Uint64("from", 0, "only keep events and scheduling records at or after this timestamp, in ticks")
+	to := flags.Uint64("to", 0, "only keep events and scheduling records at or before this timestamp, in ticks. 0 means the end of the trace")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	filter := &recordFilter{from: *from, to: *to}
+	var err error
+	if *category != "" {
+		if filter.category, err = regexp.Compile(*category); err != nil {
+			return fail(stderr, "filter", fmt.Errorf("invalid -category - %w", err))
+		}
+	}
+	if *name != "" {
+		if filter.name, err = regexp.Compile(*name); err != nil {
+			return fail(stderr, "filter", fmt.Errorf("invalid -name - %w", err))
+		}
+	}
+	if filter.pids, err = parseKoids(*pids); err != nil {
+		return fail(stderr, "filter", fmt.Errorf("invalid -pid - %w", err))
+	}
+	if filter.tids, err = parseKoids(*tids); err != nil {
+		return fail(stderr, "filter", fmt.Errorf("invalid -tid - %w", err))
+	}
+
+	kept, total, err := filterFile(flags.Arg(0), *output, filter)
+	if err != nil {
+		return fail(stderr, "filter", err)
+	}
+	fmt.Fprintf(stdout, "kept %d of %d records\n", kept, total)
+	return 0
+}
+
+// parseKoids parses a comma separated list of kernel object IDs
+func parseKoids(list string) (map[fxt.KernelObjectID]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	koids := map[fxt.KernelObjectID]bool{}
+	for _, item := range strings.Split(list, ",") {
+		koid, err := strconv.ParseUint(strings.TrimSpace(item), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		koids[fxt.KernelObjectID(koid)] = true
+	}
+	return koids, nil
+}
+
+func filterFile(inputPath string, outputPath string, filter *recordFilter) (int, int, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open %s - %w", inputPath, err)
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create %s - %w", outputPath, err)
+	}
+	defer output.Close()
+
+	buffered := bufio.NewWriter(output)
+	kept, total, err := filterTrace(input, buffered, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write %s - %w", outputPath, err)
+	}
+	return kept, total, output.Close()
+}
+
+// filterTrace copies the records of the trace in `r` that match `filter` to `w`
+// Only the table entries referenced by the kept records are written. It returns the number of records
+// kept and the total, not counting string and thread records
+func filterTrace(r io.Reader, w io.Writer, filter *recordFilter) (int, int, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	tables, err := newTableWriter(w, reader)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	kept, total := 0, 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return kept, total, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if record.Type == fxt.RecordTypeString || record.Type == fxt.RecordTypeThread {
+			continue
+		}
+		total++
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+		if decoded != nil && !filter.matches(tables, decoded) {
+			continue
+		}
+
+		kept++
+		if decoded == nil {
+			err = tables.write(record.Data)
+		} else {
+			err = tables.writeDecoded(decoded)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// eventOf returns the EventRecord embedded in a record struct, or nil if it doesn't have one
+func eventOf(decoded interface{}) *fxt.EventRecord {
+	field := reflect.ValueOf(decoded).Elem().FieldByName("EventRecord")
+	if !field.IsValid() {
+		return nil
+	}
+	return field.Addr().Interface().(*fxt.EventRecord)
+}
+
+// matches reports whether a decoded record should be kept
+//
+// Events are matched against all of the filters. Scheduling records are only matched against the time range,
+// and kernel objects against the process / thread IDs. Everything else is kept
+func (f *recordFilter) matches(tables *tableWriter, decoded interface{}) bool {
+	if event := eventOf(decoded); event != nil {
+		thread := tables.resolveThread(event.Thread)
+		if f.pids != nil && !f.pids[thread.ProcessId] {
+			return false
+		}
+		if f.tids != nil && !f.tids[thread.ThreadId] {
+			return false
+		}
+		if f.category != nil && !f.category.MatchString(tables.resolveString(event.Category)) {
+			return false
+		}
+		if f.name != nil && !f.name.MatchString(tables.resolveString(event.Name)) {
+			return false
+		}
+
+		end := event.Timestamp
+		if complete, ok := decoded.(*fxt.DurationCompleteEvent); ok {
+			end = complete.EndTimestamp
+		}
+		return f.inTimeRange(event.Timestamp, end)
+	}
+
+	switch v := decoded.(type) {
+	case *fxt.ContextSwitchRecord:
+		return f.inTimeRange(v.Timestamp, v.Timestamp)
+	case *fxt.ThreadWakeupRecord:
+		return f.inTimeRange(v.Timestamp, v.Timestamp)
+	case *fxt.KernelObjectRecord:
+		switch v.Type {
+		case fxt.KernelObjectTypeProcess:
+			return f.pids == nil || f.pids[v.Koid]
+		case fxt.KernelObjectTypeThread:
+			return f.tids == nil || f.tids[v.Koid]
+		}
+	}
+
+	return true
+}
+
+// inTimeRange reports whether the time span from `begin` to `end` overlaps the filter's time range
+func (f *recordFilter) inTimeRange(begin uint64, end uint64) bool {
+	if end < f.from {
+		return false
+	}
+	return f.to == 0 || begin <= f.to
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents returns the resolved category, name, and thread ID of the events in a trace
+func readEvents(t *testing.T, trace []byte) []string {
+	reader, err := fxt.NewReader(bytes.NewReader(trace))
+	require.NoError(t, err)
+	tables := &tableWriter{reader: reader}
+
+	var events []string
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if decoded == nil {
+			continue
+		}
+		if event := eventOf(decoded); event != nil {
+			thread := tables.resolveThread(event.Thread)
+			events = append(events, fmt.Sprintf("%s/%s@%d", tables.resolveString(event.Category), tables.resolveString(event.Name), thread.ThreadId))
+		}
+	}
+}
+
+func TestFilterTrace(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.SetThreadName(1, 2, "main"))
+	require.NoError(t, writer.SetThreadName(1, 3, "worker"))
+	require.NoError(t, writer.AddInstantEvent("http", "request", 1, 2, 100))
+	require.NoError(t, writer.AddInstantEventWithArgs("db", "query", 1, 3, 200, map[string]interface{}{"table": "users"}))
+	require.NoError(t, writer.AddDurationCompleteEvent("http", "response", 1, 3, 250, 400))
+	require.NoError(t, writer.AddInstantEvent("db", "commit", 1, 2, 500))
+
+	filterToBytes := func(filter *recordFilter) []byte {
+		var output bytes.Buffer
+		_, _, err := filterTrace(bytes.NewReader(buffer.Bytes()), &output, filter)
+		require.NoError(t, err)
+		return output.Bytes()
+	}
+
+	filtered := filterToBytes(&recordFilter{category: regexp.MustCompile("^http$")})
+	require.Equal(t, []string{"http/request@2", "http/response@3"}, readEvents(t, filtered))
+	require.NotContains(t, string(filtered), "users")
+
+	filtered = filterToBytes(&recordFilter{tids: map[fxt.KernelObjectID]bool{3: true}})
+	require.Equal(t, []string{"db/query@3", "http/response@3"}, readEvents(t, filtered))
+	require.NotContains(t, string(filtered), "main")
+	require.Contains(t, string(filtered), "worker")
+
+	// Complete events are kept if they overlap the time range
+	filtered = filterToBytes(&recordFilter{from: 300, to: 450})
+	require.Equal(t, []string{"http/response@3"}, readEvents(t, filtered))
+
+	filtered = filterToBytes(&recordFilter{name: regexp.MustCompile("^q")})
+	require.Equal(t, []string{"db/query@3"}, readEvents(t, filtered))
+	require.Contains(t, string(filtered), "Provider")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+// traceSummary is what the first pass over a trace finds out, so the second pass can pick the records to keep
+type traceSummary struct {
+	numRecords   int
+	tickRate     fxt.TickRate
+	minTimestamp uint64
+	maxTimestamp uint64
+	hasTimestamp bool
+}
+
+func runHead(args []string, stdout io.Writer, stderr io.Writer) int {
+	return runHeadTail("head", args, stdout, stderr)
+}
+
+func runTail(args []string, stdout io.Writer, stderr io.Writer) int {
+	return runHeadTail("tail", args, stdout, stderr)
+}
+
+// runHeadTail runs fxt head or fxt tail, which only differ in which end of the trace is kept
+func runHeadTail(name string, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet(name, "input.fxt", stderr)
+	output := flags.String("o", name+".fxt", "the FXT file to write")
+	count := flags.Int("n", 1000, "the number of records to keep")
+	duration := flags.Duration("duration", 0, "keep the records in this much time at the "+map[string]string{"head": "start", "tail": "end"}[name]+" of the trace, instead of a number of records")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *count < 0 || *duration < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	kept, err := headTailFile(flags.Arg(0), *output, name == "tail", *count, *duration)
+	if err != nil {
+		return fail(stderr, name, err)
+	}
+	fmt.Fprintf(stdout, "kept %d records\n", kept)
+	return 0
+}
+
+func headTailFile(inputPath string, outputPath string, tail bool, count int, duration time.Duration) (int, error) {
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s - %w", inputPath, err)
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s - %w", outputPath, err)
+	}
+	defer output.Close()
+
+	buffered := bufio.NewWriter(output)
+	kept, err := headTail(input, buffered, tail, count, duration)
+	if err != nil {
+		return 0, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write %s - %w", outputPath, err)
+	}
+	return kept, output.Close()
+}
+
+// headTail copies the first or last `count` records of the trace in `r` to `w`. If `duration` is non-zero,
+// the records with timestamps in the first or last `duration` of the trace are copied instead
+//
+// Metadata, initialization, and kernel object records are always kept, along with the table entries
+// the kept records refer to. They aren't included in `count`, or the returned number of records kept
+func headTail(r io.ReadSeeker, w io.Writer, tail bool, count int, duration time.Duration) (int, error) {
+	// Only the head of the trace by number of records can be found in a single pass
+	summary := traceSummary{}
+	if tail || duration != 0 {
+		var err error
+		if summary, err = summarize(r); err != nil {
+			return 0, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("failed to seek to the start of the trace - %w", err)
+		}
+	}
+
+	var keep func(index int, decoded interface{}) bool
+	switch {
+	case duration != 0:
+		ticks := summary.tickRate.FromDuration(duration)
+		keep = func(index int, decoded interface{}) bool {
+			timestamp, ok := timestampOf(decoded)
+			if !ok {
+				return true
+			}
+			if tail {
+				return summary.maxTimestamp-timestamp <= ticks
+			}
+			return timestamp-summary.minTimestamp <= ticks
+		}
+	case tail:
+		keep = func(index int, decoded interface{}) bool {
+			return index >= summary.numRecords-count
+		}
+	default:
+		keep = func(index int, decoded interface{}) bool {
+			return index < count
+		}
+	}
+
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	tables, err := newTableWriter(w, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	index, kept := 0, 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return kept, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if record.Type == fxt.RecordTypeString || record.Type == fxt.RecordTypeThread {
+			continue
+		}
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+		if !alwaysKept(record, decoded) {
+			if !keep(index, decoded) {
+				// The records after the head are still read, for the ones that are always kept
+				index++
+				continue
+			}
+			index++
+			kept++
+		}
+
+		if decoded == nil {
+			err = tables.write(record.Data)
+		} else {
+			err = tables.writeDecoded(decoded)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// summarize counts the records of a trace that aren't always kept, and finds the range of timestamps
+func summarize(r io.Reader) (traceSummary, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return traceSummary{}, err
+	}
+
+	summary := traceSummary{tickRate: fxt.TicksNanoseconds}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		if err != nil {
+			return traceSummary{}, err
+		}
+		if record.Type == fxt.RecordTypeString || record.Type == fxt.RecordTypeThread {
+			continue
+		}
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return traceSummary{}, fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+		if initialization, ok := decoded.(*fxt.InitializationRecord); ok && initialization.TicksPerSecond != 0 {
+			summary.tickRate = initialization.TicksPerSecond
+		}
+		if alwaysKept(record, decoded) {
+			continue
+		}
+		summary.numRecords++
+
+		if timestamp, ok := timestampOf(decoded); ok {
+			if !summary.hasTimestamp || timestamp < summary.minTimestamp {
+				summary.minTimestamp = timestamp
+			}
+			if !summary.hasTimestamp || timestamp > summary.maxTimestamp {
+				summary.maxTimestamp = timestamp
+			}
+			summary.hasTimestamp = true
+		}
+	}
+}
+
+// alwaysKept reports whether a record describes the trace, rather than something that happened in it
+func alwaysKept(record *fxt.Record, decoded interface{}) bool {
+	switch decoded.(type) {
+	case *fxt.InitializationRecord, *fxt.KernelObjectRecord:
+		return true
+	}
+	return record.Type == fxt.RecordTypeMetadata
+}
+
+// timestampOf returns the timestamp of a decoded record, if it has one
+func timestampOf(decoded interface{}) (uint64, bool) {
+	switch v := decoded.(type) {
+	case nil:
+		return 0, false
+	case *fxt.ContextSwitchRecord:
+		return v.Timestamp, true
+	case *fxt.ThreadWakeupRecord:
+		return v.Timestamp, true
+	}
+
+	if event := eventOf(decoded); event != nil {
+		return event.Timestamp, true
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadTail(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksMicroseconds))
+	require.NoError(t, writer.SetThreadName(1, 2, "main"))
+	require.NoError(t, writer.AddInstantEvent("http", "request", 1, 2, 100))
+	require.NoError(t, writer.AddInstantEvent("db", "query", 1, 3, 200))
+	require.NoError(t, writer.AddInstantEvent("db", "commit", 1, 3, 1500))
+	require.NoError(t, writer.AddInstantEvent("http", "response", 1, 2, 3000))
+	// Kernel object records after the head are still kept
+	require.NoError(t, writer.SetThreadName(1, 3, "worker"))
+
+	headTailToBytes := func(tail bool, count int, duration time.Duration) []byte {
+		var output bytes.Buffer
+		_, err := headTail(bytes.NewReader(buffer.Bytes()), &output, tail, count, duration)
+		require.NoError(t, err)
+		return output.Bytes()
+	}
+
+	head := headTailToBytes(false, 2, 0)
+	require.Equal(t, []string{"http/request@2", "db/query@3"}, readEvents(t, head))
+	require.Contains(t, string(head), "Provider")
+	require.Contains(t, string(head), "main")
+	require.Contains(t, string(head), "worker")
+
+	tail := headTailToBytes(true, 1, 0)
+	require.Equal(t, []string{"http/response@2"}, readEvents(t, tail))
+	require.Contains(t, string(tail), "Provider")
+	require.NotContains(t, string(tail), "db")
+
+	// The timestamps are in microseconds
+	head = headTailToBytes(false, 0, 2*time.Millisecond)
+	require.Equal(t, []string{"http/request@2", "db/query@3", "db/commit@3"}, readEvents(t, head))
+
+	tail = headTailToBytes(true, 0, 1500*time.Microsecond)
+	require.Equal(t, []string{"db/commit@3", "http/response@2"}, readEvents(t, tail))
+
+	// Asking for more records than the trace has keeps all of them
+	require.Len(t, readEvents(t, headTailToBytes(true, 10, 0)), 4)
+}
@@ -0,0 +1,89 @@
+/*
+fxt is a tool for creating, checking, and inspecting FXT traces
+
+	fxt <command> [arguments]
+
+Run `fxt help` for the list of commands, and `fxt <command> -h` for the arguments of each one
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a subcommand of the fxt tool
+type command struct {
+	summary string
+	// run is called with the arguments after the command name. It returns the process exit code
+	run func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"anonymize":   {summary: "hash or redact emails, paths, and other sensitive strings in a trace", run: runAnonymize},
+	"blobs":       {summary: "list the blobs embedded in a trace, or extract them to files", run: runBlobs},
+	"compile":     {summary: "compile a YAML / JSON trace description into an FXT trace", run: runCompile},
+	"filter":      {summary: "copy the records of a trace that match a filter to a new trace", run: runFilter},
+	"head":        {summary: "copy the first records, or seconds, of a trace to a new trace", run: runHead},
+	"serve":       {summary: "serve a trace locally, and open it in the Perfetto UI", run: runServe},
+	"stats":       {summary: "count the records of each type in a trace, and list its providers and time span", run: runStats},
+	"tail":        {summary: "copy the last records, or seconds, of a trace to a new trace", run: runTail},
+	"top":         {summary: "list the longest spans, and the names with the most time spent in them", run: runTop},
+	"utilization": {summary: "report how much of the trace each thread spent busy, and running", run: runUtilization},
+	"validate":    {summary: "check traces against the spec, and list the problems", run: runValidate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "fxt: unknown command `%s`\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: fxt <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet creates the flag set for a command, with a usage line listing its positional arguments
+func newFlagSet(name string, positional string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("fxt "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: fxt %s [flags] %s\n", name, positional)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// fail prints an error for a command, and returns the exit code for it
+func fail(stderr io.Writer, name string, err error) int {
+	fmt.Fprintf(stderr, "fxt %s: %v\n", name, err)
+	return 1
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// perfettoOrigin is the Perfetto UI, which is allowed to fetch the trace from the local server
+const perfettoOrigin = "https://ui.perfetto.dev"
+
+// perfettoPort is the port the Perfetto UI's content security policy allows traces to be fetched from
+// See https://perfetto.dev/docs/visualization/deep-linking-to-perfetto-ui
+const perfettoPort = 9001
+
+// openBrowser opens `link` in the user's default browser
+var openBrowser = func(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
+
+func runServe(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("serve", "trace.fxt", stderr)
+	port := flags.Int("port", perfettoPort, "the port to serve the trace on. The Perfetto UI only fetches traces from port 9001")
+	noOpen := flags.Bool("no-open", false, "print the Perfetto UI link instead of opening it in a browser")
+	keep := flags.Bool("keep", false, "keep serving the trace after it has been fetched, so the page can be reloaded")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if _, err := os.Stat(path); err != nil {
+		return fail(stderr, "serve", err)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		return fail(stderr, "serve", fmt.Errorf("failed to listen on port %d - %w", *port, err))
+	}
+
+	open := openBrowser
+	if *noOpen {
+		open = func(link string) error {
+			fmt.Fprintf(stdout, "open %s\n", link)
+			return nil
+		}
+	}
+	if err := serveTrace(listener, path, !*keep, open, stdout); err != nil {
+		return fail(stderr, "serve", err)
+	}
+	return 0
+}
+
+// perfettoLink returns the link that makes the Perfetto UI fetch and open the trace at `traceURL`
+func perfettoLink(traceURL string) string {
+	return perfettoOrigin + "/#!/?url=" + traceURL
+}
+
+// serveTrace serves the trace at `path` on `listener`, and calls `open` with the Perfetto UI link to it
+//
+// If `once` is set, it returns after the trace has been fetched once. Otherwise it serves until the
+// listener fails
+func serveTrace(listener net.Listener, path string, once bool, open func(link string) error, stdout io.Writer) error {
+	name := filepath.Base(path)
+	traceURL := (&url.URL{Scheme: "http", Host: listener.Addr().String(), Path: "/" + name}).String()
+
+	fetched := make(chan struct{})
+	var fetchedOnce sync.Once
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", perfettoOrigin)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET")
+			w.Header().Set("Access-Control-Allow-Headers", "*")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Open the file for each request, so the trace can be rewritten while it's being served
+		file, err := os.Open(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.ServeContent(w, r, name, info.ModTime(), file)
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(stdout, "served %s to %s\n", name, r.RemoteAddr)
+			fetchedOnce.Do(func() { close(fetched) })
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	fmt.Fprintf(stdout, "serving %s at %s\n", path, traceURL)
+	if err := open(perfettoLink(traceURL)); err != nil {
+		server.Close()
+		return fmt.Errorf("failed to open the Perfetto UI - %w", err)
+	}
+
+	if !once {
+		fetched = nil
+	}
+	select {
+	case <-fetched:
+		// Shutdown waits for the response to finish being sent
+		return server.Shutdown(context.Background())
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
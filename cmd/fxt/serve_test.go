@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	require.NoError(t, os.WriteFile(path, []byte("trace data"), 0666))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	open := func(link string) error {
+		require.True(t, strings.HasPrefix(link, "https://ui.perfetto.dev/#!/?url=http://127.0.0.1:"))
+		traceURL := strings.TrimPrefix(link, "https://ui.perfetto.dev/#!/?url=")
+		require.True(t, strings.HasSuffix(traceURL, "/trace.fxt"))
+
+		// The Perfetto UI is on a different origin, so it needs CORS
+		response, err := http.Get(traceURL)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "https://ui.perfetto.dev", response.Header.Get("Access-Control-Allow-Origin"))
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, "trace data", string(body))
+		return nil
+	}
+
+	// Returns once the trace has been fetched
+	require.NoError(t, serveTrace(listener, path, true, open, &stdout))
+	require.Contains(t, stdout.String(), "served trace.fxt")
+
+	_, err = http.Get("http://" + listener.Addr().String() + "/trace.fxt")
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/richiesams/fxt"
+)
+
+func runStats(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("stats", "trace.fxt", stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fail(stderr, "stats", fmt.Errorf("failed to open %s - %w", flags.Arg(0), err))
+	}
+	defer file.Close()
+
+	stats, err := readStats(file)
+	if err != nil {
+		return fail(stderr, "stats", err)
+	}
+	printStats(stdout, stats)
+	return 0
+}
+
+// readStats reads every record of a trace, and returns the Reader's statistics
+func readStats(r io.Reader) (fxt.Stats, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return fxt.Stats{}, err
+	}
+	for {
+		if _, err := reader.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return reader.Stats(), nil
+			}
+			return fxt.Stats{}, err
+		}
+	}
+}
+
+// printStats prints the totals, time span, and providers of a trace, and a table of the record types
+func printStats(w io.Writer, stats fxt.Stats) {
+	fmt.Fprintf(w, "records:    %d (%d bytes)\n", stats.Records, stats.Bytes)
+	if stats.HasTimestamps {
+		fmt.Fprintf(w, "timestamps: %d - %d", stats.FirstTimestamp, stats.LastTimestamp)
+		if stats.TicksPerSecond != 0 {
+			fmt.Fprintf(w, " (%v)", stats.Duration())
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "strings:    %d\n", stats.Strings)
+	fmt.Fprintf(w, "threads:    %d\n", stats.Threads)
+	for _, provider := range stats.Providers {
+		fmt.Fprintf(w, "provider:   %d %s\n", provider.Id, provider.Name)
+	}
+
+	recordTypes := make([]fxt.RecordType, 0, len(stats.RecordTypes))
+	for recordType := range stats.RecordTypes {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Slice(recordTypes, func(i, j int) bool {
+		return recordTypes[i] < recordTypes[j]
+	})
+
+	fmt.Fprintln(w)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TYPE\tCOUNT\tBYTES")
+	for _, recordType := range recordTypes {
+		fmt.Fprintf(table, "%s\t%d\t%d\n", recordType, stats.RecordTypes[recordType].Count, stats.RecordTypes[recordType].Bytes)
+	}
+	table.Flush()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, writer.AddProviderInfoRecord(3, "provider"))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 100))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 1100))
+	require.NoError(t, writer.Close())
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"stats", path}, &stdout, &stderr), stderr.String())
+	require.Contains(t, stdout.String(), "timestamps: 100 - 1100 (1µs)")
+	require.Contains(t, stdout.String(), "provider:   3 provider")
+	require.Regexp(t, `event\s+2\s+32`, stdout.String())
+
+	require.Equal(t, 1, run([]string{"stats", filepath.Join(t.TempDir(), "missing.fxt")}, &stdout, &stderr))
+}
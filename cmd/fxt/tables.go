@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/richiesams/fxt"
+)
+
+// maxStringIndex and maxThreadIndex are the largest indices that fit in string and thread references
+const (
+	maxStringIndex = 0x7FFF
+	maxThreadIndex = 0xFF
+)
+
+var (
+	stringRefType = reflect.TypeOf(fxt.StringRef{})
+	threadRefType = reflect.TypeOf(fxt.ThreadRef{})
+	argumentsType = reflect.TypeOf([]fxt.Argument{})
+)
+
+// tableWriter copies records from a Reader to a new trace. The string and thread tables of the new trace
+// are built up as records that use them are written, so table entries that aren't referenced by any of
+// the copied records are dropped
+type tableWriter struct {
+	out    io.Writer
+	reader *fxt.Reader
+
+	strings map[string]uint16
+	threads map[fxt.Thread]uint8
+
+	// rewrite, if set, changes each string before it's added to the new trace
+	rewrite func(str string) string
+}
+
+func newTableWriter(out io.Writer, reader *fxt.Reader) (*tableWriter, error) {
+	magic, err := fxt.MagicNumberRecord(reader.Version())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(magic); err != nil {
+		return nil, fmt.Errorf("failed to write magic number record - %w", err)
+	}
+
+	return &tableWriter{
+		out:     out,
+		reader:  reader,
+		strings: map[string]uint16{},
+		threads: map[fxt.Thread]uint8{},
+	}, nil
+}
+
+// writeDecoded writes a record struct that refers to the Reader's tables
+func (t *tableWriter) writeDecoded(decoded interface{ MarshalBinary() ([]byte, error) }) error {
+	if err := t.remap(reflect.ValueOf(decoded).Elem()); err != nil {
+		return err
+	}
+
+	data, err := decoded.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return t.write(data)
+}
+
+// remap changes the string and thread references in the struct `value` from the Reader's tables to the new ones
+func (t *tableWriter) remap(value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+
+		switch {
+		case value.Type().Field(i).Anonymous:
+			if err := t.remap(field); err != nil {
+				return err
+			}
+		case field.Type() == stringRefType:
+			ref, err := t.remapString(field.Interface().(fxt.StringRef))
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(ref))
+		case field.Type() == threadRefType:
+			ref, err := t.remapThread(field.Interface().(fxt.ThreadRef))
+			if err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(ref))
+		case field.Type() == argumentsType:
+			arguments := field.Interface().([]fxt.Argument)
+			for j := range arguments {
+				key, err := t.remapString(arguments[j].Key)
+				if err != nil {
+					return err
+				}
+				arguments[j].Key = key
+
+				if str, ok := arguments[j].Value.(fxt.StringRef); ok {
+					if arguments[j].Value, err = t.remapString(str); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveString returns the string that `ref` refers to in the Reader's string table
+func (t *tableWriter) resolveString(ref fxt.StringRef) string {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	str, _ := t.reader.LookupString(ref.Index)
+	return str
+}
+
+// resolveThread returns the thread that `ref` refers to in the Reader's thread table
+func (t *tableWriter) resolveThread(ref fxt.ThreadRef) fxt.Thread {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	thread, _ := t.reader.LookupThread(uint16(ref.Index))
+	return thread
+}
+
+func (t *tableWriter) remapString(ref fxt.StringRef) (fxt.StringRef, error) {
+	str := t.resolveString(ref)
+	if t.rewrite != nil {
+		str = t.rewrite(str)
+	}
+	return t.internString(str)
+}
+
+// internString returns a reference to `str` in the new string table, adding it if needed
+// Strings that don't fit in the table are written inline
+func (t *tableWriter) internString(str string) (fxt.StringRef, error) {
+	if str == "" {
+		return fxt.StringRef{}, nil
+	}
+	if index, ok := t.strings[str]; ok {
+		return fxt.StringRef{Index: index}, nil
+	}
+
+	index := uint16(len(t.strings) + 1)
+	if index > maxStringIndex || len(str) > fxt.MaxStringLength {
+		return fxt.StringRef{Inline: str}, nil
+	}
+	data, err := fxt.StringRecord{Index: index, Value: str}.MarshalBinary()
+	if err != nil {
+		return fxt.StringRef{}, err
+	}
+	if err := t.write(data); err != nil {
+		return fxt.StringRef{}, err
+	}
+	t.strings[str] = index
+
+	return fxt.StringRef{Index: index}, nil
+}
+
+func (t *tableWriter) remapThread(ref fxt.ThreadRef) (fxt.ThreadRef, error) {
+	thread := t.resolveThread(ref)
+	if index, ok := t.threads[thread]; ok {
+		return fxt.ThreadRef{Index: index}, nil
+	}
+
+	if len(t.threads) >= maxThreadIndex {
+		return fxt.ThreadRef{Inline: thread}, nil
+	}
+	index := uint8(len(t.threads) + 1)
+	data, err := fxt.ThreadRecord{Index: index, ProcessId: thread.ProcessId, ThreadId: thread.ThreadId}.MarshalBinary()
+	if err != nil {
+		return fxt.ThreadRef{}, err
+	}
+	if err := t.write(data); err != nil {
+		return fxt.ThreadRef{}, err
+	}
+	t.threads[thread] = index
+
+	return fxt.ThreadRef{Index: index}, nil
+}
+
+func (t *tableWriter) write(data []byte) error {
+	if _, err := t.out.Write(data); err != nil {
+		return fmt.Errorf("failed to write record - %w", err)
+	}
+	return nil
+}
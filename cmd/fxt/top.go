@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/richiesams/fxt/analysis"
+)
+
+func runTop(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("top", "trace.fxt", stderr)
+	k := flags.Int("k", 10, "the number of spans and names to list")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *k <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fail(stderr, "top", fmt.Errorf("failed to open %s - %w", flags.Arg(0), err))
+	}
+	defer file.Close()
+
+	report, err := analysis.Top(file, *k)
+	if err != nil {
+		return fail(stderr, "top", err)
+	}
+	printTop(stdout, report)
+	return 0
+}
+
+// printTop prints the longest spans and the hottest names as two tables
+func printTop(w io.Writer, report *analysis.TopReport) {
+	fmt.Fprintln(w, "longest spans:")
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "DURATION\tCATEGORY\tNAME\tPID\tTID\tSTART\tTIMESTAMP")
+	for _, span := range report.Longest {
+		fmt.Fprintf(table, "%v\t%s\t%s\t%d\t%d\t+%v\t%d\n", span.Duration, span.Category, span.Name, span.Thread.ProcessId, span.Thread.ThreadId, span.Start, span.Timestamp)
+	}
+	table.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "hottest names:")
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TOTAL\tCOUNT\tLONGEST\tCATEGORY\tNAME")
+	for _, total := range report.Hottest {
+		fmt.Fprintf(table, "%v\t%d\t%v\t%s\t%s\n", total.Total, total.Count, total.Longest, total.Category, total.Name)
+	}
+	table.Flush()
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddDurationCompleteEvent("db", "query", 1, 2, 100, 400))
+	require.NoError(t, writer.AddDurationCompleteEvent("db", "query", 1, 3, 200, 300))
+	require.NoError(t, writer.AddDurationCompleteEvent("http", "request", 1, 2, 500, 550))
+	require.NoError(t, writer.Close())
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"top", "-k", "1", path}, &stdout, &stderr))
+	require.Regexp(t, `300ns\s+db\s+query\s+1\s+2\s+\+0s\s+100\n\n`, stdout.String())
+	require.Regexp(t, `400ns\s+2\s+300ns\s+db\s+query\n$`, stdout.String())
+	require.NotContains(t, stdout.String(), "request")
+}
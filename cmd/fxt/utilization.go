@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/richiesams/fxt/analysis"
+)
+
+func runUtilization(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("utilization", "trace.fxt", stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fail(stderr, "utilization", fmt.Errorf("failed to open %s - %w", flags.Arg(0), err))
+	}
+	defer file.Close()
+
+	report, err := analysis.Utilization(file)
+	if err != nil {
+		return fail(stderr, "utilization", err)
+	}
+	printUtilization(stdout, report)
+	return 0
+}
+
+// printUtilization prints a utilization report as a table, with a row for each thread
+func printUtilization(w io.Writer, report *analysis.UtilizationReport) {
+	fmt.Fprintf(w, "trace duration: %v\n\n", report.Duration)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "PID\tTID\tNAME\tBUSY\tBUSY %\t"
+	if report.HasScheduling {
+		header += "RUNNING\tRUNNING %\t"
+	}
+	fmt.Fprintln(table, header)
+
+	for _, thread := range report.Threads {
+		fmt.Fprintf(table, "%d\t%d\t%s\t%v\t%.1f\t", thread.Thread.ProcessId, thread.Thread.ThreadId, thread.Name, thread.Busy, thread.BusyFraction*100)
+		if report.HasScheduling {
+			fmt.Fprintf(table, "%v\t%.1f\t", thread.Running, thread.RunningFraction*100)
+		}
+		fmt.Fprintln(table)
+	}
+	table.Flush()
+}
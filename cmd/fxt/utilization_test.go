@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUtilizationCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.SetThreadName(1, 2, "main"))
+	require.NoError(t, writer.AddDurationCompleteEvent("cat", "work", 1, 2, 0, 250))
+	require.NoError(t, writer.AddInstantEvent("cat", "done", 1, 3, 1000))
+	require.NoError(t, writer.Close())
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"utilization", path}, &stdout, &stderr))
+	require.Contains(t, stdout.String(), "trace duration: 1µs")
+	require.Regexp(t, `1\s+2\s+main\s+250ns\s+25.0`, stdout.String())
+	require.NotContains(t, stdout.String(), "RUNNING")
+}
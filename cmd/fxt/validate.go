@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/richiesams/fxt"
+)
+
+func runValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("validate", "trace.fxt...", stderr)
+	maxViolations := flags.Int("max", 100, "the maximum number of violations to list per trace. 0 lists them all")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, path := range flags.Args() {
+		violations, numRecords, err := validateFile(path)
+		if err != nil {
+			return fail(stderr, "validate", err)
+		}
+
+		if len(violations) == 0 {
+			fmt.Fprintf(stdout, "%s: ok - %d records\n", path, numRecords)
+			continue
+		}
+
+		code = 1
+		fmt.Fprintf(stdout, "%s: %d violations in %d records\n", path, len(violations), numRecords)
+		for i, v := range violations {
+			if *maxViolations > 0 && i == *maxViolations {
+				fmt.Fprintf(stdout, "  ... and %d more\n", len(violations)-i)
+				break
+			}
+			fmt.Fprintf(stdout, "  %s\n", v)
+		}
+	}
+
+	return code
+}
+
+func validateFile(path string) ([]fxt.Diagnostic, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open %s - %w", path, err)
+	}
+	defer file.Close()
+
+	return validate(file)
+}
+
+// validate checks every record in a trace against the spec, and returns all the problems it finds,
+// and the number of records it checked
+func validate(r io.Reader) ([]fxt.Diagnostic, int, error) {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return []fxt.Diagnostic{{Err: err}}, 0, nil
+	}
+
+	diagnostics, err := reader.Check()
+	if err != nil {
+		return nil, 0, err
+	}
+	return diagnostics, reader.Stats().Records, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, writer.AddInstantEventWithArgs("Category", "Event", 3, 4, 100, map[string]interface{}{"key": int32(5)}))
+	trace := buffer.Bytes()
+
+	violations, numRecords, err := validate(bytes.NewReader(trace))
+	require.NoError(t, err)
+	require.Empty(t, violations)
+	require.Equal(t, 6, numRecords)
+
+	// Non-zero padding after the "Event" string. The magic number and initialization records are 3 words,
+	// the "Category" string record is 2 words, and "Event" is the first 5 bytes of the next one's second word
+	corrupt := append([]byte(nil), trace...)
+	corrupt[(3+2+1)*8+6] = 0xFF
+	violations, _, err = validate(bytes.NewReader(corrupt))
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+	require.Equal(t, int64(5*8), violations[0].RecordOffset)
+
+	// Undefined references don't stop validation
+	undefined := append([]byte(nil), trace[:3*8]...)
+	undefined = append(undefined, trace[(3+2+2)*8:]...)
+	violations, numRecords, err = validate(bytes.NewReader(undefined))
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+	require.Equal(t, 4, numRecords)
+}
+
+func TestValidateCommand(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 100))
+	require.NoError(t, writer.Close())
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"validate", path}, &stdout, &stderr))
+	require.Contains(t, stdout.String(), "ok")
+
+	require.NoError(t, os.WriteFile(path, []byte("not a trace"), 0666))
+	stdout.Reset()
+	require.Equal(t, 1, run([]string{"validate", path}, &stdout, &stderr))
+	require.Contains(t, stdout.String(), "1 violations")
+}
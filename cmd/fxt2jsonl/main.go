@@ -0,0 +1,188 @@
+/*
+fxt2jsonl dumps the records of an FXT trace as JSON, one object per line
+
+	fxt2jsonl [-resolve] [trace.fxt]
+
+The trace is read from stdin if no file is given. Each line has the offset, type, and size of the record,
+along with its decoded fields. With -resolve, string and thread references are also looked up in the
+string and thread tables, and added under "resolved". The output is meant for piping into jq:
+
+	fxt2jsonl -resolve trace.fxt | jq 'select(.kind == "InstantEvent") | .resolved.name'
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/richiesams/fxt"
+)
+
+func main() {
+	resolve := flag.Bool("resolve", false, "look up string and thread references, and add the resolved values to each record")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-resolve] [trace.fxt]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s - %v\n", flag.Arg(0), err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	err := dumpRecords(input, output, *resolve)
+	if flushErr := output.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// jsonRecord is the JSON object written for each record
+type jsonRecord struct {
+	Offset      int64  `json:"offset"`
+	Type        string `json:"type"`
+	SizeInWords int    `json:"size_in_words"`
+	Header      uint64 `json:"header"`
+	// Kind is the name of the record struct the record decodes to, or empty if it has none
+	Kind     string                 `json:"kind,omitempty"`
+	Fields   interface{}            `json:"fields,omitempty"`
+	Resolved map[string]interface{} `json:"resolved,omitempty"`
+}
+
+// dumpRecords writes each record of the trace in `r` to `w` as a line of JSON
+func dumpRecords(r io.Reader, w io.Writer, resolve bool) error {
+	reader, err := fxt.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line := jsonRecord{
+			Offset:      record.Offset,
+			Type:        record.Type.String(),
+			SizeInWords: len(record.Data) / 8,
+			Header:      record.Header(),
+		}
+
+		decoded, err := record.Decode()
+		if err != nil {
+			return fmt.Errorf("failed to decode %s record at offset %d - %w", record.Type, record.Offset, err)
+		}
+		if decoded != nil {
+			line.Kind = reflect.TypeOf(decoded).Elem().Name()
+			line.Fields = decoded
+			if resolve {
+				line.Resolved = resolveRecord(reader, decoded)
+			}
+		}
+
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to write record at offset %d - %w", record.Offset, err)
+		}
+	}
+}
+
+var (
+	stringRefType = reflect.TypeOf(fxt.StringRef{})
+	threadRefType = reflect.TypeOf(fxt.ThreadRef{})
+	argumentsType = reflect.TypeOf([]fxt.Argument{})
+)
+
+// resolveRecord looks up the string and thread references of the record struct `decoded`
+// The result is keyed by the snake case field names, like "category" or "thread"
+func resolveRecord(reader *fxt.Reader, decoded interface{}) map[string]interface{} {
+	resolved := map[string]interface{}{}
+
+	var addFields func(value reflect.Value)
+	addFields = func(value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			fieldValue := value.Field(i)
+
+			switch {
+			case field.Anonymous:
+				addFields(fieldValue)
+			case field.Type == stringRefType:
+				resolved[snakeCase(field.Name)] = resolveString(reader, fieldValue.Interface().(fxt.StringRef))
+			case field.Type == threadRefType:
+				resolved[snakeCase(field.Name)] = resolveThread(reader, fieldValue.Interface().(fxt.ThreadRef))
+			case field.Type == argumentsType:
+				arguments := map[string]interface{}{}
+				for _, argument := range fieldValue.Interface().([]fxt.Argument) {
+					value := argument.Value
+					if str, ok := value.(fxt.StringRef); ok {
+						value = resolveString(reader, str)
+					}
+					arguments[resolveString(reader, argument.Key)] = value
+				}
+				resolved["arguments"] = arguments
+			}
+		}
+	}
+	addFields(reflect.ValueOf(decoded).Elem())
+
+	return resolved
+}
+
+func resolveString(reader *fxt.Reader, ref fxt.StringRef) string {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	str, _ := reader.LookupString(ref.Index)
+	return str
+}
+
+func resolveThread(reader *fxt.Reader, ref fxt.ThreadRef) fxt.Thread {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	thread, _ := reader.LookupThread(uint16(ref.Index))
+	return thread
+}
+
+// snakeCase converts a Go field name, like ProviderId, to snake case, like provider_id
+func snakeCase(name string) string {
+	var result []byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				result = append(result, '_')
+			}
+			c += 'a' - 'A'
+		}
+		result = append(result, c)
+	}
+	return string(result)
+}
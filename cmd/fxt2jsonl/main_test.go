@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpRecords(t *testing.T) {
+	var trace bytes.Buffer
+	writer, err := fxt.NewWriterTo(&trace)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddInstantEventWithArgs("Category", "Event", 3, 4, 100, map[string]interface{}{"key": "value"}))
+
+	var output bytes.Buffer
+	require.NoError(t, dumpRecords(bytes.NewReader(trace.Bytes()), &output, true))
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	// Provider info, 4 strings, a thread, and the event
+	require.Len(t, lines, 7)
+	require.Equal(t, "metadata", lines[0]["type"])
+	require.Equal(t, "ProviderInfoRecord", lines[0]["kind"])
+
+	event := lines[6]
+	require.Equal(t, "event", event["type"])
+	require.Equal(t, "InstantEvent", event["kind"])
+	require.Equal(t, map[string]interface{}{
+		"category":  "Category",
+		"name":      "Event",
+		"thread":    map[string]interface{}{"ProcessId": float64(3), "ThreadId": float64(4)},
+		"arguments": map[string]interface{}{"key": "value"},
+	}, event["resolved"])
+}
+
+func TestSnakeCase(t *testing.T) {
+	require.Equal(t, "provider_id", snakeCase("ProviderId"))
+	require.Equal(t, "name", snakeCase("Name"))
+}
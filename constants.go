@@ -4,20 +4,21 @@ var (
 	fxtMagic = []byte{0x10, 0x00, 0x04, 0x46, 0x78, 0x54, 0x16, 0x00}
 )
 
-type recordType int
+// RecordType identifies the type of a record. It's stored in the lowest 4 bits of every record header
+type RecordType int
 
 const (
-	recordTypeMetadata        recordType = 0
-	recordTypeInitialization  recordType = 1
-	recordTypeString          recordType = 2
-	recordTypeThread          recordType = 3
-	recordTypeEvent           recordType = 4
-	recordTypeBlob            recordType = 5
-	recordTypeUserspaceObject recordType = 6
-	recordTypeKernelObject    recordType = 7
-	recordTypeScheduling      recordType = 8
-	recordTypeLog             recordType = 9
-	recordTypeLargeBlob       recordType = 15
+	RecordTypeMetadata        RecordType = 0
+	RecordTypeInitialization  RecordType = 1
+	RecordTypeString          RecordType = 2
+	RecordTypeThread          RecordType = 3
+	RecordTypeEvent           RecordType = 4
+	RecordTypeBlob            RecordType = 5
+	RecordTypeUserspaceObject RecordType = 6
+	RecordTypeKernelObject    RecordType = 7
+	RecordTypeScheduling      RecordType = 8
+	RecordTypeLog             RecordType = 9
+	RecordTypeLargeBlob       RecordType = 15
 )
 
 type metadataType int
@@ -28,19 +29,20 @@ const (
 	metadataTypeProviderEvent   metadataType = 3
 )
 
-type argumentType int
+// ArgumentType identifies the type of an argument's value. It's stored in the lowest 4 bits of the argument header
+type ArgumentType int
 
 const (
-	argumentTypeNull    argumentType = 0
-	argumentTypeInt32   argumentType = 1
-	argumentTypeUInt32  argumentType = 2
-	argumentTypeInt64   argumentType = 3
-	argumentTypeUInt64  argumentType = 4
-	argumentTypeDouble  argumentType = 5
-	argumentTypeString  argumentType = 6
-	argumentTypePointer argumentType = 7
-	argumentTypeKOID    argumentType = 8
-	argumentTypeBool    argumentType = 9
+	ArgumentTypeNull    ArgumentType = 0
+	ArgumentTypeInt32   ArgumentType = 1
+	ArgumentTypeUint32  ArgumentType = 2
+	ArgumentTypeInt64   ArgumentType = 3
+	ArgumentTypeUint64  ArgumentType = 4
+	ArgumentTypeDouble  ArgumentType = 5
+	ArgumentTypeString  ArgumentType = 6
+	ArgumentTypePointer ArgumentType = 7
+	ArgumentTypeKOID    ArgumentType = 8
+	ArgumentTypeBool    ArgumentType = 9
 )
 
 type eventType int
@@ -59,25 +61,64 @@ const (
 	eventTypeFlowEnd          eventType = 10
 )
 
-type providerEventType int
+// ProviderEventType identifies the event in a provider event metadata record
+type ProviderEventType int
 
 const (
-	providerEventTypeBufferFilledUp providerEventType = 0
+	// ProviderEventTypeBufferFilledUp means the provider's buffer filled up, and records were dropped
+	ProviderEventTypeBufferFilledUp ProviderEventType = 0
 )
 
-type koidType int
+// KernelObjectType is the type of the object described by a kernel object record
+type KernelObjectType int
 
 const (
-	koidTypeProcess koidType = 1
-	koidTypeThread  koidType = 2
+	// KernelObjectTypeProcess is a process
+	KernelObjectTypeProcess KernelObjectType = 1
+	// KernelObjectTypeThread is a thread
+	KernelObjectTypeThread KernelObjectType = 2
 )
 
+// maxRecordSizeInWords is the largest size, in 8 byte words, that fits in the size field of a record header
+const maxRecordSizeInWords = 0xFFF
+
+// MaxStringLength is the longest string, in bytes, that can be stored in a string record
+const MaxStringLength = 0xFF
+
+// BlobType identifies the format of the payload of a blob record
+//
+// The spec defines BlobTypeData, BlobTypeLastBranch, and BlobTypePerfetto. The field is 8 bits, so
+// any other value from 1 up to MaxBlobType can be used for tooling-specific payloads. Viewers will
+// ignore blob types they don't understand
 type BlobType int
 
+// MaxBlobType is the largest value that fits in the blob type field of a blob record
+const MaxBlobType BlobType = 0xFF
+
+// MaxBlobSize is the largest payload a single blob record can hold
+// The record size field is 12 bits of 8 byte words, one of which is the header
+const MaxBlobSize = (0xFFF - 1) * 8
+
 const (
-	BlobTypeData       BlobType = 1
+	// BlobTypeData is an arbitrary, uninterpreted payload
+	BlobTypeData BlobType = 1
+	// BlobTypeLastBranch is a CPU last branch record buffer
 	BlobTypeLastBranch BlobType = 2
-	BlobTypePerfetto   BlobType = 3
+	// BlobTypePerfetto is a serialized perfetto.protos.Trace message
+	BlobTypePerfetto BlobType = 3
+)
+
+type largeRecordType int
+
+const (
+	largeRecordTypeBlob largeRecordType = 0
+)
+
+type largeBlobFormat int
+
+const (
+	largeBlobFormatMetadata   largeBlobFormat = 0
+	largeBlobFormatNoMetadata largeBlobFormat = 1
 )
 
 type schedulingRecordType int
@@ -0,0 +1,139 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/richiesams/fxt"
+)
+
+// FromAtrace converts Android atrace / systrace text captures
+//
+// The userspace markers written to tracing_mark_write are converted as follows, in the "atrace" category:
+//   - B|pid|name becomes a duration begin event
+//   - E|pid becomes a duration end event, closing the most recent begin on the thread
+//   - C|pid|name|value becomes a counter event, with the value in the "value" argument
+//   - S|pid|name|cookie and F|pid|name|cookie become async begin and end events
+//
+// Any scheduling events in the capture are converted as in FromFtrace. The HTML files produced by systrace
+// can be passed directly, since only the embedded trace text lines are recognized
+func FromAtrace(r io.Reader, writer *fxt.Writer) error {
+	converter := &atraceConverter{
+		ftrace: &ftraceConverter{
+			writer:       writer,
+			processIds:   map[uint64]uint64{},
+			namedThreads: map[uint64]string{},
+		},
+		stacks: map[uint64][]string{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if err := converter.convertLine(scanner.Text()); err != nil {
+			return fmt.Errorf("failed to convert line %d - %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read atrace input - %w", err)
+	}
+
+	return nil
+}
+
+type atraceConverter struct {
+	ftrace *ftraceConverter
+
+	// stacks holds the names of the open duration events on each thread
+	stacks map[uint64][]string
+}
+
+func (c *atraceConverter) convertLine(line string) error {
+	match := ftraceLine.FindStringSubmatch(line)
+	if match == nil || match[7] != "tracing_mark_write" {
+		return c.ftrace.convertLine(line)
+	}
+
+	comm, tidStr, seconds, fraction, marker := match[1], match[2], match[5], match[6], match[8]
+
+	tid, _ := strconv.ParseUint(tidStr, 10, 64)
+	timestamp, err := ftraceTimestamp(seconds, fraction)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Split(strings.TrimSpace(marker), "|")
+	pid := tid
+	if len(fields) > 1 {
+		if parsed, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			pid = parsed
+		}
+	}
+	c.ftrace.processIds[tid] = pid
+
+	if err := c.ftrace.nameThread(tid, comm); err != nil {
+		return err
+	}
+
+	processId := fxt.KernelObjectID(pid)
+	threadId := fxt.KernelObjectID(tid)
+	writer := c.ftrace.writer
+
+	switch fields[0] {
+	case "B":
+		if len(fields) < 3 {
+			return fmt.Errorf("malformed begin marker `%s`", marker)
+		}
+		name := truncate(strings.Join(fields[2:], "|"))
+		c.stacks[tid] = append(c.stacks[tid], name)
+		return writer.AddDurationBeginEvent("atrace", name, processId, threadId, timestamp)
+	case "E":
+		stack := c.stacks[tid]
+		if len(stack) == 0 {
+			// The begin happened before the capture started
+			return nil
+		}
+		name := stack[len(stack)-1]
+		c.stacks[tid] = stack[:len(stack)-1]
+		return writer.AddDurationEndEvent("atrace", name, processId, threadId, timestamp)
+	case "C":
+		if len(fields) < 4 {
+			return fmt.Errorf("malformed counter marker `%s`", marker)
+		}
+		value, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed counter value in `%s`", marker)
+		}
+		return writer.AddCounterEvent("atrace", truncate(fields[2]), processId, threadId, timestamp, map[string]interface{}{"value": value}, 0)
+	case "S", "F":
+		if len(fields) < 4 {
+			return fmt.Errorf("malformed async marker `%s`", marker)
+		}
+		name := truncate(fields[2])
+		asyncId := atraceAsyncId(pid, fields[2], fields[3])
+		if fields[0] == "S" {
+			return writer.AddAsyncBeginEvent("atrace", name, processId, threadId, timestamp, asyncId)
+		}
+		return writer.AddAsyncEndEvent("atrace", name, processId, threadId, timestamp, asyncId)
+	default:
+		// Free-form text written to the marker file
+		return nil
+	}
+}
+
+// atraceAsyncId derives an async correlation ID. atrace cookies are only unique per process and name
+func atraceAsyncId(pid uint64, name string, cookie string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(strconv.FormatUint(pid, 10)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(cookie))
+	return hash.Sum64()
+}
@@ -0,0 +1,58 @@
+package convert_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const atraceText = `<html><body><script class="trace-data" type="application/text">
+# tracer: nop
+   RenderThread-2201  ( 2180) [001] ...1  5000.000100: tracing_mark_write: B|2180|DrawFrame
+   RenderThread-2201  ( 2180) [001] ...1  5000.000200: tracing_mark_write: B|2180|flush commands
+   RenderThread-2201  ( 2180) [001] ...1  5000.000300: tracing_mark_write: E|2180
+   RenderThread-2201  ( 2180) [001] ...1  5000.000400: tracing_mark_write: C|2180|queued_buffers|3
+   RenderThread-2201  ( 2180) [001] ...1  5000.000500: tracing_mark_write: S|2180|launching: com.example|42
+   RenderThread-2201  ( 2180) [001] ...1  5000.000600: tracing_mark_write: E
+   RenderThread-2201  ( 2180) [001] ...1  5000.000700: tracing_mark_write: E|2180
+   RenderThread-2201  ( 2180) [001] d..2  5000.000800: sched_switch: prev_comm=RenderThread prev_pid=2201 prev_prio=110 prev_state=S ==> next_comm=swapper/1 next_pid=0 next_prio=120
+   RenderThread-2201  ( 2180) [001] ...1  5000.000900: tracing_mark_write: F|2180|launching: com.example|42
+</script></body></html>
+`
+
+func TestFromAtrace(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromAtrace(strings.NewReader(atraceText), writer)
+	require.NoError(t, err)
+
+	err = convert.FromAtrace(strings.NewReader("app-1 [000] 1.0: tracing_mark_write: C|1|missing_value\n"), writer)
+	require.Error(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
@@ -0,0 +1,365 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/richiesams/fxt"
+)
+
+// babeltraceLine matches a line of babeltrace / babeltrace2 text output:
+//
+//	[<timestamp>] (+<delta>) <hostname> <event name>: { <stream context> }, { <event context> }, { <payload> }
+//
+// The delta and hostname are optional, depending on the babeltrace options used
+var babeltraceLine = regexp.MustCompile(`^\[([0-9:.]+)\]\s+(?:\(\+[0-9?.]+\)\s+)?(?:(\S+)\s+)?([\w:.]+):\s*(.*)$`)
+
+// FromBabeltraceText converts the text output of babeltrace2 (or babeltrace 1.x), which is how LTTng traces
+// are imported
+//
+// Only the text output is supported. The binary Common Trace Format (CTF) that LTTng writes isn't read, since
+// decoding it requires interpreting the trace's TSDL metadata. Rather than duplicating that, CTF traces are
+// converted by piping them through babeltrace, the reference CTF reader:
+//
+//	babeltrace2 --clock-seconds /path/to/lttng-trace | <program calling FromBabeltraceText>
+//
+// With --clock-seconds, timestamps are seconds since the clock's origin, which are converted to nanoseconds.
+// Without it, timestamps are the time of day, which are converted to nanoseconds since midnight.
+//
+// Kernel events are mapped as follows:
+//   - sched_switch becomes a context switch record
+//   - sched_wakeup / sched_waking become thread wakeup records
+//   - syscall_entry_* / syscall_exit_* become duration begin / end events in the "syscall" category, on the thread
+//     that was running on the CPU at the time
+//
+// All other events (e.g. userspace tracepoints) become instant events, categorized by their tracepoint provider,
+// with their payload fields as arguments. The thread is taken from the vtid / vpid (or tid / pid) context fields
+// when present, or else from the thread that was running on the CPU. Payloads with more than 15 fields are capped
+// like FromJaegerJSON's tags
+func FromBabeltraceText(r io.Reader, writer *fxt.Writer) error {
+	converter := &babeltraceConverter{
+		writer:       writer,
+		running:      map[uint64]uint64{},
+		processIds:   map[uint64]uint64{},
+		namedThreads: map[uint64]string{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if err := converter.convertLine(scanner.Text()); err != nil {
+			return fmt.Errorf("failed to convert line %d - %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read babeltrace input - %w", err)
+	}
+
+	return nil
+}
+
+type babeltraceConverter struct {
+	writer *fxt.Writer
+
+	// running maps each CPU to the thread currently running on it, as tracked from sched_switch events
+	running map[uint64]uint64
+	// processIds maps thread IDs to their process, when known
+	processIds map[uint64]uint64
+	// namedThreads tracks the last name written for each thread
+	namedThreads map[uint64]string
+}
+
+func (c *babeltraceConverter) convertLine(line string) error {
+	match := babeltraceLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	timestamp, err := babeltraceTimestamp(match[1])
+	if err != nil {
+		return err
+	}
+	eventName := match[3]
+
+	// Merge the stream context, event context, and payload into one set of fields
+	fields := map[string]interface{}{}
+	for _, group := range splitBabeltraceGroups(match[4]) {
+		for key, value := range parseBabeltraceFields(group) {
+			fields[key] = value
+		}
+	}
+
+	cpu, _ := fields["cpu_id"].(int64)
+
+	switch {
+	case eventName == "sched_switch":
+		return c.convertSwitch(uint64(cpu), timestamp, fields)
+	case eventName == "sched_wakeup" || eventName == "sched_waking" || eventName == "sched_wakeup_new":
+		return c.convertWakeup(uint64(cpu), timestamp, fields)
+	case strings.HasPrefix(eventName, "syscall_entry_"):
+		processId, threadId := c.eventThread(uint64(cpu), fields)
+		name := strings.TrimPrefix(eventName, "syscall_entry_")
+		return c.writer.AddDurationBeginEventWithArgs("syscall", truncate(name), processId, threadId, timestamp, payloadArgs(fields))
+	case strings.HasPrefix(eventName, "syscall_exit_"):
+		processId, threadId := c.eventThread(uint64(cpu), fields)
+		name := strings.TrimPrefix(eventName, "syscall_exit_")
+		return c.writer.AddDurationEndEventWithArgs("syscall", truncate(name), processId, threadId, timestamp, payloadArgs(fields))
+	default:
+		category, name := "kernel", eventName
+		if provider := strings.IndexByte(eventName, ':'); provider >= 0 {
+			category, name = eventName[:provider], eventName[provider+1:]
+		}
+
+		processId, threadId := c.eventThread(uint64(cpu), fields)
+		return c.writer.AddInstantEventWithArgs(truncate(category), truncate(name), processId, threadId, timestamp, payloadArgs(fields))
+	}
+}
+
+func (c *babeltraceConverter) convertSwitch(cpu uint64, timestamp uint64, fields map[string]interface{}) error {
+	prevTid, _ := fields["prev_tid"].(int64)
+	nextTid, _ := fields["next_tid"].(int64)
+	prevState, _ := fields["prev_state"].(int64)
+	prevComm, _ := fields["prev_comm"].(string)
+	nextComm, _ := fields["next_comm"].(string)
+
+	c.running[cpu] = uint64(nextTid)
+
+	if err := c.nameThread(uint64(prevTid), prevComm); err != nil {
+		return err
+	}
+	if err := c.nameThread(uint64(nextTid), nextComm); err != nil {
+		return err
+	}
+
+	return c.writer.AddContextSwitchRecord(uint16(cpu), lttngThreadState(prevState), fxt.KernelObjectID(prevTid), fxt.KernelObjectID(nextTid), timestamp)
+}
+
+func (c *babeltraceConverter) convertWakeup(cpu uint64, timestamp uint64, fields map[string]interface{}) error {
+	tid, _ := fields["tid"].(int64)
+	comm, _ := fields["comm"].(string)
+	if targetCpu, ok := fields["target_cpu"].(int64); ok {
+		cpu = uint64(targetCpu)
+	}
+
+	if err := c.nameThread(uint64(tid), comm); err != nil {
+		return err
+	}
+
+	return c.writer.AddThreadWakeupRecord(uint16(cpu), fxt.KernelObjectID(tid), timestamp)
+}
+
+// eventThread picks the thread an event is attributed to, from the context fields if present,
+// or else the thread running on the event's CPU
+func (c *babeltraceConverter) eventThread(cpu uint64, fields map[string]interface{}) (fxt.KernelObjectID, fxt.KernelObjectID) {
+	threadId, hasThread := contextId(fields, "vtid", "tid")
+	if !hasThread {
+		threadId = c.running[cpu]
+	}
+
+	processId, hasProcess := contextId(fields, "vpid", "pid")
+	if hasProcess {
+		c.processIds[threadId] = processId
+	} else if known, ok := c.processIds[threadId]; ok {
+		processId = known
+	} else {
+		processId = threadId
+	}
+
+	if procname, ok := fields["procname"].(string); ok && hasThread {
+		// Names are best effort
+		_ = c.nameThread(threadId, procname)
+	}
+
+	return fxt.KernelObjectID(processId), fxt.KernelObjectID(threadId)
+}
+
+// contextId returns the first of the given integer fields that's present
+func contextId(fields map[string]interface{}, keys ...string) (uint64, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key].(int64); ok {
+			return uint64(value), true
+		}
+	}
+
+	return 0, false
+}
+
+// nameThread writes a kernel object record for the thread, if its name changed since it was last written
+func (c *babeltraceConverter) nameThread(tid uint64, comm string) error {
+	if tid == 0 || comm == "" || c.namedThreads[tid] == comm {
+		return nil
+	}
+	c.namedThreads[tid] = comm
+
+	processId, ok := c.processIds[tid]
+	if !ok {
+		processId = tid
+	}
+
+	return c.writer.SetThreadName(fxt.KernelObjectID(processId), fxt.KernelObjectID(tid), truncate(comm))
+}
+
+// payloadArgs converts parsed fields into event arguments, leaving out the context fields that
+// are already represented by the event's thread, and spilling the fields past the argument limit
+func payloadArgs(fields map[string]interface{}) map[string]interface{} {
+	args := map[string]interface{}{}
+	for key, value := range fields {
+		switch key {
+		case "cpu_id", "vtid", "tid", "vpid", "pid", "procname":
+			continue
+		}
+
+		if str, ok := value.(string); ok {
+			value = truncate(str)
+		}
+		args[truncate(key)] = value
+	}
+
+	return capArgs(args)
+}
+
+// babeltraceTimestamp converts either a `seconds.fraction` or `HH:MM:SS.fraction` timestamp to nanoseconds
+func babeltraceTimestamp(timestamp string) (uint64, error) {
+	whole, fraction, _ := strings.Cut(timestamp, ".")
+
+	var seconds uint64
+	for _, part := range strings.Split(whole, ":") {
+		value, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp `%s`", timestamp)
+		}
+		seconds = seconds*60 + value
+	}
+
+	if len(fraction) > 9 {
+		fraction = fraction[:9]
+	}
+	fraction += strings.Repeat("0", 9-len(fraction))
+	nanos, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp `%s`", timestamp)
+	}
+
+	return seconds*1000000000 + nanos, nil
+}
+
+// splitBabeltraceGroups splits `{ ... }, { ... }` into the contents of each top level group
+func splitBabeltraceGroups(str string) []string {
+	var groups []string
+
+	depth := 0
+	start := 0
+	inString := false
+	for i := 0; i < len(str); i++ {
+		switch ch := str[i]; {
+		case inString:
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == '{' || ch == '[':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case ch == '}' || ch == ']':
+			depth--
+			if depth == 0 {
+				groups = append(groups, str[start:i])
+			}
+		}
+	}
+
+	return groups
+}
+
+// parseBabeltraceFields parses the `key = value, key = value` contents of a group
+// Integers become int64, quoted strings become strings, and anything else (floats, nested
+// structures, enums) is kept as its text representation
+func parseBabeltraceFields(group string) map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	depth := 0
+	start := 0
+	inString := false
+	addField := func(field string) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return
+		}
+		fields[strings.TrimSpace(key)] = parseBabeltraceValue(strings.TrimSpace(value))
+	}
+
+	for i := 0; i < len(group); i++ {
+		switch ch := group[i]; {
+		case inString:
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+		case ch == '{' || ch == '[' || ch == '(':
+			depth++
+		case ch == '}' || ch == ']' || ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			addField(group[start:i])
+			start = i + 1
+		}
+	}
+	addField(group[start:])
+
+	return fields
+}
+
+func parseBabeltraceValue(value string) interface{} {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	}
+
+	if number, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return number
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number
+	}
+
+	return value
+}
+
+// lttngThreadState maps a Linux task state bitmask to the closest Zircon thread state
+func lttngThreadState(state int64) uint8 {
+	switch {
+	case state == 0:
+		// TASK_RUNNING. The thread was preempted
+		return threadStateRunning
+	case state&(1|2|0x400) != 0:
+		// TASK_INTERRUPTIBLE, TASK_UNINTERRUPTIBLE, TASK_IDLE
+		return threadStateBlocked
+	case state&(4|8) != 0:
+		// __TASK_STOPPED, __TASK_TRACED
+		return threadStateSuspended
+	case state&0x10 != 0:
+		// EXIT_DEAD
+		return threadStateDead
+	case state&0x20 != 0:
+		// EXIT_ZOMBIE
+		return threadStateDying
+	default:
+		return threadStateBlocked
+	}
+}
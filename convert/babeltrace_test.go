@@ -0,0 +1,101 @@
+package convert_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const babeltraceText = `[1234.000000100] (+?.?????????) myhost sched_switch: { cpu_id = 0 }, { prev_comm = "swapper/0", prev_tid = 0, prev_prio = 20, prev_state = 0, next_comm = "myapp", next_tid = 4321, next_prio = 20 }
+[1234.000000200] (+0.000000100) myhost syscall_entry_read: { cpu_id = 0 }, { fd = 3, buf = 0x7FFD1234, count = 4096 }
+[1234.000000300] (+0.000000100) myhost syscall_exit_read: { cpu_id = 0 }, { ret = 12, buf = 0x7FFD1234 }
+[1234.000000400] (+0.000000100) myhost myapp:request_done: { cpu_id = 0 }, { vpid = 4320, vtid = 4321, procname = "myapp" }, { path = "/index.html", latency = 1.5, tags = [ [0] = 1, [1] = 2 ] }
+[1234.000000500] (+0.000000100) myhost sched_waking: { cpu_id = 0 }, { comm = "worker", tid = 4400, prio = 20, target_cpu = 1 }
+[00:20:34.000000600] myhost sched_switch: { cpu_id = 0 }, { prev_comm = "myapp", prev_tid = 4321, prev_prio = 20, prev_state = 1, next_comm = "swapper/0", next_tid = 0, next_prio = 20 }
+`
+
+func TestFromBabeltraceText(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromBabeltraceText(strings.NewReader(babeltraceText), writer)
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestFromBabeltraceTextManyFields(t *testing.T) {
+	var fields []string
+	for i := 0; i < 20; i++ {
+		fields = append(fields, fmt.Sprintf("field%02d = %d", i, i))
+	}
+	line := fmt.Sprintf("[1234.000000100] myhost myapp:event: { cpu_id = 0 }, { vpid = 1, vtid = 2 }, { %s }\n", strings.Join(fields, ", "))
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, convert.FromBabeltraceText(strings.NewReader(line), writer))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+	require.Equal(t, "event", event.Name)
+	require.Len(t, event.Arguments, 15)
+	require.Contains(t, event.Arguments, fxt.ResolvedArgument{Key: "more_args", Value: `{"field14":14,"field15":15,"field16":16,"field17":17,"field18":18,"field19":19}`})
+}
+
+func TestFromBabeltraceTextManyLongFields(t *testing.T) {
+	var fields []string
+	for i := 0; i < 20; i++ {
+		fields = append(fields, fmt.Sprintf("field%02d = \"%s\"", i, strings.Repeat("x", 40)))
+	}
+	line := fmt.Sprintf("[1234.000000100] myhost myapp:event: { cpu_id = 0 }, { vpid = 1, vtid = 2 }, { %s }\n", strings.Join(fields, ", "))
+
+	// The overflow argument must fit in the string table, even with the default LongStringError policy
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, convert.FromBabeltraceText(strings.NewReader(line), writer))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+	require.Len(t, event.Arguments, 15)
+	require.Contains(t, event.Arguments, fxt.ResolvedArgument{Key: "field00", Value: strings.Repeat("x", 40)})
+
+	for _, argument := range event.Arguments {
+		if argument.Key == "more_args" {
+			require.Len(t, argument.Value, fxt.MaxStringLength)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/richiesams/fxt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Description is a declarative description of a trace. It's usually written by hand in YAML or JSON
+//
+// Timestamps are in ticks. The caller decides the tick rate with the initialization record, like with the
+// other converters. Arguments can be any value accepted by the fxt.Writer
+type Description struct {
+	Processes []DescribedProcess `yaml:"processes" json:"processes"`
+	Counters  []DescribedCounter `yaml:"counters" json:"counters"`
+	Flows     []DescribedFlow    `yaml:"flows" json:"flows"`
+}
+
+// DescribedProcess is a process, and the threads in it
+type DescribedProcess struct {
+	Pid     fxt.KernelObjectID `yaml:"pid" json:"pid"`
+	Name    string             `yaml:"name" json:"name"`
+	Threads []DescribedThread  `yaml:"threads" json:"threads"`
+}
+
+// DescribedThread is a thread, and the events on it
+type DescribedThread struct {
+	Tid      fxt.KernelObjectID `yaml:"tid" json:"tid"`
+	Name     string             `yaml:"name" json:"name"`
+	Spans    []DescribedSpan    `yaml:"spans" json:"spans"`
+	Instants []DescribedInstant `yaml:"instants" json:"instants"`
+	Async    []DescribedAsync   `yaml:"async" json:"async"`
+}
+
+// DescribedSpan is a duration. Children are nested inside it, on the same thread
+type DescribedSpan struct {
+	Category string                 `yaml:"category" json:"category"`
+	Name     string                 `yaml:"name" json:"name"`
+	Begin    uint64                 `yaml:"begin" json:"begin"`
+	End      uint64                 `yaml:"end" json:"end"`
+	Args     map[string]interface{} `yaml:"args" json:"args"`
+	Children []DescribedSpan        `yaml:"children" json:"children"`
+}
+
+// DescribedInstant is an instant event
+type DescribedInstant struct {
+	Category  string                 `yaml:"category" json:"category"`
+	Name      string                 `yaml:"name" json:"name"`
+	Timestamp uint64                 `yaml:"ts" json:"ts"`
+	Args      map[string]interface{} `yaml:"args" json:"args"`
+}
+
+// DescribedAsync is an async duration, that begins and ends on the thread it's described in
+type DescribedAsync struct {
+	Category string                 `yaml:"category" json:"category"`
+	Name     string                 `yaml:"name" json:"name"`
+	Id       uint64                 `yaml:"id" json:"id"`
+	Begin    uint64                 `yaml:"begin" json:"begin"`
+	End      uint64                 `yaml:"end" json:"end"`
+	Args     map[string]interface{} `yaml:"args" json:"args"`
+}
+
+// DescribedCounter is a series of counter samples
+type DescribedCounter struct {
+	Category string                   `yaml:"category" json:"category"`
+	Name     string                   `yaml:"name" json:"name"`
+	Id       uint64                   `yaml:"id" json:"id"`
+	Pid      fxt.KernelObjectID       `yaml:"pid" json:"pid"`
+	Tid      fxt.KernelObjectID       `yaml:"tid" json:"tid"`
+	Samples  []DescribedCounterSample `yaml:"samples" json:"samples"`
+}
+
+// DescribedCounterSample is the values of a counter at a point in time
+type DescribedCounterSample struct {
+	Timestamp uint64                 `yaml:"ts" json:"ts"`
+	Values    map[string]interface{} `yaml:"values" json:"values"`
+}
+
+// DescribedFlow is a flow between points on different threads
+// The first step is written as a flow begin event, the last as a flow end event, and any others as flow steps
+type DescribedFlow struct {
+	Category string                 `yaml:"category" json:"category"`
+	Name     string                 `yaml:"name" json:"name"`
+	Id       uint64                 `yaml:"id" json:"id"`
+	Steps    []DescribedFlowStep    `yaml:"steps" json:"steps"`
+	Args     map[string]interface{} `yaml:"args" json:"args"`
+}
+
+// DescribedFlowStep is a point in a flow
+type DescribedFlowStep struct {
+	Pid       fxt.KernelObjectID `yaml:"pid" json:"pid"`
+	Tid       fxt.KernelObjectID `yaml:"tid" json:"tid"`
+	Timestamp uint64             `yaml:"ts" json:"ts"`
+}
+
+// FromDescription converts a YAML or JSON trace Description. This is useful for writing examples,
+// test fixtures for viewers, and reproductions of viewer bugs:
+//
+//	processes:
+//	  - pid: 1
+//	    name: server
+//	    threads:
+//	      - tid: 2
+//	        name: main
+//	        spans:
+//	          - {category: http, name: request, begin: 0, end: 100, children: [{category: db, name: query, begin: 10, end: 60}]}
+//	counters:
+//	  - {category: memory, name: heap, pid: 1, tid: 2, samples: [{ts: 0, values: {bytes: 1024}}, {ts: 50, values: {bytes: 4096}}]}
+func FromDescription(r io.Reader, writer *fxt.Writer) error {
+	var description Description
+	if err := yaml.NewDecoder(r).Decode(&description); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse trace description - %w", err)
+	}
+
+	return WriteDescription(&description, writer)
+}
+
+// WriteDescription writes the records for a trace Description
+func WriteDescription(description *Description, writer *fxt.Writer) error {
+	for _, process := range description.Processes {
+		if process.Name != "" {
+			if err := writer.SetProcessName(process.Pid, process.Name); err != nil {
+				return err
+			}
+		}
+
+		for _, thread := range process.Threads {
+			if err := writeDescribedThread(writer, process.Pid, thread); err != nil {
+				return fmt.Errorf("invalid thread %d/%d - %w", process.Pid, thread.Tid, err)
+			}
+		}
+	}
+
+	for _, counter := range description.Counters {
+		for _, sample := range counter.Samples {
+			if err := writer.AddCounterEvent(counter.Category, counter.Name, counter.Pid, counter.Tid, sample.Timestamp, sample.Values, counter.Id); err != nil {
+				return fmt.Errorf("invalid counter `%s` - %w", counter.Name, err)
+			}
+		}
+	}
+
+	for _, flow := range description.Flows {
+		if err := writeDescribedFlow(writer, flow); err != nil {
+			return fmt.Errorf("invalid flow `%s` - %w", flow.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func writeDescribedThread(writer *fxt.Writer, pid fxt.KernelObjectID, thread DescribedThread) error {
+	if thread.Name != "" {
+		if err := writer.SetThreadName(pid, thread.Tid, thread.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, span := range thread.Spans {
+		if err := writeDescribedSpan(writer, pid, thread.Tid, span); err != nil {
+			return err
+		}
+	}
+
+	for _, instant := range thread.Instants {
+		if err := writer.AddInstantEventWithArgs(instant.Category, instant.Name, pid, thread.Tid, instant.Timestamp, instant.Args); err != nil {
+			return err
+		}
+	}
+
+	for _, async := range thread.Async {
+		if async.End < async.Begin {
+			return fmt.Errorf("async `%s` ends before it begins", async.Name)
+		}
+		if err := writer.AddAsyncBeginEventWithArgs(async.Category, async.Name, pid, thread.Tid, async.Begin, async.Id, async.Args); err != nil {
+			return err
+		}
+		if err := writer.AddAsyncEndEvent(async.Category, async.Name, pid, thread.Tid, async.End, async.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDescribedSpan writes a span, followed by its children
+func writeDescribedSpan(writer *fxt.Writer, pid fxt.KernelObjectID, tid fxt.KernelObjectID, span DescribedSpan) error {
+	if span.End < span.Begin {
+		return fmt.Errorf("span `%s` ends before it begins", span.Name)
+	}
+	if err := writer.AddDurationCompleteEventWithArgs(span.Category, span.Name, pid, tid, span.Begin, span.End, span.Args); err != nil {
+		return err
+	}
+
+	for _, child := range span.Children {
+		if child.Begin < span.Begin || child.End > span.End {
+			return fmt.Errorf("span `%s` isn't inside its parent `%s`", child.Name, span.Name)
+		}
+		if err := writeDescribedSpan(writer, pid, tid, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeDescribedFlow(writer *fxt.Writer, flow DescribedFlow) error {
+	if len(flow.Steps) < 2 {
+		return fmt.Errorf("a flow needs at least 2 steps, but it has %d", len(flow.Steps))
+	}
+
+	for i, step := range flow.Steps {
+		var err error
+		switch i {
+		case 0:
+			err = writer.AddFlowBeginEventWithArgs(flow.Category, flow.Name, step.Pid, step.Tid, step.Timestamp, flow.Id, flow.Args)
+		case len(flow.Steps) - 1:
+			err = writer.AddFlowEndEvent(flow.Category, flow.Name, step.Pid, step.Tid, step.Timestamp, flow.Id)
+		default:
+			err = writer.AddFlowStepEvent(flow.Category, flow.Name, step.Pid, step.Tid, step.Timestamp, flow.Id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
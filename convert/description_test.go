@@ -0,0 +1,117 @@
+package convert_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const descriptionYAML = `
+processes:
+  - pid: 1
+    name: server
+    threads:
+      - tid: 2
+        name: main
+        spans:
+          - category: http
+            name: request
+            begin: 0
+            end: 100
+            args: {path: /users, status: 200}
+            children:
+              - {category: db, name: query, begin: 10, end: 60}
+        instants:
+          - {category: http, name: retry, ts: 70}
+        async:
+          - {category: io, name: read, id: 5, begin: 20, end: 90}
+      - tid: 3
+counters:
+  - {category: memory, name: heap, pid: 1, tid: 2, id: 1, samples: [{ts: 0, values: {bytes: 1024}}, {ts: 50, values: {bytes: 4096}}]}
+flows:
+  - category: rpc
+    name: call
+    id: 9
+    steps:
+      - {pid: 1, tid: 2, ts: 10}
+      - {pid: 1, tid: 3, ts: 20}
+      - {pid: 1, tid: 2, ts: 30}
+`
+
+func TestFromDescription(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	err = convert.FromDescription(strings.NewReader(descriptionYAML), writer)
+	require.NoError(t, err)
+
+	reader, err := fxt.NewReader(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+
+	kinds := map[string]int{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		switch decoded.(type) {
+		case *fxt.DurationCompleteEvent:
+			kinds["span"]++
+		case *fxt.InstantEvent:
+			kinds["instant"]++
+		case *fxt.AsyncBeginEvent, *fxt.AsyncEndEvent:
+			kinds["async"]++
+		case *fxt.CounterEvent:
+			kinds["counter"]++
+		case *fxt.FlowBeginEvent, *fxt.FlowStepEvent, *fxt.FlowEndEvent:
+			kinds["flow"]++
+		case *fxt.KernelObjectRecord:
+			kinds["name"]++
+		}
+	}
+	require.Equal(t, map[string]int{"span": 2, "instant": 1, "async": 2, "counter": 2, "flow": 3, "name": 2}, kinds)
+}
+
+func TestFromDescriptionJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	err = convert.FromDescription(strings.NewReader(`{"processes": [{"pid": 1, "threads": [{"tid": 2, "spans": [{"category": "c", "name": "n", "begin": 0, "end": 10}]}]}]}`), writer)
+	require.NoError(t, err)
+}
+
+func TestFromDescriptionErrors(t *testing.T) {
+	writer, err := fxt.NewWriterTo(io.Discard)
+	require.NoError(t, err)
+
+	// A child outside its parent
+	err = convert.FromDescription(strings.NewReader(`
+processes:
+  - pid: 1
+    threads:
+      - tid: 2
+        spans:
+          - {name: parent, begin: 10, end: 20, children: [{name: child, begin: 0, end: 15}]}
+`), writer)
+	require.ErrorContains(t, err, "isn't inside its parent")
+
+	// A flow with only one step
+	err = convert.FromDescription(strings.NewReader(`flows: [{name: flow, steps: [{pid: 1, tid: 2, ts: 0}]}]`), writer)
+	require.Error(t, err)
+
+	err = convert.FromDescription(strings.NewReader(`processes: 5`), writer)
+	require.Error(t, err)
+}
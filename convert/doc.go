@@ -0,0 +1,12 @@
+/*
+Package convert turns traces captured in other formats into FXT
+
+Each converter reads its input format and writes the equivalent records using an fxt.Writer.
+The converters only write the records describing the trace itself (process / thread names, events, etc).
+The caller is responsible for the provider and initialization records, so several inputs can be combined
+into a single FXT file.
+
+Unless otherwise documented, converters write timestamps in nanoseconds, so the caller should write an
+initialization record of fxt.TicksNanoseconds
+*/
+package convert
@@ -0,0 +1,251 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/richiesams/fxt"
+)
+
+// ftraceLine matches the common prefix of an ftrace / trace-cmd text line:
+//
+//	<comm>-<tid> [(<tgid>)] [<cpu>] [<flags>] <seconds>.<fraction>: <event>: <details>
+var ftraceLine = regexp.MustCompile(`^\s*(.+)-(\d+)\s+(?:\(\s*(\d+|-+)\)\s+)?\[(\d+)\]\s+(?:\S+\s+)?(\d+)\.(\d+):\s+(\w+):\s*(.*)$`)
+
+// traceCmdSwitch matches trace-cmd report's compact sched_switch format:
+//
+//	<prev_comm>:<prev_pid> [<prev_prio>] <prev_state> ==> <next_comm>:<next_pid> [<next_prio>]
+var traceCmdSwitch = regexp.MustCompile(`^(.+):(\d+)\s+\[-?\d+\]\s+(\S+)\s+==>\s+(.+):(\d+)\s+\[-?\d+\]`)
+
+// traceCmdWakeup matches trace-cmd report's compact sched_wakeup format:
+//
+//	<comm>:<pid> [<prio>] [success=1] CPU:<cpu>
+var traceCmdWakeup = regexp.MustCompile(`^(.+):(\d+)\s+\[-?\d+\](?:\s+success=\d+)?\s+CPU:(\d+)`)
+
+// Zircon thread states, used for the outgoing thread state of context switch records
+const (
+	threadStateRunning   = 1
+	threadStateSuspended = 2
+	threadStateBlocked   = 3
+	threadStateDying     = 4
+	threadStateDead      = 5
+)
+
+// FromFtrace converts the text output of ftrace (the tracefs `trace` file) or `trace-cmd report`
+//
+// sched_switch lines become context switch records, and sched_wakeup / sched_waking lines become thread
+// wakeup records. Threads are named after the command names seen in the trace. All other lines are ignored.
+//
+// If the trace was recorded with the record-tgid option, threads are attributed to their processes.
+// Otherwise each thread is treated as its own process
+func FromFtrace(r io.Reader, writer *fxt.Writer) error {
+	converter := &ftraceConverter{
+		writer:       writer,
+		processIds:   map[uint64]uint64{},
+		namedThreads: map[uint64]string{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if err := converter.convertLine(scanner.Text()); err != nil {
+			return fmt.Errorf("failed to convert line %d - %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ftrace input - %w", err)
+	}
+
+	return nil
+}
+
+type ftraceConverter struct {
+	writer *fxt.Writer
+
+	// processIds maps thread IDs to their process (tgid), when known
+	processIds map[uint64]uint64
+	// namedThreads tracks the last name written for each thread
+	namedThreads map[uint64]string
+}
+
+func (c *ftraceConverter) convertLine(line string) error {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return nil
+	}
+
+	match := ftraceLine.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	comm, tidStr, tgidStr, cpuStr, seconds, fraction, event, details := match[1], match[2], match[3], match[4], match[5], match[6], match[7], match[8]
+
+	tid, _ := strconv.ParseUint(tidStr, 10, 64)
+	if tgid, err := strconv.ParseUint(tgidStr, 10, 64); err == nil {
+		c.processIds[tid] = tgid
+	}
+
+	cpu, err := strconv.ParseUint(cpuStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid CPU number `%s`", cpuStr)
+	}
+
+	timestamp, err := ftraceTimestamp(seconds, fraction)
+	if err != nil {
+		return err
+	}
+
+	if err := c.nameThread(tid, comm); err != nil {
+		return err
+	}
+
+	switch event {
+	case "sched_switch":
+		return c.convertSwitch(uint16(cpu), timestamp, details)
+	case "sched_wakeup", "sched_waking", "sched_wakeup_new":
+		return c.convertWakeup(uint16(cpu), timestamp, details)
+	default:
+		return nil
+	}
+}
+
+func (c *ftraceConverter) convertSwitch(cpu uint16, timestamp uint64, details string) error {
+	var prevComm, prevState, nextComm string
+	var prevPid, nextPid uint64
+
+	if fields := ftraceFields(details); fields["prev_pid"] != "" {
+		prevComm, prevState, nextComm = fields["prev_comm"], fields["prev_state"], fields["next_comm"]
+		prevPid, _ = strconv.ParseUint(fields["prev_pid"], 10, 64)
+		nextPid, _ = strconv.ParseUint(fields["next_pid"], 10, 64)
+	} else if match := traceCmdSwitch.FindStringSubmatch(details); match != nil {
+		prevComm, prevState, nextComm = match[1], match[3], match[4]
+		prevPid, _ = strconv.ParseUint(match[2], 10, 64)
+		nextPid, _ = strconv.ParseUint(match[5], 10, 64)
+	} else {
+		return fmt.Errorf("unrecognized sched_switch format `%s`", details)
+	}
+
+	if err := c.nameThread(prevPid, prevComm); err != nil {
+		return err
+	}
+	if err := c.nameThread(nextPid, nextComm); err != nil {
+		return err
+	}
+
+	return c.writer.AddContextSwitchRecord(cpu, ftraceThreadState(prevState), fxt.KernelObjectID(prevPid), fxt.KernelObjectID(nextPid), timestamp)
+}
+
+func (c *ftraceConverter) convertWakeup(cpu uint16, timestamp uint64, details string) error {
+	var comm string
+	var pid uint64
+
+	if fields := ftraceFields(details); fields["pid"] != "" {
+		comm = fields["comm"]
+		pid, _ = strconv.ParseUint(fields["pid"], 10, 64)
+		if targetCpu, err := strconv.ParseUint(fields["target_cpu"], 10, 16); err == nil {
+			cpu = uint16(targetCpu)
+		}
+	} else if match := traceCmdWakeup.FindStringSubmatch(details); match != nil {
+		comm = match[1]
+		pid, _ = strconv.ParseUint(match[2], 10, 64)
+		if targetCpu, err := strconv.ParseUint(match[3], 10, 16); err == nil {
+			cpu = uint16(targetCpu)
+		}
+	} else {
+		return fmt.Errorf("unrecognized wakeup format `%s`", details)
+	}
+
+	if err := c.nameThread(pid, comm); err != nil {
+		return err
+	}
+
+	return c.writer.AddThreadWakeupRecord(cpu, fxt.KernelObjectID(pid), timestamp)
+}
+
+// nameThread writes a kernel object record for the thread, if its name changed since it was last written
+func (c *ftraceConverter) nameThread(tid uint64, comm string) error {
+	// Each CPU's idle task is pid 0. Naming them would just produce a misleading "swapper/N" thread
+	if tid == 0 || comm == "" || comm == "<...>" || c.namedThreads[tid] == comm {
+		return nil
+	}
+	c.namedThreads[tid] = comm
+
+	processId, ok := c.processIds[tid]
+	if !ok {
+		processId = tid
+	}
+
+	return c.writer.SetThreadName(fxt.KernelObjectID(processId), fxt.KernelObjectID(tid), truncate(comm))
+}
+
+// ftraceFields parses the `key=value key=value` details of raw ftrace events
+// Values run until the next key, so commands with spaces are handled
+func ftraceFields(details string) map[string]string {
+	fields := map[string]string{}
+
+	key := ""
+	for _, token := range strings.Fields(details) {
+		if token == "==>" {
+			key = ""
+			continue
+		}
+
+		if equals := strings.IndexByte(token, '='); equals > 0 {
+			key = token[:equals]
+			fields[key] = token[equals+1:]
+		} else if key != "" {
+			fields[key] += " " + token
+		}
+	}
+
+	return fields
+}
+
+// ftraceTimestamp converts an ftrace `seconds.fraction` timestamp to nanoseconds
+func ftraceTimestamp(seconds string, fraction string) (uint64, error) {
+	secs, err := strconv.ParseUint(seconds, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp `%s.%s`", seconds, fraction)
+	}
+
+	// The fraction is microseconds by default, or nanoseconds with the trace-cmd -t option
+	if len(fraction) > 9 {
+		fraction = fraction[:9]
+	}
+	fraction += strings.Repeat("0", 9-len(fraction))
+	nanos, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp `%s.%s`", seconds, fraction)
+	}
+
+	return secs*1000000000 + nanos, nil
+}
+
+// ftraceThreadState maps a Linux task state to the closest Zircon thread state
+func ftraceThreadState(state string) uint8 {
+	state = strings.TrimSuffix(state, "+")
+	if state == "" {
+		return threadStateRunning
+	}
+
+	switch state[0] {
+	case 'R':
+		return threadStateRunning
+	case 'S', 'D', 'I':
+		return threadStateBlocked
+	case 'T', 't':
+		return threadStateSuspended
+	case 'X':
+		return threadStateDead
+	case 'Z':
+		return threadStateDying
+	default:
+		return threadStateBlocked
+	}
+}
@@ -0,0 +1,56 @@
+package convert_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const ftraceText = `# tracer: nop
+#
+#           TASK-PID     CPU#  ||||   TIMESTAMP  FUNCTION
+#              | |         |   ||||      |         |
+          <idle>-0       [001] d..2  1234.567890: sched_switch: prev_comm=swapper/1 prev_pid=0 prev_prio=120 prev_state=R ==> next_comm=kworker/1:0 next_pid=88 next_prio=120
+         kworker-88      [001] d..3  1234.567900: sched_wakeup: comm=bash pid=1234 prio=120 target_cpu=002
+            bash-1234    (  1230) [002] d..2  1234.568000: sched_switch: prev_comm=bash prev_pid=1234 prev_prio=120 prev_state=S ==> next_comm=swapper/2 next_pid=0 next_prio=120
+            bash-1234    [002]  1234.568100: sched_switch: bash:1234 [120] D ==> swapper/2:0 [120]
+            bash-1234    [002]  1234.568200: sched_wakeup: bash:1234 [120] success=1 CPU:002
+            bash-1234    [002]  1234.568300: irq_handler_entry: irq=30 name=eth0
+`
+
+func TestFromFtrace(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromFtrace(strings.NewReader(ftraceText), writer)
+	require.NoError(t, err)
+
+	err = convert.FromFtrace(strings.NewReader("bash-1 [000] 1.0: sched_switch: garbage\n"), writer)
+	require.Error(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
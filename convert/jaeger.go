@@ -0,0 +1,133 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/richiesams/fxt"
+)
+
+type jaegerDocument struct {
+	Data []jaegerTrace `json:"data"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     uint64            `json:"startTime"`
+	Duration      uint64            `json:"duration"`
+	Tags          []jaegerKeyValue  `json:"tags"`
+	Logs          []jaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type jaegerLog struct {
+	Timestamp uint64           `json:"timestamp"`
+	Fields    []jaegerKeyValue `json:"fields"`
+}
+
+type jaegerProcess struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// FromJaegerJSON converts the JSON returned by the Jaeger query API (and the "Download JSON" button in the Jaeger UI)
+//
+// Each service becomes a process, and each span a duration complete event with its tags as arguments.
+// Span logs are written as instant events. Since spans within a service can overlap without nesting,
+// they're spread across as many threads as needed to keep the slices on each thread properly nested.
+// References between spans on different threads are written as flows
+//
+// An event holds at most 15 arguments, so for spans and logs with more tags or fields, the first 14 keys in
+// sorted order are kept as arguments, and the rest are written as a JSON object in a "more_args" argument
+func FromJaegerJSON(r io.Reader, writer *fxt.Writer) error {
+	var document jaegerDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return fmt.Errorf("failed to parse Jaeger JSON - %w", err)
+	}
+
+	var spans []*span
+	for _, trace := range document.Data {
+		for _, jaegerSpan := range trace.Spans {
+			service := jaegerSpan.ProcessID
+			if process, ok := trace.Processes[jaegerSpan.ProcessID]; ok {
+				service = process.ServiceName
+			}
+
+			s := &span{
+				id:      jaegerSpan.TraceID + ":" + jaegerSpan.SpanID,
+				service: service,
+				name:    jaegerSpan.OperationName,
+				begin:   jaegerSpan.StartTime * 1000,
+				end:     (jaegerSpan.StartTime + jaegerSpan.Duration) * 1000,
+				args:    jaegerArgs(jaegerSpan.Tags),
+			}
+			for _, reference := range jaegerSpan.References {
+				s.parents = append(s.parents, reference.TraceID+":"+reference.SpanID)
+			}
+			for _, log := range jaegerSpan.Logs {
+				instant := spanInstant{
+					name:      "log",
+					timestamp: log.Timestamp * 1000,
+					args:      jaegerArgs(log.Fields),
+				}
+				// By convention, the "event" field names the log
+				if event, ok := instant.args["event"].(string); ok {
+					instant.name = event
+					delete(instant.args, "event")
+				}
+				s.instants = append(s.instants, instant)
+			}
+
+			spans = append(spans, s)
+		}
+	}
+
+	return writeSpans(writer, spans)
+}
+
+// jaegerArgs converts Jaeger tags / log fields into event arguments
+func jaegerArgs(keyValues []jaegerKeyValue) map[string]interface{} {
+	args := map[string]interface{}{}
+	for _, kv := range keyValues {
+		key := truncate(kv.Key)
+		switch v := kv.Value.(type) {
+		case bool:
+			args[key] = v
+		case float64:
+			if kv.Type == "int64" {
+				args[key] = int64(v)
+			} else {
+				args[key] = v
+			}
+		case string:
+			args[key] = truncate(v)
+		case nil:
+			args[key] = nil
+		default:
+			args[key] = truncate(fmt.Sprint(v))
+		}
+	}
+
+	return args
+}
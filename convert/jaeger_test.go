@@ -0,0 +1,141 @@
+package convert_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const jaegerJSON = `{
+	"data": [{
+		"traceID": "abc",
+		"spans": [
+			{
+				"traceID": "abc", "spanID": "1", "operationName": "GET /users", "references": [],
+				"startTime": 1000, "duration": 500, "processID": "p1",
+				"tags": [{"key": "http.status_code", "type": "int64", "value": 200}, {"key": "error", "type": "bool", "value": false}],
+				"logs": [{"timestamp": 1100, "fields": [{"key": "event", "type": "string", "value": "cache miss"}]}]
+			},
+			{
+				"traceID": "abc", "spanID": "2", "operationName": "SELECT users",
+				"references": [{"refType": "CHILD_OF", "traceID": "abc", "spanID": "1"}],
+				"startTime": 1200, "duration": 100, "processID": "p2",
+				"tags": [{"key": "db.statement", "type": "string", "value": "SELECT * FROM users"}]
+			}
+		],
+		"processes": {
+			"p1": {"serviceName": "frontend"},
+			"p2": {"serviceName": "database"}
+		}
+	}]
+}`
+
+func TestFromJaegerJSON(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromJaegerJSON(strings.NewReader(jaegerJSON), writer)
+	require.NoError(t, err)
+
+	err = convert.FromJaegerJSON(strings.NewReader("{not json"), writer)
+	require.Error(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestFromJaegerJSONManyTags(t *testing.T) {
+	var tags []string
+	for i := 0; i < 20; i++ {
+		tags = append(tags, fmt.Sprintf(`{"key": "tag%02d", "type": "int64", "value": %d}`, i, i))
+	}
+	document := fmt.Sprintf(`{"data": [{"traceID": "abc", "spans": [{
+		"traceID": "abc", "spanID": "1", "operationName": "span", "startTime": 1000, "duration": 500, "processID": "p1",
+		"tags": [%s]
+	}], "processes": {"p1": {"serviceName": "frontend"}}}]}`, strings.Join(tags, ","))
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, convert.FromJaegerJSON(strings.NewReader(document), writer))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+	_, err = reader.NextEvent()
+	require.True(t, errors.Is(err, io.EOF))
+
+	require.Len(t, event.Arguments, 15)
+	args := map[string]interface{}{}
+	for _, argument := range event.Arguments {
+		args[argument.Key] = argument.Value
+	}
+	require.Equal(t, int64(0), args["tag00"])
+	require.Equal(t, int64(13), args["tag13"])
+
+	var overflow map[string]int64
+	require.NoError(t, json.Unmarshal([]byte(args["more_args"].(string)), &overflow))
+	require.Equal(t, map[string]int64{"tag14": 14, "tag15": 15, "tag16": 16, "tag17": 17, "tag18": 18, "tag19": 19}, overflow)
+}
+
+func TestFromJaegerJSONManyLongTags(t *testing.T) {
+	var tags []string
+	for i := 0; i < 20; i++ {
+		tags = append(tags, fmt.Sprintf(`{"key": "tag%02d", "type": "string", "value": "%s"}`, i, strings.Repeat("x", 60)))
+	}
+	document := fmt.Sprintf(`{"data": [{"traceID": "abc", "spans": [{
+		"traceID": "abc", "spanID": "1", "operationName": "span", "startTime": 1000, "duration": 500, "processID": "p1",
+		"tags": [%s]
+	}], "processes": {"p1": {"serviceName": "frontend"}}}]}`, strings.Join(tags, ","))
+
+	// The overflow argument must fit in the string table, even with the default LongStringError policy
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, convert.FromJaegerJSON(strings.NewReader(document), writer))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+
+	require.Len(t, event.Arguments, 15)
+	for _, argument := range event.Arguments {
+		if argument.Key == "more_args" {
+			overflow := argument.Value.(string)
+			require.Len(t, overflow, fxt.MaxStringLength)
+			require.True(t, strings.HasPrefix(overflow, `{"tag14":"xxx`))
+		}
+	}
+}
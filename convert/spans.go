@@ -0,0 +1,213 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/richiesams/fxt"
+)
+
+// maxArgs is the most arguments an FXT event can have
+const maxArgs = 15
+
+// overflowArg is the argument that holds the arguments that don't fit in an event
+const overflowArg = "more_args"
+
+// span is the format-independent representation of a distributed tracing span
+// shared by the Jaeger and Zipkin converters
+type span struct {
+	id       string
+	parents  []string
+	service  string
+	name     string
+	begin    uint64
+	end      uint64
+	args     map[string]interface{}
+	instants []spanInstant
+
+	// Assigned when the spans are laid out
+	processId fxt.KernelObjectID
+	threadId  fxt.KernelObjectID
+}
+
+// spanInstant is a point-in-time annotation on a span, e.g. a Jaeger log or a Zipkin annotation
+type spanInstant struct {
+	name      string
+	timestamp uint64
+	args      map[string]interface{}
+}
+
+// writeSpans writes distributed tracing spans to `writer`
+//
+// Each service becomes a process. Spans can overlap arbitrarily within a service, but slices on a
+// thread must nest. So the spans of each service are packed into as few "lanes" as possible, where
+// each lane becomes a thread. References between spans on different lanes are written as flows
+func writeSpans(writer *fxt.Writer, spans []*span) error {
+	services := map[string][]*span{}
+	for _, s := range spans {
+		services[s.service] = append(services[s.service], s)
+	}
+
+	serviceNames := make([]string, 0, len(services))
+	for name := range services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	for i, serviceName := range serviceNames {
+		processId := fxt.KernelObjectID(i + 1)
+		if err := writer.SetProcessName(processId, truncate(serviceName)); err != nil {
+			return err
+		}
+
+		numLanes := layoutLanes(services[serviceName], processId)
+		for lane := 0; lane < numLanes; lane++ {
+			if err := writer.SetThreadName(processId, fxt.KernelObjectID(lane+1), fmt.Sprintf("%s %d", truncate(serviceName), lane)); err != nil {
+				return err
+			}
+		}
+	}
+
+	byId := map[string]*span{}
+	for _, s := range spans {
+		byId[s.id] = s
+	}
+
+	for _, s := range spans {
+		category := truncate(s.service)
+		name := truncate(s.name)
+		if err := writer.AddDurationCompleteEventWithArgs(category, name, s.processId, s.threadId, s.begin, s.end, capArgs(s.args)); err != nil {
+			return fmt.Errorf("failed to write span %s - %w", s.id, err)
+		}
+
+		for _, instant := range s.instants {
+			if err := writer.AddInstantEventWithArgs(category, truncate(instant.name), s.processId, s.threadId, instant.timestamp, capArgs(instant.args)); err != nil {
+				return fmt.Errorf("failed to write annotation for span %s - %w", s.id, err)
+			}
+		}
+
+		for _, parentId := range s.parents {
+			parent, ok := byId[parentId]
+			if !ok || (parent.processId == s.processId && parent.threadId == s.threadId) {
+				// Either the parent wasn't captured, or the nesting already shows the relationship
+				continue
+			}
+
+			// Flow events bind to the enclosing slice, so the begin must be inside the parent
+			flowBegin := s.begin
+			if flowBegin < parent.begin {
+				flowBegin = parent.begin
+			}
+			if flowBegin > parent.end {
+				flowBegin = parent.end
+			}
+
+			flowId := spanFlowId(parentId, s.id)
+			if err := writer.AddFlowBeginEvent(truncate(parent.service), "reference", parent.processId, parent.threadId, flowBegin, flowId); err != nil {
+				return err
+			}
+			if err := writer.AddFlowEndEvent(category, "reference", s.processId, s.threadId, s.begin, flowId); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// layoutLanes assigns each span a thread so that the spans on every thread nest properly
+// It returns the number of lanes used
+func layoutLanes(spans []*span, processId fxt.KernelObjectID) int {
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].begin != spans[j].begin {
+			return spans[i].begin < spans[j].begin
+		}
+		// Longer spans first, so parents are placed before the children they enclose
+		return spans[i].end > spans[j].end
+	})
+
+	// Each lane is a stack of the currently open spans on it
+	var lanes [][]*span
+	for _, s := range spans {
+		placed := false
+		for i := range lanes {
+			// Close the spans that ended before this one starts
+			stack := lanes[i]
+			for len(stack) > 0 && stack[len(stack)-1].end <= s.begin {
+				stack = stack[:len(stack)-1]
+			}
+			lanes[i] = stack
+
+			if len(stack) == 0 || s.end <= stack[len(stack)-1].end {
+				lanes[i] = append(stack, s)
+				s.threadId = fxt.KernelObjectID(i + 1)
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			lanes = append(lanes, []*span{s})
+			s.threadId = fxt.KernelObjectID(len(lanes))
+		}
+		s.processId = processId
+	}
+
+	return len(lanes)
+}
+
+// spanFlowId derives a flow correlation ID for the reference between two spans
+func spanFlowId(parentId string, childId string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(parentId))
+	hash.Write([]byte{0})
+	hash.Write([]byte(childId))
+	return hash.Sum64()
+}
+
+// capArgs fits `args` in the arguments of one event. If there are too many, the first keys, in order, are
+// kept, and the rest are written as a JSON object in overflowArg, truncated to fit in the FXT string table
+func capArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) <= maxArgs {
+		return args
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	capped := make(map[string]interface{}, maxArgs)
+	overflow := map[string]interface{}{}
+	for _, key := range keys {
+		if len(capped) < maxArgs-1 && key != overflowArg {
+			capped[key] = args[key]
+		} else {
+			overflow[key] = args[key]
+		}
+	}
+
+	// The values are only bools, numbers, strings, and nil, so they always marshal
+	data, _ := json.Marshal(overflow)
+	capped[overflowArg] = truncate(string(data))
+	return capped
+}
+
+// truncate shortens a string so it fits in the FXT string table
+func truncate(str string) string {
+	if len(str) <= fxt.MaxStringLength {
+		return str
+	}
+
+	// Don't split a multi-byte character
+	end := fxt.MaxStringLength
+	for end > 0 && !utf8.RuneStart(str[end]) {
+		end--
+	}
+
+	return str[:end]
+}
@@ -0,0 +1,30 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutLanesNestsSpans(t *testing.T) {
+	root := &span{id: "root", begin: 0, end: 100}
+	child := &span{id: "child", begin: 10, end: 50}
+	grandchild := &span{id: "grandchild", begin: 20, end: 30}
+	// Overlaps child without nesting inside it, so it needs its own lane
+	overlapping := &span{id: "overlapping", begin: 40, end: 80}
+	// Starts after child ends, so it can share child's lane
+	sibling := &span{id: "sibling", begin: 60, end: 70}
+
+	numLanes := layoutLanes([]*span{sibling, overlapping, grandchild, child, root}, 7)
+	require.Equal(t, 2, numLanes)
+
+	require.EqualValues(t, 1, root.threadId)
+	require.EqualValues(t, 1, child.threadId)
+	require.EqualValues(t, 1, grandchild.threadId)
+	require.EqualValues(t, 2, overlapping.threadId)
+	require.EqualValues(t, 1, sibling.threadId)
+
+	for _, s := range []*span{root, child, grandchild, overlapping, sibling} {
+		require.EqualValues(t, 7, s.processId)
+	}
+}
@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/richiesams/fxt"
+)
+
+// tracyProcessId is the process the zones of a Tracy capture are attributed to
+const tracyProcessId = fxt.KernelObjectID(1)
+
+// FromTracyCSV converts the per-zone CSV export of a Tracy profiler capture:
+//
+//	tracy-csvexport --unwrap capture.tracy > zones.csv
+//
+// Each zone becomes a duration complete event in the "tracy" category, with its source location
+// as arguments. Zones are written to process 1, on the thread from the export's thread column.
+// Older versions of tracy-csvexport don't export the thread, in which case all zones are written to thread 1.
+// The aggregated (non --unwrap) export has no timestamps, so it's rejected
+func FromTracyCSV(r io.Reader, writer *fxt.Writer) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read Tracy CSV header - %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, required := range []string{"name", "ns_since_start", "exec_time_ns"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("Tracy CSV is missing the `%s` column. It must be exported with --unwrap", required)
+		}
+	}
+
+	if err := writer.SetProcessName(tracyProcessId, "tracy"); err != nil {
+		return err
+	}
+
+	namedThreads := map[fxt.KernelObjectID]bool{}
+	row := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		row++
+		if err != nil {
+			return fmt.Errorf("failed to read Tracy CSV row %d - %w", row, err)
+		}
+
+		column := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		begin, err := strconv.ParseUint(column("ns_since_start"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ns_since_start on row %d - %w", row, err)
+		}
+		duration, err := strconv.ParseUint(column("exec_time_ns"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid exec_time_ns on row %d - %w", row, err)
+		}
+
+		threadId := fxt.KernelObjectID(1)
+		if thread := column("thread"); thread != "" {
+			parsed, err := strconv.ParseUint(thread, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid thread on row %d - %w", row, err)
+			}
+			threadId = fxt.KernelObjectID(parsed)
+		}
+		if !namedThreads[threadId] {
+			namedThreads[threadId] = true
+			if err := writer.SetThreadName(tracyProcessId, threadId, fmt.Sprintf("Thread %d", threadId)); err != nil {
+				return err
+			}
+		}
+
+		args := map[string]interface{}{}
+		if file := column("src_file"); file != "" {
+			args["file"] = truncate(file)
+		}
+		if line, err := strconv.ParseInt(column("src_line"), 10, 32); err == nil {
+			args["line"] = int32(line)
+		}
+
+		if err := writer.AddDurationCompleteEventWithArgs("tracy", truncate(column("name")), tracyProcessId, threadId, begin, begin+duration, args); err != nil {
+			return fmt.Errorf("failed to write zone on row %d - %w", row, err)
+		}
+	}
+
+	return nil
+}
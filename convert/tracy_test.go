@@ -0,0 +1,52 @@
+package convert_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const tracyCSV = `name,src_file,src_line,ns_since_start,exec_time_ns,thread
+Frame,src/main.cpp,42,1000,5000,7
+Update,src/update.cpp,10,1200,2000,7
+"Render, opaque",src/render.cpp,88,3500,1000,7
+Job,src/jobs.cpp,5,1500,700,9
+`
+
+func TestFromTracyCSV(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromTracyCSV(strings.NewReader(tracyCSV), writer)
+	require.NoError(t, err)
+
+	// The aggregated export can't be converted
+	err = convert.FromTracyCSV(strings.NewReader("name,src_file,src_line,total_ns,total_perc,counts,mean_ns,min_ns,max_ns,std_ns\n"), writer)
+	require.Error(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
@@ -0,0 +1,112 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/richiesams/fxt"
+)
+
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      uint64             `json:"timestamp"`
+	Duration       uint64             `json:"duration"`
+	Shared         bool               `json:"shared"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// FromZipkinJSON converts a Zipkin v2 JSON span list, as returned by the Zipkin API
+//
+// The layout matches FromJaegerJSON. Tags are written as string arguments, along with the span kind
+// and remote service name if they're set, and capped at 15 like FromJaegerJSON's. Annotations are written as
+// instant events
+func FromZipkinJSON(r io.Reader, writer *fxt.Writer) error {
+	var zipkinSpans []zipkinSpan
+	if err := json.NewDecoder(r).Decode(&zipkinSpans); err != nil {
+		return fmt.Errorf("failed to parse Zipkin JSON - %w", err)
+	}
+
+	var spans []*span
+	for _, zipkinSpan := range zipkinSpans {
+		service := "unknown"
+		if zipkinSpan.LocalEndpoint != nil && zipkinSpan.LocalEndpoint.ServiceName != "" {
+			service = zipkinSpan.LocalEndpoint.ServiceName
+		}
+
+		// Zipkin's shared spans reuse the client's span ID on the server side, so the ID alone isn't unique
+		id := zipkinSpan.TraceID + ":" + zipkinSpan.ID
+		if zipkinSpan.Shared {
+			id += ":shared"
+		}
+
+		s := &span{
+			id:      id,
+			service: service,
+			name:    zipkinSpan.Name,
+			begin:   zipkinSpan.Timestamp * 1000,
+			end:     (zipkinSpan.Timestamp + zipkinSpan.Duration) * 1000,
+			args:    map[string]interface{}{},
+		}
+
+		if zipkinSpan.Shared {
+			// The server half of a shared span is the child of the client half
+			s.parents = append(s.parents, zipkinSpan.TraceID+":"+zipkinSpan.ID)
+		} else if zipkinSpan.ParentID != "" {
+			s.parents = append(s.parents, zipkinSpan.TraceID+":"+zipkinSpan.ParentID)
+		}
+
+		for key, value := range zipkinSpan.Tags {
+			s.args[truncate(key)] = truncate(value)
+		}
+		if zipkinSpan.Kind != "" {
+			s.args["kind"] = zipkinSpan.Kind
+		}
+		if zipkinSpan.RemoteEndpoint != nil && zipkinSpan.RemoteEndpoint.ServiceName != "" {
+			s.args["remote_service"] = truncate(zipkinSpan.RemoteEndpoint.ServiceName)
+		}
+
+		for _, annotation := range zipkinSpan.Annotations {
+			s.instants = append(s.instants, spanInstant{
+				name:      annotation.Value,
+				timestamp: annotation.Timestamp * 1000,
+				args:      map[string]interface{}{},
+			})
+		}
+
+		spans = append(spans, s)
+	}
+
+	// A child of a shared span refers to it by the client's ID. But the child belongs to the server half,
+	// which is in the same service
+	services := map[string]string{}
+	for _, s := range spans {
+		services[s.id] = s.service
+	}
+	for _, s := range spans {
+		for i, parentId := range s.parents {
+			sharedId := parentId + ":shared"
+			if service, ok := services[sharedId]; ok && sharedId != s.id && service == s.service {
+				s.parents[i] = sharedId
+			}
+		}
+	}
+
+	return writeSpans(writer, spans)
+}
@@ -0,0 +1,61 @@
+package convert_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/convert"
+
+	"github.com/stretchr/testify/require"
+)
+
+const zipkinJSON = `[
+	{
+		"traceId": "abc", "id": "1", "name": "get /users", "kind": "CLIENT",
+		"timestamp": 1000, "duration": 500,
+		"localEndpoint": {"serviceName": "frontend"}, "remoteEndpoint": {"serviceName": "backend"},
+		"annotations": [{"timestamp": 1010, "value": "wire send"}],
+		"tags": {"http.method": "GET"}
+	},
+	{
+		"traceId": "abc", "id": "1", "name": "get /users", "kind": "SERVER", "shared": true,
+		"timestamp": 1100, "duration": 300,
+		"localEndpoint": {"serviceName": "backend"}
+	},
+	{
+		"traceId": "abc", "parentId": "1", "id": "2", "name": "query",
+		"timestamp": 1150, "duration": 100,
+		"localEndpoint": {"serviceName": "backend"}
+	}
+]`
+
+func TestFromZipkinJSON(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	err = convert.FromZipkinJSON(strings.NewReader(zipkinJSON), writer)
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
@@ -0,0 +1,38 @@
+package fxt
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// nextCorrelationId starts at a random value, so separate processes are unlikely to hand out the same IDs
+var nextCorrelationId = rand.New(rand.NewSource(time.Now().UnixNano())).Uint64()
+
+// NewCorrelationId returns a process-unique ID suitable for async and flow correlation IDs
+// IDs are handed out sequentially from a random starting point, so IDs from separate processes
+// are unlikely to collide when their traces are viewed together
+func NewCorrelationId() uint64 {
+	return atomic.AddUint64(&nextCorrelationId, 1)
+}
+
+// CorrelationIdFromString derives a deterministic async or flow correlation ID from a string, like a
+// request ID. It's the 64-bit FNV-1a hash of the string
+//
+// Every producer that sees the same string derives the same ID, so the events they write are connected
+// when their traces are viewed together, without having to pass the ID between them
+func CorrelationIdFromString(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
+
+// CounterIdFromName derives a deterministic counter ID from the category and name of a counter. It's the
+// 64-bit FNV-1a hash of "category/name"
+//
+// Each counter gets its own ID in every process, without a CounterRegistry to hand them out. Different
+// counters are very unlikely to get the same ID, but a CounterRegistry can still be used to check
+func CounterIdFromName(category string, name string) uint64 {
+	return CorrelationIdFromString(category + "/" + name)
+}
@@ -0,0 +1,27 @@
+package fxt_test
+
+import (
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationIdFromString(t *testing.T) {
+	// Known FNV-1a values, so IDs stay the same across versions and match other implementations
+	require.Equal(t, uint64(0xcbf29ce484222325), fxt.CorrelationIdFromString(""))
+	require.Equal(t, uint64(0xaf63dc4c8601ec8c), fxt.CorrelationIdFromString("a"))
+
+	require.Equal(t, fxt.CorrelationIdFromString("request-42"), fxt.CorrelationIdFromString("request-42"))
+	require.NotEqual(t, fxt.CorrelationIdFromString("request-42"), fxt.CorrelationIdFromString("request-43"))
+}
+
+func TestCounterIdFromName(t *testing.T) {
+	require.Equal(t, fxt.CorrelationIdFromString("memory/Heap"), fxt.CounterIdFromName("memory", "Heap"))
+	require.NotEqual(t, fxt.CounterIdFromName("memory", "Heap"), fxt.CounterIdFromName("memory", "Allocations"))
+
+	registry := fxt.NewCounterRegistry()
+	require.NoError(t, registry.Register("memory", "Heap", fxt.CounterIdFromName("memory", "Heap")))
+	require.NoError(t, registry.Register("memory", "Allocations", fxt.CounterIdFromName("memory", "Allocations")))
+}
@@ -0,0 +1,95 @@
+package fxt
+
+import (
+	"fmt"
+	"sync"
+)
+
+// counterSeries identifies a counter by its category and name
+type counterSeries struct {
+	category string
+	name     string
+}
+
+// CounterRegistry keeps track of which counter each counter ID is used by, and hands out IDs that aren't
+// used yet
+//
+// Counter events are grouped into series by their counter ID, so counters written with the default ID of 0,
+// or with IDs picked by hand, can end up merged onto one track. Register the counters written with fixed IDs
+// to catch collisions, and let the registry assign the IDs of the others with Id
+//
+// A counter can have several IDs, for separate instances of it, but each ID belongs to a single counter.
+// All methods are safe to call from multiple goroutines
+type CounterRegistry struct {
+	mu sync.Mutex
+	// owners is the counter each ID is used by, and ids the first ID registered or assigned for each counter
+	owners map[uint64]counterSeries
+	ids    map[counterSeries]uint64
+	// nextId is where the search for an unused ID starts
+	nextId uint64
+}
+
+// NewCounterRegistry creates an empty CounterRegistry. IDs are assigned starting at 1, since 0 is the ID
+// most counters are written with
+func NewCounterRegistry() *CounterRegistry {
+	return &CounterRegistry{
+		owners: map[uint64]counterSeries{},
+		ids:    map[counterSeries]uint64{},
+		nextId: 1,
+	}
+}
+
+// Register records that the counter `category`/`name` is written with `counterId`
+// It returns an error if `counterId` is already used by a different counter, so the collision can be fixed
+// before the counters are merged in the trace. Registering the same counter and ID again is fine
+func (r *CounterRegistry) Register(category string, name string, counterId uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series := counterSeries{category: category, name: name}
+	if owner, ok := r.owners[counterId]; ok {
+		if owner != series {
+			return fmt.Errorf("counter ID %d of %s/%s is already used by %s/%s", counterId, category, name, owner.category, owner.name)
+		}
+		return nil
+	}
+
+	r.owners[counterId] = series
+	if _, ok := r.ids[series]; !ok {
+		r.ids[series] = counterId
+	}
+	return nil
+}
+
+// Id returns the ID of the counter `category`/`name`. The first time it's called for a counter that hasn't
+// been registered, it's assigned the lowest ID that isn't used by another counter
+func (r *CounterRegistry) Id(category string, name string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series := counterSeries{category: category, name: name}
+	if id, ok := r.ids[series]; ok {
+		return id
+	}
+
+	for {
+		if _, ok := r.owners[r.nextId]; !ok {
+			break
+		}
+		r.nextId++
+	}
+	id := r.nextId
+	r.nextId++
+	r.owners[id] = series
+	r.ids[series] = id
+	return id
+}
+
+// Lookup returns the category and name of the counter `counterId` is used by, if there is one
+func (r *CounterRegistry) Lookup(counterId uint64) (category string, name string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series, ok := r.owners[counterId]
+	return series.category, series.name, ok
+}
@@ -0,0 +1,70 @@
+package fxt_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterRegistryRegister(t *testing.T) {
+	registry := fxt.NewCounterRegistry()
+
+	require.NoError(t, registry.Register("queue", "Depth", 1))
+	require.NoError(t, registry.Register("queue", "Depth", 1))
+	// Separate instances of the same counter can have their own IDs
+	require.NoError(t, registry.Register("queue", "Depth", 2))
+
+	err := registry.Register("memory", "Heap", 1)
+	require.EqualError(t, err, "counter ID 1 of memory/Heap is already used by queue/Depth")
+	err = registry.Register("memory", "Depth", 2)
+	require.EqualError(t, err, "counter ID 2 of memory/Depth is already used by queue/Depth")
+
+	category, name, ok := registry.Lookup(2)
+	require.True(t, ok)
+	require.Equal(t, "queue", category)
+	require.Equal(t, "Depth", name)
+	_, _, ok = registry.Lookup(3)
+	require.False(t, ok)
+}
+
+func TestCounterRegistryId(t *testing.T) {
+	registry := fxt.NewCounterRegistry()
+	require.NoError(t, registry.Register("queue", "Depth", 2))
+
+	require.Equal(t, uint64(1), registry.Id("memory", "Heap"))
+	// IDs that are registered are skipped
+	require.Equal(t, uint64(3), registry.Id("memory", "Allocations"))
+	require.Equal(t, uint64(1), registry.Id("memory", "Heap"))
+	// Registered counters keep their ID
+	require.Equal(t, uint64(2), registry.Id("queue", "Depth"))
+
+	// Assigned IDs are owned like registered ones
+	require.Error(t, registry.Register("queue", "Latency", 3))
+	require.NoError(t, registry.Register("memory", "Allocations", 3))
+}
+
+func TestCounterRegistryConcurrent(t *testing.T) {
+	registry := fxt.NewCounterRegistry()
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	ids := make([]uint64, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			ids[i] = registry.Id("category", name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	seen := map[uint64]bool{}
+	for i, id := range ids {
+		require.False(t, seen[id], "ID %d was assigned twice", id)
+		seen[id] = true
+		require.Equal(t, id, registry.Id("category", names[i]))
+	}
+}
@@ -0,0 +1,148 @@
+package fxt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CPUSampler periodically reads how busy the machine's CPUs and a process are, and writes the utilization
+// to a Writer as counter events, so system load can be seen alongside the application's events
+//
+// Two counters are written each sample:
+//   - "CPU" with the percentage of time all the CPUs were "busy"
+//   - "Process CPU" with the process's "user", "system", and "total" CPU usage, as a percentage of one CPU
+//
+// Then a "CPU <n>" counter is written for each CPU, with the percentage of time it was "busy". They're separate
+// counters since an event can't have an argument for every CPU of a large machine
+//
+// Utilization is measured between samples, so nothing is written by the first sample.
+// The process that's measured is the one the events are attributed to, which is the current process unless
+// overridden with WithSamplerThread
+//
+// It's supported on Linux, where it reads /proc/stat and /proc/<pid>/stat, and on Windows, where it uses
+// NtQuerySystemInformation and GetProcessTimes. Sampling returns an error on other platforms
+type CPUSampler struct {
+	*Sampler
+
+	writer *Writer
+
+	last    cpuStats
+	hasLast bool
+}
+
+// cpuTime is how long a CPU has spent busy, and in total, in platform specific units
+type cpuTime struct {
+	busy  uint64
+	total uint64
+}
+
+// cpuStats is a snapshot of the CPU times of the machine and a process
+type cpuStats struct {
+	// all is the time of all the CPUs combined, and perCPU is the time of each online CPU by number
+	all    cpuTime
+	perCPU map[int]cpuTime
+
+	// processUser and processSystem are the process's time in user and kernel mode, in the same units as the CPUs
+	processUser   uint64
+	processSystem uint64
+}
+
+// NewCPUSampler creates a CPUSampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "CPU" category unless overridden with WithSamplerCategory
+func NewCPUSampler(writer *Writer, options ...SamplerOption) *CPUSampler {
+	cpuSampler := &CPUSampler{
+		writer: writer,
+	}
+	cpuSampler.Sampler = &Sampler{
+		config: newSamplerConfig("CPU", 0, options),
+		sample: cpuSampler.sample,
+	}
+
+	return cpuSampler
+}
+
+func (s *CPUSampler) sample(timestamp uint64) error {
+	stats, err := readCPUStats(s.config.processId)
+	if err != nil {
+		return fmt.Errorf("failed to read CPU usage - %w", err)
+	}
+
+	// Utilization needs two samples
+	if s.hasLast {
+		config := s.config
+		total, perCPU := cpuUtilization(s.last, stats)
+		err := s.writer.AddCounterEvent(config.category, "CPU", config.processId, config.threadId, timestamp, map[string]interface{}{
+			"busy": total,
+		}, 0)
+		if err != nil {
+			return err
+		}
+		err = s.writer.AddCounterEvent(config.category, "Process CPU", config.processId, config.threadId, timestamp, processUtilization(s.last, stats), 0)
+		if err != nil {
+			return err
+		}
+
+		cpus := make([]int, 0, len(perCPU))
+		for cpu := range perCPU {
+			cpus = append(cpus, cpu)
+		}
+		sort.Ints(cpus)
+		for _, cpu := range cpus {
+			err := s.writer.AddCounterEvent(config.category, fmt.Sprintf("CPU %d", cpu), config.processId, config.threadId, timestamp, map[string]interface{}{
+				"busy": perCPU[cpu],
+			}, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.last = stats
+	s.hasLast = true
+
+	return nil
+}
+
+// cpuUtilization returns the percentage of time all the CPUs were busy between the snapshots, and the
+// percentage for each CPU that's online in both, by number
+func cpuUtilization(last cpuStats, current cpuStats) (float64, map[int]float64) {
+	perCPU := make(map[int]float64, len(current.perCPU))
+	for cpu, usage := range current.perCPU {
+		if lastUsage, ok := last.perCPU[cpu]; ok {
+			perCPU[cpu] = busyPercent(lastUsage, usage)
+		}
+	}
+
+	return busyPercent(last.all, current.all), perCPU
+}
+
+// processUtilization returns the process's CPU usage between the snapshots as a percentage of one CPU, so a
+// process keeping two CPUs busy is at 200%
+func processUtilization(last cpuStats, current cpuStats) map[string]interface{} {
+	// The time of all the CPUs combined passes numCPUs times faster than the wall clock
+	elapsed := 0.0
+	if len(current.perCPU) > 0 && current.all.total > last.all.total {
+		elapsed = float64(current.all.total-last.all.total) / float64(len(current.perCPU))
+	}
+	percent := func(lastTime uint64, currentTime uint64) float64 {
+		if elapsed == 0 || currentTime < lastTime {
+			return 0
+		}
+		return float64(currentTime-lastTime) / elapsed * 100
+	}
+
+	return map[string]interface{}{
+		"user":   percent(last.processUser, current.processUser),
+		"system": percent(last.processSystem, current.processSystem),
+		"total":  percent(last.processUser+last.processSystem, current.processUser+current.processSystem),
+	}
+}
+
+// busyPercent returns the percentage of time a CPU was busy between two snapshots
+func busyPercent(last cpuTime, current cpuTime) float64 {
+	if current.total <= last.total || current.busy < last.busy {
+		return 0
+	}
+	return float64(current.busy-last.busy) / float64(current.total-last.total) * 100
+}
@@ -0,0 +1,29 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+)
+
+func readCPUStats(processId KernelObjectID) (cpuStats, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuStats{}, err
+	}
+	stats, err := parseProcStat(string(data))
+	if err != nil {
+		return cpuStats{}, fmt.Errorf("failed to parse /proc/stat - %w", err)
+	}
+
+	path := fmt.Sprintf("/proc/%d/stat", processId)
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return cpuStats{}, err
+	}
+	stats.processUser, stats.processSystem, err = parseProcPidStat(string(data))
+	if err != nil {
+		return cpuStats{}, fmt.Errorf("failed to parse %s - %w", path, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package fxt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readCPUStats(processId KernelObjectID) (cpuStats, error) {
+	return cpuStats{}, fmt.Errorf("CPU sampling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,59 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUSampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	sampler := fxt.NewCPUSampler(writer)
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		require.Error(t, sampler.Sample())
+		return
+	}
+
+	// The first sample only records the starting point
+	require.NoError(t, sampler.Sample())
+	// Stay busy for a few clock ticks, so the process has some CPU time to report
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+	}
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	counters := map[string]map[string]interface{}{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "CPU", event.Category)
+
+		values := map[string]interface{}{}
+		for _, argument := range event.Arguments {
+			values[argument.Key] = argument.Value
+		}
+		counters[event.Name] = values
+	}
+
+	require.Greater(t, len(counters), 2)
+	require.Contains(t, counters["CPU"], "busy")
+	require.Contains(t, counters["CPU 0"], "busy")
+	require.Contains(t, counters["Process CPU"], "user")
+	require.Contains(t, counters["Process CPU"], "system")
+	require.Greater(t, counters["Process CPU"]["total"], 0.0)
+}
@@ -0,0 +1,81 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var procNtQuerySystemInformation = syscall.NewLazyDLL("ntdll.dll").NewProc("NtQuerySystemInformation")
+
+const (
+	systemProcessorPerformanceInformation = 8
+	// processorPerformanceSize is the size of SYSTEM_PROCESSOR_PERFORMANCE_INFORMATION:
+	//
+	//	LARGE_INTEGER IdleTime
+	//	LARGE_INTEGER KernelTime
+	//	LARGE_INTEGER UserTime
+	//	LARGE_INTEGER DpcTime
+	//	LARGE_INTEGER InterruptTime
+	//	ULONG         InterruptCount
+	processorPerformanceSize = 48
+
+	processQueryLimitedInformation = 0x1000
+)
+
+// readCPUStats reads the times of each CPU with NtQuerySystemInformation, and of the process with
+// GetProcessTimes. The times are in 100ns units
+//
+// Only the CPUs in the calling thread's processor group are reported, so machines with more than 64
+// logical CPUs are partially covered
+func readCPUStats(processId KernelObjectID) (cpuStats, error) {
+	buffer := make([]byte, runtime.NumCPU()*processorPerformanceSize)
+	var length uint32
+	status, _, _ := procNtQuerySystemInformation.Call(
+		systemProcessorPerformanceInformation,
+		uintptr(unsafe.Pointer(&buffer[0])),
+		uintptr(len(buffer)),
+		uintptr(unsafe.Pointer(&length)),
+	)
+	if status != 0 {
+		return cpuStats{}, fmt.Errorf("NtQuerySystemInformation failed with status 0x%x", status)
+	}
+
+	stats := cpuStats{
+		perCPU: map[int]cpuTime{},
+	}
+	for cpu := 0; (cpu+1)*processorPerformanceSize <= int(length); cpu++ {
+		info := buffer[cpu*processorPerformanceSize:]
+		idle := binary.LittleEndian.Uint64(info[0:])
+		// Kernel time includes the idle time
+		kernel := binary.LittleEndian.Uint64(info[8:])
+		user := binary.LittleEndian.Uint64(info[16:])
+
+		usage := cpuTime{busy: kernel + user - idle, total: kernel + user}
+		stats.perCPU[cpu] = usage
+		stats.all.busy += usage.busy
+		stats.all.total += usage.total
+	}
+
+	process, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(processId))
+	if err != nil {
+		return cpuStats{}, fmt.Errorf("failed to open process %d - %w", processId, err)
+	}
+	defer syscall.CloseHandle(process)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(process, &creation, &exit, &kernel, &user); err != nil {
+		return cpuStats{}, fmt.Errorf("failed to get the times of process %d - %w", processId, err)
+	}
+	stats.processUser = filetimeTicks(user)
+	stats.processSystem = filetimeTicks(kernel)
+
+	return stats, nil
+}
+
+// filetimeTicks returns a FILETIME duration in 100ns units
+func filetimeTicks(t syscall.Filetime) uint64 {
+	return uint64(t.HighDateTime)<<32 | uint64(t.LowDateTime)
+}
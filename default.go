@@ -0,0 +1,117 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The environment variables read by EnableFromEnv
+const (
+	// EnvTrace is the path of the file the default trace is written to
+	EnvTrace = "FXT_TRACE"
+	// EnvCategories is a comma separated list of the categories written to the default trace. All the
+	// categories are written when it's empty
+	EnvCategories = "FXT_CATEGORIES"
+)
+
+// defaultTrace is the default TraceWriter, and the function that ends its trace
+type defaultTrace struct {
+	writer TraceWriter
+	close  func() error
+}
+
+var (
+	defaultTraceWriter atomic.Pointer[defaultTrace]
+	// defaultMu serializes changing the default TraceWriter, so the previous one is only closed once
+	defaultMu sync.Mutex
+)
+
+func init() {
+	if err := EnableFromEnv(); err != nil {
+		defaultWarningHandler(err)
+	}
+}
+
+// SetDefault makes `w` the default TraceWriter, which the package-level event functions like Instant and
+// BeginDuration write to. A nil `w` disables them again
+//
+// If the previous default was enabled with EnableFromEnv, its trace is ended and its file is closed. Other
+// TraceWriters are left for their owners to close. In binaries built with the fxt_disabled tag, `w` is ignored
+func SetDefault(w TraceWriter) error {
+	if w == nil {
+		return swapDefault(nil)
+	}
+	return swapDefault(&defaultTrace{writer: w})
+}
+
+// EnableFromEnv makes the default TraceWriter write to the file at the path in FXT_TRACE, limited to the
+// categories in FXT_CATEGORIES, like:
+//
+//	FXT_TRACE=/tmp/app.fxt FXT_CATEGORIES=net,db ./app
+//
+// It does nothing if FXT_TRACE isn't set. It's called when the package is initialized, so programs that write
+// their events to Default are traced without any code changes. Its errors are logged with the log package then
+//
+// It does nothing in binaries built with the fxt_disabled tag, where the default TraceWriter is always a NopWriter
+//
+// The trace's timestamps are in nanoseconds, as returned by WallClock, and its process is named after the
+// executable, with the metadata written by RecordProcessMetadata. Call CloseDefault before exiting, to end the
+// open spans and close the file
+func EnableFromEnv() error {
+	path := os.Getenv(EnvTrace)
+	if path == "" || compiledOut {
+		return nil
+	}
+
+	writer, err := NewWriter(path, WithCloseOpenSpans())
+	if err != nil {
+		return fmt.Errorf("failed to enable tracing from %s - %w", EnvTrace, err)
+	}
+	if err := writer.AddTickRateRecord(TicksNanoseconds); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to enable tracing from %s - %w", EnvTrace, err)
+	}
+	if err := writer.RecordProcessMetadata(KernelObjectID(os.Getpid())); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to enable tracing from %s - %w", EnvTrace, err)
+	}
+
+	var traceWriter TraceWriter = writer
+	if categories := parseCategories(os.Getenv(EnvCategories)); len(categories) > 0 {
+		traceWriter = NewCategoryFilter(writer, categories...)
+	}
+
+	return swapDefault(&defaultTrace{writer: traceWriter, close: writer.Close})
+}
+
+// CloseDefault ends the default trace, closing the file it's written to, and makes the default TraceWriter a
+// NopWriter again
+func CloseDefault() error {
+	return swapDefault(nil)
+}
+
+// swapDefault replaces the default TraceWriter with `trace`, and closes the previous one
+func swapDefault(trace *defaultTrace) error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	previous := defaultTraceWriter.Swap(trace)
+	if previous == nil || previous.close == nil {
+		return nil
+	}
+	return previous.close()
+}
+
+// parseCategories splits a comma separated list of categories, dropping the empty ones
+func parseCategories(list string) []string {
+	var categories []string
+	for _, category := range strings.Split(list, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,35 @@
+//go:build fxt_disabled
+
+package fxt_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledOut(t *testing.T) {
+	// Nothing is written to the trace from the environment
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	t.Setenv(fxt.EnvTrace, path)
+	require.NoError(t, fxt.EnableFromEnv())
+	require.NoFileExists(t, path)
+
+	// or to one set explicitly
+	recorder := &recordingWriter{}
+	require.NoError(t, fxt.SetDefault(recorder))
+	require.False(t, fxt.Enabled())
+	require.Equal(t, fxt.NopWriter{}, fxt.Default())
+	require.NoError(t, fxt.Instant("net", "connect", nil))
+	require.NoError(t, fxt.SetDefault(nil))
+	require.Empty(t, recorder.instants)
+
+	if !raceEnabled {
+		require.Zero(t, testing.AllocsPerRun(100, func() {
+			fxt.Instant("net", "request", map[string]interface{}{"retries": 3})
+		}))
+	}
+}
@@ -0,0 +1,148 @@
+//go:build !fxt_disabled
+
+package fxt
+
+import (
+	"os"
+)
+
+// compiledOut is whether the package-level event functions were compiled out with the fxt_disabled build tag
+const compiledOut = false
+
+// Default returns the process's default TraceWriter. It's a NopWriter unless one was set with SetDefault, or
+// tracing was enabled with EnableFromEnv, which is called when the package is initialized
+func Default() TraceWriter {
+	if trace := defaultTraceWriter.Load(); trace != nil {
+		return trace.writer
+	}
+	return NopWriter{}
+}
+
+// Enabled reports whether there's a default TraceWriter. Code writing events with the package-level functions
+// can check it before doing expensive work to build their arguments
+func Enabled() bool {
+	return defaultTraceWriter.Load() != nil
+}
+
+// defaultProcessId is the process ID of the events written with the package-level event functions
+var defaultProcessId = KernelObjectID(os.Getpid())
+
+// defaultWriter returns the default TraceWriter, the calling goroutine's ID, and the current time, for the
+// package-level event functions. It returns nil when there's no default, without looking up the rest
+func defaultWriter() (TraceWriter, KernelObjectID, uint64) {
+	trace := defaultTraceWriter.Load()
+	if trace == nil {
+		return nil, 0, 0
+	}
+	return trace.writer, GoroutineId(), WallClock()
+}
+
+// The package-level event functions write to the default TraceWriter, and do nothing when there isn't one. This
+// lets libraries write optional trace events, without a TraceWriter being passed through every constructor
+//
+// The events are written on the thread of the calling goroutine, using its ID from GoroutineId, in the current
+// process. They're timestamped with WallClock, so the default TraceWriter's trace should have an initialization
+// record of TicksNanoseconds, like the ones enabled with EnableFromEnv. `arguments` can be nil
+//
+// Building with the fxt_disabled tag replaces them with empty functions, which the compiler removes entirely,
+// along with any `if fxt.Enabled()` blocks guarding them
+
+// Instant writes an instant event to the default TraceWriter
+func Instant(category string, name string, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddInstantEventWithArgs(category, name, defaultProcessId, threadId, timestamp, arguments)
+}
+
+// Counter writes a counter event to the default TraceWriter, with the values in `arguments`
+func Counter(category string, name string, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddCounterEvent(category, name, defaultProcessId, threadId, timestamp, arguments, 0)
+}
+
+// BeginDuration writes a duration begin event to the default TraceWriter. End it with EndDuration on the same goroutine
+func BeginDuration(category string, name string, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddDurationBeginEventWithArgs(category, name, defaultProcessId, threadId, timestamp, arguments)
+}
+
+// EndDuration writes a duration end event to the default TraceWriter
+func EndDuration(category string, name string, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddDurationEndEventWithArgs(category, name, defaultProcessId, threadId, timestamp, arguments)
+}
+
+// CompleteDuration writes a duration complete event to the default TraceWriter, from `beginTimestamp` until now.
+// `beginTimestamp` should come from WallClock
+func CompleteDuration(category string, name string, beginTimestamp uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddDurationCompleteEventWithArgs(category, name, defaultProcessId, threadId, beginTimestamp, timestamp, arguments)
+}
+
+// AsyncBegin writes an async begin event to the default TraceWriter
+func AsyncBegin(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddAsyncBeginEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
+
+// AsyncInstant writes an async instant event to the default TraceWriter
+func AsyncInstant(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddAsyncInstantEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
+
+// AsyncEnd writes an async end event to the default TraceWriter
+func AsyncEnd(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddAsyncEndEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
+
+// FlowBegin writes a flow begin event to the default TraceWriter. It must be within a duration
+func FlowBegin(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddFlowBeginEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
+
+// FlowStep writes a flow step event to the default TraceWriter. It must be within a duration
+func FlowStep(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddFlowStepEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
+
+// FlowEnd writes a flow end event to the default TraceWriter. It must be within a duration
+func FlowEnd(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	w, threadId, timestamp := defaultWriter()
+	if w == nil {
+		return nil
+	}
+	return w.AddFlowEndEventWithArgs(category, name, defaultProcessId, threadId, timestamp, correlationId, arguments)
+}
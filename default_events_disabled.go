@@ -0,0 +1,77 @@
+//go:build fxt_disabled
+
+package fxt
+
+// compiledOut is whether the package-level event functions were compiled out with the fxt_disabled build tag
+const compiledOut = true
+
+// Default returns the process's default TraceWriter. Tracing was compiled out with the fxt_disabled build tag,
+// so it's always a NopWriter
+func Default() TraceWriter {
+	return NopWriter{}
+}
+
+// Enabled reports whether there's a default TraceWriter. Tracing was compiled out with the fxt_disabled build
+// tag, so it's always false, and the blocks it guards are removed by the compiler
+func Enabled() bool {
+	return false
+}
+
+// The package-level event functions do nothing, since tracing was compiled out with the fxt_disabled build tag.
+// They're inlined, so the calls themselves are removed. Their arguments are still evaluated, though, like the maps
+// passed to them, so expensive argument construction should be guarded with `if fxt.Enabled()`
+
+// Instant does nothing, since tracing was compiled out
+func Instant(category string, name string, arguments map[string]interface{}) error {
+	return nil
+}
+
+// Counter does nothing, since tracing was compiled out
+func Counter(category string, name string, arguments map[string]interface{}) error {
+	return nil
+}
+
+// BeginDuration does nothing, since tracing was compiled out
+func BeginDuration(category string, name string, arguments map[string]interface{}) error {
+	return nil
+}
+
+// EndDuration does nothing, since tracing was compiled out
+func EndDuration(category string, name string, arguments map[string]interface{}) error {
+	return nil
+}
+
+// CompleteDuration does nothing, since tracing was compiled out
+func CompleteDuration(category string, name string, beginTimestamp uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// AsyncBegin does nothing, since tracing was compiled out
+func AsyncBegin(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// AsyncInstant does nothing, since tracing was compiled out
+func AsyncInstant(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// AsyncEnd does nothing, since tracing was compiled out
+func AsyncEnd(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// FlowBegin does nothing, since tracing was compiled out
+func FlowBegin(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// FlowStep does nothing, since tracing was compiled out
+func FlowStep(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+// FlowEnd does nothing, since tracing was compiled out
+func FlowEnd(category string, name string, correlationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
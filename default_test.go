@@ -0,0 +1,96 @@
+//go:build !fxt_disabled
+
+package fxt_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnableFromEnv(t *testing.T) {
+	require.Equal(t, fxt.NopWriter{}, fxt.Default())
+
+	// Nothing is enabled without a trace path
+	t.Setenv(fxt.EnvTrace, "")
+	require.NoError(t, fxt.EnableFromEnv())
+	require.Equal(t, fxt.NopWriter{}, fxt.Default())
+
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	t.Setenv(fxt.EnvTrace, path)
+	t.Setenv(fxt.EnvCategories, "net, db,")
+	require.NoError(t, fxt.EnableFromEnv())
+
+	pid := fxt.KernelObjectID(os.Getpid())
+	require.NoError(t, fxt.Default().AddInstantEvent("net", "connect", pid, 1, 100))
+	require.NoError(t, fxt.Default().AddInstantEvent("ui", "click", pid, 1, 200))
+	require.NoError(t, fxt.Default().AddDurationBeginEvent("db", "query", pid, 1, 300))
+
+	// Closing ends the open spans, and disables tracing again
+	require.NoError(t, fxt.CloseDefault())
+	require.Equal(t, fxt.NopWriter{}, fxt.Default())
+	require.NoError(t, fxt.CloseDefault())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, event.Category+"/"+event.Name)
+	}
+	require.Equal(t, []string{"net/connect", "db/query", "db/query"}, names)
+}
+
+func TestSetDefault(t *testing.T) {
+	// The package-level functions do nothing without a default
+	require.False(t, fxt.Enabled())
+	require.NoError(t, fxt.Instant("net", "connect", nil))
+
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, fxt.SetDefault(writer))
+	require.True(t, fxt.Enabled())
+	require.Equal(t, writer, fxt.Default())
+
+	require.NoError(t, fxt.BeginDuration("db", "query", map[string]interface{}{"table": "users"}))
+	require.NoError(t, fxt.Instant("db", "row", nil))
+	require.NoError(t, fxt.Counter("db", "rows", map[string]interface{}{"count": int64(1)}))
+	require.NoError(t, fxt.EndDuration("db", "query", nil))
+
+	// Unsetting the default leaves the writer open for its owner
+	require.NoError(t, fxt.SetDefault(nil))
+	require.False(t, fxt.Enabled())
+	require.NoError(t, fxt.Instant("net", "connect", nil))
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, fxt.KernelObjectID(os.Getpid()), event.Thread.ProcessId)
+		require.Equal(t, fxt.GoroutineId(), event.Thread.ThreadId)
+		names = append(names, event.Category+"/"+event.Name)
+	}
+	require.Equal(t, []string{"db/query", "db/row", "db/rows", "db/query"}, names)
+}
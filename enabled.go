@@ -0,0 +1,19 @@
+package fxt
+
+// SetEnabled turns writing events on or off at runtime. It's cheaper and simpler than closing the Writer and
+// creating a new one, so tracing can be toggled around the interesting parts of a long running process
+//
+// While disabled, the methods adding events, blobs, and scheduling records return nil without writing anything,
+// after a single atomic load. Process and thread names, provider, and initialization records are still written,
+// so the trace stays readable when it's enabled again. A Writer is enabled when it's created
+//
+// Durations that begin while disabled and end once enabled, or the other way around, are only half written.
+// Viewers show these as unterminated, or ignore them
+func (w *Writer) SetEnabled(enabled bool) {
+	w.disabled.Store(!enabled)
+}
+
+// Enabled reports whether the Writer is writing events, as set by SetEnabled
+func (w *Writer) Enabled() bool {
+	return !w.disabled.Load()
+}
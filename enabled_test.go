@@ -0,0 +1,52 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetEnabled(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	threadWriter := writer.NewThreadWriter(1, 3)
+	require.True(t, writer.Enabled())
+
+	require.NoError(t, writer.AddInstantEvent("category", "before", 1, 2, 100))
+
+	writer.SetEnabled(false)
+	require.False(t, writer.Enabled())
+	require.NoError(t, writer.SetThreadName(1, 2, "main"))
+	require.NoError(t, writer.AddInstantEvent("category", "disabled", 1, 2, 200))
+	require.NoError(t, writer.AddCounterEvent("category", "disabled", 1, 2, 200, map[string]interface{}{"value": int64(1)}, 0))
+	require.NoError(t, writer.AddDurationCompleteEvent("category", "disabled", 1, 2, 200, 300))
+	require.NoError(t, writer.AddBlobRecord("disabled", []byte("data"), fxt.BlobTypeData))
+	require.NoError(t, threadWriter.AddInstantEvent("category", "disabled", 200))
+
+	writer.SetEnabled(true)
+	require.NoError(t, writer.AddInstantEvent("category", "after", 1, 2, 400))
+	require.NoError(t, threadWriter.AddInstantEvent("category", "after", 400))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, event.Name)
+	}
+	require.Equal(t, []string{"before", "after", "after"}, names)
+
+	// The thread was still named while disabled
+	require.True(t, bytes.Contains(buffer.Bytes(), []byte("main")))
+}
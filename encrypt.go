@@ -0,0 +1,342 @@
+package fxt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted traces are sealed with AES-GCM, in segments, so they can be written and read as a stream. An
+// encrypted trace is:
+//   - the magic bytes "FXTAES\x00\x01"
+//   - a random 8 byte nonce prefix
+//   - a sequence of segments, each with a 4 byte big endian header, and the sealed bytes of up to
+//     EncryptedSegmentSize bytes of the trace. The highest bit of the header marks the last segment, and the
+//     rest is the size of the sealed bytes, which includes GCM's 16 byte tag
+//
+// The nonce of each segment is the prefix, followed by the index of the segment as 4 big endian bytes, and the
+// segment's header is authenticated with it. So segments that are changed, reordered, or dropped, and traces
+// that are cut short, fail to decrypt, rather than being read as a shorter trace
+const (
+	// EncryptedSegmentSize is the most trace bytes sealed in each segment of an encrypted trace
+	EncryptedSegmentSize = 64 * 1024
+)
+
+var encryptedMagic = []byte("FXTAES\x00\x01")
+
+const (
+	noncePrefixSize     = 8
+	segmentHeaderSize   = 4
+	lastSegmentFlag     = 1 << 31
+	maxSealedSegmentLen = EncryptedSegmentSize + 16
+)
+
+// NewEncryptedWriter is the same as NewWriter, but the trace is encrypted with `key`, which is an AES-128,
+// AES-192, or AES-256 key of 16, 24, or 32 bytes. Read it with NewEncryptedReader and the same key
+//
+// This is for traces whose arguments hold sensitive data, and must be encrypted at rest. Each trace gets a
+// random nonce prefix, so a key can be reused for many traces, including the ones started by Reset. Only the
+// last, partial segment is kept in memory, and Flush writes it out, so Close must be called to end the trace
+func NewEncryptedWriter(filePath string, key []byte, options ...WriterOption) (*Writer, error) {
+	// Check the key up front, so a bad one doesn't leave an empty file behind
+	if _, err := newGCM(key); err != nil {
+		return nil, err
+	}
+	createFile := func(filePath string) (io.WriteCloser, error) {
+		file, err := createFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		encrypter, err := NewEncryptingWriter(file, key)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		encrypter.closer = file
+		return encrypter, nil
+	}
+
+	file, err := createFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dest file %s - %w", filePath, err)
+	}
+
+	writer, err := newWriter(file, file, filePath, options)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer.createFile = createFile
+
+	return writer, nil
+}
+
+// EncryptingWriter encrypts everything written to it with AES-GCM, in the format read by NewDecryptingReader.
+// It can be given to NewWriterTo to encrypt a trace written to a network connection, a buffer, etc. The
+// Writer's Flush flushes it too
+type EncryptingWriter struct {
+	dst io.Writer
+	// closer is the file the EncryptingWriter was opened for by NewEncryptedWriter, if any
+	closer io.Closer
+	aead   cipher.AEAD
+	nonce  []byte
+	index  uint32
+	// buffer holds the bytes of the segment being filled, and sealed the last segment sealed
+	buffer []byte
+	sealed []byte
+	closed bool
+	err    error
+}
+
+// NewEncryptingWriter creates an EncryptingWriter, which encrypts with `key` and writes to `dst`. The key is an
+// AES-128, AES-192, or AES-256 key of 16, 24, or 32 bytes
+//
+// Close must be called after the trace is written, to write the last segment. It doesn't close `dst`
+func NewEncryptingWriter(dst io.Writer, key []byte) (*EncryptingWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+noncePrefixSize)
+	header = append(header, encryptedMagic...)
+	header = header[:len(encryptedMagic)+noncePrefixSize]
+	if _, err := rand.Read(header[len(encryptedMagic):]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix - %w", err)
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write encryption header - %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, header[len(encryptedMagic):])
+	return &EncryptingWriter{
+		dst:    dst,
+		aead:   aead,
+		nonce:  nonce,
+		buffer: make([]byte, 0, EncryptedSegmentSize),
+	}, nil
+}
+
+// Write encrypts `data`. Whole segments are written to the destination as they fill up
+func (e *EncryptingWriter) Write(data []byte) (int, error) {
+	if e.closed {
+		return 0, os.ErrClosed
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	written := 0
+	for len(data) > 0 {
+		n := copy(e.buffer[len(e.buffer):cap(e.buffer)], data)
+		e.buffer = e.buffer[:len(e.buffer)+n]
+		data = data[n:]
+		written += n
+		if len(e.buffer) == cap(e.buffer) {
+			if err := e.writeSegment(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush writes the bytes of the partial segment as a segment of their own, so everything written so far can be
+// decrypted. Flushing often makes the trace larger, by 20 bytes per segment
+func (e *EncryptingWriter) Flush() error {
+	if e.closed {
+		return os.ErrClosed
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.buffer) == 0 {
+		return nil
+	}
+	return e.writeSegment(false)
+}
+
+// Sync commits the file opened by NewEncryptedWriter to stable storage. Only the segments written so far are
+// synced, so Flush should be called first
+func (e *EncryptingWriter) Sync() error {
+	if syncer, ok := e.closer.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Close writes the last segment, which ends the encrypted trace. The destination is only closed if it was
+// opened by NewEncryptedWriter
+func (e *EncryptingWriter) Close() error {
+	if e.closed {
+		return os.ErrClosed
+	}
+	err := e.err
+	if err == nil {
+		err = e.writeSegment(true)
+	}
+	e.closed = true
+
+	if e.closer != nil {
+		if closeErr := e.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// writeSegment seals the buffered bytes, and writes them as the next segment
+func (e *EncryptingWriter) writeSegment(last bool) error {
+	size := uint32(len(e.buffer) + e.aead.Overhead())
+	if last {
+		size |= lastSegmentFlag
+	}
+	var header [segmentHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:], size)
+
+	binary.BigEndian.PutUint32(e.nonce[noncePrefixSize:], e.index)
+	e.sealed = e.aead.Seal(append(e.sealed[:0], header[:]...), e.nonce, e.buffer, header[:])
+	e.buffer = e.buffer[:0]
+	e.index++
+	if e.index == 0 {
+		e.err = errors.New("encrypted trace exceeds the maximum number of segments")
+		return e.err
+	}
+
+	if _, err := e.dst.Write(e.sealed); err != nil {
+		e.err = fmt.Errorf("failed to write encrypted segment - %w", err)
+		return e.err
+	}
+	return nil
+}
+
+// NewEncryptedReader creates a Reader for the trace in `r`, encrypted with `key` by NewEncryptedWriter or an
+// EncryptingWriter
+//
+// The trace is authenticated as it's read, so a segment that's been changed, or a trace that's been cut short,
+// makes Next return an error, rather than records that weren't written
+func NewEncryptedReader(r io.Reader, key []byte) (*Reader, error) {
+	decrypter, err := NewDecryptingReader(r, key)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(decrypter)
+}
+
+// DecryptingReader decrypts an encrypted trace, written by an EncryptingWriter
+type DecryptingReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	nonce []byte
+	index uint32
+	// plain is the rest of the current segment, sealed the bytes of the next, and done is set after the last one
+	plain  []byte
+	sealed []byte
+	done   bool
+	err    error
+}
+
+// NewDecryptingReader creates a DecryptingReader, which decrypts the trace in `src` with `key`, and checks it
+// starts with the header of an encrypted trace
+func NewDecryptingReader(src io.Reader, key []byte) (*DecryptingReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DecryptingReader{
+		src:   bufio.NewReader(src),
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+	}
+	header := make([]byte, len(encryptedMagic)+noncePrefixSize)
+	if _, err := io.ReadFull(d.src, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header - %w", err)
+	}
+	if string(header[:len(encryptedMagic)]) != string(encryptedMagic) {
+		return nil, errors.New("not an encrypted trace - the header doesn't match")
+	}
+	copy(d.nonce, header[len(encryptedMagic):])
+
+	return d, nil
+}
+
+// Read reads the decrypted trace. It returns io.EOF after the last segment
+func (d *DecryptingReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.readSegment(); err != nil {
+			d.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// readSegment reads and decrypts the next segment
+func (d *DecryptingReader) readSegment() error {
+	var header [segmentHeaderSize]byte
+	if _, err := io.ReadFull(d.src, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("encrypted trace ends before its last segment - %w", err)
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	last := size&lastSegmentFlag != 0
+	size &^= lastSegmentFlag
+	if size < uint32(d.aead.Overhead()) || size > maxSealedSegmentLen {
+		return fmt.Errorf("invalid size %d of encrypted segment %d", size, d.index)
+	}
+
+	if cap(d.sealed) < int(size) {
+		d.sealed = make([]byte, size)
+	}
+	d.sealed = d.sealed[:size]
+	if _, err := io.ReadFull(d.src, d.sealed); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("truncated encrypted segment %d - %w", d.index, err)
+	}
+
+	binary.BigEndian.PutUint32(d.nonce[noncePrefixSize:], d.index)
+	plain, err := d.aead.Open(d.sealed[:0], d.nonce, d.sealed, header[:])
+	if err != nil {
+		return fmt.Errorf("failed to decrypt segment %d - %w", d.index, err)
+	}
+	d.plain = plain
+	d.index++
+
+	if last {
+		d.done = true
+		if _, err := d.src.Peek(1); err == nil {
+			return errors.New("encrypted trace has data after its last segment")
+		}
+	}
+	return nil
+}
+
+// newGCM creates the AES-GCM cipher for `key`
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key - %w", err)
+	}
+	return cipher.NewGCM(block)
+}
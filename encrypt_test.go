@@ -0,0 +1,119 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// countEvents reads every record of a trace, and returns the number of events
+func countEvents(reader *fxt.Reader) (int, error) {
+	events := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		if record.Type == fxt.RecordTypeEvent {
+			events++
+		}
+	}
+}
+
+func TestEncryptedWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt.enc")
+	writer, err := fxt.NewEncryptedWriter(path, testKey)
+	require.NoError(t, err)
+	// Enough events to fill a few segments
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, writer.AddInstantEventWithArgs("category", "secret", 1, 2, uint64(i), map[string]interface{}{"password": "hunter2"}))
+	}
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Greater(t, len(data), 2*fxt.EncryptedSegmentSize)
+	require.False(t, bytes.Contains(data, []byte("hunter2")))
+
+	reader, err := fxt.NewEncryptedReader(bytes.NewReader(data), testKey)
+	require.NoError(t, err)
+	events, err := countEvents(reader)
+	require.NoError(t, err)
+	require.Equal(t, 10000, events)
+
+	_, err = fxt.NewEncryptedWriter(filepath.Join(t.TempDir(), "bad.fxt"), []byte("short"))
+	require.Error(t, err)
+}
+
+func TestEncryptingWriterFlush(t *testing.T) {
+	var buffer bytes.Buffer
+	encrypter, err := fxt.NewEncryptingWriter(&buffer, testKey)
+	require.NoError(t, err)
+	writer, err := fxt.NewWriterTo(encrypter)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+
+	// Flushing the Writer flushes the partial segment, so the records written so far can be decrypted, but the
+	// trace isn't finished until the EncryptingWriter is closed
+	require.NoError(t, writer.Flush())
+	reader, err := fxt.NewEncryptedReader(bytes.NewReader(buffer.Bytes()), testKey)
+	require.NoError(t, err)
+	events, err := countEvents(reader)
+	require.Equal(t, 1, events)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	require.NoError(t, writer.Close())
+	require.NoError(t, encrypter.Close())
+	reader, err = fxt.NewEncryptedReader(bytes.NewReader(buffer.Bytes()), testKey)
+	require.NoError(t, err)
+	events, err = countEvents(reader)
+	require.NoError(t, err)
+	require.Equal(t, 1, events)
+}
+
+func TestDecryptingReaderErrors(t *testing.T) {
+	var buffer bytes.Buffer
+	encrypter, err := fxt.NewEncryptingWriter(&buffer, testKey)
+	require.NoError(t, err)
+	writer, err := fxt.NewWriterTo(encrypter)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+	require.NoError(t, writer.Close())
+	require.NoError(t, encrypter.Close())
+	data := buffer.Bytes()
+
+	// The wrong key
+	otherKey := bytes.Repeat([]byte{1}, 32)
+	_, err = fxt.NewEncryptedReader(bytes.NewReader(data), otherKey)
+	require.Error(t, err)
+
+	// A changed byte
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = io.ReadAll(mustDecrypt(t, tampered))
+	require.ErrorContains(t, err, "failed to decrypt segment")
+
+	// A trace that's not encrypted
+	_, err = fxt.NewDecryptingReader(bytes.NewReader(make([]byte, 64)), testKey)
+	require.Error(t, err)
+}
+
+func mustDecrypt(t *testing.T, data []byte) io.Reader {
+	t.Helper()
+
+	decrypter, err := fxt.NewDecryptingReader(bytes.NewReader(data), testKey)
+	require.NoError(t, err)
+	return decrypter
+}
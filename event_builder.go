@@ -0,0 +1,119 @@
+package fxt
+
+// EventBuilder builds an event field by field, and writes it with one of its terminal methods
+// (Instant, Begin, End, Complete, Counter, ...). It is created with Writer.Event
+//
+//	err := writer.Event("Category", "Name").Thread(pid, tid).At(ts).Arg("key", value).Instant()
+//
+// Fields that aren't set default to zero, and events without arguments are written without any.
+// A builder shouldn't be reused after calling a terminal method
+type EventBuilder struct {
+	writer    *Writer
+	category  string
+	name      string
+	processId KernelObjectID
+	threadId  KernelObjectID
+	timestamp uint64
+	arguments map[string]interface{}
+}
+
+// Event starts building an event with the given category and name
+func (w *Writer) Event(category string, name string) *EventBuilder {
+	return &EventBuilder{
+		writer:   w,
+		category: category,
+		name:     name,
+	}
+}
+
+// Thread sets the process / thread the event happened on
+func (b *EventBuilder) Thread(processId KernelObjectID, threadId KernelObjectID) *EventBuilder {
+	b.processId = processId
+	b.threadId = threadId
+	return b
+}
+
+// At sets the timestamp of the event. For Complete, this is the begin timestamp
+func (b *EventBuilder) At(timestamp uint64) *EventBuilder {
+	b.timestamp = timestamp
+	return b
+}
+
+// Arg adds an argument to the event. Setting the same key twice overwrites the previous value
+func (b *EventBuilder) Arg(key string, value interface{}) *EventBuilder {
+	if b.arguments == nil {
+		b.arguments = map[string]interface{}{}
+	}
+	b.arguments[key] = value
+	return b
+}
+
+// Args adds all the entries of `arguments` to the event
+func (b *EventBuilder) Args(arguments map[string]interface{}) *EventBuilder {
+	for key, value := range arguments {
+		b.Arg(key, value)
+	}
+	return b
+}
+
+func (b *EventBuilder) args() map[string]interface{} {
+	if b.arguments == nil {
+		return map[string]interface{}{}
+	}
+	return b.arguments
+}
+
+// Instant writes the event as an instant event
+func (b *EventBuilder) Instant() error {
+	return b.writer.AddInstantEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, b.args())
+}
+
+// Counter writes the event as a counter event. The arguments are the counter values
+func (b *EventBuilder) Counter(counterId uint64) error {
+	return b.writer.AddCounterEvent(b.category, b.name, b.processId, b.threadId, b.timestamp, b.args(), counterId)
+}
+
+// Begin writes the event as a duration begin event
+func (b *EventBuilder) Begin() error {
+	return b.writer.AddDurationBeginEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, b.args())
+}
+
+// End writes the event as a duration end event
+func (b *EventBuilder) End() error {
+	return b.writer.AddDurationEndEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, b.args())
+}
+
+// Complete writes the event as a duration complete event, from the timestamp set with At to `endTimestamp`
+func (b *EventBuilder) Complete(endTimestamp uint64) error {
+	return b.writer.AddDurationCompleteEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, endTimestamp, b.args())
+}
+
+// AsyncBegin writes the event as an async begin event
+func (b *EventBuilder) AsyncBegin(asyncCorrelationId uint64) error {
+	return b.writer.AddAsyncBeginEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, asyncCorrelationId, b.args())
+}
+
+// AsyncInstant writes the event as an async instant event
+func (b *EventBuilder) AsyncInstant(asyncCorrelationId uint64) error {
+	return b.writer.AddAsyncInstantEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, asyncCorrelationId, b.args())
+}
+
+// AsyncEnd writes the event as an async end event
+func (b *EventBuilder) AsyncEnd(asyncCorrelationId uint64) error {
+	return b.writer.AddAsyncEndEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, asyncCorrelationId, b.args())
+}
+
+// FlowBegin writes the event as a flow begin event
+func (b *EventBuilder) FlowBegin(flowCorrelationId uint64) error {
+	return b.writer.AddFlowBeginEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, flowCorrelationId, b.args())
+}
+
+// FlowStep writes the event as a flow step event
+func (b *EventBuilder) FlowStep(flowCorrelationId uint64) error {
+	return b.writer.AddFlowStepEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, flowCorrelationId, b.args())
+}
+
+// FlowEnd writes the event as a flow end event
+func (b *EventBuilder) FlowEnd(flowCorrelationId uint64) error {
+	return b.writer.AddFlowEndEventWithArgs(b.category, b.name, b.processId, b.threadId, b.timestamp, flowCorrelationId, b.args())
+}
@@ -0,0 +1,75 @@
+package fxt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBuilder(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	// Write the same events with the builder and the Add* methods. The files should be identical
+	write := func(fileName string, events func(writer *fxt.Writer) error) []byte {
+		filePath := filepath.Join(tempDir, fileName)
+		writer, err := fxt.NewWriter(filePath)
+		require.NoError(t, err)
+
+		require.NoError(t, events(writer))
+		require.NoError(t, writer.Close())
+
+		data, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		return data
+	}
+
+	built := write("built.fxt", func(writer *fxt.Writer) error {
+		if err := writer.Event("Category", "Instant").Thread(1, 2).At(100).Arg("count", 5).Instant(); err != nil {
+			return err
+		}
+		if err := writer.Event("Category", "Duration").Thread(1, 2).At(200).Begin(); err != nil {
+			return err
+		}
+		if err := writer.Event("Category", "Duration").Thread(1, 2).At(300).End(); err != nil {
+			return err
+		}
+		if err := writer.Event("Category", "Complete").Thread(1, 3).At(400).Args(map[string]interface{}{"ok": true}).Complete(500); err != nil {
+			return err
+		}
+		if err := writer.Event("Category", "Counter").Thread(1, 2).At(600).Arg("value", 1.5).Counter(7); err != nil {
+			return err
+		}
+		return writer.Event("Category", "Flow").Thread(1, 2).At(700).FlowBegin(9)
+	})
+
+	direct := write("direct.fxt", func(writer *fxt.Writer) error {
+		if err := writer.AddInstantEventWithArgs("Category", "Instant", 1, 2, 100, map[string]interface{}{"count": 5}); err != nil {
+			return err
+		}
+		if err := writer.AddDurationBeginEvent("Category", "Duration", 1, 2, 200); err != nil {
+			return err
+		}
+		if err := writer.AddDurationEndEvent("Category", "Duration", 1, 2, 300); err != nil {
+			return err
+		}
+		if err := writer.AddDurationCompleteEventWithArgs("Category", "Complete", 1, 3, 400, 500, map[string]interface{}{"ok": true}); err != nil {
+			return err
+		}
+		if err := writer.AddCounterEvent("Category", "Counter", 1, 2, 600, map[string]interface{}{"value": 1.5}, 7); err != nil {
+			return err
+		}
+		return writer.AddFlowBeginEvent("Category", "Flow", 1, 2, 700, 9)
+	})
+
+	require.Equal(t, direct, built)
+}
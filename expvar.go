@@ -0,0 +1,149 @@
+package fxt
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// ExpvarSampler periodically snapshots expvar variables and writes each one to a Writer
+// as a counter event named after the variable
+//
+// *expvar.Int and *expvar.Float variables are written with a single "value" argument.
+// *expvar.Map variables are written with one argument per numeric member, keyed by the member name.
+// expvar.Func variables are written if their value is a number or a map of numbers.
+// Members and values of any other type are skipped. Maps with more members than an event has arguments
+// are split across several counter events of the same name
+type ExpvarSampler struct {
+	*Sampler
+
+	writer *Writer
+	names  []string
+}
+
+// NewExpvarSampler creates an ExpvarSampler writing the variables named `names` to `writer`.
+// Call Start to begin sampling
+//
+// Variables that don't exist yet when a sample is taken are skipped, so the sampler can be
+// created before the variables are published
+//
+// Events are written to the "expvar" category unless overridden with WithSamplerCategory
+func NewExpvarSampler(writer *Writer, names []string, options ...SamplerOption) (*ExpvarSampler, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no expvar variables to sample")
+	}
+
+	expvarSampler := &ExpvarSampler{
+		writer: writer,
+		names:  names,
+	}
+	expvarSampler.Sampler = &Sampler{
+		config: newSamplerConfig("expvar", 0, options),
+		sample: expvarSampler.sample,
+	}
+
+	return expvarSampler, nil
+}
+
+func (s *ExpvarSampler) sample(timestamp uint64) error {
+	config := s.config
+	for _, name := range s.names {
+		variable := expvar.Get(name)
+		if variable == nil {
+			continue
+		}
+
+		args := expvarArgs(variable)
+		if len(args) == 0 {
+			continue
+		}
+
+		for _, chunk := range SplitArgs(args) {
+			if err := s.writer.AddCounterEvent(config.category, name, config.processId, config.threadId, timestamp, chunk, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expvarArgs converts an expvar variable into counter arguments
+func expvarArgs(variable expvar.Var) map[string]interface{} {
+	switch v := variable.(type) {
+	case *expvar.Int:
+		return map[string]interface{}{"value": v.Value()}
+	case *expvar.Float:
+		return map[string]interface{}{"value": v.Value()}
+	case *expvar.Map:
+		args := map[string]interface{}{}
+		v.Do(func(member expvar.KeyValue) {
+			switch m := member.Value.(type) {
+			case *expvar.Int:
+				args[member.Key] = m.Value()
+			case *expvar.Float:
+				args[member.Key] = m.Value()
+			}
+		})
+		return args
+	case expvar.Func:
+		return numericArgs(v.Value())
+	default:
+		return nil
+	}
+}
+
+// numericArgs converts the result of an expvar.Func into counter arguments
+func numericArgs(value interface{}) map[string]interface{} {
+	if number, ok := toCounterValue(value); ok {
+		return map[string]interface{}{"value": number}
+	}
+
+	switch v := value.(type) {
+	case map[string]int64:
+		args := map[string]interface{}{}
+		for key, member := range v {
+			args[key] = member
+		}
+		return args
+	case map[string]float64:
+		args := map[string]interface{}{}
+		for key, member := range v {
+			args[key] = member
+		}
+		return args
+	case map[string]interface{}:
+		args := map[string]interface{}{}
+		for key, member := range v {
+			if number, ok := toCounterValue(member); ok {
+				args[key] = number
+			}
+		}
+		return args
+	default:
+		return nil
+	}
+}
+
+// toCounterValue converts a Go number into one of the types accepted as an argument value
+func toCounterValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return v, true
+	case int64:
+		return v, true
+	case uint:
+		return uint64(v), true
+	case uint32:
+		return v, true
+	case uint64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return nil, false
+	}
+}
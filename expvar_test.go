@@ -0,0 +1,93 @@
+package fxt_test
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarSampler(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	_, err = fxt.NewExpvarSampler(writer, nil)
+	require.Error(t, err)
+
+	requests := expvar.NewInt("fxt_test_requests")
+	requests.Add(5)
+	load := expvar.NewFloat("fxt_test_load")
+	load.Set(0.75)
+	queues := expvar.NewMap("fxt_test_queues")
+	queues.Add("high", 3)
+	queues.AddFloat("low", 1.5)
+	queues.Set("ignored", new(expvar.String))
+	expvar.Publish("fxt_test_func", expvar.Func(func() interface{} { return map[string]interface{}{"a": 1, "b": "skip"} }))
+
+	sampler, err := fxt.NewExpvarSampler(writer, []string{
+		"fxt_test_requests",
+		"fxt_test_load",
+		"fxt_test_queues",
+		"fxt_test_func",
+		"fxt_test_not_published_yet",
+	})
+	require.NoError(t, err)
+
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestExpvarSamplerLargeMap(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	members := expvar.NewMap("fxt_test_large_map")
+	for i := 0; i < 20; i++ {
+		members.Add(fmt.Sprintf("member%02d", i), int64(i))
+	}
+
+	sampler, err := fxt.NewExpvarSampler(writer, []string{"fxt_test_large_map"})
+	require.NoError(t, err)
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, writer.Close())
+
+	events := readEvents(t, buffer.Bytes())
+	require.Len(t, events, 2)
+	values := map[string]interface{}{}
+	for _, event := range events {
+		require.Equal(t, "fxt_test_large_map", event.Name)
+		require.LessOrEqual(t, len(event.Arguments), 15)
+		for _, argument := range event.Arguments {
+			values[argument.Key] = argument.Value
+		}
+	}
+	require.Len(t, values, 20)
+	require.Equal(t, int64(19), values["member19"])
+}
@@ -0,0 +1,99 @@
+package fxt
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// DefaultFlushBytes is the size of the buffer used by WithFlushPolicy, when the policy's Bytes is zero
+const DefaultFlushBytes = 64 * 1024
+
+// FlushPolicy controls when a buffered Writer writes its buffered records to the destination
+//
+// Larger buffers and longer intervals mean fewer writes, but more records are lost if the process crashes
+type FlushPolicy struct {
+	// Bytes is how many bytes are buffered before they're written. Zero means DefaultFlushBytes
+	Bytes int
+	// Interval is the longest time a record stays buffered before it's written, including the records
+	// buffered by ThreadWriters. Zero means records are only written when the buffer is full, or on
+	// Flush and Close
+	Interval time.Duration
+}
+
+// WithFlushPolicy makes the Writer buffer the records it writes, and write them according to `policy`
+//
+// By default, each record is written to the destination as soon as it's added
+func WithFlushPolicy(policy FlushPolicy) WriterOption {
+	return func(w *Writer) {
+		w.flushPolicy = policy
+		w.bufferOutput = true
+	}
+}
+
+// startFlusher starts the goroutine that flushes the Writer every FlushPolicy.Interval, if there is one
+func (w *Writer) startFlusher() {
+	if w.flushPolicy.Interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	w.stopFlusher = stop
+	go func() {
+		ticker := time.NewTicker(w.flushPolicy.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.mu.Lock()
+				if err := w.flush(); err != nil {
+					w.warn(fmt.Errorf("timed flush failed - %w", err))
+				}
+				w.mu.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// bufferedOutput wraps the destination in a buffer, if the Writer has a flush policy
+func (w *Writer) bufferedOutput() {
+	if !w.bufferOutput {
+		w.buffered = nil
+		return
+	}
+
+	size := w.flushPolicy.Bytes
+	if size <= 0 {
+		size = DefaultFlushBytes
+	}
+	w.buffered = bufio.NewWriterSize(w.out, size)
+	w.out = w.buffered
+}
+
+// flush writes the events buffered by the ThreadWriters, waits for the pipeline, and then writes the Writer's own buffer
+// The Writer's lock must be held
+func (w *Writer) flush() error {
+	if err := w.flushThreadWriters(); err != nil {
+		return err
+	}
+	if w.pipeline != nil {
+		if err := w.pipeline.drain(); err != nil {
+			return err
+		}
+	}
+	if w.buffered != nil {
+		if err := w.buffered.Flush(); err != nil {
+			return fmt.Errorf("failed to write buffered records - %w", err)
+		}
+	}
+	// Destinations with buffers of their own, like an EncryptingWriter, are flushed too
+	if flusher, ok := w.dst.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return fmt.Errorf("failed to flush the destination - %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package fxt_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a bytes.Buffer that can be written by the Writer's flush goroutine, and read by the test
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Len()
+}
+
+func TestFlushPolicyBytes(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithFlushPolicy(fxt.FlushPolicy{Bytes: 256}))
+	require.NoError(t, err)
+
+	// The records stay in the buffer until there's 256 bytes of them
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.Zero(t, buffer.Len())
+	for i := 0; i < 19; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	}
+	require.NotZero(t, buffer.Len())
+
+	require.NoError(t, writer.Flush())
+	// Magic number, 2 string records of 2 words, a thread record of 3 words, and 20 events of 2 words
+	require.Equal(t, (1+2*2+3+20*2)*8, buffer.Len())
+}
+
+func TestFlushPolicyInterval(t *testing.T) {
+	var buffer lockedBuffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithFlushPolicy(fxt.FlushPolicy{Interval: 10 * time.Millisecond}))
+	require.NoError(t, err)
+	threadWriter := writer.NewThreadWriter(1, 2)
+	require.NoError(t, threadWriter.AddInstantEvent("category", "name", 100))
+	require.Zero(t, buffer.Len())
+
+	// The timed flush writes both the Writer's buffer, and the ThreadWriter's
+	require.Eventually(t, func() bool {
+		return buffer.Len() == (1+2*2+3+2)*8
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, writer.Close())
+}
+
+func TestFlushPolicyIntervalAfterReset(t *testing.T) {
+	var first, second lockedBuffer
+	writer, err := fxt.NewWriterTo(&first, fxt.WithFlushPolicy(fxt.FlushPolicy{Interval: 10 * time.Millisecond}))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// Close stops the timed flushes, and ResetTo starts them again
+	require.NoError(t, writer.ResetTo(&second))
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.Eventually(t, func() bool {
+		return second.Len() == (1+2*2+3+2)*8
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,180 @@
+//go:build linux
+
+package fxtebpf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+// tracefsPaths are where tracefs is usually mounted
+var tracefsPaths = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// Start attaches eBPF programs to the sched_switch and sched_wakeup tracepoints, and writes the scheduling
+// events of the traced processes to `writer` until Stop is called
+func Start(writer *fxt.Writer, options ...Option) (*Collector, error) {
+	c := newCollector(writer, options)
+
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, fmt.Errorf("failed to remove the memlock limit - %w", err)
+	}
+
+	var closers []func() error
+	closeAll := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	events, err := ebpf.NewMap(&ebpf.MapSpec{Name: "fxt_sched", Type: ebpf.PerfEventArray})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the perf event array - %w", err)
+	}
+	closers = append(closers, events.Close)
+
+	tracepoints := []struct {
+		name   string
+		kind   uint32
+		fields []string
+	}{
+		{name: "sched_switch", kind: eventSwitch, fields: []string{"prev_pid", "next_pid", "prev_state"}},
+		{name: "sched_wakeup", kind: eventWakeup, fields: []string{"pid", "target_cpu"}},
+	}
+	var links []link.Link
+	for _, tracepoint := range tracepoints {
+		fields, err := readFields(tracepoint.name, tracepoint.fields)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Name:         tracepoint.name,
+			Type:         ebpf.TracePoint,
+			License:      "GPL",
+			Instructions: program(tracepoint.kind, events.FD(), fields),
+		})
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to load the %s program - %w", tracepoint.name, err)
+		}
+		closers = append(closers, prog.Close)
+
+		tp, err := link.Tracepoint("sched", tracepoint.name, prog, nil)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to attach to the %s tracepoint - %w", tracepoint.name, err)
+		}
+		closers = append(closers, tp.Close)
+		links = append(links, tp)
+	}
+
+	// Waking the reader for every event would make it the busiest thread of the traced process, so it's only
+	// woken once a buffer is a quarter full. Stop flushes the rest
+	reader, err := perf.NewReaderWithOptions(events, c.bufferSize, perf.ReaderOptions{Watermark: c.bufferSize / 4})
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to create the perf reader - %w", err)
+	}
+
+	var monotonic unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &monotonic); err != nil {
+		reader.Close()
+		closeAll()
+		return nil, fmt.Errorf("failed to read the monotonic clock - %w", err)
+	}
+	c.offset = time.Now().UnixNano() - monotonic.Nano()
+
+	c.stop = func() error {
+		// Stop capturing, then write the events that are still in the buffers before releasing everything
+		var errs []error
+		for _, tp := range links {
+			errs = append(errs, tp.Close())
+		}
+		errs = append(errs, reader.Flush())
+		<-c.done
+		errs = append(errs, reader.Close(), closeAll())
+		return errors.Join(errs...)
+	}
+	go c.read(reader)
+
+	return c, nil
+}
+
+// read writes the events from `reader` until it's flushed or closed
+func (c *Collector) read(reader *perf.Reader) {
+	defer close(c.done)
+
+	var record perf.Record
+	for {
+		err := reader.ReadInto(&record)
+		if errors.Is(err, perf.ErrFlushed) || errors.Is(err, perf.ErrClosed) {
+			return
+		}
+		if err != nil {
+			c.fail(fmt.Errorf("failed to read scheduling events - %w", err))
+			return
+		}
+
+		if record.LostSamples > 0 {
+			c.mu.Lock()
+			c.lost += record.LostSamples
+			c.mu.Unlock()
+			continue
+		}
+
+		e, err := decodeEvent(record.RawSample)
+		if err == nil {
+			err = c.writeEvent(e)
+		}
+		if err != nil {
+			c.fail(fmt.Errorf("failed to write scheduling event - %w", err))
+			return
+		}
+	}
+}
+
+// fail records the first error writing the events
+func (c *Collector) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// readFields reads the layout of a sched tracepoint from tracefs, and returns the fields a program copies
+func readFields(tracepoint string, names []string) ([]field, error) {
+	var lastErr error
+	for _, tracefs := range tracefsPaths {
+		format, err := os.ReadFile(fmt.Sprintf("%s/events/sched/%s/format", tracefs, tracepoint))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		fields, err := parseFormat(string(format))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the format of %s - %w", tracepoint, err)
+		}
+		found, err := lookupFields(fields, names...)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected format of %s - %w", tracepoint, err)
+		}
+		return found, nil
+	}
+
+	return nil, fmt.Errorf("failed to read the format of %s from tracefs - %w", tracepoint, lastErr)
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package fxtebpf_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxtebpf"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	collector, err := fxtebpf.Start(writer)
+	if err != nil {
+		t.Skipf("can't attach the eBPF programs: %v", err)
+	}
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, collector.Stop())
+	require.NoError(t, writer.Close())
+
+	// Sleeping switches this process's threads out and back in
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	threads := map[fxt.KernelObjectID]bool{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if contextSwitch, ok := decoded.(*fxt.ContextSwitchRecord); ok {
+			threads[contextSwitch.OutgoingThreadId] = true
+			threads[contextSwitch.IncomingThreadId] = true
+		}
+	}
+
+	tasks, err := os.ReadDir("/proc/self/task")
+	require.NoError(t, err)
+	captured := false
+	for _, task := range tasks {
+		threadId, err := strconv.ParseUint(task.Name(), 10, 64)
+		require.NoError(t, err)
+		captured = captured || threads[fxt.KernelObjectID(threadId)]
+	}
+	require.True(t, captured, "no context switches of this process were captured")
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package fxtebpf
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/richiesams/fxt"
+)
+
+// Start attaches eBPF programs to the sched_switch and sched_wakeup tracepoints, and writes the scheduling
+// events of the traced processes to `writer` until Stop is called
+//
+// eBPF is only available on Linux, so it always returns an error on other platforms
+func Start(writer *fxt.Writer, options ...Option) (*Collector, error) {
+	return nil, fmt.Errorf("eBPF scheduler capture is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,252 @@
+// Package fxtebpf captures how the Linux kernel schedules the threads of traced processes, and writes it
+// to an FXT trace as context switch and thread wakeup records
+//
+// The sched_switch and sched_wakeup tracepoints are attached with eBPF programs, which are assembled at
+// runtime from the layout of the tracepoints in tracefs, so no compiled BPF objects are needed. Loading
+// them needs root, or CAP_BPF and CAP_PERFMON
+//
+// It lives in its own module so the core fxt package doesn't depend on github.com/cilium/ebpf
+package fxtebpf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/richiesams/fxt"
+)
+
+// Option configures a Collector
+type Option func(*Collector)
+
+// WithProcesses sets the processes whose threads are traced. It defaults to the current process
+func WithProcesses(processIds ...int) Option {
+	return func(c *Collector) {
+		c.processIds = processIds
+	}
+}
+
+// WithTickRate sets the tick rate of the timestamps written to the trace. It defaults to fxt.TicksNanoseconds
+//
+// The kernel's monotonic timestamps are converted to ticks since the Unix epoch, so they line up with events
+// written with fxt.WallClock or TickRate.WallClock
+func WithTickRate(rate fxt.TickRate) Option {
+	return func(c *Collector) {
+		c.tickRate = rate
+	}
+}
+
+// WithBufferSize sets the size of the buffer each CPU's scheduling events are passed to user space in.
+// It defaults to 256 KiB. Events are lost if a buffer fills up before it's read, which Lost counts
+func WithBufferSize(size int) Option {
+	return func(c *Collector) {
+		c.bufferSize = size
+	}
+}
+
+// Collector writes the scheduling events of the traced processes' threads to a Writer, from the time
+// it's started until it's stopped
+//
+// Context switch records are written for the switches where the outgoing or incoming thread is traced, so
+// the other thread can belong to any process. Thread wakeup records are written for traced threads
+type Collector struct {
+	writer     *fxt.Writer
+	processIds []int
+	tickRate   fxt.TickRate
+	bufferSize int
+	// offset is added to the kernel's monotonic timestamps to get nanoseconds since the Unix epoch
+	offset int64
+
+	threads *threadSet
+	lost    uint64
+
+	// stop releases the kernel resources, and done is closed once the events have all been written
+	stop func() error
+	done chan struct{}
+	err  error
+	mu   sync.Mutex
+}
+
+func newCollector(writer *fxt.Writer, options []Option) *Collector {
+	c := &Collector{
+		writer:     writer,
+		processIds: []int{os.Getpid()},
+		tickRate:   fxt.TicksNanoseconds,
+		bufferSize: 256 * 1024,
+		done:       make(chan struct{}),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.threads = newThreadSet(c.processIds)
+
+	return c
+}
+
+// Lost returns the number of scheduling events the kernel dropped because a buffer was full
+func (c *Collector) Lost() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lost
+}
+
+// Stop detaches the eBPF programs, and returns once the events captured so far have been written
+// It returns the first error writing the events, if there was one
+func (c *Collector) Stop() error {
+	err := c.stop()
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return err
+}
+
+// Event kinds, as written by the eBPF programs
+const (
+	eventSwitch uint32 = 0
+	eventWakeup uint32 = 1
+)
+
+// eventSize is the size of the events the eBPF programs write:
+//
+//	u64 monotonic timestamp
+//	u32 CPU
+//	u32 kind
+//	u32 prev_pid for switches, the woken thread for wakeups
+//	u32 next_pid for switches, target_cpu for wakeups
+//	u64 prev_state for switches
+const eventSize = 32
+
+// event is a scheduling event decoded from an eBPF program's output
+type event struct {
+	timestamp uint64
+	cpu       uint32
+	kind      uint32
+	first     uint32
+	second    uint32
+	state     uint64
+}
+
+func decodeEvent(data []byte) (event, error) {
+	if len(data) < eventSize {
+		return event{}, fmt.Errorf("scheduling event is %d bytes, expected %d", len(data), eventSize)
+	}
+	return event{
+		timestamp: binary.LittleEndian.Uint64(data[0:]),
+		cpu:       binary.LittleEndian.Uint32(data[8:]),
+		kind:      binary.LittleEndian.Uint32(data[12:]),
+		first:     binary.LittleEndian.Uint32(data[16:]),
+		second:    binary.LittleEndian.Uint32(data[20:]),
+		state:     binary.LittleEndian.Uint64(data[24:]),
+	}, nil
+}
+
+// writeEvent writes the record for a scheduling event, if it involves a traced thread
+func (c *Collector) writeEvent(e event) error {
+	timestamp := c.tickRate.FromNanoseconds(uint64(int64(e.timestamp) + c.offset))
+
+	switch e.kind {
+	case eventSwitch:
+		if !c.threads.contains(e.first) && !c.threads.contains(e.second) {
+			return nil
+		}
+		return c.writer.AddContextSwitchRecord(uint16(e.cpu), linuxThreadState(e.state), fxt.KernelObjectID(e.first), fxt.KernelObjectID(e.second), timestamp)
+	case eventWakeup:
+		if !c.threads.contains(e.first) {
+			return nil
+		}
+		return c.writer.AddThreadWakeupRecord(uint16(e.second), fxt.KernelObjectID(e.first), timestamp)
+	}
+	return fmt.Errorf("unknown scheduling event kind %d", e.kind)
+}
+
+// Zircon thread states, used for the outgoing thread state of context switch records
+const (
+	threadStateRunning   = 1
+	threadStateSuspended = 2
+	threadStateBlocked   = 3
+	threadStateDying     = 4
+	threadStateDead      = 5
+)
+
+// linuxThreadState maps the prev_state of sched_switch to the closest Zircon thread state
+func linuxThreadState(state uint64) uint8 {
+	// Newer kernels set TASK_REPORT_MAX (0x100) for preempted tasks, and the state is 0 otherwise
+	switch {
+	case state&0xFF == 0:
+		return threadStateRunning
+	case state&0x03 != 0:
+		// TASK_INTERRUPTIBLE or TASK_UNINTERRUPTIBLE
+		return threadStateBlocked
+	case state&0x0C != 0:
+		// __TASK_STOPPED or __TASK_TRACED
+		return threadStateSuspended
+	case state&0x10 != 0:
+		// EXIT_DEAD
+		return threadStateDead
+	default:
+		// EXIT_ZOMBIE, TASK_PARKED, TASK_DEAD, ...
+		return threadStateDying
+	}
+}
+
+// threadSet tells whether a thread belongs to one of the traced processes, caching the answers
+type threadSet struct {
+	processIds []int
+	// isThread reports whether thread `threadId` belongs to process `processId`. It's replaced in tests
+	isThread func(processId int, threadId uint32) bool
+
+	traced map[uint32]bool
+	// untraced is forgotten every second, since new threads of the traced processes can reuse the IDs of
+	// threads that have exited
+	untraced      map[uint32]bool
+	untracedSince time.Time
+}
+
+func newThreadSet(processIds []int) *threadSet {
+	return &threadSet{
+		processIds:    processIds,
+		isThread:      procIsThread,
+		traced:        map[uint32]bool{},
+		untraced:      map[uint32]bool{},
+		untracedSince: time.Now(),
+	}
+}
+
+func (s *threadSet) contains(threadId uint32) bool {
+	// The idle thread is never traced
+	if threadId == 0 {
+		return false
+	}
+	if s.traced[threadId] {
+		return true
+	}
+	if time.Since(s.untracedSince) > time.Second {
+		s.untraced = map[uint32]bool{}
+		s.untracedSince = time.Now()
+	}
+	if s.untraced[threadId] {
+		return false
+	}
+
+	for _, processId := range s.processIds {
+		if s.isThread(processId, threadId) {
+			s.traced[threadId] = true
+			return true
+		}
+	}
+	s.untraced[threadId] = true
+	return false
+}
+
+// procIsThread checks procfs for thread `threadId` of process `processId`
+func procIsThread(processId int, threadId uint32) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d/task/%d", processId, threadId))
+	return err == nil
+}
@@ -0,0 +1,148 @@
+package fxtebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/require"
+)
+
+const schedSwitchFormat = `name: sched_switch
+ID: 316
+format:
+	field:unsigned short common_type;	offset:0;	size:2;	signed:0;
+	field:unsigned char common_flags;	offset:2;	size:1;	signed:0;
+	field:unsigned char common_preempt_count;	offset:3;	size:1;	signed:0;
+	field:int common_pid;	offset:4;	size:4;	signed:1;
+
+	field:char prev_comm[16];	offset:8;	size:16;	signed:0;
+	field:pid_t prev_pid;	offset:24;	size:4;	signed:1;
+	field:int prev_prio;	offset:28;	size:4;	signed:1;
+	field:long prev_state;	offset:32;	size:8;	signed:1;
+	field:char next_comm[16];	offset:40;	size:16;	signed:0;
+	field:pid_t next_pid;	offset:56;	size:4;	signed:1;
+	field:int next_prio;	offset:60;	size:4;	signed:1;
+
+print fmt: "prev_comm=%s prev_pid=%d prev_prio=%d prev_state=%s%s ==> next_comm=%s next_pid=%d next_prio=%d"
+`
+
+func TestParseFormat(t *testing.T) {
+	fields, err := parseFormat(schedSwitchFormat)
+	require.NoError(t, err)
+	require.Equal(t, field{offset: 8, size: 16}, fields["prev_comm"])
+	require.Equal(t, field{offset: 32, size: 8}, fields["prev_state"])
+
+	found, err := lookupFields(fields, "prev_pid", "next_pid", "prev_state")
+	require.NoError(t, err)
+	require.Equal(t, []field{{offset: 24, size: 4}, {offset: 56, size: 4}, {offset: 32, size: 8}}, found)
+
+	_, err = lookupFields(fields, "target_cpu")
+	require.Error(t, err)
+	_, err = lookupFields(fields, "prev_comm")
+	require.Error(t, err)
+}
+
+func TestProgram(t *testing.T) {
+	fields := []field{{offset: 24, size: 4}, {offset: 56, size: 4}, {offset: 32, size: 8}}
+	instructions := program(eventSwitch, 3, fields)
+
+	// The program must assemble, and copy each field with a load from the tracepoint's data
+	var buffer bytes.Buffer
+	require.NoError(t, instructions.Marshal(&buffer, binary.LittleEndian))
+	var loads []int16
+	for _, instruction := range instructions {
+		if instruction.OpCode.Class() == asm.LdXClass && instruction.Src == asm.R6 {
+			loads = append(loads, instruction.Offset)
+		}
+	}
+	require.Equal(t, []int16{24, 56, 32}, loads)
+}
+
+func TestLinuxThreadState(t *testing.T) {
+	require.Equal(t, uint8(threadStateRunning), linuxThreadState(0))
+	require.Equal(t, uint8(threadStateRunning), linuxThreadState(0x100))
+	require.Equal(t, uint8(threadStateBlocked), linuxThreadState(1))
+	require.Equal(t, uint8(threadStateBlocked), linuxThreadState(2))
+	require.Equal(t, uint8(threadStateSuspended), linuxThreadState(4))
+	require.Equal(t, uint8(threadStateDead), linuxThreadState(0x10))
+	require.Equal(t, uint8(threadStateDying), linuxThreadState(0x20))
+}
+
+func encodeEvent(e event) []byte {
+	data := make([]byte, eventSize)
+	binary.LittleEndian.PutUint64(data[0:], e.timestamp)
+	binary.LittleEndian.PutUint32(data[8:], e.cpu)
+	binary.LittleEndian.PutUint32(data[12:], e.kind)
+	binary.LittleEndian.PutUint32(data[16:], e.first)
+	binary.LittleEndian.PutUint32(data[20:], e.second)
+	binary.LittleEndian.PutUint64(data[24:], e.state)
+	return data
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	c := newCollector(writer, []Option{WithProcesses(100)})
+	c.offset = 1000
+	checks := 0
+	c.threads.isThread = func(processId int, threadId uint32) bool {
+		checks++
+		return processId == 100 && (threadId == 100 || threadId == 101)
+	}
+
+	samples := []event{
+		// Traced thread switched out for another process's thread
+		{timestamp: 1, cpu: 2, kind: eventSwitch, first: 101, second: 500, state: 1},
+		// Neither thread is traced
+		{timestamp: 2, cpu: 2, kind: eventSwitch, first: 500, second: 0},
+		// Traced thread woken onto CPU 3
+		{timestamp: 3, cpu: 1, kind: eventWakeup, first: 101, second: 3},
+		{timestamp: 4, cpu: 1, kind: eventWakeup, first: 500, second: 3},
+	}
+	for _, sample := range samples {
+		e, err := decodeEvent(encodeEvent(sample))
+		require.NoError(t, err)
+		require.NoError(t, c.writeEvent(e))
+	}
+	// Thread 500 is only looked up once, and the idle thread never is
+	require.Equal(t, 2, checks)
+	_, err = decodeEvent(make([]byte, 8))
+	require.Error(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var records []interface{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if record.Type != fxt.RecordTypeScheduling {
+			continue
+		}
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		records = append(records, decoded)
+	}
+
+	require.Len(t, records, 2)
+	contextSwitch, ok := records[0].(*fxt.ContextSwitchRecord)
+	require.True(t, ok)
+	require.Equal(t, uint16(2), contextSwitch.CPU)
+	require.Equal(t, uint64(1001), contextSwitch.Timestamp)
+	require.Equal(t, uint8(threadStateBlocked), contextSwitch.OutgoingThreadState)
+	wakeup, ok := records[1].(*fxt.ThreadWakeupRecord)
+	require.True(t, ok)
+	require.Equal(t, uint16(3), wakeup.CPU)
+	require.Equal(t, uint64(1003), wakeup.Timestamp)
+}
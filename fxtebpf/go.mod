@@ -0,0 +1,19 @@
+module github.com/richiesams/fxt/fxtebpf
+
+go 1.25.0
+
+require (
+	github.com/cilium/ebpf v0.16.0
+	github.com/richiesams/fxt v0.0.0
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.47.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/exp v0.0.0-20230224173230-c95f2b4c22f2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/richiesams/fxt => ../
@@ -0,0 +1,143 @@
+package fxtebpf
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/asm"
+)
+
+// field is the position of a field in a tracepoint's data
+type field struct {
+	offset int16
+	size   int
+}
+
+// parseFormat parses the `format` file of a tracepoint in tracefs, and returns its fields by name
+//
+// The fields are listed one per line, like:
+//
+//	field:pid_t prev_pid;	offset:24;	size:4;	signed:1;
+func parseFormat(format string) (map[string]field, error) {
+	fields := map[string]field{}
+	scanner := bufio.NewScanner(strings.NewReader(format))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "field:") {
+			continue
+		}
+
+		var name string
+		var f field
+		for _, part := range strings.Split(line, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "field":
+				declaration := strings.Fields(value)
+				if len(declaration) == 0 {
+					return nil, fmt.Errorf("invalid field %q", line)
+				}
+				name = declaration[len(declaration)-1]
+				if bracket := strings.IndexByte(name, '['); bracket >= 0 {
+					name = name[:bracket]
+				}
+			case "offset", "size":
+				number, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s in field %q - %w", key, line, err)
+				}
+				if key == "offset" {
+					f.offset = int16(number)
+				} else {
+					f.size = number
+				}
+			}
+		}
+		fields[name] = f
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// lookupFields returns the fields of a tracepoint that a program copies, in order
+func lookupFields(fields map[string]field, names ...string) ([]field, error) {
+	found := make([]field, 0, len(names))
+	for _, name := range names {
+		f, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("tracepoint has no %s field", name)
+		}
+		if f.size != 4 && f.size != 8 {
+			return nil, fmt.Errorf("tracepoint field %s is %d bytes, expected 4 or 8", name, f.size)
+		}
+		found = append(found, f)
+	}
+	return found, nil
+}
+
+// Offsets of the fields of the events the programs write, from the start of the event on the stack
+const (
+	eventTimestamp = -eventSize
+	eventCPU       = eventTimestamp + 8
+	eventKind      = eventCPU + 4
+	eventFirst     = eventKind + 4
+	eventSecond    = eventFirst + 4
+	eventState     = eventSecond + 4
+)
+
+// program assembles a tracepoint program that writes an event of kind `kind` to the perf event array with
+// file descriptor `eventsFd`. `fields` are the tracepoint fields copied to the first, second, and state
+// fields of the event. Fields that aren't given are left as zero
+func program(kind uint32, eventsFd int, fields []field) asm.Instructions {
+	instructions := asm.Instructions{
+		// The tracepoint's data is in R1, which calls overwrite
+		asm.Mov.Reg(asm.R6, asm.R1),
+
+		// The event is built on the stack, starting zeroed
+		asm.Mov.Imm(asm.R0, 0),
+		asm.StoreMem(asm.RFP, eventTimestamp, asm.R0, asm.DWord),
+		asm.StoreMem(asm.RFP, eventCPU, asm.R0, asm.DWord),
+		asm.StoreMem(asm.RFP, eventFirst, asm.R0, asm.DWord),
+		asm.StoreMem(asm.RFP, eventState, asm.R0, asm.DWord),
+
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, eventTimestamp, asm.R0, asm.DWord),
+		asm.FnGetSmpProcessorId.Call(),
+		asm.StoreMem(asm.RFP, eventCPU, asm.R0, asm.Word),
+		asm.StoreImm(asm.RFP, eventKind, int64(kind), asm.Word),
+	}
+
+	targets := []int16{eventFirst, eventSecond, eventState}
+	for i, f := range fields {
+		size := asm.Word
+		if f.size == 8 && targets[i] == eventState {
+			size = asm.DWord
+		}
+		instructions = append(instructions,
+			asm.LoadMem(asm.R1, asm.R6, f.offset, size),
+			asm.StoreMem(asm.RFP, targets[i], asm.R1, size),
+		)
+	}
+
+	return append(instructions,
+		// bpf_perf_event_output(ctx, events, BPF_F_CURRENT_CPU, &event, sizeof(event))
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.LoadMapPtr(asm.R2, eventsFd),
+		asm.LoadImm(asm.R3, 0xFFFFFFFF, asm.DWord),
+		asm.Mov.Reg(asm.R4, asm.RFP),
+		asm.Add.Imm(asm.R4, eventTimestamp),
+		asm.Mov.Imm(asm.R5, eventSize),
+		asm.FnPerfEventOutput.Call(),
+
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Return(),
+	)
+}
@@ -0,0 +1,313 @@
+// Package fxtgotrace converts Go execution traces into FXT
+//
+// It reads the execution trace format of Go 1.22 and later, captured with runtime/trace or
+// `go test -trace`, using the parser in golang.org/x/exp/trace. It lives in its own module so the
+// core fxt package doesn't depend on golang.org/x/exp
+package fxtgotrace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richiesams/fxt"
+
+	"golang.org/x/exp/trace"
+)
+
+// Categories of the events written by Convert
+const (
+	CategoryTask   = "go.task"
+	CategoryRegion = "go.region"
+	CategoryLog    = "go.log"
+	CategoryRange  = "go.range"
+	CategoryMetric = "go.metric"
+)
+
+// Zircon thread states, used for the outgoing thread state of context switch records
+const (
+	threadStateRunning = 1
+	threadStateBlocked = 3
+	threadStateDead    = 5
+)
+
+// runtimeThreadId is the thread of the events that aren't attributed to a goroutine, like metrics and GC ranges
+const runtimeThreadId fxt.KernelObjectID = 0
+
+// Convert reads the Go execution trace in `r`, and writes it to `writer` as the events of process `processId`
+//
+// Goroutines are converted to threads, with their goroutine ID as the thread ID, and Ps to CPUs:
+//   - A goroutine starting or stopping running on a P becomes a context switch record on that CPU
+//   - A goroutine being unblocked becomes a thread wakeup record, on the CPU of the goroutine that unblocked it
+//   - Tasks become async spans, with the task ID as the correlation ID. Regions become async spans within
+//     their task, or within an async track of their goroutine for regions outside of any task
+//   - Logs become async instant events within their task, or instant events on their goroutine
+//   - Ranges, like GC and stop-the-world pauses, become async spans named after the range
+//   - Metrics, like the heap goal, become counter events with the ID from fxt.CounterIdFromName
+//
+// The P and M (OS thread) each event happened on are kept in its "P" and "M" arguments, when the trace has them
+//
+// Timestamps are the trace's nanosecond timestamps, so the caller should write an initialization record of
+// fxt.TicksNanoseconds. As with the converters in the convert package, the provider and initialization
+// records are left to the caller
+func Convert(r io.Reader, writer *fxt.Writer, processId fxt.KernelObjectID) error {
+	reader, err := trace.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read Go execution trace - %w", err)
+	}
+
+	c := &converter{
+		writer:     writer,
+		processId:  processId,
+		named:      map[trace.GoID]bool{},
+		running:    map[trace.ProcID]trace.GoID{},
+		taskTypes:  map[trace.TaskID]string{},
+		rangeNames: map[string]bool{},
+	}
+	for {
+		event, err := reader.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read Go execution trace - %w", err)
+		}
+
+		if err := c.convertEvent(event); err != nil {
+			return fmt.Errorf("failed to convert %s event at %d - %w", event.Kind(), event.Time(), err)
+		}
+	}
+}
+
+type converter struct {
+	writer    *fxt.Writer
+	processId fxt.KernelObjectID
+
+	// named is the goroutines whose threads have been named
+	named map[trace.GoID]bool
+	// running is the goroutine running on each P
+	running map[trace.ProcID]trace.GoID
+	// taskTypes is the type of each task that has begun, since end events may not have it
+	taskTypes map[trace.TaskID]string
+	// rangeNames is the ranges that are in progress, by their correlation key
+	rangeNames map[string]bool
+	// namedRuntime is set once the runtime thread has been named
+	namedRuntime bool
+}
+
+func (c *converter) convertEvent(event trace.Event) error {
+	timestamp := uint64(event.Time())
+
+	switch event.Kind() {
+	case trace.EventStateTransition:
+		transition := event.StateTransition()
+		if transition.Resource.Kind == trace.ResourceGoroutine {
+			return c.convertGoroutineTransition(event, transition, timestamp)
+		}
+
+	case trace.EventTaskBegin:
+		task := event.Task()
+		c.taskTypes[task.ID] = task.Type
+		threadId, err := c.thread(event.Goroutine())
+		if err != nil {
+			return err
+		}
+		args := c.arguments(event)
+		if task.Parent != trace.NoTask && task.Parent != trace.BackgroundTask {
+			args["parent"] = uint64(task.Parent)
+		}
+		return c.writer.AddAsyncBeginEventWithArgs(CategoryTask, task.Type, c.processId, threadId, timestamp, uint64(task.ID), args)
+
+	case trace.EventTaskEnd:
+		task := event.Task()
+		name, ok := c.taskTypes[task.ID]
+		if !ok {
+			name = task.Type
+		}
+		delete(c.taskTypes, task.ID)
+		threadId, err := c.thread(event.Goroutine())
+		if err != nil {
+			return err
+		}
+		return c.writer.AddAsyncEndEventWithArgs(CategoryTask, name, c.processId, threadId, timestamp, uint64(task.ID), c.arguments(event))
+
+	case trace.EventRegionBegin, trace.EventRegionEnd:
+		region := event.Region()
+		threadId, err := c.thread(event.Goroutine())
+		if err != nil {
+			return err
+		}
+		correlationId := regionCorrelationId(region.Task, event.Goroutine())
+		if event.Kind() == trace.EventRegionBegin {
+			return c.writer.AddAsyncBeginEventWithArgs(CategoryRegion, region.Type, c.processId, threadId, timestamp, correlationId, c.arguments(event))
+		}
+		return c.writer.AddAsyncEndEventWithArgs(CategoryRegion, region.Type, c.processId, threadId, timestamp, correlationId, c.arguments(event))
+
+	case trace.EventLog:
+		log := event.Log()
+		threadId, err := c.thread(event.Goroutine())
+		if err != nil {
+			return err
+		}
+		name := log.Category
+		if name == "" {
+			name = "log"
+		}
+		args := c.arguments(event)
+		args["message"] = log.Message
+		if log.Task == trace.NoTask || log.Task == trace.BackgroundTask {
+			return c.writer.AddInstantEventWithArgs(CategoryLog, name, c.processId, threadId, timestamp, args)
+		}
+		return c.writer.AddAsyncInstantEventWithArgs(CategoryLog, name, c.processId, threadId, timestamp, uint64(log.Task), args)
+
+	case trace.EventRangeBegin, trace.EventRangeActive, trace.EventRangeEnd:
+		return c.convertRange(event, timestamp)
+
+	case trace.EventMetric:
+		metric := event.Metric()
+		if metric.Value.Kind() != trace.ValueUint64 {
+			return nil
+		}
+		threadId, err := c.thread(trace.NoGoroutine)
+		if err != nil {
+			return err
+		}
+		return c.writer.AddCounterEvent(CategoryMetric, metric.Name, c.processId, threadId, timestamp, map[string]interface{}{"value": metric.Value.Uint64()}, fxt.CounterIdFromName(CategoryMetric, metric.Name))
+	}
+
+	return nil
+}
+
+// convertGoroutineTransition writes the scheduling records for a goroutine changing state
+func (c *converter) convertGoroutineTransition(event trace.Event, transition trace.StateTransition, timestamp uint64) error {
+	goroutine := transition.Resource.Goroutine()
+	threadId, err := c.thread(goroutine)
+	if err != nil {
+		return err
+	}
+	from, to := transition.Goroutine()
+	proc := event.Proc()
+
+	// Goroutines being unblocked are woken by the goroutine that emitted the event, on its P
+	if from == trace.GoWaiting && to == trace.GoRunnable {
+		if proc == trace.NoProc {
+			return nil
+		}
+		return c.writer.AddThreadWakeupRecordWithArgs(uint16(proc), threadId, timestamp, c.transitionArguments(event, transition))
+	}
+
+	if proc == trace.NoProc {
+		return nil
+	}
+	if from == trace.GoRunning && to != trace.GoRunning {
+		delete(c.running, proc)
+		return c.writer.AddContextSwitchRecordWithArgs(uint16(proc), goThreadState(to), threadId, 0, timestamp, c.transitionArguments(event, transition))
+	}
+	if to == trace.GoRunning && from != trace.GoRunning {
+		outgoing := fxt.KernelObjectID(0)
+		if previous, ok := c.running[proc]; ok {
+			// The previous goroutine's switch out wasn't in the trace, so it's assumed to have been preempted
+			outgoing = fxt.KernelObjectID(previous)
+		}
+		c.running[proc] = goroutine
+		return c.writer.AddContextSwitchRecordWithArgs(uint16(proc), threadStateRunning, outgoing, threadId, timestamp, c.transitionArguments(event, transition))
+	}
+	return nil
+}
+
+// convertRange writes the async begin or end event of a range, like a GC cycle
+func (c *converter) convertRange(event trace.Event, timestamp uint64) error {
+	r := event.Range()
+	threadId, err := c.thread(event.Goroutine())
+	if err != nil {
+		return err
+	}
+
+	key := r.Name
+	switch r.Scope.Kind {
+	case trace.ResourceGoroutine:
+		key = fmt.Sprintf("%s/goroutine-%d", r.Name, r.Scope.Goroutine())
+	case trace.ResourceProc:
+		key = fmt.Sprintf("%s/proc-%d", r.Name, r.Scope.Proc())
+	}
+	correlationId := fxt.CorrelationIdFromString(key)
+
+	if event.Kind() == trace.EventRangeEnd {
+		if !c.rangeNames[key] {
+			// The range began before the trace did, and wasn't reported as active
+			return nil
+		}
+		delete(c.rangeNames, key)
+		return c.writer.AddAsyncEndEventWithArgs(CategoryRange, r.Name, c.processId, threadId, timestamp, correlationId, c.arguments(event))
+	}
+	if c.rangeNames[key] {
+		return nil
+	}
+	c.rangeNames[key] = true
+	return c.writer.AddAsyncBeginEventWithArgs(CategoryRange, r.Name, c.processId, threadId, timestamp, correlationId, c.arguments(event))
+}
+
+// thread returns the thread ID of a goroutine, naming its thread the first time it's seen. Events that
+// aren't attributed to a goroutine are written on the runtime thread
+func (c *converter) thread(goroutine trace.GoID) (fxt.KernelObjectID, error) {
+	if goroutine == trace.NoGoroutine {
+		if !c.namedRuntime {
+			if err := c.writer.SetThreadName(c.processId, runtimeThreadId, "runtime"); err != nil {
+				return 0, err
+			}
+			c.namedRuntime = true
+		}
+		return runtimeThreadId, nil
+	}
+
+	threadId := fxt.KernelObjectID(goroutine)
+	if !c.named[goroutine] {
+		if err := c.writer.SetThreadName(c.processId, threadId, fmt.Sprintf("goroutine-%d", goroutine)); err != nil {
+			return 0, err
+		}
+		c.named[goroutine] = true
+	}
+	return threadId, nil
+}
+
+// arguments returns the P and M an event happened on, as arguments
+func (c *converter) arguments(event trace.Event) map[string]interface{} {
+	args := map[string]interface{}{}
+	if proc := event.Proc(); proc != trace.NoProc {
+		args["P"] = int64(proc)
+	}
+	if thread := event.Thread(); thread != trace.NoThread {
+		args["M"] = int64(thread)
+	}
+	return args
+}
+
+// transitionArguments returns the arguments of an event, along with the reason for a state transition
+func (c *converter) transitionArguments(event trace.Event, transition trace.StateTransition) map[string]interface{} {
+	args := c.arguments(event)
+	if transition.Reason != "" {
+		args["reason"] = transition.Reason
+	}
+	return args
+}
+
+// regionCorrelationId returns the correlation ID of the regions of a task. Regions outside of any task are
+// grouped by goroutine instead, since they're only nested within their goroutine
+func regionCorrelationId(task trace.TaskID, goroutine trace.GoID) uint64 {
+	if task == trace.NoTask || task == trace.BackgroundTask {
+		return fxt.CorrelationIdFromString(fmt.Sprintf("goroutine-%d", goroutine))
+	}
+	return uint64(task)
+}
+
+// goThreadState maps the state a goroutine stopped running for to the closest Zircon thread state
+func goThreadState(state trace.GoState) uint8 {
+	switch state {
+	case trace.GoRunnable:
+		return threadStateRunning
+	case trace.GoNotExist:
+		return threadStateDead
+	default:
+		return threadStateBlocked
+	}
+}
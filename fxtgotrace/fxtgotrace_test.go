@@ -0,0 +1,69 @@
+package fxtgotrace_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime/trace"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxtgotrace"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	var capture bytes.Buffer
+	require.NoError(t, trace.Start(&capture))
+
+	ctx, task := trace.NewTask(context.Background(), "Request")
+	trace.WithRegion(ctx, "Handle", func() {
+		trace.Log(ctx, "user", "42")
+	})
+	done := make(chan struct{})
+	go func() {
+		trace.WithRegion(context.Background(), "Background", func() {})
+		close(done)
+	}()
+	<-done
+	task.End()
+	trace.Stop()
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, fxtgotrace.Convert(&capture, writer, 1))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	events := map[string]int{}
+	var switches int
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if record.Type == fxt.RecordTypeScheduling {
+			switches++
+			continue
+		}
+		event, err := reader.Resolve(record)
+		require.NoError(t, err)
+		if event != nil {
+			events[event.Category+"/"+event.Name]++
+		}
+	}
+
+	// Each task and region has a begin and an end event
+	require.Equal(t, 2, events[fxtgotrace.CategoryTask+"/Request"])
+	require.Equal(t, 2, events[fxtgotrace.CategoryRegion+"/Handle"])
+	require.Equal(t, 2, events[fxtgotrace.CategoryRegion+"/Background"])
+	require.Equal(t, 1, events[fxtgotrace.CategoryLog+"/user"])
+	require.NotZero(t, switches)
+}
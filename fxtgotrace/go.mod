@@ -0,0 +1,17 @@
+module github.com/richiesams/fxt/fxtgotrace
+
+go 1.24.0
+
+require (
+	github.com/richiesams/fxt v0.0.0
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/exp v0.0.0-20260112195511-716be5621a96
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/richiesams/fxt => ../
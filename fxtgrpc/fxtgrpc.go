@@ -0,0 +1,257 @@
+// Package fxtgrpc provides gRPC interceptors that write FXT duration events for every RPC
+//
+// It mirrors the fxthttp package: the client side propagates a flow correlation ID to the server in
+// the FlowIdMetadataKey metadata entry, and the two sides are connected with flow events. RPCs that already
+// carry W3C traceparent metadata use a flow ID derived from it instead
+//
+// Events are written on the thread of the goroutine handling the RPC, so the Writer must be created with
+// fxt.WithGoroutineThreads. Servers handle each RPC on a new goroutine, far more than the thread table holds,
+// and the option writes the threads inline once the table is full
+//
+// It lives in its own module so the core fxt package doesn't depend on gRPC
+package fxtgrpc
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/richiesams/fxt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// FlowIdMetadataKey is the metadata key used to propagate the flow correlation ID from client to server
+const FlowIdMetadataKey = "x-fxt-flow-id"
+
+// Option configures the interceptors
+type Option func(*config)
+
+type config struct {
+	category  string
+	processId fxt.KernelObjectID
+	clock     fxt.Clock
+}
+
+// WithCategory sets the category of the events. It defaults to "grpc"
+func WithCategory(category string) Option {
+	return func(c *config) {
+		c.category = category
+	}
+}
+
+// WithProcessId sets the process the events are attributed to. It defaults to the current process
+func WithProcessId(processId fxt.KernelObjectID) Option {
+	return func(c *config) {
+		c.processId = processId
+	}
+}
+
+// WithClock sets the clock used to timestamp events. It defaults to fxt.WallClock
+func WithClock(clock fxt.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+func newConfig(options []Option) config {
+	c := config{
+		category:  "grpc",
+		processId: fxt.KernelObjectID(os.Getpid()),
+		clock:     fxt.WallClock,
+	}
+	for _, option := range options {
+		option(&c)
+	}
+
+	return c
+}
+
+// beginSpan writes the duration begin event for an RPC, and returns whether it was written
+// If it wasn't, the error is reported to the Writer's warning handler, and the RPC isn't traced
+func (c config) beginSpan(writer *fxt.Writer, method string, timestamp uint64) bool {
+	if err := writer.AddDurationBeginEvent(c.category, method, c.processId, fxt.CurrentGoroutine, timestamp); err != nil {
+		writer.Warn(err)
+		return false
+	}
+
+	return true
+}
+
+// endSpan writes the duration end event for an RPC, reporting any error to the Writer's warning handler
+func (c config) endSpan(writer *fxt.Writer, method string, timestamp uint64, rpcErr error) {
+	if err := writer.AddDurationEndEventWithArgs(c.category, method, c.processId, fxt.CurrentGoroutine, timestamp, map[string]interface{}{
+		"method": method,
+		"status": status.Code(rpcErr).String(),
+	}); err != nil {
+		writer.Warn(err)
+	}
+}
+
+// serverSpan writes the events for an RPC handled by a server
+func (c config) serverSpan(ctx context.Context, writer *fxt.Writer, method string, handle func() error) error {
+	begin := c.clock()
+
+	traced := c.beginSpan(writer, method, begin)
+	if traced {
+		if err := c.flowStep(ctx, writer, begin); err != nil {
+			writer.Warn(err)
+		}
+	}
+
+	err := handle()
+
+	if traced {
+		c.endSpan(writer, method, c.clock(), err)
+	}
+
+	return err
+}
+
+// flowStep writes a flow step event for the flow propagated by the client, if any
+func (c config) flowStep(ctx context.Context, writer *fxt.Writer, timestamp uint64) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	if values := md.Get(FlowIdMetadataKey); len(values) > 0 {
+		if flowId, err := strconv.ParseUint(values[0], 16, 64); err == nil {
+			return writer.AddFlowStepEvent(c.category, "rpc", c.processId, fxt.CurrentGoroutine, timestamp, flowId)
+		}
+	}
+	if values := md.Get(fxt.TraceparentHeader); len(values) > 0 {
+		if tc, err := fxt.ParseTraceparent(values[0]); err == nil {
+			return writer.AddTraceFlowStep(c.category, "rpc", c.processId, fxt.CurrentGoroutine, timestamp, tc)
+		}
+	}
+
+	return nil
+}
+
+// outgoingTraceContext returns the trace context set up by other instrumentation in the outgoing metadata, if any
+func outgoingTraceContext(ctx context.Context) (fxt.TraceContext, bool) {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return fxt.TraceContext{}, false
+	}
+	values := md.Get(fxt.TraceparentHeader)
+	if len(values) == 0 {
+		return fxt.TraceContext{}, false
+	}
+
+	tc, err := fxt.ParseTraceparent(values[0])
+	return tc, err == nil
+}
+
+// clientSpan writes the events for an RPC sent by a client
+func (c config) clientSpan(ctx context.Context, writer *fxt.Writer, method string, invoke func(context.Context) error) error {
+	// Reuse the trace context set up by other instrumentation, so the flow matches the server's
+	tc, hasTraceContext := outgoingTraceContext(ctx)
+	var flowId uint64
+	if hasTraceContext {
+		flowId = tc.FlowId()
+	} else {
+		flowId = fxt.NewCorrelationId()
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, FlowIdMetadataKey, strconv.FormatUint(flowId, 16))
+
+	begin := c.clock()
+
+	traced := c.beginSpan(writer, method, begin)
+	if traced {
+		var err error
+		if hasTraceContext {
+			err = writer.AddTraceFlowBegin(c.category, "rpc", c.processId, fxt.CurrentGoroutine, begin, tc)
+		} else {
+			err = writer.AddFlowBeginEvent(c.category, "rpc", c.processId, fxt.CurrentGoroutine, begin, flowId)
+		}
+		if err != nil {
+			writer.Warn(err)
+		}
+	}
+
+	err := invoke(ctx)
+
+	if !traced {
+		return err
+	}
+
+	end := c.clock()
+	var flowErr error
+	if hasTraceContext {
+		flowErr = writer.AddTraceFlowEnd(c.category, "rpc", c.processId, fxt.CurrentGoroutine, end, tc)
+	} else {
+		flowErr = writer.AddFlowEndEvent(c.category, "rpc", c.processId, fxt.CurrentGoroutine, end, flowId)
+	}
+	if flowErr != nil {
+		writer.Warn(flowErr)
+	}
+	c.endSpan(writer, method, end, err)
+
+	return err
+}
+
+// UnaryServerInterceptor returns an interceptor that writes a duration event for every unary RPC handled by the server,
+// with the method and status code as arguments
+//
+// Errors writing events are reported to the Writer's warning handler. Tracing never changes how the RPC is handled
+func UnaryServerInterceptor(writer *fxt.Writer, options ...Option) grpc.UnaryServerInterceptor {
+	c := newConfig(options)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		err := c.serverSpan(ctx, writer, info.FullMethod, func() error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that writes a duration event covering the lifetime
+// of every stream handled by the server
+func StreamServerInterceptor(writer *fxt.Writer, options ...Option) grpc.StreamServerInterceptor {
+	c := newConfig(options)
+
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return c.serverSpan(stream.Context(), writer, info.FullMethod, func() error {
+			return handler(srv, stream)
+		})
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor that writes a duration event for every unary RPC sent by the client,
+// and propagates a flow ID to the server
+func UnaryClientInterceptor(writer *fxt.Writer, options ...Option) grpc.UnaryClientInterceptor {
+	c := newConfig(options)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return c.clientSpan(ctx, writer, method, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that writes a duration event for the creation of every
+// stream opened by the client, and propagates a flow ID to the server
+//
+// The event covers opening the stream, not its full lifetime, since the stream outlives the interceptor
+func StreamClientInterceptor(writer *fxt.Writer, options ...Option) grpc.StreamClientInterceptor {
+	c := newConfig(options)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var stream grpc.ClientStream
+		err := c.clientSpan(ctx, writer, method, func(ctx context.Context) error {
+			var err error
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		})
+
+		return stream, err
+	}
+}
@@ -0,0 +1,171 @@
+package fxtgrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxtgrpc"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptors(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	// Flow events must be inside the RPC's duration event, so the flow check doesn't warn
+	var warnings []error
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"), fxt.WithGoroutineThreads(), fxt.WithFlowCheck(), fxt.WithSpanCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	serverInterceptor := fxtgrpc.UnaryServerInterceptor(writer)
+	clientInterceptor := fxtgrpc.UnaryClientInterceptor(writer)
+
+	// Wire the client interceptor directly to the server interceptor, passing the metadata across
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		require.True(t, ok)
+		require.NotEmpty(t, md.Get(fxtgrpc.FlowIdMetadataKey))
+
+		serverCtx := metadata.NewIncomingContext(context.Background(), md)
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := serverInterceptor(serverCtx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "missing")
+		})
+		return err
+	}
+
+	err = clientInterceptor(context.Background(), "/test.Service/Get", nil, nil, nil, invoker)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+// readFlowIds returns the correlation IDs of the flow events in the trace
+func readFlowIds(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var flowIds []uint64
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			return flowIds
+		}
+		require.NoError(t, err)
+
+		switch decoded := event.Decoded.(type) {
+		case *fxt.FlowBeginEvent:
+			flowIds = append(flowIds, decoded.CorrelationId)
+		case *fxt.FlowStepEvent:
+			flowIds = append(flowIds, decoded.CorrelationId)
+		case *fxt.FlowEndEvent:
+			flowIds = append(flowIds, decoded.CorrelationId)
+		}
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithGoroutineThreads(), fxt.WithFlowCheck(), fxt.WithWarningHandler(func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	}))
+	require.NoError(t, err)
+
+	tc, err := fxt.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+
+	serverInterceptor := fxtgrpc.UnaryServerInterceptor(writer)
+	clientInterceptor := fxtgrpc.UnaryClientInterceptor(writer)
+
+	// The server only sees the traceparent, as if the client side wasn't traced with fxt
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		require.True(t, ok)
+
+		serverCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(fxt.TraceparentHeader, md.Get(fxt.TraceparentHeader)[0]))
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := serverInterceptor(serverCtx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), fxt.TraceparentHeader, tc.Traceparent())
+	require.NoError(t, clientInterceptor(ctx, "/test.Service/Get", nil, nil, nil, invoker))
+	require.NoError(t, writer.Close())
+
+	require.Equal(t, []uint64{tc.FlowId(), tc.FlowId(), tc.FlowId()}, readFlowIds(t, buffer.Bytes()))
+}
+
+func TestManyGoroutines(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithGoroutineThreads(), fxt.WithWarningHandler(func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	}))
+	require.NoError(t, err)
+
+	serverInterceptor := fxtgrpc.UnaryServerInterceptor(writer)
+
+	// Each RPC is handled on its own goroutine, so there are more threads than the thread table holds
+	const rpcs = 300
+	for i := 0; i < rpcs; i++ {
+		done := make(chan struct{})
+		go func(i int) {
+			defer close(done)
+			info := &grpc.UnaryServerInfo{FullMethod: fmt.Sprintf("/test.Service/Get%d", i)}
+			_, _ = serverInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		}(i)
+		<-done
+	}
+
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if _, ok := event.Decoded.(*fxt.DurationEndEvent); ok {
+			names = append(names, event.Name)
+		}
+	}
+	require.Len(t, names, rpcs)
+	require.Equal(t, fmt.Sprintf("/test.Service/Get%d", rpcs-1), names[len(names)-1])
+}
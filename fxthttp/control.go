@@ -0,0 +1,183 @@
+package fxthttp
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/richiesams/fxt"
+)
+
+// ControlOption configures the handler created by NewControlHandler
+type ControlOption func(*controlConfig)
+
+type controlConfig struct {
+	token    string
+	nextPath func() string
+}
+
+// WithToken makes the control handler reject requests that don't carry `token` in an
+// "Authorization: Bearer <token>" header
+func WithToken(token string) ControlOption {
+	return func(c *controlConfig) {
+		c.token = token
+	}
+}
+
+// WithRotation enables the rotate operation, which ends the current trace and starts a new one in the
+// file at the path returned by `nextPath`, like fxt.Writer.Reset
+func WithRotation(nextPath func() string) ControlOption {
+	return func(c *controlConfig) {
+		c.nextPath = nextPath
+	}
+}
+
+// ControlStatus is the JSON body of the control handler's responses
+type ControlStatus struct {
+	// Enabled is whether the Writer is writing events, as set by the start and stop operations
+	Enabled bool `json:"enabled"`
+	// TickRate is the number of ticks per second of the trace's timestamps, or zero if it hasn't been set
+	TickRate uint64 `json:"tick_rate"`
+	// FormatVersion is the revision of the spec the trace is written in
+	FormatVersion string `json:"format_version"`
+	// Path is the file the trace was last rotated to, if it has been
+	Path string `json:"path,omitempty"`
+	// Rotations is the number of times the trace has been rotated
+	Rotations int `json:"rotations"`
+}
+
+// controlHandler serves the operations of NewControlHandler
+type controlHandler struct {
+	writer *fxt.Writer
+	config controlConfig
+
+	// rotateMu serializes the rotations, so `path` and `rotations` match the file being written
+	rotateMu  sync.Mutex
+	path      string
+	rotations int
+}
+
+// NewControlHandler returns a handler that lets operators control the tracing of a live service, with
+// requests to the last element of the URL path:
+//
+//   - POST start resumes writing events, with fxt.Writer.SetEnabled
+//   - POST stop pauses writing events
+//   - POST flush writes everything buffered to the destination
+//   - POST rotate ends the current trace and starts a new one, when enabled with WithRotation
+//   - GET status reports the state of the Writer
+//
+// Each successful request responds with the ControlStatus as JSON. It's meant to be mounted on an internal
+// port, like:
+//
+//	mux.Handle("/debug/fxt/", fxthttp.NewControlHandler(writer, fxthttp.WithToken(token)))
+//
+// and driven with curl:
+//
+//	curl -X POST -H "Authorization: Bearer $TOKEN" http://localhost:6060/debug/fxt/flush
+func NewControlHandler(writer *fxt.Writer, options ...ControlOption) http.Handler {
+	h := &controlHandler{writer: writer}
+	for _, option := range options {
+		option(&h.config)
+	}
+
+	return h
+}
+
+func (h *controlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	operation := path.Base(r.URL.Path)
+	method := http.MethodPost
+	if operation == "status" {
+		method = http.MethodGet
+	}
+
+	var run func() error
+	switch operation {
+	case "start":
+		run = func() error {
+			h.writer.SetEnabled(true)
+			return nil
+		}
+	case "stop":
+		run = func() error {
+			h.writer.SetEnabled(false)
+			return nil
+		}
+	case "flush":
+		run = h.writer.Flush
+	case "rotate":
+		if h.config.nextPath == nil {
+			http.Error(w, "rotation is not enabled", http.StatusNotImplemented)
+			return
+		}
+		run = h.rotate
+	case "status":
+		run = func() error { return nil }
+	default:
+		http.Error(w, fmt.Sprintf("unknown operation %q", operation), http.StatusNotFound)
+		return
+	}
+
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, fmt.Sprintf("%s must be a %s request", operation, method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := run(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to %s the trace - %v", operation, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.status())
+}
+
+// authorized reports whether the request carries the token set with WithToken, if any
+func (h *controlHandler) authorized(r *http.Request) bool {
+	if h.config.token == "" {
+		return true
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.config.token)) == 1
+}
+
+func (h *controlHandler) rotate() error {
+	h.rotateMu.Lock()
+	defer h.rotateMu.Unlock()
+
+	nextPath := h.config.nextPath()
+	if err := h.writer.Reset(nextPath); err != nil {
+		return err
+	}
+	h.path = nextPath
+	h.rotations++
+	return nil
+}
+
+func (h *controlHandler) status() ControlStatus {
+	h.rotateMu.Lock()
+	defer h.rotateMu.Unlock()
+
+	return ControlStatus{
+		Enabled:       h.writer.Enabled(),
+		TickRate:      uint64(h.writer.TickRate()),
+		FormatVersion: h.writer.FormatVersion().String(),
+		Path:          h.path,
+		Rotations:     h.rotations,
+	}
+}
@@ -0,0 +1,106 @@
+package fxthttp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxthttp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlHandler(t *testing.T) {
+	tempDir := t.TempDir()
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "0.fxt"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, writer.Close())
+	}()
+
+	next := 0
+	nextPath := func() string {
+		next++
+		return filepath.Join(tempDir, fmt.Sprintf("%d.fxt", next))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/fxt/", fxthttp.NewControlHandler(writer, fxthttp.WithToken("secret"), fxthttp.WithRotation(nextPath)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	do := func(method string, operation string, token string) (*http.Response, fxthttp.ControlStatus) {
+		request, err := http.NewRequest(method, server.URL+"/debug/fxt/"+operation, nil)
+		require.NoError(t, err)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		var status fxthttp.ControlStatus
+		if response.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+		}
+		return response, status
+	}
+
+	response, _ := do(http.MethodGet, "status", "")
+	require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	response, _ = do(http.MethodGet, "status", "wrong")
+	require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, status := do(http.MethodGet, "status", "secret")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.True(t, status.Enabled)
+	require.Zero(t, status.Rotations)
+
+	response, status = do(http.MethodPost, "stop", "secret")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.False(t, status.Enabled)
+	require.False(t, writer.Enabled())
+
+	response, status = do(http.MethodPost, "start", "secret")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.True(t, status.Enabled)
+
+	response, _ = do(http.MethodPost, "flush", "secret")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, status = do(http.MethodPost, "rotate", "secret")
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, 1, status.Rotations)
+	require.Equal(t, filepath.Join(tempDir, "1.fxt"), status.Path)
+	require.FileExists(t, status.Path)
+
+	// Operations changing the trace must be POSTs
+	response, _ = do(http.MethodGet, "stop", "secret")
+	require.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	require.Equal(t, http.MethodPost, response.Header.Get("Allow"))
+	require.True(t, writer.Enabled())
+
+	response, _ = do(http.MethodPost, "explode", "secret")
+	require.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestControlHandlerWithoutRotation(t *testing.T) {
+	writer, err := fxt.NewWriter(filepath.Join(t.TempDir(), "test.fxt"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, writer.Close())
+	}()
+
+	recorder := httptest.NewRecorder()
+	fxthttp.NewControlHandler(writer).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/rotate", nil))
+	require.Equal(t, http.StatusNotImplemented, recorder.Code)
+
+	// Without a token, every request is allowed
+	recorder = httptest.NewRecorder()
+	fxthttp.NewControlHandler(writer).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
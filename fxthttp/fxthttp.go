@@ -0,0 +1,254 @@
+// Package fxthttp provides net/http middleware that writes FXT duration events for every request
+//
+// The client and server sides of a request are connected with flow events, keyed off an ID that
+// the client propagates in the FlowIdHeader request header. When both sides are traced into
+// the same file (or the files are merged), the viewer draws an arrow from the client's request
+// to the server's handler and back again
+//
+// Requests that already carry a W3C traceparent header (for example, from OpenTelemetry instrumentation)
+// use a flow ID derived from it instead, so they stitch together with other services' instrumentation
+//
+// Events are written on the thread of the goroutine handling the request, so the Writer must be created
+// with fxt.WithGoroutineThreads. Servers handle each connection on a new goroutine, far more than the
+// thread table holds, and the option writes the threads inline once the table is full
+//
+// NewControlHandler serves endpoints to start, stop, flush, and rotate a trace, so operators can control the
+// tracing of a live service with curl
+package fxthttp
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/richiesams/fxt"
+)
+
+// FlowIdHeader is the request header used to propagate the flow correlation ID from client to server
+const FlowIdHeader = "X-Fxt-Flow-Id"
+
+// Option configures the middleware
+type Option func(*config)
+
+type config struct {
+	category  string
+	processId fxt.KernelObjectID
+	clock     fxt.Clock
+	spanName  func(*http.Request) string
+}
+
+// WithCategory sets the category of the events. It defaults to "http"
+func WithCategory(category string) Option {
+	return func(c *config) {
+		c.category = category
+	}
+}
+
+// WithProcessId sets the process the events are attributed to. It defaults to the current process
+func WithProcessId(processId fxt.KernelObjectID) Option {
+	return func(c *config) {
+		c.processId = processId
+	}
+}
+
+// WithClock sets the clock used to timestamp events. It defaults to fxt.WallClock
+func WithClock(clock fxt.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithSpanName sets the function used to name the duration event for a request.
+// By default, the name is the request method followed by the URL path
+func WithSpanName(spanName func(*http.Request) string) Option {
+	return func(c *config) {
+		c.spanName = spanName
+	}
+}
+
+func newConfig(options []Option) config {
+	c := config{
+		category:  "http",
+		processId: fxt.KernelObjectID(os.Getpid()),
+		clock:     fxt.WallClock,
+		spanName:  defaultSpanName,
+	}
+	for _, option := range options {
+		option(&c)
+	}
+
+	return c
+}
+
+func defaultSpanName(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// NewHandler wraps `next` so every request it serves is written to `writer` as a duration event,
+// with the method, URL, and response status code as arguments
+//
+// If the request carries a FlowIdHeader or traceparent header, a flow step event is written inside the
+// duration event, connecting it to the client's request
+//
+// Errors writing events are reported to the Writer's warning handler, since there's no one to return them to.
+// Tracing never changes how the request is handled
+func NewHandler(writer *fxt.Writer, next http.Handler, options ...Option) http.Handler {
+	c := newConfig(options)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := c.spanName(r)
+		begin := c.clock()
+
+		traced := c.beginSpan(writer, name, begin)
+		if traced {
+			if err := c.requestFlowStep(writer, r, begin); err != nil {
+				writer.Warn(err)
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if traced {
+			c.endSpan(writer, name, c.clock(), map[string]interface{}{
+				"method": r.Method,
+				"url":    r.URL.String(),
+				"status": int32(recorder.status),
+			})
+		}
+	})
+}
+
+// beginSpan writes the duration begin event for a request, and returns whether it was written
+// If it wasn't, the error is reported to the Writer's warning handler, and the request isn't traced
+func (c config) beginSpan(writer *fxt.Writer, name string, timestamp uint64) bool {
+	if err := writer.AddDurationBeginEvent(c.category, name, c.processId, fxt.CurrentGoroutine, timestamp); err != nil {
+		writer.Warn(err)
+		return false
+	}
+
+	return true
+}
+
+// endSpan writes the duration end event for a request, reporting any error to the Writer's warning handler
+func (c config) endSpan(writer *fxt.Writer, name string, timestamp uint64, arguments map[string]interface{}) {
+	if err := writer.AddDurationEndEventWithArgs(c.category, name, c.processId, fxt.CurrentGoroutine, timestamp, arguments); err != nil {
+		writer.Warn(err)
+	}
+}
+
+// requestFlowStep writes a flow step event for the flow propagated by the client, if any
+func (c config) requestFlowStep(writer *fxt.Writer, r *http.Request, timestamp uint64) error {
+	if flowId, err := strconv.ParseUint(r.Header.Get(FlowIdHeader), 16, 64); err == nil {
+		return writer.AddFlowStepEvent(c.category, "request", c.processId, fxt.CurrentGoroutine, timestamp, flowId)
+	}
+	if tc, err := fxt.ParseTraceparent(r.Header.Get(fxt.TraceparentHeader)); err == nil {
+		return writer.AddTraceFlowStep(c.category, "request", c.processId, fxt.CurrentGoroutine, timestamp, tc)
+	}
+
+	return nil
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Transport is an http.RoundTripper that writes a duration event for every request it sends,
+// and propagates a flow ID to the server in the FlowIdHeader header
+type Transport struct {
+	writer *fxt.Writer
+	base   http.RoundTripper
+	config config
+}
+
+// NewTransport wraps `base` so every request it sends is written to `writer`.
+// If `base` is nil, http.DefaultTransport is used
+func NewTransport(writer *fxt.Writer, base http.RoundTripper, options ...Option) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		writer: writer,
+		base:   base,
+		config: newConfig(options),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+//
+// A flow begin event is written when the request is sent, and a flow end event when the response
+// arrives, both inside the duration event for the request
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c := t.config
+	// Reuse the trace context set up by other instrumentation, so the flow matches the server's
+	tc, tcErr := fxt.ParseTraceparent(r.Header.Get(fxt.TraceparentHeader))
+	var flowId uint64
+	if tcErr == nil {
+		flowId = tc.FlowId()
+	} else {
+		flowId = fxt.NewCorrelationId()
+	}
+
+	// RoundTrippers must not modify the caller's request
+	r = r.Clone(r.Context())
+	r.Header.Set(FlowIdHeader, strconv.FormatUint(flowId, 16))
+
+	name := c.spanName(r)
+	begin := c.clock()
+
+	traced := c.beginSpan(t.writer, name, begin)
+	if traced {
+		var err error
+		if tcErr == nil {
+			err = t.writer.AddTraceFlowBegin(c.category, "request", c.processId, fxt.CurrentGoroutine, begin, tc)
+		} else {
+			err = t.writer.AddFlowBeginEvent(c.category, "request", c.processId, fxt.CurrentGoroutine, begin, flowId)
+		}
+		if err != nil {
+			t.writer.Warn(err)
+		}
+	}
+
+	response, err := t.base.RoundTrip(r)
+
+	if !traced {
+		return response, err
+	}
+
+	end := c.clock()
+	var flowErr error
+	if tcErr == nil {
+		flowErr = t.writer.AddTraceFlowEnd(c.category, "request", c.processId, fxt.CurrentGoroutine, end, tc)
+	} else {
+		flowErr = t.writer.AddFlowEndEvent(c.category, "request", c.processId, fxt.CurrentGoroutine, end, flowId)
+	}
+	if flowErr != nil {
+		t.writer.Warn(flowErr)
+	}
+
+	args := map[string]interface{}{
+		"method": r.Method,
+		"url":    r.URL.String(),
+	}
+	if err != nil {
+		args["error"] = err.Error()
+	} else {
+		args["status"] = int32(response.StatusCode)
+	}
+	c.endSpan(t.writer, name, end, args)
+
+	return response, err
+}
@@ -0,0 +1,114 @@
+package fxthttp_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxthttp"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	// Flow events must be inside the request's duration event, so the flow check doesn't warn
+	var warnings []error
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"), fxt.WithGoroutineThreads(), fxt.WithFlowCheck(), fxt.WithSpanCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	var receivedFlowId string
+	handler := fxthttp.NewHandler(writer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedFlowId = r.Header.Get(fxthttp.FlowIdHeader)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &http.Client{Transport: fxthttp.NewTransport(writer, nil)}
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/brew", nil)
+	require.NoError(t, err)
+
+	response, err := client.Do(request)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+
+	require.Equal(t, http.StatusTeapot, response.StatusCode)
+	require.NotEmpty(t, receivedFlowId)
+
+	// The caller's request is left untouched
+	require.Empty(t, request.Header.Get(fxthttp.FlowIdHeader))
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestMiddlewareManyGoroutines(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithGoroutineThreads(), fxt.WithWarningHandler(func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	}))
+	require.NoError(t, err)
+
+	handler := fxthttp.NewHandler(writer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Each request is served on its own goroutine, like a server handling new connections,
+	// so there are more threads than the thread table holds
+	const requests = 300
+	for i := 0; i < requests; i++ {
+		done := make(chan struct{})
+		go func(i int) {
+			defer close(done)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/requests/%d", i), nil))
+		}(i)
+		<-done
+	}
+
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var names []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if _, ok := event.Decoded.(*fxt.DurationEndEvent); ok {
+			names = append(names, event.Name)
+		}
+	}
+	require.Len(t, names, requests)
+	require.Equal(t, fmt.Sprintf("GET /requests/%d", requests-1), names[len(names)-1])
+}
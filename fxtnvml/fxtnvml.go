@@ -0,0 +1,26 @@
+// Package fxtnvml samples NVIDIA GPUs with NVML, and writes their utilization and memory to an FXT trace as
+// counter events, so GPU work can be seen alongside the application's events
+//
+// NVML is loaded from the driver's libnvidia-ml.so.1 at runtime, so building needs cgo but not the driver.
+// Without cgo, or on platforms other than Linux, NewSampler always returns an error
+//
+// It lives in its own module so the core fxt package doesn't depend on github.com/NVIDIA/go-nvml
+package fxtnvml
+
+import (
+	"errors"
+
+	"github.com/richiesams/fxt"
+)
+
+// Sampler is an fxt.Sampler that holds NVML loaded until it's closed
+type Sampler struct {
+	*fxt.Sampler
+
+	shutdown func() error
+}
+
+// Close stops sampling, and unloads NVML. It returns the first error encountered while sampling, if any
+func (s *Sampler) Close() error {
+	return errors.Join(s.Stop(), s.shutdown())
+}
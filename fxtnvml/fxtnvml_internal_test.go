@@ -0,0 +1,118 @@
+//go:build linux && cgo
+
+package fxtnvml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDevice struct {
+	utilization nvml.Utilization
+	memory      nvml.Memory
+	processes   []nvml.ProcessUtilizationSample
+	processRet  nvml.Return
+
+	lastSeen []uint64
+}
+
+func (d *fakeDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	return d.utilization, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	return d.memory, nvml.SUCCESS
+}
+
+func (d *fakeDevice) GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return) {
+	d.lastSeen = append(d.lastSeen, lastSeenTimestamp)
+	return d.processes, d.processRet
+}
+
+func TestSample(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	first := &fakeDevice{
+		utilization: nvml.Utilization{Gpu: 80, Memory: 40},
+		memory:      nvml.Memory{Total: 1000, Used: 250},
+		processes: []nvml.ProcessUtilizationSample{
+			{Pid: 200, TimeStamp: 10, SmUtil: 30},
+			{Pid: 100, TimeStamp: 20, SmUtil: 50},
+			{Pid: 200, TimeStamp: 30, SmUtil: 60},
+		},
+	}
+	second := &fakeDevice{
+		utilization: nvml.Utilization{Gpu: 5},
+		processRet:  nvml.ERROR_NOT_SUPPORTED,
+	}
+	g := newGPUs(writer, []device{first, second})
+
+	require.NoError(t, g.sample("GPU", 1, 2, 100))
+	require.NoError(t, g.sample("GPU", 1, 2, 200))
+	require.NoError(t, writer.Close())
+
+	// Process samples are only asked for since the newest one already seen
+	require.Equal(t, []uint64{0, 30}, first.lastSeen)
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	type counter struct {
+		name      string
+		processId fxt.KernelObjectID
+		values    map[string]interface{}
+	}
+	var counters []counter
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if event.Timestamp != 100 {
+			continue
+		}
+
+		values := map[string]interface{}{}
+		for _, argument := range event.Arguments {
+			values[argument.Key] = argument.Value
+		}
+		counters = append(counters, counter{name: event.Name, processId: event.Thread.ProcessId, values: values})
+	}
+
+	require.Equal(t, []counter{
+		{name: "GPU 0", processId: 1, values: map[string]interface{}{
+			"gpu_percent":              uint32(80),
+			"memory_bandwidth_percent": uint32(40),
+			"memory_used_bytes":        uint64(250),
+			"memory_total_bytes":       uint64(1000),
+		}},
+		// The newest sample of each process, attributed to that process
+		{name: "GPU 0 Process", processId: 100, values: map[string]interface{}{
+			"sm_percent":      uint32(50),
+			"memory_percent":  uint32(0),
+			"encoder_percent": uint32(0),
+			"decoder_percent": uint32(0),
+		}},
+		{name: "GPU 0 Process", processId: 200, values: map[string]interface{}{
+			"sm_percent":      uint32(60),
+			"memory_percent":  uint32(0),
+			"encoder_percent": uint32(0),
+			"decoder_percent": uint32(0),
+		}},
+		{name: "GPU 1", processId: 1, values: map[string]interface{}{
+			"gpu_percent":              uint32(5),
+			"memory_bandwidth_percent": uint32(0),
+			"memory_used_bytes":        uint64(0),
+			"memory_total_bytes":       uint64(0),
+		}},
+	}, counters)
+}
@@ -0,0 +1,29 @@
+package fxtnvml_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxtnvml"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	sampler, err := fxtnvml.NewSampler(writer, fxt.WithSamplerInterval(time.Millisecond))
+	if err != nil {
+		t.Skipf("NVML is not available - %v", err)
+	}
+
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, sampler.Start())
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, sampler.Close())
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,17 @@
+module github.com/richiesams/fxt/fxtnvml
+
+go 1.25.0
+
+require (
+	github.com/NVIDIA/go-nvml v0.12.4-0
+	github.com/richiesams/fxt v0.0.0
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/richiesams/fxt => ../
@@ -0,0 +1,153 @@
+//go:build linux && cgo
+
+package fxtnvml
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// NewSampler loads NVML, and creates a Sampler writing the utilization and memory of every GPU to `writer`.
+// Call Start to begin sampling, and Close once done with it
+//
+// Each sample writes a "GPU <n>" counter for every GPU, numbered like nvidia-smi, with:
+//   - "gpu_percent", the percentage of time a kernel was running on the GPU
+//   - "memory_bandwidth_percent", the percentage of time its memory was being read or written
+//   - "memory_used_bytes" and "memory_total_bytes"
+//
+// Then, on GPUs that support it, a "GPU <n> Process" counter is written for each process that used the GPU
+// since the previous sample, with its "sm_percent", "memory_percent", "encoder_percent" and "decoder_percent".
+// These are attributed to the process using the GPU, rather than the one set with fxt.WithSamplerThread
+//
+// Events are written to the "GPU" category unless overridden with fxt.WithSamplerCategory
+func NewSampler(writer *fxt.Writer, options ...fxt.SamplerOption) (*Sampler, error) {
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML - %w", ret)
+	}
+	shutdown := func() error {
+		if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to shut down NVML - %w", ret)
+		}
+		return nil
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		shutdown()
+		return nil, fmt.Errorf("failed to count the GPUs - %w", ret)
+	}
+	devices := make([]device, 0, count)
+	for i := 0; i < count; i++ {
+		d, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			shutdown()
+			return nil, fmt.Errorf("failed to get GPU %d - %w", i, ret)
+		}
+		devices = append(devices, d)
+	}
+
+	g := newGPUs(writer, devices)
+	return &Sampler{
+		Sampler:  fxt.NewSampler(g.sample, append([]fxt.SamplerOption{fxt.WithSamplerCategory("GPU")}, options...)...),
+		shutdown: shutdown,
+	}, nil
+}
+
+// device is the part of nvml.Device that's sampled. It's replaced in tests
+type device interface {
+	GetUtilizationRates() (nvml.Utilization, nvml.Return)
+	GetMemoryInfo() (nvml.Memory, nvml.Return)
+	GetProcessUtilization(lastSeenTimestamp uint64) ([]nvml.ProcessUtilizationSample, nvml.Return)
+}
+
+// gpus samples a set of GPUs
+type gpus struct {
+	writer  *fxt.Writer
+	devices []device
+	// lastSeen is the timestamp of the newest process sample of each GPU, so NVML only returns newer ones
+	lastSeen []uint64
+}
+
+func newGPUs(writer *fxt.Writer, devices []device) *gpus {
+	return &gpus{
+		writer:   writer,
+		devices:  devices,
+		lastSeen: make([]uint64, len(devices)),
+	}
+}
+
+func (g *gpus) sample(category string, processId fxt.KernelObjectID, threadId fxt.KernelObjectID, timestamp uint64) error {
+	for i, d := range g.devices {
+		name := fmt.Sprintf("GPU %d", i)
+
+		utilization, ret := d.GetUtilizationRates()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to read the utilization of %s - %w", name, ret)
+		}
+		memory, ret := d.GetMemoryInfo()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to read the memory of %s - %w", name, ret)
+		}
+		err := g.writer.AddCounterEvent(category, name, processId, threadId, timestamp, map[string]interface{}{
+			"gpu_percent":              utilization.Gpu,
+			"memory_bandwidth_percent": utilization.Memory,
+			"memory_used_bytes":        memory.Used,
+			"memory_total_bytes":       memory.Total,
+		}, 0)
+		if err != nil {
+			return err
+		}
+
+		processes, ret := d.GetProcessUtilization(g.lastSeen[i])
+		// Not every GPU keeps per process samples, and there are none if no process used the GPU since the last sample
+		if ret == nvml.ERROR_NOT_SUPPORTED || ret == nvml.ERROR_NOT_FOUND {
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("failed to read the process utilization of %s - %w", name, ret)
+		}
+		if err := g.writeProcesses(i, name+" Process", category, threadId, timestamp, processes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeProcesses writes the newest sample of each process that used GPU `index`
+func (g *gpus) writeProcesses(index int, name string, category string, threadId fxt.KernelObjectID, timestamp uint64, processes []nvml.ProcessUtilizationSample) error {
+	newest := map[uint32]nvml.ProcessUtilizationSample{}
+	for _, process := range processes {
+		if last, ok := newest[process.Pid]; !ok || process.TimeStamp > last.TimeStamp {
+			newest[process.Pid] = process
+		}
+		if process.TimeStamp > g.lastSeen[index] {
+			g.lastSeen[index] = process.TimeStamp
+		}
+	}
+
+	pids := make([]uint32, 0, len(newest))
+	for pid := range newest {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	for _, pid := range pids {
+		process := newest[pid]
+		err := g.writer.AddCounterEvent(category, name, fxt.KernelObjectID(pid), threadId, timestamp, map[string]interface{}{
+			"sm_percent":      process.SmUtil,
+			"memory_percent":  process.MemUtil,
+			"encoder_percent": process.EncUtil,
+			"decoder_percent": process.DecUtil,
+		}, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
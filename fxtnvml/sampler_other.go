@@ -0,0 +1,17 @@
+//go:build !linux || !cgo
+
+package fxtnvml
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/richiesams/fxt"
+)
+
+// NewSampler loads NVML, and creates a Sampler writing the utilization and memory of every GPU to `writer`
+//
+// NVML is only loaded on Linux with cgo, so it always returns an error otherwise
+func NewSampler(writer *fxt.Writer, options ...fxt.SamplerOption) (*Sampler, error) {
+	return nil, fmt.Errorf("NVML sampling is not supported on %s, or without cgo", runtime.GOOS)
+}
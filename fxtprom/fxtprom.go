@@ -0,0 +1,111 @@
+// Package fxtprom bridges Prometheus metrics into FXT counter events
+//
+// It lives in its own module so the core fxt package doesn't depend on the Prometheus client
+package fxtprom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// NewSampler creates an fxt.Sampler that gathers metrics from `gatherer` on every tick, and writes the
+// selected gauges and counters to `writer` as counter events. Call Start to begin sampling
+//
+// Each metric family is written as one counter event named after the family. Every labelled series
+// in the family becomes an argument, keyed by its labels in the form `key="value",key="value"`.
+// Series without labels use the key "value". An event holds at most 15 arguments, so families with more
+// series are split across several counter events of the same name. Histograms and summaries are skipped
+//
+// `selected` filters families by name. If it's nil, all gauges and counters are written
+//
+// Events are written to the "prometheus" category unless overridden with fxt.WithSamplerCategory
+func NewSampler(writer *fxt.Writer, gatherer prometheus.Gatherer, selected func(name string) bool, options ...fxt.SamplerOption) *fxt.Sampler {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	sample := func(category string, processId fxt.KernelObjectID, threadId fxt.KernelObjectID, timestamp uint64) error {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return fmt.Errorf("failed to gather prometheus metrics - %w", err)
+		}
+
+		for _, family := range families {
+			if selected != nil && !selected(family.GetName()) {
+				continue
+			}
+
+			args := familyArgs(family)
+			if len(args) == 0 {
+				continue
+			}
+
+			for _, chunk := range fxt.SplitArgs(args) {
+				if err := writer.AddCounterEvent(category, family.GetName(), processId, threadId, timestamp, chunk, 0); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return fxt.NewSampler(sample, append([]fxt.SamplerOption{fxt.WithSamplerCategory("prometheus")}, options...)...)
+}
+
+// SelectNames returns a filter for NewSampler that selects the families with the given names
+func SelectNames(names ...string) func(name string) bool {
+	set := map[string]bool{}
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return func(name string) bool {
+		return set[name]
+	}
+}
+
+// familyArgs converts the gauge and counter series in a metric family into counter arguments
+func familyArgs(family *dto.MetricFamily) map[string]interface{} {
+	args := map[string]interface{}{}
+
+	for _, metric := range family.GetMetric() {
+		var value float64
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			value = metric.GetGauge().GetValue()
+		case dto.MetricType_COUNTER:
+			value = metric.GetCounter().GetValue()
+		case dto.MetricType_UNTYPED:
+			value = metric.GetUntyped().GetValue()
+		default:
+			return nil
+		}
+
+		args[labelsKey(metric.GetLabel())] = value
+	}
+
+	return args
+}
+
+// labelsKey formats a label set as an argument key
+func labelsKey(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return "value"
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+	}
+	// The client already sorts labels, but be defensive so keys are stable
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,100 @@
+package fxtprom_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+	"github.com/richiesams/fxt/fxtprom"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	registry := prometheus.NewRegistry()
+
+	inFlight := prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight"})
+	registry.MustRegister(inFlight)
+	inFlight.Set(4)
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"method", "code"})
+	registry.MustRegister(requests)
+	requests.WithLabelValues("GET", "200").Add(10)
+	requests.WithLabelValues("POST", "500").Inc()
+
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds"})
+	registry.MustRegister(latency)
+	latency.Observe(0.25)
+
+	sampler := fxtprom.NewSampler(writer, registry, fxtprom.SelectNames("in_flight", "requests_total", "latency_seconds"))
+
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestSamplerManySeries(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"path"})
+	registry.MustRegister(requests)
+	for i := 0; i < 40; i++ {
+		requests.WithLabelValues(fmt.Sprintf("/%d", i)).Add(float64(i))
+	}
+
+	sampler := fxtprom.NewSampler(writer, registry, nil)
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	series := map[string]interface{}{}
+	events := 0
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "requests_total", event.Name)
+		require.LessOrEqual(t, len(event.Arguments), 15)
+		for _, argument := range event.Arguments {
+			series[argument.Key] = argument.Value
+		}
+		events++
+	}
+	require.Equal(t, 3, events)
+	require.Len(t, series, 40)
+	require.Equal(t, float64(39), series[`path="/39"`])
+}
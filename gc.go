@@ -0,0 +1,172 @@
+package fxt
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultGCThreadId is the synthetic thread ID GC events are written to by default
+// It's well above the range of real thread IDs, so it won't collide with application threads
+const defaultGCThreadId = KernelObjectID(math.MaxUint32) + 1
+
+// GCRecorder writes the stop-the-world pause time of every garbage collection cycle as a duration
+// event onto a dedicated synthetic "GC" thread, so latency spikes in application spans can be
+// correlated with collector activity
+//
+// runtime.MemStats only reports the total pause time of each cycle, and when its last pause ended.
+// It doesn't report when the concurrent mark phase starts, or each of the cycle's pauses. So each cycle
+// is written as one "GC pause" slice, as long as the cycle's total pause time and ending when its last
+// pause ended, with the cycle number and heap sizes as arguments. The slice doesn't show how long the
+// whole cycle took, and merges the pauses at its start and end
+//
+// The recorder is driven by a finalizer that runs after every collection, so no polling is needed.
+// Timestamps are derived from the configured clock, converted with the Writer's tick rate. If no
+// initialization record has been written, the clock is assumed to tick in nanoseconds
+type GCRecorder struct {
+	writer *Writer
+	config samplerConfig
+
+	mu        sync.Mutex
+	running   bool
+	lastNumGC uint32
+	err       error
+
+	// generation is bumped on every Start, so sentinels armed before a Stop are ignored
+	generation uint64
+}
+
+// gcSentinel is a heap object whose finalizer notifies the GCRecorder that a collection happened
+type gcSentinel struct {
+	recorder   *GCRecorder
+	generation uint64
+}
+
+// NewGCRecorder creates a GCRecorder writing to `writer`. Call Start to begin recording
+//
+// Events are written to the "GC" category unless overridden with WithSamplerCategory.
+// WithSamplerInterval has no effect
+func NewGCRecorder(writer *Writer, options ...SamplerOption) *GCRecorder {
+	return &GCRecorder{
+		writer: writer,
+		config: newSamplerConfig("GC", defaultGCThreadId, options),
+	}
+}
+
+// Start names the synthetic GC thread and begins recording collections
+// Collections that happened before Start are not recorded
+func (r *GCRecorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return fmt.Errorf("GC recorder is already running")
+	}
+
+	if err := r.writer.SetThreadName(r.config.processId, r.config.threadId, "GC"); err != nil {
+		return err
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	r.running = true
+	r.generation++
+	r.err = nil
+	r.lastNumGC = stats.NumGC
+	r.arm()
+
+	return nil
+}
+
+// Stop records any collections that haven't been written yet and stops recording
+// It returns the first error encountered while recording, if any
+func (r *GCRecorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running {
+		return nil
+	}
+	r.running = false
+
+	r.record()
+	return r.err
+}
+
+// arm allocates a new sentinel that will be finalized during the next collection
+func (r *GCRecorder) arm() {
+	sentinel := &gcSentinel{recorder: r, generation: r.generation}
+	runtime.SetFinalizer(sentinel, func(s *gcSentinel) {
+		s.recorder.onGC(s.generation)
+	})
+}
+
+func (r *GCRecorder) onGC(generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.running || generation != r.generation {
+		return
+	}
+
+	r.record()
+	r.arm()
+}
+
+// record writes the pauses of all the cycles that completed since the last call
+// r.mu must be held
+func (r *GCRecorder) record() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	now := time.Now()
+	nowTicks := r.config.clock()
+	tickRate := r.writer.TickRate()
+	if tickRate == 0 {
+		tickRate = TicksNanoseconds
+	}
+
+	first := r.lastNumGC + 1
+	// The runtime only keeps the last 256 pauses
+	if stats.NumGC-r.lastNumGC > uint32(len(stats.PauseEnd)) {
+		first = stats.NumGC - uint32(len(stats.PauseEnd)) + 1
+	}
+
+	for cycle := first; cycle <= stats.NumGC; cycle++ {
+		index := (cycle + uint32(len(stats.PauseEnd)) - 1) % uint32(len(stats.PauseEnd))
+		pauseEnd := time.Unix(0, int64(stats.PauseEnd[index]))
+
+		// PauseEnd is wall clock time, so the pause appears to end in the future if the wall clock
+		// stepped back since. FromDuration clamps that to zero, and the subtractions are clamped so
+		// they can't wrap around
+		endTimestamp := saturatingSub(nowTicks, tickRate.FromDuration(now.Sub(pauseEnd)))
+		beginTimestamp := saturatingSub(endTimestamp, tickRate.FromNanoseconds(stats.PauseNs[index]))
+
+		args := map[string]interface{}{
+			"cycle": cycle,
+		}
+		// The heap sizes are only accurate for the most recent cycle
+		if cycle == stats.NumGC {
+			args["heap_alloc_bytes"] = stats.HeapAlloc
+			args["next_gc_bytes"] = stats.NextGC
+		}
+
+		err := r.writer.AddDurationCompleteEventWithArgs(r.config.category, "GC pause", r.config.processId, r.config.threadId, beginTimestamp, endTimestamp, args)
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+
+	r.lastNumGC = stats.NumGC
+}
+
+// saturatingSub returns a - b, or zero if b is larger
+func saturatingSub(a uint64, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
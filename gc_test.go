@@ -0,0 +1,92 @@
+package fxt_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCRecorder(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	recorder := fxt.NewGCRecorder(writer)
+
+	err = recorder.Start()
+	require.NoError(t, err)
+
+	err = recorder.Start()
+	require.Error(t, err)
+
+	runtime.GC()
+	runtime.GC()
+
+	err = recorder.Stop()
+	require.NoError(t, err)
+
+	err = recorder.Stop()
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestGCRecorderTickRate(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksMicroseconds))
+
+	microseconds := func() uint64 {
+		return uint64(time.Now().UnixMicro())
+	}
+	recorder := fxt.NewGCRecorder(writer, fxt.WithSamplerClock(microseconds))
+
+	start := microseconds()
+	require.NoError(t, recorder.Start())
+	runtime.GC()
+	runtime.GC()
+	require.NoError(t, recorder.Stop())
+	stop := microseconds()
+	require.NoError(t, writer.Close())
+
+	// The pauses are converted to the Writer's tick rate, so they fall within the test, rather than
+	// being offset by the difference between nanoseconds and microseconds
+	pauses := 0
+	for _, event := range readEvents(t, buffer.Bytes()) {
+		complete, ok := event.Decoded.(*fxt.DurationCompleteEvent)
+		if !ok {
+			continue
+		}
+		require.Equal(t, "GC pause", event.Name)
+		require.LessOrEqual(t, event.Timestamp, complete.EndTimestamp)
+		require.GreaterOrEqual(t, complete.EndTimestamp, start-1000)
+		require.LessOrEqual(t, complete.EndTimestamp, stop)
+		pauses++
+	}
+	require.GreaterOrEqual(t, pauses, 2)
+}
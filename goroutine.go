@@ -0,0 +1,145 @@
+package fxt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+var goroutinePrefix = []byte("goroutine ")
+
+// GoroutineId returns the runtime's ID for the calling goroutine
+//
+// Go intentionally doesn't expose goroutine IDs, so this parses the header of the goroutine's stack trace.
+// The ID is stable for the lifetime of the goroutine and never reused, which makes it a good pseudo
+// thread ID for attributing events to goroutines. It costs roughly a microsecond, so callers in hot paths
+// should look it up once and pass it along
+func GoroutineId() KernelObjectID {
+	var buffer [64]byte
+	stack := buffer[:runtime.Stack(buffer[:], false)]
+
+	// The stack starts with "goroutine 123 [running]:"
+	stack = bytes.TrimPrefix(stack, goroutinePrefix)
+	if end := bytes.IndexByte(stack, ' '); end >= 0 {
+		stack = stack[:end]
+	}
+
+	id, err := strconv.ParseUint(string(stack), 10, 64)
+	if err != nil {
+		// The format of the header is part of the runtime's traceback output, which has been stable since Go 1.0
+		panic("fxt: unable to parse goroutine ID from stack header")
+	}
+
+	return KernelObjectID(id)
+}
+
+// CurrentGoroutine is a thread ID that stands for the calling goroutine, in Writers created with WithGoroutineThreads
+const CurrentGoroutine KernelObjectID = ^KernelObjectID(0)
+
+var errCurrentGoroutine = errors.New("thread ID CurrentGoroutine can only be used with WithGoroutineThreads")
+
+// WithGoroutineThreads makes the Writer write the events with the thread ID CurrentGoroutine on a thread
+// for the calling goroutine, so code that doesn't know what thread it's on doesn't need to pass one around
+//
+// The goroutine's ID from GoroutineId is used as the thread ID, and the first event of each goroutine in
+// the trace names its thread "goroutine-<id>", unless it was already named with SetThreadName or
+// SetGoroutineName. Go has no goroutine-local storage to cache the ID in, so it's looked up for every event,
+// before the Writer's lock is taken. That costs roughly a microsecond, so goroutines in hot paths should
+// create a ThreadWriter with CurrentGoroutine instead, which looks it up once
+//
+// Programs can start far more goroutines than the thread table holds, so once it's full, threads are written
+// inline in each record that uses them, as with WithTableLimits, rather than failing with an error
+//
+// The same caveat as SetGoroutineName applies: goroutine IDs and OS thread IDs are different number spaces
+func WithGoroutineThreads() WriterOption {
+	return func(w *Writer) {
+		w.goroutineThreads = true
+	}
+}
+
+// maxGoroutineNames is the most goroutine threads the Writer remembers naming. Once it's reached, they're
+// forgotten, and each goroutine's thread is named again on its next event
+const maxGoroutineNames = 4096
+
+// callingGoroutine returns the ID of the calling goroutine if `threadId` is CurrentGoroutine, for
+// goroutineThread. It's called before taking the Writer's lock, so the lookup isn't done while holding it
+func (w *Writer) callingGoroutine(threadId KernelObjectID) KernelObjectID {
+	if threadId != CurrentGoroutine || !w.goroutineThreads {
+		return 0
+	}
+	return GoroutineId()
+}
+
+// goroutineThread returns `goroutineId`, from callingGoroutine, if `threadId` is CurrentGoroutine, naming
+// its thread the first time it's seen in the trace, and `threadId` otherwise. The Writer's lock must be held
+func (w *Writer) goroutineThread(processId KernelObjectID, threadId KernelObjectID, goroutineId KernelObjectID) (KernelObjectID, error) {
+	if threadId != CurrentGoroutine {
+		return threadId, nil
+	}
+	if !w.goroutineThreads {
+		return 0, errCurrentGoroutine
+	}
+
+	if err := w.nameGoroutineThread(processId, goroutineId); err != nil {
+		return 0, err
+	}
+	return goroutineId, nil
+}
+
+// nameGoroutineThread names the thread of goroutine `goroutineId` "goroutine-<id>", unless it was already named
+// in the trace. The Writer's lock must be held
+func (w *Writer) nameGoroutineThread(processId KernelObjectID, goroutineId KernelObjectID) error {
+	thread := Thread{ProcessId: processId, ThreadId: goroutineId}
+	if _, ok := w.namedThreads[thread]; ok {
+		return nil
+	}
+	if _, ok := w.goroutineNames[thread]; ok {
+		return nil
+	}
+
+	// Each name is only used once, so it's written inline, rather than taking a slot in the string table
+	nameRef := StringRef{Inline: fmt.Sprintf("goroutine-%d", goroutineId)}
+	if err := w.writeThreadName(processId, goroutineId, nameRef); err != nil {
+		return fmt.Errorf("failed to name goroutine %d - %w", goroutineId, err)
+	}
+
+	// Goroutines come and go, so the names are forgotten in bulk, rather than kept for every goroutine ever seen
+	if len(w.goroutineNames) >= maxGoroutineNames {
+		w.goroutineNames = map[Thread]struct{}{}
+	}
+	w.goroutineNames[thread] = struct{}{}
+	return nil
+}
+
+// SetGoroutineName names the calling goroutine, using its goroutine ID as the thread ID
+// It returns the thread ID, so it can be used for all future events from the goroutine
+//
+// Goroutine IDs and OS thread IDs are different number spaces. Mixing the two within one
+// process may cause events from unrelated goroutines and threads to share a track
+func (w *Writer) SetGoroutineName(processId KernelObjectID, name string) (KernelObjectID, error) {
+	threadId := GoroutineId()
+	if err := w.SetThreadName(processId, threadId, name); err != nil {
+		return 0, err
+	}
+
+	return threadId, nil
+}
+
+// LockOSThreadId wires the calling goroutine to its current OS thread with runtime.LockOSThread,
+// and returns the OS thread's ID. Events using the ID will line up with OS-level data like
+// scheduling records
+//
+// The caller is responsible for calling runtime.UnlockOSThread once it's finished using the ID
+func LockOSThreadId() (KernelObjectID, error) {
+	runtime.LockOSThread()
+
+	threadId, err := osThreadId()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return 0, err
+	}
+
+	return threadId, nil
+}
@@ -0,0 +1,9 @@
+package fxt
+
+import (
+	"syscall"
+)
+
+func osThreadId() (KernelObjectID, error) {
+	return KernelObjectID(syscall.Gettid()), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package fxt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func osThreadId() (KernelObjectID, error) {
+	return 0, fmt.Errorf("OS thread IDs are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,169 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineId(t *testing.T) {
+	id := fxt.GoroutineId()
+	require.NotZero(t, id)
+
+	// Stable within a goroutine
+	require.Equal(t, id, fxt.GoroutineId())
+
+	// Unique across goroutines
+	otherId := make(chan fxt.KernelObjectID)
+	go func() {
+		otherId <- fxt.GoroutineId()
+	}()
+	require.NotEqual(t, id, <-otherId)
+}
+
+func TestSetGoroutineName(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	threadId, err := writer.SetGoroutineName(3, "Worker")
+	require.NoError(t, err)
+	require.Equal(t, fxt.GoroutineId(), threadId)
+
+	err = writer.AddInstantEvent("Test", "Instant", 3, threadId, 100)
+	require.NoError(t, err)
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "windows" {
+		osThreadId, err := fxt.LockOSThreadId()
+		require.NoError(t, err)
+		require.NotZero(t, osThreadId)
+		runtime.UnlockOSThread()
+	}
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
+
+func TestGoroutineThreads(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithGoroutineThreads())
+	require.NoError(t, err)
+
+	main := fxt.GoroutineId()
+	require.NoError(t, writer.SetThreadName(1, fxt.CurrentGoroutine, "Main"))
+	require.NoError(t, writer.AddInstantEvent("Test", "Main", 1, fxt.CurrentGoroutine, 100))
+
+	other := make(chan fxt.KernelObjectID)
+	go func() {
+		defer close(other)
+		other <- fxt.GoroutineId()
+		require.NoError(t, writer.AddInstantEvent("Test", "First", 1, fxt.CurrentGoroutine, 200))
+		require.NoError(t, writer.AddDurationCompleteEvent("Test", "Second", 1, fxt.CurrentGoroutine, 200, 300))
+
+		threadWriter := writer.NewThreadWriter(1, fxt.CurrentGoroutine)
+		require.NoError(t, threadWriter.AddInstantEvent("Test", "Buffered", 400))
+		require.NoError(t, threadWriter.Close())
+	}()
+	otherId := <-other
+	<-other
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	names := map[fxt.KernelObjectID]string{}
+	events := map[string]fxt.KernelObjectID{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if object, ok := decoded.(*fxt.KernelObjectRecord); ok && object.Type == fxt.KernelObjectTypeThread {
+			_, named := names[object.Koid]
+			require.False(t, named, "thread %d was named twice", object.Koid)
+			names[object.Koid] = reader.ResolveString(object.Name)
+		}
+		if event, err := reader.Resolve(record); err == nil && event != nil {
+			events[event.Name] = event.Thread.ThreadId
+		}
+	}
+
+	require.Equal(t, map[fxt.KernelObjectID]string{
+		main:    "Main",
+		otherId: fmt.Sprintf("goroutine-%d", otherId),
+	}, names)
+	require.Equal(t, map[string]fxt.KernelObjectID{
+		"Main":     main,
+		"First":    otherId,
+		"Second":   otherId,
+		"Buffered": otherId,
+	}, events)
+}
+
+func TestGoroutineThreadsMany(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithGoroutineThreads())
+	require.NoError(t, err)
+
+	// More goroutines than the thread table holds
+	const goroutines = 300
+	ids := make(chan fxt.KernelObjectID, goroutines)
+	for i := 0; i < goroutines; i++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			ids <- fxt.GoroutineId()
+			require.NoError(t, writer.AddInstantEvent("Test", "Instant", 1, fxt.CurrentGoroutine, 100))
+		}()
+		<-done
+	}
+	close(ids)
+	require.NoError(t, writer.Close())
+
+	threads := map[fxt.KernelObjectID]bool{}
+	for _, event := range readEvents(t, buffer.Bytes()) {
+		threads[event.Thread.ThreadId] = true
+	}
+	require.Len(t, threads, goroutines)
+	for id := range ids {
+		require.True(t, threads[id])
+	}
+}
+
+func TestGoroutineThreadsOff(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	require.Error(t, writer.AddInstantEvent("Test", "Instant", 1, fxt.CurrentGoroutine, 100))
+	require.Error(t, writer.SetThreadName(1, fxt.CurrentGoroutine, "Main"))
+}
@@ -0,0 +1,12 @@
+package fxt
+
+import (
+	"syscall"
+)
+
+var procGetCurrentThreadId = syscall.NewLazyDLL("kernel32.dll").NewProc("GetCurrentThreadId")
+
+func osThreadId() (KernelObjectID, error) {
+	threadId, _, _ := procGetCurrentThreadId.Call()
+	return KernelObjectID(threadId), nil
+}
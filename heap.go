@@ -0,0 +1,78 @@
+package fxt
+
+import (
+	"runtime"
+	"time"
+)
+
+// HeapSampler periodically reads the Go runtime's heap and allocation statistics
+// and writes them to a Writer as counter events
+//
+// Two counters are written each sample:
+//   - "Heap" with the in-use bytes, allocated bytes and number of live objects
+//   - "Allocations" with the allocation, free, and allocated byte rates per second since the previous sample
+//
+// Reading the statistics briefly stops the world, so very short intervals should be avoided
+type HeapSampler struct {
+	*Sampler
+
+	writer *Writer
+
+	lastSampleTime time.Time
+	lastMallocs    uint64
+	lastFrees      uint64
+	lastTotalAlloc uint64
+}
+
+// NewHeapSampler creates a HeapSampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "Memory" category unless overridden with WithSamplerCategory
+func NewHeapSampler(writer *Writer, options ...SamplerOption) *HeapSampler {
+	heapSampler := &HeapSampler{
+		writer: writer,
+	}
+	heapSampler.Sampler = &Sampler{
+		config: newSamplerConfig("Memory", 0, options),
+		sample: heapSampler.sample,
+	}
+
+	return heapSampler
+}
+
+func (s *HeapSampler) sample(timestamp uint64) error {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	now := time.Now()
+
+	config := s.config
+	err := s.writer.AddCounterEvent(config.category, "Heap", config.processId, config.threadId, timestamp, map[string]interface{}{
+		"heap_inuse_bytes": stats.HeapInuse,
+		"heap_alloc_bytes": stats.HeapAlloc,
+		"heap_objects":     stats.HeapObjects,
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	// Rates need two samples
+	if !s.lastSampleTime.IsZero() {
+		elapsed := now.Sub(s.lastSampleTime).Seconds()
+		if elapsed > 0 {
+			err := s.writer.AddCounterEvent(config.category, "Allocations", config.processId, config.threadId, timestamp, map[string]interface{}{
+				"allocs_per_sec":      float64(stats.Mallocs-s.lastMallocs) / elapsed,
+				"frees_per_sec":       float64(stats.Frees-s.lastFrees) / elapsed,
+				"alloc_bytes_per_sec": float64(stats.TotalAlloc-s.lastTotalAlloc) / elapsed,
+			}, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.lastSampleTime = now
+	s.lastMallocs = stats.Mallocs
+	s.lastFrees = stats.Frees
+	s.lastTotalAlloc = stats.TotalAlloc
+
+	return nil
+}
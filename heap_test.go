@@ -0,0 +1,86 @@
+package fxt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeapSampler(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	err = writer.AddInitializationRecord(1000000000)
+	require.NoError(t, err)
+
+	sampler := fxt.NewHeapSampler(writer, fxt.WithSamplerInterval(time.Millisecond))
+
+	// A manual sample can be taken without starting the background goroutine
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	// Allocate between two samples, so the allocation rate is known to be non-zero
+	for i := 0; i < 1000; i++ {
+		heapSink = append(heapSink, make([]byte, 64))
+	}
+	time.Sleep(time.Millisecond)
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	err = sampler.Start()
+	require.NoError(t, err)
+
+	// Starting twice is an error
+	err = sampler.Start()
+	require.Error(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	err = sampler.Stop()
+	require.NoError(t, err)
+
+	// Stopping a stopped sampler is a no-op
+	err = sampler.Stop()
+	require.NoError(t, err)
+
+	err = writer.Close()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	counters := map[string][]map[string]interface{}{}
+	for _, event := range readEvents(t, data) {
+		require.Equal(t, "Memory", event.Category)
+		args := map[string]interface{}{}
+		for _, argument := range event.Arguments {
+			args[argument.Key] = argument.Value
+		}
+		counters[event.Name] = append(counters[event.Name], args)
+	}
+
+	// Every sample writes the heap sizes, and every sample after the first the allocation rates
+	require.GreaterOrEqual(t, len(counters["Heap"]), 2)
+	for _, heap := range counters["Heap"] {
+		require.Greater(t, heap["heap_inuse_bytes"], uint64(0))
+		require.Greater(t, heap["heap_objects"], uint64(0))
+	}
+	require.NotEmpty(t, counters["Allocations"])
+	require.Greater(t, counters["Allocations"][0]["allocs_per_sec"], float64(0))
+}
+
+// heapSink keeps the allocations made by TestHeapSampler from being optimized away
+var heapSink [][]byte
@@ -0,0 +1,96 @@
+package fxt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// htmlTemplate is a standalone page embedding a trace. It hands the trace to the Perfetto UI with
+// postMessage, as described in https://perfetto.dev/docs/visualization/deep-linking-to-perfetto-ui
+var htmlTemplate = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 3em; }
+button, a { font-size: 1.2em; margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>This page contains an embedded FXT trace ({{.Size}} bytes).</p>
+<button id="open">Open in Perfetto UI</button>
+<a id="download" download="{{.FileName}}" href="#">Download {{.FileName}}</a>
+<p id="status"></p>
+<script>
+(function() {
+  const perfettoOrigin = "https://ui.perfetto.dev";
+  // html/template escapes the data as a JS string literal
+  const encoded = {{.Data}};
+  const raw = atob(encoded);
+  const bytes = new Uint8Array(raw.length);
+  for (let i = 0; i < raw.length; i++) {
+    bytes[i] = raw.charCodeAt(i);
+  }
+
+  document.getElementById("download").href = URL.createObjectURL(new Blob([bytes], {type: "application/octet-stream"}));
+
+  const status = document.getElementById("status");
+  document.getElementById("open").addEventListener("click", function() {
+    const ui = window.open(perfettoOrigin);
+    if (!ui) {
+      status.textContent = "Popup blocked. Allow popups for this page and try again.";
+      return;
+    }
+
+    status.textContent = "Waiting for the Perfetto UI to load...";
+    // The UI replies PONG once it's ready to receive the trace
+    const ping = setInterval(function() { ui.postMessage("PING", perfettoOrigin); }, 50);
+    window.addEventListener("message", function onMessage(event) {
+      if (event.origin !== perfettoOrigin || event.data !== "PONG") {
+        return;
+      }
+      clearInterval(ping);
+      window.removeEventListener("message", onMessage);
+
+      ui.postMessage({perfetto: {buffer: bytes.buffer, title: {{.Title}}, fileName: {{.FileName}}}}, perfettoOrigin);
+      status.textContent = "Trace sent to the Perfetto UI.";
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// WriteHTML writes a standalone HTML page with the FXT trace read from `trace` embedded in it
+//
+// The page can be emailed or attached to a bug and opened with nothing but a browser. It has a
+// button which opens the trace in the Perfetto UI (https://ui.perfetto.dev), and a link to save the
+// original trace file. `title` is used for the page and the trace in the UI
+func WriteHTML(dst io.Writer, trace io.Reader, title string) error {
+	data, err := io.ReadAll(trace)
+	if err != nil {
+		return fmt.Errorf("failed to read trace - %w", err)
+	}
+
+	err = htmlTemplate.Execute(dst, struct {
+		Title    string
+		FileName string
+		Size     int
+		Data     string
+	}{
+		Title:    title,
+		FileName: title + ".fxt",
+		Size:     len(data),
+		Data:     base64.StdEncoding.EncodeToString(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write HTML - %w", err)
+	}
+
+	return nil
+}
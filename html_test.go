@@ -0,0 +1,27 @@
+package fxt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	trace := []byte{0x10, 0x00, 0x04, 0x46, 0x78, 0x54, 0x16, 0x00}
+
+	var page bytes.Buffer
+	err := fxt.WriteHTML(&page, bytes.NewReader(trace), `My "trace" <1>`)
+	require.NoError(t, err)
+
+	html := page.String()
+	require.Contains(t, html, base64.StdEncoding.EncodeToString(trace))
+
+	// The title must be escaped in both the markup and the script
+	require.Contains(t, html, "<title>My &#34;trace&#34; &lt;1&gt;</title>")
+	require.False(t, strings.Contains(html, `My "trace" <1>`))
+}
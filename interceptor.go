@@ -0,0 +1,126 @@
+package fxt
+
+// EventKind is the kind of an Event passed to an Interceptor
+type EventKind int
+
+const (
+	EventKindInstant          = EventKind(eventTypeInstant)
+	EventKindCounter          = EventKind(eventTypeCounter)
+	EventKindDurationBegin    = EventKind(eventTypeDurationBegin)
+	EventKindDurationEnd      = EventKind(eventTypeDurationEnd)
+	EventKindDurationComplete = EventKind(eventTypeDurationComplete)
+	EventKindAsyncBegin       = EventKind(eventTypeAsyncBegin)
+	EventKindAsyncInstant     = EventKind(eventTypeAsyncInstant)
+	EventKindAsyncEnd         = EventKind(eventTypeAsyncEnd)
+	EventKindFlowBegin        = EventKind(eventTypeFlowBegin)
+	EventKindFlowStep         = EventKind(eventTypeFlowStep)
+	EventKindFlowEnd          = EventKind(eventTypeFlowEnd)
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventKindInstant:
+		return "instant"
+	case EventKindCounter:
+		return "counter"
+	case EventKindDurationBegin:
+		return "duration begin"
+	case EventKindDurationEnd:
+		return "duration end"
+	case EventKindDurationComplete:
+		return "duration complete"
+	case EventKindAsyncBegin:
+		return "async begin"
+	case EventKindAsyncInstant:
+		return "async instant"
+	case EventKindAsyncEnd:
+		return "async end"
+	case EventKindFlowBegin:
+		return "flow begin"
+	case EventKindFlowStep:
+		return "flow step"
+	case EventKindFlowEnd:
+		return "flow end"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is an event about to be written, as passed to an Interceptor
+//
+// The Category, Name, and Arguments can be changed, and the event is written with the changes. The other fields
+// are for the Interceptor to look at, and changes to them are ignored
+type Event struct {
+	Kind      EventKind
+	Category  string
+	Name      string
+	ProcessId KernelObjectID
+	ThreadId  KernelObjectID
+	// Timestamp is the timestamp the event was added with, before it's normalized, or the begin timestamp of a
+	// duration complete event
+	Timestamp uint64
+	// Arguments is a copy of the arguments the event was added with, so it can be changed freely. It's never nil
+	Arguments map[string]interface{}
+}
+
+// Interceptor looks at an event before it's written, and can change it. Returning false drops the event
+type Interceptor func(event *Event) bool
+
+// AddInterceptor adds an Interceptor, which is run on every event added to the Writer, or its ThreadWriters,
+// before it's encoded. Interceptors run in the order they were added, and once one drops an event, the ones after
+// it aren't run. They can rename categories, add common arguments, or enforce naming conventions, without
+// wrapping every call site
+//
+// The events written by the Writer itself, like the duration end events of WithCloseOpenSpans, aren't intercepted.
+// Neither are other records, like thread names and blobs. With WithSpanCheck and WithAsyncCheck, the spans are
+// checked with the changed names, so an Interceptor that drops or renames a begin event must do the same to its
+// end event
+//
+// Interceptors are run with the Writer's lock held, and by ThreadWriters on their own goroutines, so they must be
+// safe to call concurrently, and mustn't call the Writer
+func (w *Writer) AddInterceptor(interceptor Interceptor) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var interceptors []Interceptor
+	if current := w.interceptors.Load(); current != nil {
+		interceptors = append(interceptors, *current...)
+	}
+	interceptors = append(interceptors, interceptor)
+	w.interceptors.Store(&interceptors)
+}
+
+// intercept runs the Writer's Interceptors on an event. It returns the event's changed category, name, and
+// arguments, and false if it was dropped
+func (w *Writer) intercept(kind EventKind, category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) (string, string, map[string]interface{}, bool) {
+	interceptors := w.interceptors.Load()
+	if interceptors == nil {
+		return category, name, arguments, true
+	}
+
+	event := Event{
+		Kind:      kind,
+		Category:  category,
+		Name:      name,
+		ProcessId: processId,
+		ThreadId:  threadId,
+		Timestamp: timestamp,
+		// The caller's map is left untouched
+		Arguments: make(map[string]interface{}, len(arguments)),
+	}
+	for key, value := range arguments {
+		event.Arguments[key] = value
+	}
+
+	for _, interceptor := range *interceptors {
+		if !interceptor(&event) {
+			return "", "", nil, false
+		}
+	}
+	return event.Category, event.Name, event.Arguments, true
+}
+
+// intercept runs the Writer's Interceptors on an event of the ThreadWriter's thread
+func (t *ThreadWriter) intercept(kind EventKind, category string, name string, timestamp uint64, arguments map[string]interface{}) (string, string, map[string]interface{}, bool) {
+	return t.writer.intercept(kind, category, name, t.thread.ProcessId, t.thread.ThreadId, timestamp, arguments)
+}
@@ -0,0 +1,109 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents reads the events of a trace, resolved
+func readEvents(t *testing.T, data []byte) []*fxt.ResolvedEvent {
+	t.Helper()
+
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var events []*fxt.ResolvedEvent
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	var kinds []fxt.EventKind
+	writer.AddInterceptor(func(event *fxt.Event) bool {
+		kinds = append(kinds, event.Kind)
+		return event.Category != "debug"
+	})
+	writer.AddInterceptor(func(event *fxt.Event) bool {
+		event.Category = strings.TrimPrefix(event.Category, "old.")
+		event.Name = strings.ToLower(event.Name)
+		event.Arguments["service"] = "frontend"
+		return true
+	})
+
+	arguments := map[string]interface{}{"id": 7}
+	require.NoError(t, writer.AddInstantEventWithArgs("old.category", "Instant", 1, 2, 100, arguments))
+	require.NoError(t, writer.AddInstantEvent("debug", "dropped", 1, 2, 200))
+	require.NoError(t, writer.AddDurationCompleteEvent("category", "Complete", 1, 2, 300, 400))
+	threadWriter := writer.NewThreadWriter(1, 3)
+	require.NoError(t, threadWriter.AddCounterEvent("old.category", "Counter", 500, map[string]interface{}{"value": 1}, 0))
+	require.NoError(t, threadWriter.AddInstantEvent("debug", "dropped", 600))
+	require.NoError(t, writer.Close())
+
+	// The caller's arguments aren't changed
+	require.Equal(t, map[string]interface{}{"id": 7}, arguments)
+	require.Equal(t, []fxt.EventKind{
+		fxt.EventKindInstant,
+		fxt.EventKindInstant,
+		fxt.EventKindDurationComplete,
+		fxt.EventKindCounter,
+		fxt.EventKindInstant,
+	}, kinds)
+
+	events := readEvents(t, buffer.Bytes())
+	require.Len(t, events, 3)
+	expected := []struct {
+		category  string
+		name      string
+		arguments int
+	}{
+		{"category", "instant", 2},
+		{"category", "complete", 1},
+		{"category", "counter", 2},
+	}
+	for i, event := range events {
+		require.Equal(t, expected[i].category, event.Category)
+		require.Equal(t, expected[i].name, event.Name)
+		require.Len(t, event.Arguments, expected[i].arguments)
+		require.Contains(t, event.Arguments, fxt.ResolvedArgument{Key: "service", Value: "frontend"})
+	}
+}
+
+func TestInterceptorSpans(t *testing.T) {
+	var buffer bytes.Buffer
+	var warnings []error
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithSpanCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+
+	// Renaming both ends of a span keeps it matched
+	writer.AddInterceptor(func(event *fxt.Event) bool {
+		event.Name = "renamed"
+		return true
+	})
+	require.NoError(t, writer.AddDurationBeginEvent("category", "span", 1, 2, 100))
+	require.NoError(t, writer.AddDurationEndEvent("category", "span", 1, 2, 200))
+	require.NoError(t, writer.Close())
+	require.Empty(t, warnings)
+
+	events := readEvents(t, buffer.Bytes())
+	require.Len(t, events, 2)
+	require.Equal(t, "renamed", events[0].Name)
+	require.Equal(t, "renamed", events[1].Name)
+}
@@ -0,0 +1,113 @@
+package fxt
+
+import (
+	"fmt"
+	"time"
+)
+
+// IOSampler periodically reads how much I/O a process is doing, and writes the rates to a Writer as counter
+// events, which helps tell which spans are I/O bound
+//
+// Three counters are written each sample, with rates per second since the previous sample:
+//   - "Process I/O" with the bytes read and written, and the read and write system calls made, by the process.
+//     This includes reads and writes of pipes, sockets, and files that were served from the page cache
+//   - "Disk I/O" with the bytes the process caused to be read from, or written to, storage
+//   - "Network I/O" with the bytes received and transmitted on the network interfaces, other than loopback, of
+//     the process's network namespace. Linux doesn't account socket traffic per process, so inside a container
+//     these are the container's, and otherwise the host's
+//
+// Rates are measured between samples, so nothing is written by the first sample.
+// The process that's measured is the one the events are attributed to, which is the current process unless
+// overridden with WithSamplerThread
+//
+// It's supported on Linux, where it reads /proc/<pid>/io and /proc/<pid>/net/dev. Sampling returns an error on
+// other platforms
+type IOSampler struct {
+	*Sampler
+
+	writer *Writer
+
+	lastSampleTime time.Time
+	last           ioStats
+}
+
+// ioStats is a snapshot of a process's I/O counters
+type ioStats struct {
+	// readChars and writeChars are the bytes passed to read and write system calls
+	readChars     uint64
+	writeChars    uint64
+	readSyscalls  uint64
+	writeSyscalls uint64
+	// readBytes and writeBytes are the bytes read from, or written to, storage
+	readBytes  uint64
+	writeBytes uint64
+
+	receivedBytes    uint64
+	transmittedBytes uint64
+}
+
+// NewIOSampler creates an IOSampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "IO" category unless overridden with WithSamplerCategory
+func NewIOSampler(writer *Writer, options ...SamplerOption) *IOSampler {
+	ioSampler := &IOSampler{
+		writer: writer,
+	}
+	ioSampler.Sampler = &Sampler{
+		config: newSamplerConfig("IO", 0, options),
+		sample: ioSampler.sample,
+	}
+
+	return ioSampler
+}
+
+func (s *IOSampler) sample(timestamp uint64) error {
+	stats, err := readIOStats(s.config.processId)
+	if err != nil {
+		return fmt.Errorf("failed to read I/O counters - %w", err)
+	}
+	now := time.Now()
+
+	// Rates need two samples
+	if !s.lastSampleTime.IsZero() {
+		elapsed := now.Sub(s.lastSampleTime).Seconds()
+		if elapsed > 0 {
+			rate := func(last uint64, current uint64) float64 {
+				if current < last {
+					return 0
+				}
+				return float64(current-last) / elapsed
+			}
+
+			config := s.config
+			err := s.writer.AddCounterEvent(config.category, "Process I/O", config.processId, config.threadId, timestamp, map[string]interface{}{
+				"read_bytes_per_sec":     rate(s.last.readChars, stats.readChars),
+				"write_bytes_per_sec":    rate(s.last.writeChars, stats.writeChars),
+				"read_syscalls_per_sec":  rate(s.last.readSyscalls, stats.readSyscalls),
+				"write_syscalls_per_sec": rate(s.last.writeSyscalls, stats.writeSyscalls),
+			}, 0)
+			if err != nil {
+				return err
+			}
+			err = s.writer.AddCounterEvent(config.category, "Disk I/O", config.processId, config.threadId, timestamp, map[string]interface{}{
+				"read_bytes_per_sec":  rate(s.last.readBytes, stats.readBytes),
+				"write_bytes_per_sec": rate(s.last.writeBytes, stats.writeBytes),
+			}, 0)
+			if err != nil {
+				return err
+			}
+			err = s.writer.AddCounterEvent(config.category, "Network I/O", config.processId, config.threadId, timestamp, map[string]interface{}{
+				"received_bytes_per_sec":    rate(s.last.receivedBytes, stats.receivedBytes),
+				"transmitted_bytes_per_sec": rate(s.last.transmittedBytes, stats.transmittedBytes),
+			}, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.lastSampleTime = now
+	s.last = stats
+
+	return nil
+}
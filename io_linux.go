@@ -0,0 +1,30 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+)
+
+func readIOStats(processId KernelObjectID) (ioStats, error) {
+	path := fmt.Sprintf("/proc/%d/io", processId)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ioStats{}, err
+	}
+	stats, err := parseProcPidIO(string(data))
+	if err != nil {
+		return ioStats{}, fmt.Errorf("failed to parse %s - %w", path, err)
+	}
+
+	path = fmt.Sprintf("/proc/%d/net/dev", processId)
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return ioStats{}, err
+	}
+	stats.receivedBytes, stats.transmittedBytes, err = parseProcNetDev(string(data))
+	if err != nil {
+		return ioStats{}, fmt.Errorf("failed to parse %s - %w", path, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fxt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readIOStats(processId KernelObjectID) (ioStats, error) {
+	return ioStats{}, fmt.Errorf("I/O sampling is not supported on %s", runtime.GOOS)
+}
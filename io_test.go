@@ -0,0 +1,58 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOSampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	sampler := fxt.NewIOSampler(writer)
+	if runtime.GOOS != "linux" {
+		require.Error(t, sampler.Sample())
+		return
+	}
+
+	// The first sample only records the starting point
+	require.NoError(t, sampler.Sample())
+	err = os.WriteFile(filepath.Join(t.TempDir(), "data"), make([]byte, 1<<20), 0o644)
+	require.NoError(t, err)
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	counters := map[string]map[string]interface{}{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "IO", event.Category)
+
+		values := map[string]interface{}{}
+		for _, argument := range event.Arguments {
+			values[argument.Key] = argument.Value
+		}
+		counters[event.Name] = values
+	}
+
+	require.Len(t, counters, 3)
+	require.Greater(t, counters["Process I/O"]["write_bytes_per_sec"], 0.0)
+	require.Greater(t, counters["Process I/O"]["write_syscalls_per_sec"], 0.0)
+	require.Contains(t, counters["Disk I/O"], "write_bytes_per_sec")
+	require.Contains(t, counters["Network I/O"], "received_bytes_per_sec")
+}
@@ -0,0 +1,74 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// MemorySampler periodically reads how much memory a process is using, and writes it to a Writer as
+// counter events, so memory trends can be followed over a trace
+//
+// Two counters are written each sample:
+//   - "Process Memory" with the resident set size ("rss_bytes") and virtual size ("virtual_bytes") of the process
+//   - "Go Heap" with the Go runtime's heap, stack, and total memory, when the process is the current one
+//
+// The process that's measured is the one the events are attributed to, which is the current process unless
+// overridden with WithSamplerThread
+//
+// It's supported on Linux, where it reads /proc/<pid>/statm, and on Windows, where it uses GetProcessMemoryInfo.
+// There the resident set size is the working set, and the virtual size is the commit charge. Sampling returns an
+// error on other platforms
+//
+// Reading the Go runtime's statistics briefly stops the world, so very short intervals should be avoided
+type MemorySampler struct {
+	*Sampler
+
+	writer *Writer
+}
+
+// NewMemorySampler creates a MemorySampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "Memory" category unless overridden with WithSamplerCategory
+func NewMemorySampler(writer *Writer, options ...SamplerOption) *MemorySampler {
+	memorySampler := &MemorySampler{
+		writer: writer,
+	}
+	memorySampler.Sampler = &Sampler{
+		config: newSamplerConfig("Memory", 0, options),
+		sample: memorySampler.sample,
+	}
+
+	return memorySampler
+}
+
+func (s *MemorySampler) sample(timestamp uint64) error {
+	resident, virtual, err := readProcessMemory(s.config.processId)
+	if err != nil {
+		return fmt.Errorf("failed to read process memory - %w", err)
+	}
+
+	config := s.config
+	err = s.writer.AddCounterEvent(config.category, "Process Memory", config.processId, config.threadId, timestamp, map[string]interface{}{
+		"rss_bytes":     resident,
+		"virtual_bytes": virtual,
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	// The Go runtime's statistics are only known for the current process
+	if config.processId != KernelObjectID(os.Getpid()) {
+		return nil
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return s.writer.AddCounterEvent(config.category, "Go Heap", config.processId, config.threadId, timestamp, map[string]interface{}{
+		"heap_alloc_bytes":  stats.HeapAlloc,
+		"heap_inuse_bytes":  stats.HeapInuse,
+		"heap_sys_bytes":    stats.HeapSys,
+		"stack_inuse_bytes": stats.StackInuse,
+		"sys_bytes":         stats.Sys,
+	}, 0)
+}
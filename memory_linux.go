@@ -0,0 +1,20 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+)
+
+func readProcessMemory(processId KernelObjectID) (resident uint64, virtual uint64, err error) {
+	path := fmt.Sprintf("/proc/%d/statm", processId)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	virtual, resident, err = parseProcPidStatm(string(data), uint64(os.Getpagesize()))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse %s - %w", path, err)
+	}
+
+	return resident, virtual, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package fxt
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readProcessMemory(processId KernelObjectID) (resident uint64, virtual uint64, err error) {
+	return 0, 0, fmt.Errorf("memory sampling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,65 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	sampler := fxt.NewMemorySampler(writer, fxt.WithSamplerInterval(time.Millisecond))
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		require.Error(t, sampler.Sample())
+		return
+	}
+
+	require.NoError(t, sampler.Sample())
+
+	// The Go heap is only written for the current process
+	parent := fxt.NewMemorySampler(writer, fxt.WithSamplerThread(fxt.KernelObjectID(os.Getppid()), 0))
+	require.NoError(t, parent.Sample())
+
+	require.NoError(t, sampler.Start())
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, sampler.Stop())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	counts := map[fxt.KernelObjectID]map[string]int{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "Memory", event.Category)
+
+		if counts[event.Thread.ProcessId] == nil {
+			counts[event.Thread.ProcessId] = map[string]int{}
+		}
+		counts[event.Thread.ProcessId][event.Name]++
+
+		if event.Name == "Process Memory" {
+			require.Equal(t, "rss_bytes", event.Arguments[0].Key)
+			require.Greater(t, event.Arguments[0].Value, uint64(0))
+		}
+	}
+
+	self := counts[fxt.KernelObjectID(os.Getpid())]
+	require.Greater(t, self["Process Memory"], 1)
+	require.Equal(t, self["Process Memory"], self["Go Heap"])
+	require.Equal(t, map[string]int{"Process Memory": 1}, counts[fxt.KernelObjectID(os.Getppid())])
+}
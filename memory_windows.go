@@ -0,0 +1,40 @@
+package fxt
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procK32GetProcessMemoryInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("K32GetProcessMemoryInfo")
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	cb                         uint32
+	pageFaultCount             uint32
+	peakWorkingSetSize         uintptr
+	workingSetSize             uintptr
+	quotaPeakPagedPoolUsage    uintptr
+	quotaPagedPoolUsage        uintptr
+	quotaPeakNonPagedPoolUsage uintptr
+	quotaNonPagedPoolUsage     uintptr
+	pagefileUsage              uintptr
+	peakPagefileUsage          uintptr
+}
+
+func readProcessMemory(processId KernelObjectID) (resident uint64, virtual uint64, err error) {
+	process, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(processId))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open process %d - %w", processId, err)
+	}
+	defer syscall.CloseHandle(process)
+
+	var counters processMemoryCounters
+	counters.cb = uint32(unsafe.Sizeof(counters))
+	ok, _, callErr := procK32GetProcessMemoryInfo.Call(uintptr(process), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb))
+	if ok == 0 {
+		return 0, 0, fmt.Errorf("failed to get the memory info of process %d - %w", processId, callErr)
+	}
+
+	return uint64(counters.workingSetSize), uint64(counters.pagefileUsage), nil
+}
@@ -0,0 +1,69 @@
+package fxt
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// RecordProcessMetadata names `processId` after the executable, and attaches a description of where the trace
+// came from to it, so every trace is self-describing. The kernel object record naming the process gets the
+// arguments:
+//   - "command_line", the program's arguments, separated by spaces
+//   - "executable", the path of the executable
+//   - "hostname", the name of the machine
+//   - "os" and "arch", the GOOS and GOARCH the program was built for
+//   - "go_version", the Go release the program was built with
+//   - "env.<name>", for each of the `environment` variables that are set, like "env.KUBERNETES_NAMESPACE"
+//
+// The metadata is of the current process, so `processId` is usually os.Getpid(). The executable and hostname
+// are left out if they can't be found. Long command lines are handled by the Writer's LongStringPolicy
+//
+// An event has at most 15 arguments, so at most 9 environment variables can be recorded
+func (w *Writer) RecordProcessMetadata(processId KernelObjectID, environment ...string) error {
+	metadata := processMetadata(environment)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nameRef, err := w.getOrCreateStringRef(filepath.Base(os.Args[0]))
+	if err != nil {
+		return err
+	}
+
+	arguments, err := w.prepareArguments(metadata)
+	if err != nil {
+		return err
+	}
+
+	return w.writeRecord(KernelObjectRecord{
+		Type:      KernelObjectTypeProcess,
+		Koid:      processId,
+		Name:      nameRef,
+		Arguments: arguments,
+	}.appendRecord(w.scratch[:0]))
+}
+
+// processMetadata gathers the arguments written by RecordProcessMetadata
+func processMetadata(environment []string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"command_line": strings.Join(os.Args, " "),
+		"os":           runtime.GOOS,
+		"arch":         runtime.GOARCH,
+		"go_version":   runtime.Version(),
+	}
+	if executable, err := os.Executable(); err == nil {
+		metadata["executable"] = executable
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		metadata["hostname"] = hostname
+	}
+	for _, name := range environment {
+		if value, ok := os.LookupEnv(name); ok {
+			metadata["env."+name] = value
+		}
+	}
+
+	return metadata
+}
@@ -0,0 +1,63 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordProcessMetadata(t *testing.T) {
+	t.Setenv("FXT_TEST_REGION", "eu-west-1")
+
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.RecordProcessMetadata(42, "FXT_TEST_REGION", "FXT_TEST_UNSET"))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var process *fxt.KernelObjectRecord
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if object, ok := decoded.(*fxt.KernelObjectRecord); ok && object.Type == fxt.KernelObjectTypeProcess {
+			process = object
+			break
+		}
+	}
+	require.NotNil(t, process)
+	require.Equal(t, fxt.KernelObjectID(42), process.Koid)
+	require.Equal(t, filepath.Base(os.Args[0]), reader.ResolveString(process.Name))
+
+	metadata := map[string]interface{}{}
+	for _, argument := range reader.ResolveArguments(process.Arguments) {
+		metadata[argument.Key] = argument.Value
+	}
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	require.Equal(t, hostname, metadata["hostname"])
+	require.Equal(t, executable, metadata["executable"])
+	require.Equal(t, runtime.GOOS, metadata["os"])
+	require.Equal(t, runtime.GOARCH, metadata["arch"])
+	require.Equal(t, runtime.Version(), metadata["go_version"])
+	require.Contains(t, metadata["command_line"], os.Args[0])
+	require.Equal(t, "eu-west-1", metadata["env.FXT_TEST_REGION"])
+	require.NotContains(t, metadata, "env.FXT_TEST_UNSET")
+}
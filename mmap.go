@@ -0,0 +1,92 @@
+package fxt
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultMmapSize is the initial size of the mapping used by NewMmapWriter, when it's given a size of zero
+const DefaultMmapSize = 16 * 1024 * 1024
+
+// NewMmapWriter is the same as NewWriter, but the records are copied into a memory mapping of the file
+// instead of being written with a system call each. This helps when write overhead dominates, like when
+// tracing at very high event rates
+//
+// The mapping starts at `initialSize` bytes, or DefaultMmapSize if it's zero, and doubles whenever it fills up.
+// The file is truncated to the size of the trace by Close, so it must be called. Until then, the file has
+// zeroes after the last record
+//
+// Platforms without mmap support transparently fall back to writing to the file normally.
+// Reset keeps using a memory mapped file
+func NewMmapWriter(filePath string, initialSize int, options ...WriterOption) (*Writer, error) {
+	if initialSize < 0 {
+		return nil, fmt.Errorf("invalid initial size %d - must not be negative", initialSize)
+	}
+	if initialSize == 0 {
+		initialSize = DefaultMmapSize
+	}
+	createFile := func(filePath string) (io.WriteCloser, error) {
+		return createMmapFile(filePath, initialSize)
+	}
+
+	file, err := createFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dest file %s - %w", filePath, err)
+	}
+
+	writer, err := newWriter(file, file, filePath, options)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer.createFile = createFile
+
+	return writer, nil
+}
+
+// MmapReader is a Reader for a memory mapped trace file
+//
+// The records are sliced from the mapping, rather than copied, so the Data of each Record and its Payload
+// are only valid until Close is called
+type MmapReader struct {
+	*Reader
+	unmap func() error
+}
+
+// NewMmapReader memory maps the trace file at `filePath`, and creates a Reader for it
+// This avoids copying the whole trace when scanning it for a small subset of its records
+//
+// Platforms without mmap support transparently fall back to reading the whole file into memory
+func NewMmapReader(filePath string) (*MmapReader, error) {
+	data, unmap, err := mapFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s - %w", filePath, err)
+	}
+
+	reader, err := NewReaderFromBytes(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	return &MmapReader{Reader: reader, unmap: unmap}, nil
+}
+
+// Close unmaps the trace file
+func (r *MmapReader) Close() error {
+	if r.unmap == nil {
+		return os.ErrClosed
+	}
+	unmap := r.unmap
+	r.unmap = nil
+	return unmap()
+}
+
+// createFile creates the file at `filePath`, for NewWriter and Reset
+func createFile(filePath string) (io.WriteCloser, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
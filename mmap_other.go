@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package fxt
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile falls back to reading the whole file on platforms without mmap
+func mapFile(filePath string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
+
+// createMmapFile falls back to a normal file on platforms without mmap
+func createMmapFile(filePath string, initialSize int) (io.WriteCloser, error) {
+	return createFile(filePath)
+}
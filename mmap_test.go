@@ -0,0 +1,128 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMmapWriter(t *testing.T) {
+	writeTrace := func(writer fxt.TraceWriter) {
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, writer.AddInstantEventWithArgs("category", "event", 1, 2, uint64(i), map[string]interface{}{"index": i}))
+		}
+	}
+
+	var expected bytes.Buffer
+	writer, err := fxt.NewWriterTo(&expected)
+	require.NoError(t, err)
+	writeTrace(writer)
+
+	// The mapping starts at a single page, so it has to grow several times
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "mmap.fxt")
+	mmapWriter, err := fxt.NewMmapWriter(path, 1)
+	require.NoError(t, err)
+	writeTrace(mmapWriter)
+
+	// Reset keeps writing through a mapping, and closes the previous file
+	resetPath := filepath.Join(tempDir, "reset.fxt")
+	require.NoError(t, mmapWriter.Reset(resetPath))
+	writeTrace(mmapWriter)
+	require.NoError(t, mmapWriter.Close())
+
+	for _, path := range []string{path, resetPath} {
+		actual, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, expected.Bytes(), actual)
+	}
+
+	_, err = fxt.NewMmapWriter(path, -1)
+	require.Error(t, err)
+}
+
+func TestMmapReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "event", 1, 2, 100))
+	require.NoError(t, writer.AddBlobRecord("blob", []byte("blob data"), fxt.BlobTypeData))
+	require.NoError(t, writer.AddLargeBlobRecord("category", "large", []byte("large blob data")))
+	require.NoError(t, writer.AddLargeBlobEventRecordWithArgs("category", "large", 1, 2, 200, []byte("large blob event data"), map[string]interface{}{"key": "value"}))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewMmapReader(path)
+	require.NoError(t, err)
+
+	var payloads []string
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		switch record.Type {
+		case fxt.RecordTypeEvent:
+			event, err := reader.Resolve(record)
+			require.NoError(t, err)
+			require.Equal(t, "event", event.Name)
+		case fxt.RecordTypeString, fxt.RecordTypeBlob, fxt.RecordTypeLargeBlob:
+			payload, err := record.Payload()
+			require.NoError(t, err)
+			payloads = append(payloads, string(payload))
+		default:
+			_, err := record.Payload()
+			require.Error(t, err)
+		}
+	}
+	require.Equal(t, []string{"category", "event", "blob", "blob data", "large", "large blob data", "key", "value", "large blob event data"}, payloads)
+
+	require.NoError(t, reader.Close())
+	require.Error(t, reader.Close())
+
+	_, err = fxt.NewMmapReader(filepath.Join(t.TempDir(), "missing.fxt"))
+	require.Error(t, err)
+}
+
+func TestMmapReaderDoesNotCopyBlobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.fxt")
+	writer, err := fxt.NewWriter(path)
+	require.NoError(t, err)
+	data := make([]byte, 1<<20)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, writer.AddLargeBlobRecord("category", "large", data))
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewMmapReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	blobs := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if record.Type == fxt.RecordTypeLargeBlob {
+			blobs++
+		}
+	}
+	runtime.ReadMemStats(&after)
+
+	require.Equal(t, 20, blobs)
+	// Far less than the 20 MB of blob payloads
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
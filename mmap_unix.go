@@ -0,0 +1,122 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package fxt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the whole file at `filePath` read only, and returns the mapping and the function to unmap it
+func mapFile(filePath string) ([]byte, func() error, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The mapping stays valid after the file is closed
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
+
+// mmapFile writes to a file through a shared memory mapping, which is grown as needed
+type mmapFile struct {
+	file *os.File
+	// data is the mapping of the whole file, of which the first `length` bytes have been written
+	data   []byte
+	length int
+}
+
+func createMmapFile(filePath string, initialSize int) (io.WriteCloser, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mmapFile{file: file}
+	if err := m.grow(initialSize); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// grow remaps the file with at least `size` bytes, rounded up to a whole number of pages
+func (m *mmapFile) grow(size int) error {
+	pageSize := os.Getpagesize()
+	size = (size + pageSize - 1) / pageSize * pageSize
+
+	if m.data != nil {
+		if err := syscall.Munmap(m.data); err != nil {
+			return fmt.Errorf("failed to unmap %s - %w", m.file.Name(), err)
+		}
+		m.data = nil
+	}
+	if err := m.file.Truncate(int64(size)); err != nil {
+		return err
+	}
+
+	data, err := syscall.Mmap(int(m.file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("failed to map %s - %w", m.file.Name(), err)
+	}
+	m.data = data
+
+	return nil
+}
+
+func (m *mmapFile) Write(p []byte) (int, error) {
+	if m.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if m.length+len(p) > len(m.data) {
+		size := 2 * len(m.data)
+		if size < m.length+len(p) {
+			size = m.length + len(p)
+		}
+		if err := m.grow(size); err != nil {
+			return 0, err
+		}
+	}
+
+	copy(m.data[m.length:], p)
+	m.length += len(p)
+	return len(p), nil
+}
+
+// Close unmaps the file, and truncates it to the bytes that were written
+func (m *mmapFile) Close() error {
+	if m.file == nil {
+		return os.ErrClosed
+	}
+	file := m.file
+	m.file = nil
+
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	if err != nil {
+		err = fmt.Errorf("failed to unmap %s - %w", file.Name(), err)
+	} else {
+		err = file.Truncate(int64(m.length))
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
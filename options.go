@@ -0,0 +1,89 @@
+package fxt
+
+import (
+	"log"
+)
+
+// WriterOption configures a Writer
+type WriterOption func(*Writer)
+
+// WithLongStringPolicy sets how string argument values longer than MaxStringLength bytes are written
+// It defaults to LongStringError
+func WithLongStringPolicy(policy LongStringPolicy) WriterOption {
+	return func(w *Writer) {
+		w.longStringPolicy = policy
+	}
+}
+
+// WithFormatVersion sets the revision of the FXT spec the Writer writes. It defaults to LatestFormatVersion
+//
+// Creating the Writer fails if the version isn't supported
+func WithFormatVersion(version FormatVersion) WriterOption {
+	return func(w *Writer) {
+		w.formatVersion = version
+	}
+}
+
+// WarningHandler is called with problems the Writer detects, that don't prevent it from writing records
+type WarningHandler func(err error)
+
+func defaultWarningHandler(err error) {
+	log.Printf("fxt: %v", err)
+}
+
+// WithWarningHandler sets the function warnings are reported to. By default, they're logged with the log package
+//
+// The handler is called while the Writer's lock is held, so it must not call back into the Writer
+func WithWarningHandler(handler WarningHandler) WriterOption {
+	return func(w *Writer) {
+		w.warningHandler = handler
+	}
+}
+
+// WithTimestampCheck enables checking that the timestamps on each thread never go backwards
+// It defaults to TimestampCheckOff
+func WithTimestampCheck(check TimestampCheck) WriterOption {
+	return func(w *Writer) {
+		w.timestampCheck = check
+	}
+}
+
+// WithTimestampNormalization makes the Writer subtract the first timestamp it sees from all the
+// timestamps it writes, so the trace starts near zero. This avoids precision issues in viewers when
+// using clocks with huge absolute values, like WallClock
+//
+// Events are often written out of order across threads, so timestamps earlier than the first one are
+// clamped to zero. Use WithTimestampOffset to subtract a known start time instead
+func WithTimestampNormalization() WriterOption {
+	return func(w *Writer) {
+		w.normalizeTimestamps = true
+		w.hasTimestampOffset = false
+		w.fixedTimestampOffset = false
+	}
+}
+
+// WithTimestampOffset makes the Writer subtract `offset` from all the timestamps it writes
+// Timestamps earlier than `offset` are clamped to zero
+func WithTimestampOffset(offset uint64) WriterOption {
+	return func(w *Writer) {
+		w.normalizeTimestamps = true
+		w.timestampOffset = offset
+		w.hasTimestampOffset = true
+		w.fixedTimestampOffset = true
+	}
+}
+
+// Warn reports `err` to the Writer's warning handler
+// It's for code that writes events on behalf of others, like middleware, and has no caller to return errors to
+func (w *Writer) Warn(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.warn(err)
+}
+
+func (w *Writer) warn(err error) {
+	if w.warningHandler != nil {
+		w.warningHandler(err)
+	}
+}
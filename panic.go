@@ -0,0 +1,39 @@
+package fxt
+
+import (
+	"fmt"
+)
+
+// FlushOnPanic flushes `w`, and syncs the file it writes to, when the calling goroutine is panicking, so the
+// events leading up to a crash aren't lost in the buffers of WithFlushPolicy, WithPipeline, or ThreadWriters.
+// It must be deferred directly, at the top of main and of each goroutine whose panics should be caught:
+//
+//	defer fxt.FlushOnPanic(writer)
+//
+// The panic carries on once the Writer is flushed, so the program crashes as it would have, or is recovered by
+// an outer deferred function. Errors flushing the Writer are reported to the warning handler. The open spans
+// aren't ended, since the panic may still be recovered
+func FlushOnPanic(w *Writer) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if err := w.flush(); err != nil {
+		w.warn(fmt.Errorf("failed to flush on panic - %w", err))
+	} else if err := w.sync(); err != nil {
+		w.warn(fmt.Errorf("failed to sync on panic - %w", err))
+	}
+	w.mu.Unlock()
+
+	panic(value)
+}
+
+// sync commits the file the Writer opened to stable storage, if it can be. The Writer's lock must be held
+func (w *Writer) sync() error {
+	if syncer, ok := w.closer.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package fxt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushOnPanic(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithFlushPolicy(fxt.FlushPolicy{}))
+	require.NoError(t, err)
+
+	// Nothing is flushed without a panic
+	func() {
+		defer fxt.FlushOnPanic(writer)
+		require.NoError(t, writer.AddInstantEvent("category", "calm", 1, 2, 100))
+	}()
+	require.False(t, bytes.Contains(buffer.Bytes(), []byte("calm")))
+
+	func() {
+		defer func() {
+			require.Equal(t, "boom", recover())
+		}()
+		defer fxt.FlushOnPanic(writer)
+
+		require.NoError(t, writer.AddInstantEvent("category", "before crash", 1, 2, 200))
+		panic("boom")
+	}()
+	require.True(t, bytes.Contains(buffer.Bytes(), []byte("calm")))
+	require.True(t, bytes.Contains(buffer.Bytes(), []byte("before crash")))
+
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,196 @@
+package fxt
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Section is the events of one provider section of a trace
+type Section struct {
+	// ProviderId is the provider of the section, if HasProvider is set. The events before the first
+	// provider section or provider info record don't have a provider
+	ProviderId  uint32
+	HasProvider bool
+	// Offset is the position of the start of the section in the trace, in bytes
+	Offset int64
+	Events []*ResolvedEvent
+}
+
+// ParseSections splits the FXT trace in `data` at its provider section and provider info records, and
+// decodes and resolves the events of the sections on `workers` goroutines, or one per CPU if it's zero
+// The sections are returned in the order they appear in the trace
+//
+// The trace is first scanned for the string and thread records, which only needs the record headers, so
+// each section can resolve its references without reading the ones before it. Records are checked the
+// same way as by Reader.Next, and the error for the first bad record in the trace is returned
+//
+// Like NewReaderFromBytes, the records aren't copied, so `data` must not be changed while the events are in use
+func ParseSections(data []byte, workers int) ([]Section, error) {
+	reader, err := NewReaderFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	history := &tableHistory{
+		strings: map[uint16][]stringBinding{},
+		threads: map[uint16][]threadBinding{},
+	}
+	sections := []Section{{Offset: reader.Offset()}}
+	records := [][]*Record{nil}
+	for {
+		record, err := reader.nextRecord()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if err := reader.updateTables(record); err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+		history.add(reader, record)
+
+		if providerId, ok := sectionStart(record); ok {
+			sections = append(sections, Section{ProviderId: providerId, HasProvider: true, Offset: record.Offset})
+			records = append(records, nil)
+		}
+		records[len(records)-1] = append(records[len(records)-1], record)
+	}
+	if len(records[0]) == 0 && len(sections) > 1 {
+		// Traces usually start with a provider info record, so there's nothing before the first section
+		sections, records = sections[1:], records[1:]
+	}
+
+	errs := make([]error, len(sections))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				sections[index].Events, errs[index] = history.resolveEvents(records[index])
+			}
+		}()
+	}
+	for i := range sections {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
+
+// sectionStart returns the provider of the section `record` starts, if it's a provider section or
+// provider info record
+func sectionStart(record *Record) (uint32, bool) {
+	if record.Type != RecordTypeMetadata {
+		return 0, false
+	}
+
+	header := record.Header()
+	switch metadataType((header >> 16) & 0xF) {
+	case metadataTypeProviderInfo, metadataTypeProviderSection:
+		return uint32((header >> 20) & 0xFFFFFFFF), true
+	default:
+		return 0, false
+	}
+}
+
+// stringBinding and threadBinding are the values given to a table index by the record at `offset`
+type stringBinding struct {
+	offset int64
+	value  string
+}
+
+type threadBinding struct {
+	offset int64
+	value  Thread
+}
+
+// tableHistory is every value each string and thread index has had, in the order they were defined
+// Indices can be defined again, so references are looked up as of the record they're in
+type tableHistory struct {
+	strings map[uint16][]stringBinding
+	threads map[uint16][]threadBinding
+}
+
+// add records the table entry defined by `record`, after the Reader has added it to its tables
+func (h *tableHistory) add(reader *Reader, record *Record) {
+	header := record.Header()
+
+	switch record.Type {
+	case RecordTypeString:
+		index := uint16((header >> 16) & 0x7FFF)
+		h.strings[index] = append(h.strings[index], stringBinding{offset: record.Offset, value: reader.stringTable[index]})
+	case RecordTypeThread:
+		index := uint16((header >> 16) & 0xFF)
+		h.threads[index] = append(h.threads[index], threadBinding{offset: record.Offset, value: reader.threadTable[index]})
+	}
+}
+
+// resolveEvents checks the references of `records`, and returns their events resolved
+func (h *tableHistory) resolveEvents(records []*Record) ([]*ResolvedEvent, error) {
+	var events []*ResolvedEvent
+	for _, record := range records {
+		if !hasReferences(record.Type) {
+			continue
+		}
+
+		decoded, err := decodeRecord(record.Type, record.Data)
+		if err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+		if decoded == nil {
+			continue
+		}
+		tables := tablesAt{history: h, offset: record.Offset}
+		if err := checkDecodedReferences(tables, decoded); err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+		if event := resolveEvent(tables, decoded); event != nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// tablesAt looks up the table entries as they were when the record at `offset` was read
+type tablesAt struct {
+	history *tableHistory
+	offset  int64
+}
+
+func (t tablesAt) LookupString(index uint16) (string, bool) {
+	bindings := t.history.strings[index]
+	i := sort.Search(len(bindings), func(i int) bool {
+		return bindings[i].offset >= t.offset
+	})
+	if i == 0 {
+		return "", false
+	}
+	return bindings[i-1].value, true
+}
+
+func (t tablesAt) LookupThread(index uint16) (Thread, bool) {
+	bindings := t.history.threads[index]
+	i := sort.Search(len(bindings), func(i int) bool {
+		return bindings[i].offset >= t.offset
+	})
+	if i == 0 {
+		return Thread{}, false
+	}
+	return bindings[i-1].value, true
+}
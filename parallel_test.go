@@ -0,0 +1,77 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSections(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "before", 1, 1, 50))
+
+	first := writer.NewThreadWriter(1, 1, fxt.WithProvider(2))
+	second := writer.NewThreadWriter(1, 2, fxt.WithProvider(1))
+	for i := uint64(0); i < 10; i++ {
+		require.NoError(t, first.AddInstantEventWithArgs("category", "first", 100+i, map[string]interface{}{"i": i}))
+		require.NoError(t, second.AddDurationCompleteEvent("category", "second", 100+i, 200+i))
+	}
+	require.NoError(t, writer.Flush())
+	require.NoError(t, first.AddInstantEvent("category", "again", 300))
+	require.NoError(t, writer.Close())
+
+	sections, err := fxt.ParseSections(buffer.Bytes(), 4)
+	require.NoError(t, err)
+
+	var providers []uint32
+	for _, section := range sections {
+		providers = append(providers, section.ProviderId)
+		require.Equal(t, section.ProviderId != 0, section.HasProvider)
+	}
+	// The last event is in the same provider's section as the ones before it, so no section record is written for it
+	require.Equal(t, []uint32{0, 1, 2}, providers)
+	require.Len(t, sections[1].Events, 10)
+	require.Equal(t, "second", sections[1].Events[0].Name)
+	require.Equal(t, fxt.Thread{ProcessId: 1, ThreadId: 2}, sections[1].Events[0].Thread)
+	require.Equal(t, []fxt.ResolvedArgument{{Key: "i", Value: uint64(9)}}, sections[2].Events[9].Arguments)
+	require.Equal(t, "again", sections[2].Events[10].Name)
+
+	// The events are the same as reading the trace sequentially
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	for _, section := range sections {
+		for _, event := range section.Events {
+			expected, err := reader.NextEvent()
+			require.NoError(t, err)
+			require.Equal(t, expected, event)
+		}
+	}
+	_, err = reader.NextEvent()
+	require.True(t, errors.Is(err, io.EOF))
+}
+
+func TestParseSectionsBadReference(t *testing.T) {
+	magic, err := fxt.MagicNumberRecord(fxt.LatestFormatVersion)
+	require.NoError(t, err)
+	section, err := fxt.ProviderSectionRecord{ProviderId: 1}.MarshalBinary()
+	require.NoError(t, err)
+	event, err := fxt.InstantEvent{EventRecord: fxt.EventRecord{
+		Category: fxt.StringRef{Index: 1},
+		Name:     fxt.StringRef{Inline: "event"},
+		Thread:   fxt.ThreadRef{Inline: fxt.Thread{ProcessId: 1, ThreadId: 2}},
+	}}.MarshalBinary()
+	require.NoError(t, err)
+
+	trace := append(append(magic, section...), event...)
+	_, err = fxt.ParseSections(trace, 0)
+	var corrupt *fxt.CorruptRecordError
+	require.True(t, errors.As(err, &corrupt))
+	require.Equal(t, int64(len(magic)+len(section)), corrupt.Offset)
+}
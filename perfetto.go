@@ -0,0 +1,82 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// perfettoTracePacketField is the field number of `repeated TracePacket packet` in the perfetto.protos.Trace message
+const perfettoTracePacketField = 1
+
+// AddPerfettoBlob embeds serialized Perfetto trace data in the file, as one or more blob records of type BlobTypePerfetto
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#blob-record
+//
+// `trace` must be a serialized perfetto.protos.Trace message, for example the output of proto.Marshal on a
+// Trace, or the contents of a .perfetto-trace file. A blob record holds at most MaxBlobSize bytes, so larger
+// traces are split across several records. Since the concatenation of serialized Trace messages is itself a
+// valid Trace, the split is made between TracePackets, and each record holds a valid Trace message.
+// A single TracePacket larger than a blob record can't be split, and returns an error
+func (w *Writer) AddPerfettoBlob(name string, trace []byte) error {
+	chunks, err := splitPerfettoTrace(trace, MaxBlobSize)
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := w.AddBlobRecord(name, chunk, BlobTypePerfetto); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitPerfettoTrace splits a serialized Trace message between TracePackets into chunks of at most maxSize bytes
+func splitPerfettoTrace(trace []byte, maxSize int) ([][]byte, error) {
+	var chunks [][]byte
+
+	chunkStart := 0
+	offset := 0
+	for offset < len(trace) {
+		fieldStart := offset
+
+		tag, n := binary.Uvarint(trace[offset:])
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid perfetto trace - malformed field tag at offset %d", offset)
+		}
+		offset += n
+
+		fieldNumber, wireType := tag>>3, tag&0x7
+		if fieldNumber != perfettoTracePacketField || wireType != 2 {
+			return nil, fmt.Errorf("invalid perfetto trace - unexpected field %d (wire type %d) at offset %d", fieldNumber, wireType, fieldStart)
+		}
+
+		length, n := binary.Uvarint(trace[offset:])
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid perfetto trace - malformed packet length at offset %d", offset)
+		}
+		offset += n
+
+		if length > uint64(len(trace)-offset) {
+			return nil, fmt.Errorf("invalid perfetto trace - packet at offset %d is truncated", fieldStart)
+		}
+		offset += int(length)
+
+		if offset-fieldStart > maxSize {
+			return nil, fmt.Errorf("perfetto trace packet at offset %d is %d bytes, which is larger than the maximum blob size of %d", fieldStart, offset-fieldStart, maxSize)
+		}
+
+		// Start a new chunk if this packet doesn't fit in the current one
+		if offset-chunkStart > maxSize {
+			chunks = append(chunks, trace[chunkStart:fieldStart])
+			chunkStart = fieldStart
+		}
+	}
+
+	if offset > chunkStart {
+		chunks = append(chunks, trace[chunkStart:offset])
+	}
+
+	return chunks, nil
+}
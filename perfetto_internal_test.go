@@ -0,0 +1,53 @@
+package fxt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// perfettoPacket serializes a TracePacket field with a payload of `size` bytes
+func perfettoPacket(size int, fill byte) []byte {
+	packet := []byte{perfettoTracePacketField<<3 | 2}
+	for length := uint64(size); ; length >>= 7 {
+		if length < 0x80 {
+			packet = append(packet, byte(length))
+			break
+		}
+		packet = append(packet, byte(length)|0x80)
+	}
+
+	return append(packet, bytes.Repeat([]byte{fill}, size)...)
+}
+
+func TestSplitPerfettoTrace(t *testing.T) {
+	var trace []byte
+	trace = append(trace, perfettoPacket(10, 'a')...)
+	trace = append(trace, perfettoPacket(10, 'b')...)
+	trace = append(trace, perfettoPacket(20, 'c')...)
+
+	// Everything fits in one chunk
+	chunks, err := splitPerfettoTrace(trace, 1024)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{trace}, chunks)
+
+	// Each packet is 12 / 12 / 22 bytes. The first two fit together
+	chunks, err = splitPerfettoTrace(trace, 24)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	require.Equal(t, trace[:24], chunks[0])
+	require.Equal(t, trace[24:], chunks[1])
+
+	// A packet that can't fit anywhere is an error
+	_, err = splitPerfettoTrace(trace, 16)
+	require.Error(t, err)
+
+	// Truncated data is an error
+	_, err = splitPerfettoTrace(trace[:len(trace)-1], 1024)
+	require.Error(t, err)
+
+	// As is anything other than packets
+	_, err = splitPerfettoTrace([]byte{2<<3 | 0, 1}, 1024)
+	require.Error(t, err)
+}
@@ -0,0 +1,264 @@
+package fxt
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// WithPipeline splits encoding and writing into pipeline stages, so the Writer can use more than one core
+//
+// Event records are encoded by `workers` goroutines, or one per CPU if it's zero, and written in the order
+// they were added by another goroutine. The Writer's lock is only held while the strings and threads
+// are added to the tables, so goroutines adding events spend less time waiting for each other
+//
+// Events are checked before they're handed to the workers, so an event that can't be encoded returns its error
+// to the caller, like it does without the pipeline. Errors writing the records happen on another goroutine, so
+// they're returned by every call to the Writer after they happen, including Flush and Close, until the Writer
+// is reset to a new destination. Close must be called to stop the goroutines
+func WithPipeline(workers int) WriterOption {
+	return func(w *Writer) {
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		w.pipelineWorkers = workers
+	}
+}
+
+// pipelinedOutput starts the pipeline writing to the destination, if the Writer was created with WithPipeline
+// If the pipeline is already running, it has been drained by reset, so it can switch destinations
+func (w *Writer) pipelinedOutput() {
+	if w.pipelineWorkers == 0 {
+		return
+	}
+
+	if w.pipeline == nil {
+		w.pipeline = newPipeline(w.out, w.pipelineWorkers)
+	} else {
+		w.pipeline.out = w.out
+	}
+}
+
+// pipelineJob is a record to encode, or already encoded data to pass through in order
+type pipelineJob struct {
+	seq    uint64
+	record recordAppender
+	data   *[]byte
+	// barrier is closed once everything before it has been written
+	barrier chan struct{}
+}
+
+// pipeline encodes records on worker goroutines, and writes them in order on another
+type pipeline struct {
+	// out is where the records are written. It's only changed while the pipeline is drained
+	out     io.Writer
+	nextSeq uint64
+
+	jobs    chan pipelineJob
+	results chan pipelineJob
+	workers sync.WaitGroup
+	written chan struct{}
+	buffers sync.Pool
+
+	errMu sync.Mutex
+	// err is the first error writing a record. The output is left part way through a record, so it's kept
+	// until the pipeline is reset
+	err error
+	// encodeErr is an error encoding a record, which only loses that record, so it's returned once
+	encodeErr error
+}
+
+func newPipeline(out io.Writer, workers int) *pipeline {
+	p := &pipeline{
+		out:     out,
+		jobs:    make(chan pipelineJob, workers*64),
+		results: make(chan pipelineJob, workers*64),
+		written: make(chan struct{}),
+		buffers: sync.Pool{New: func() interface{} {
+			buffer := make([]byte, 0, 256)
+			return &buffer
+		}},
+	}
+
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.encode()
+	}
+	go p.write()
+
+	return p
+}
+
+// encode is run by each worker goroutine
+func (p *pipeline) encode() {
+	defer p.workers.Done()
+
+	for job := range p.jobs {
+		if job.record != nil {
+			data := p.buffers.Get().(*[]byte)
+			encoded, err := job.record.appendRecord((*data)[:0])
+			if err != nil {
+				p.setEncodeErr(err)
+				encoded = (*data)[:0]
+			}
+			*data = encoded
+			job.record = nil
+			job.data = data
+		}
+		p.results <- job
+	}
+}
+
+// write is run by the goroutine writing the records. It puts the encoded records back in order
+func (p *pipeline) write() {
+	defer close(p.written)
+
+	pending := map[uint64]pipelineJob{}
+	var next uint64
+	for job := range p.results {
+		pending[job.seq] = job
+
+		for {
+			job, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if job.barrier != nil {
+				close(job.barrier)
+				continue
+			}
+			if len(*job.data) > 0 && p.writeErr() == nil {
+				if _, err := p.out.Write(*job.data); err != nil {
+					p.setErr(fmt.Errorf("failed to write record - %w", err))
+				}
+			}
+			p.buffers.Put(job.data)
+		}
+	}
+}
+
+// writeErr returns the first error writing a record
+func (p *pipeline) writeErr() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	return p.err
+}
+
+func (p *pipeline) setErr(err error) {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *pipeline) setEncodeErr(err error) {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	if p.encodeErr == nil {
+		p.encodeErr = err
+	}
+}
+
+// Err returns the first error writing a record, or else the error encoding a record, if it hasn't been
+// returned already
+func (p *pipeline) Err() error {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	err := p.encodeErr
+	p.encodeErr = nil
+	return err
+}
+
+// clearErr forgets the errors, once the Writer has switched to a new destination. The pipeline must be drained
+func (p *pipeline) clearErr() {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+
+	p.err = nil
+	p.encodeErr = nil
+}
+
+// submitRecord queues a record to be encoded. The record must not refer to memory the caller will reuse
+// The Writer's lock must be held, so the records are numbered in the order they were added
+func (p *pipeline) submitRecord(record recordAppender) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	p.jobs <- pipelineJob{seq: p.nextSeq, record: record}
+	p.nextSeq++
+	return nil
+}
+
+// submitData queues a copy of encoded data to be written. The Writer's lock must be held
+func (p *pipeline) submitData(data []byte) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	buffer := p.buffers.Get().(*[]byte)
+	*buffer = append((*buffer)[:0], data...)
+	p.jobs <- pipelineJob{seq: p.nextSeq, data: buffer}
+	p.nextSeq++
+	return nil
+}
+
+// drain waits for everything submitted so far to be written. The Writer's lock must be held
+func (p *pipeline) drain() error {
+	barrier := make(chan struct{})
+	p.jobs <- pipelineJob{seq: p.nextSeq, barrier: barrier}
+	p.nextSeq++
+	<-barrier
+
+	return p.Err()
+}
+
+// stop drains the pipeline, and stops its goroutines. The Writer's lock must be held
+func (p *pipeline) stop() error {
+	err := p.drain()
+
+	close(p.jobs)
+	p.workers.Wait()
+	close(p.results)
+	<-p.written
+
+	return err
+}
+
+// eventAppender is implemented by the event record structs
+type eventAppender interface {
+	recordAppender
+	// checkEvent returns the error appendRecord would, without encoding the record
+	checkEvent() error
+}
+
+// writeEvent writes an event record. With WithPipeline, the record is encoded by the pipeline's workers
+//
+// The event's arguments must not be reused by the Writer afterwards, which prepareArguments ensures
+// when there's a pipeline. This is generic, rather than taking a recordAppender, so the record only
+// escapes to the heap when it's handed to the pipeline
+func writeEvent[R eventAppender](w *Writer, record R) error {
+	if err := w.maybeRotate(); err != nil {
+		return err
+	}
+	if w.pipeline != nil {
+		// The record is checked here, so an event that can't be encoded fails the call that added it
+		if err := record.checkEvent(); err != nil {
+			return err
+		}
+		return w.pipeline.submitRecord(record)
+	}
+	return w.writeRecord(record.appendRecord(w.scratch[:0]))
+}
@@ -0,0 +1,161 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// addPipelineEvents adds a mix of records, so the output of a pipelined Writer can be compared to a normal one
+func addPipelineEvents(t *testing.T, writer *fxt.Writer) {
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	for i := 0; i < 500; i++ {
+		require.NoError(t, writer.AddDurationBeginEventWithArgs("category", "span", 1, 2, uint64(i*10), map[string]interface{}{"index": i, "label": "value"}))
+		require.NoError(t, writer.AddCounterEvent("category", "counter", 1, 2, uint64(i*10+1), map[string]interface{}{"count": i}, 3))
+		require.NoError(t, writer.AddBlobRecord("blob", []byte{byte(i)}, fxt.BlobTypeData))
+		require.NoError(t, writer.AddDurationEndEvent("category", "span", 1, 2, uint64(i*10+2)))
+	}
+
+	threadWriter := writer.NewThreadWriter(1, 3)
+	require.NoError(t, threadWriter.AddInstantEvent("category", "buffered", 5000))
+}
+
+func TestPipelineMatchesWriter(t *testing.T) {
+	var expected bytes.Buffer
+	writer, err := fxt.NewWriterTo(&expected)
+	require.NoError(t, err)
+	addPipelineEvents(t, writer)
+	require.NoError(t, writer.Close())
+
+	var actual bytes.Buffer
+	pipelined, err := fxt.NewWriterTo(&actual, fxt.WithPipeline(4))
+	require.NoError(t, err)
+	addPipelineEvents(t, pipelined)
+	require.NoError(t, pipelined.Flush())
+	require.Equal(t, expected.Bytes(), actual.Bytes())
+	require.NoError(t, pipelined.Close())
+}
+
+func TestPipelineConcurrent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithPipeline(0))
+	require.NoError(t, err)
+
+	const numThreads = 8
+	const numEvents = 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		threadId := fxt.KernelObjectID(i + 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numEvents; j++ {
+				require.NoError(t, writer.AddInstantEventWithArgs("category", "event", 1, threadId, uint64(j), map[string]interface{}{"index": j}))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, writer.Close())
+
+	// The events of each thread are in the order they were added
+	counts := map[uint8]int{}
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		if event, ok := record.(*fxt.InstantEvent); ok {
+			require.Equal(t, uint64(counts[event.Thread.Index]), event.Timestamp)
+			counts[event.Thread.Index]++
+		}
+	}
+	require.Len(t, counts, numThreads)
+	for _, count := range counts {
+		require.Equal(t, numEvents, count)
+	}
+}
+
+func TestPipelineReset(t *testing.T) {
+	var first bytes.Buffer
+	writer, err := fxt.NewWriterTo(&first, fxt.WithPipeline(2))
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+
+	// Everything added before the reset ends up in the first trace
+	var second bytes.Buffer
+	require.NoError(t, writer.ResetTo(&second))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+	require.NoError(t, writer.Flush())
+	require.Equal(t, first.Bytes(), second.Bytes())
+
+	// A closed Writer starts a new pipeline when it's reset
+	require.NoError(t, writer.Close())
+	var third bytes.Buffer
+	require.NoError(t, writer.ResetTo(&third))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+	require.NoError(t, writer.Close())
+	require.Equal(t, first.Bytes(), third.Bytes())
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+var errFailingWriter = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errFailingWriter
+}
+
+func TestPipelineWriteError(t *testing.T) {
+	writer, err := fxt.NewWriterTo(failingWriter{}, fxt.WithPipeline(2))
+	require.NoError(t, err)
+
+	// The error happens on another goroutine, so it's returned by Flush
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.ErrorIs(t, writer.Flush(), errFailingWriter)
+	require.ErrorIs(t, writer.AddInstantEvent("category", "name", 1, 2, 100), errFailingWriter)
+	require.ErrorIs(t, writer.Close(), errFailingWriter)
+}
+
+func TestPipelineEncodeError(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithPipeline(2))
+	require.NoError(t, err)
+
+	// An event that can't be encoded fails the call that added it, and nothing after it
+	arguments := map[string]interface{}{}
+	for i := 0; i < 16; i++ {
+		arguments[fmt.Sprintf("arg%d", i)] = i
+	}
+	require.ErrorContains(t, writer.AddInstantEventWithArgs("category", "name", 1, 2, 100, arguments), "too many arguments")
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 200))
+	require.NoError(t, writer.Flush())
+	require.NoError(t, writer.Close())
+
+	records := readRecords(t, buffer.Bytes())
+	events := 0
+	for _, record := range records {
+		if _, ok := record.(*fxt.InstantEvent); ok {
+			events++
+		}
+	}
+	require.Equal(t, 1, events)
+}
+
+func TestPipelineResetAfterWriteError(t *testing.T) {
+	writer, err := fxt.NewWriterTo(failingWriter{}, fxt.WithPipeline(2))
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.ErrorIs(t, writer.Flush(), errFailingWriter)
+
+	// The error is returned by the reset, but doesn't carry over to the new destination
+	var buffer bytes.Buffer
+	require.ErrorIs(t, writer.ResetTo(&buffer), errFailingWriter)
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.NoError(t, writer.Close())
+	require.Len(t, readRecords(t, buffer.Bytes()), 4)
+}
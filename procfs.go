@@ -0,0 +1,236 @@
+package fxt
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseProcStat parses the CPU lines of /proc/stat, which look like:
+//
+//	cpu  user nice system idle iowait irq softirq steal guest guest_nice
+//	cpu0 user nice system idle iowait irq softirq steal guest guest_nice
+//
+// The times are in clock ticks. Guest time is already counted in user time, so it's ignored
+func parseProcStat(data string) (cpuStats, error) {
+	stats := cpuStats{
+		perCPU: map[int]cpuTime{},
+	}
+	hasAll := false
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if len(fields) < 5 {
+			return cpuStats{}, fmt.Errorf("invalid CPU line %q", scanner.Text())
+		}
+
+		// Older kernels don't have the later columns
+		var times [8]uint64
+		for i := 0; i < len(times) && i+1 < len(fields); i++ {
+			value, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return cpuStats{}, fmt.Errorf("invalid CPU line %q - %w", scanner.Text(), err)
+			}
+			times[i] = value
+		}
+		user, nice, system, idle, iowait, irq, softirq, steal := times[0], times[1], times[2], times[3], times[4], times[5], times[6], times[7]
+		busy := user + nice + system + irq + softirq + steal
+		usage := cpuTime{busy: busy, total: busy + idle + iowait}
+
+		if fields[0] == "cpu" {
+			stats.all = usage
+			hasAll = true
+			continue
+		}
+		cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil {
+			return cpuStats{}, fmt.Errorf("invalid CPU line %q - %w", scanner.Text(), err)
+		}
+		stats.perCPU[cpu] = usage
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuStats{}, err
+	}
+	if !hasAll {
+		return cpuStats{}, fmt.Errorf("no CPU totals")
+	}
+
+	return stats, nil
+}
+
+// parseProcPidStat parses the user and system time, in clock ticks, from /proc/<pid>/stat
+//
+// The second field is the command name in parentheses, which can itself contain spaces and parentheses,
+// so the fields are counted from the last closing parenthesis
+func parseProcPidStat(data string) (user uint64, system uint64, err error) {
+	end := strings.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, 0, fmt.Errorf("missing command name")
+	}
+
+	// The fields after the command name start at the third, state. utime and stime are the 14th and 15th
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 13 {
+		return 0, 0, fmt.Errorf("expected at least 15 fields, got %d", len(fields)+2)
+	}
+	user, err = strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid utime - %w", err)
+	}
+	system, err = strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stime - %w", err)
+	}
+
+	return user, system, nil
+}
+
+// parseProcPidStatm parses the virtual size and resident set size, in bytes, from /proc/<pid>/statm,
+// whose first two fields are those sizes in pages
+func parseProcPidStatm(data string, pageSize uint64) (virtual uint64, resident uint64, err error) {
+	fields := strings.Fields(data)
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("expected at least 2 fields, got %d", len(fields))
+	}
+	virtual, err = strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size - %w", err)
+	}
+	resident, err = strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resident - %w", err)
+	}
+
+	return virtual * pageSize, resident * pageSize, nil
+}
+
+// parseProcPidIO parses /proc/<pid>/io, which has a "name: value" line for each counter, like:
+//
+//	rchar: 323934931
+//	wchar: 323929600
+//	syscr: 632687
+//	syscw: 632675
+//	read_bytes: 0
+//	write_bytes: 323932160
+//	cancelled_write_bytes: 0
+func parseProcPidIO(data string) (ioStats, error) {
+	var stats ioStats
+	counters := map[string]*uint64{
+		"rchar":       &stats.readChars,
+		"wchar":       &stats.writeChars,
+		"syscr":       &stats.readSyscalls,
+		"syscw":       &stats.writeSyscalls,
+		"read_bytes":  &stats.readBytes,
+		"write_bytes": &stats.writeBytes,
+	}
+
+	found := 0
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		counter, ok := counters[strings.TrimSpace(name)]
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return ioStats{}, fmt.Errorf("invalid %s - %w", name, err)
+		}
+		*counter = parsed
+		found++
+	}
+	if err := scanner.Err(); err != nil {
+		return ioStats{}, err
+	}
+	if found != len(counters) {
+		return ioStats{}, fmt.Errorf("expected %d counters, got %d", len(counters), found)
+	}
+
+	return stats, nil
+}
+
+// parseProcNetDev parses /proc/<pid>/net/dev, and returns the bytes received and transmitted by all the
+// interfaces other than loopback. After two header lines, it has a line for each interface, like:
+//
+//	eth0: 1234 10 0 0 0 0 0 0 5678 20 0 0 0 0 0 0
+//
+// The first field is the received bytes, and the ninth the transmitted bytes
+func parseProcNetDev(data string) (received uint64, transmitted uint64, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		name, values, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(values)
+		if len(fields) < 9 {
+			return 0, 0, fmt.Errorf("invalid interface line %q", scanner.Text())
+		}
+		receivedBytes, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid interface line %q - %w", scanner.Text(), err)
+		}
+		transmittedBytes, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid interface line %q - %w", scanner.Text(), err)
+		}
+		received += receivedBytes
+		transmitted += transmittedBytes
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return received, transmitted, nil
+}
+
+// parseProcPidCgroup returns the path of a process's cgroup v2 from /proc/<pid>/cgroup, which has a
+// "hierarchy-ID:controllers:path" line for each hierarchy. The v2 hierarchy's line is "0::path"
+func parseProcPidCgroup(data string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("process is not in a cgroup v2 hierarchy")
+}
+
+// parseCgroup2Mount returns where the cgroup v2 hierarchy is mounted, and the cgroup that's at the mount point,
+// from /proc/self/mountinfo. Its lines look like:
+//
+//	42 32 0:38 / /sys/fs/cgroup rw,relatime shared:1 - cgroup2 cgroup2 rw
+//
+// The fourth and fifth fields are the root and mount point, and the first after the "-" is the filesystem type
+func parseCgroup2Mount(data string) (mountPoint string, root string, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i := 5; i+1 < len(fields); i++ {
+			if fields[i] != "-" {
+				continue
+			}
+			if fields[i+1] == "cgroup2" {
+				return fields[4], fields[3], nil
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	return "", "", fmt.Errorf("cgroup v2 is not mounted")
+}
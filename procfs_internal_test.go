@@ -0,0 +1,154 @@
+package fxt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const procStat = `cpu  100 10 50 800 40 5 5 0 20 0
+cpu0 60 5 30 390 10 3 2 0 20 0
+cpu2 40 5 20 410 30 2 3 0 0 0
+intr 12345 0 0
+ctxt 67890
+btime 1700000000
+`
+
+func TestParseProcStat(t *testing.T) {
+	stats, err := parseProcStat(procStat)
+	require.NoError(t, err)
+	require.Equal(t, cpuTime{busy: 170, total: 1010}, stats.all)
+	require.Equal(t, map[int]cpuTime{
+		0: {busy: 100, total: 500},
+		2: {busy: 70, total: 510},
+	}, stats.perCPU)
+
+	// Older kernels have fewer columns
+	stats, err = parseProcStat("cpu 1 2 3 4\n")
+	require.NoError(t, err)
+	require.Equal(t, cpuTime{busy: 6, total: 10}, stats.all)
+
+	_, err = parseProcStat("intr 1\n")
+	require.Error(t, err)
+	_, err = parseProcStat("cpu 1 2 x 4\n")
+	require.Error(t, err)
+}
+
+func TestParseProcPidStat(t *testing.T) {
+	user, system, err := parseProcPidStat("1234 (my (odd) cmd) S 1 1234 1234 0 -1 4194304 100 0 0 0 250 75 0 0 20 0 1 0 100 1000 100\n")
+	require.NoError(t, err)
+	require.Equal(t, uint64(250), user)
+	require.Equal(t, uint64(75), system)
+
+	_, _, err = parseProcPidStat("1234 cmd S 1")
+	require.Error(t, err)
+	_, _, err = parseProcPidStat("1234 (cmd) S 1 2 3")
+	require.Error(t, err)
+}
+
+func TestCPUUtilization(t *testing.T) {
+	last := cpuStats{
+		all:           cpuTime{busy: 100, total: 1000},
+		perCPU:        map[int]cpuTime{0: {busy: 50, total: 500}, 1: {busy: 50, total: 500}},
+		processUser:   10,
+		processSystem: 10,
+	}
+	current := cpuStats{
+		all:           cpuTime{busy: 250, total: 1200},
+		perCPU:        map[int]cpuTime{0: {busy: 150, total: 600}, 1: {busy: 100, total: 600}, 2: {busy: 1, total: 2}},
+		processUser:   40,
+		processSystem: 30,
+	}
+
+	// CPU 2 came online between the snapshots, so it has no utilization yet
+	total, perCPU := cpuUtilization(last, current)
+	require.Equal(t, 75.0, total)
+	require.Equal(t, map[int]float64{0: 100.0, 1: 50.0}, perCPU)
+
+	// 200 ticks of all the CPUs combined is 200/3 ticks of wall time
+	require.InDeltaMapValues(t, map[string]interface{}{
+		"user":   45.0,
+		"system": 30.0,
+		"total":  75.0,
+	}, processUtilization(last, current), 1e-9)
+
+	// Counters that didn't move, or went backwards, are 0
+	total, perCPU = cpuUtilization(current, last)
+	require.Equal(t, 0.0, total)
+	require.Equal(t, map[int]float64{0: 0.0, 1: 0.0}, perCPU)
+	require.Equal(t, map[string]interface{}{"user": 0.0, "system": 0.0, "total": 0.0}, processUtilization(current, current))
+}
+
+func TestParseProcPidStatm(t *testing.T) {
+	virtual, resident, err := parseProcPidStatm("1000 250 100 10 0 300 0\n", 4096)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000*4096), virtual)
+	require.Equal(t, uint64(250*4096), resident)
+
+	_, _, err = parseProcPidStatm("1000", 4096)
+	require.Error(t, err)
+	_, _, err = parseProcPidStatm("1000 x", 4096)
+	require.Error(t, err)
+}
+
+func TestParseProcPidIO(t *testing.T) {
+	stats, err := parseProcPidIO(`rchar: 1000
+wchar: 2000
+syscr: 30
+syscw: 40
+read_bytes: 4096
+write_bytes: 8192
+cancelled_write_bytes: 0
+`)
+	require.NoError(t, err)
+	require.Equal(t, ioStats{
+		readChars:     1000,
+		writeChars:    2000,
+		readSyscalls:  30,
+		writeSyscalls: 40,
+		readBytes:     4096,
+		writeBytes:    8192,
+	}, stats)
+
+	_, err = parseProcPidIO("rchar: 1000\n")
+	require.Error(t, err)
+	_, err = parseProcPidIO("rchar: x\n")
+	require.Error(t, err)
+}
+
+func TestParseProcNetDev(t *testing.T) {
+	received, transmitted, err := parseProcNetDev(`Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 9999 10 0 0 0 0 0 0 9999 10 0 0 0 0 0 0
+  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0
+ wlan0: 300 3 0 0 0 0 0 0 400 4 0 0 0 0 0 0
+`)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1300), received)
+	require.Equal(t, uint64(2400), transmitted)
+
+	_, _, err = parseProcNetDev("eth0: 1 2 3\n")
+	require.Error(t, err)
+}
+
+func TestParseProcPidCgroup(t *testing.T) {
+	path, err := parseProcPidCgroup("4:memory:/docker/abc\n1:cpu:/\n0::/system.slice/app.service\n")
+	require.NoError(t, err)
+	require.Equal(t, "/system.slice/app.service", path)
+
+	_, err = parseProcPidCgroup("4:memory:/docker/abc\n")
+	require.Error(t, err)
+}
+
+func TestParseCgroup2Mount(t *testing.T) {
+	mountPoint, root, err := parseCgroup2Mount(`32 24 0:28 / /sys/fs/cgroup rw,relatime - tmpfs tmpfs rw,mode=755
+36 32 0:32 / /sys/fs/cgroup/memory rw,relatime shared:5 - cgroup cgroup rw,memory
+42 32 0:38 /kubepods /sys/fs/cgroup/unified rw,relatime shared:9 master:1 - cgroup2 cgroup2 rw
+`)
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/unified", mountPoint)
+	require.Equal(t, "/kubepods", root)
+
+	_, _, err = parseCgroup2Mount("36 32 0:32 / /sys/fs/cgroup/memory rw,relatime - cgroup cgroup rw,memory\n")
+	require.Error(t, err)
+}
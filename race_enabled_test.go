@@ -0,0 +1,6 @@
+//go:build race
+
+package fxt_test
+
+// raceEnabled is set when testing with -race, which adds allocations of its own
+const raceEnabled = true
@@ -0,0 +1,622 @@
+package fxt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// String returns the name of the record type
+func (t RecordType) String() string {
+	switch t {
+	case RecordTypeMetadata:
+		return "metadata"
+	case RecordTypeInitialization:
+		return "initialization"
+	case RecordTypeString:
+		return "string"
+	case RecordTypeThread:
+		return "thread"
+	case RecordTypeEvent:
+		return "event"
+	case RecordTypeBlob:
+		return "blob"
+	case RecordTypeUserspaceObject:
+		return "userspace object"
+	case RecordTypeKernelObject:
+		return "kernel object"
+	case RecordTypeScheduling:
+		return "scheduling"
+	case RecordTypeLog:
+		return "log"
+	case RecordTypeLargeBlob:
+		return "large blob"
+	default:
+		return fmt.Sprintf("reserved(%d)", int(t))
+	}
+}
+
+// Record is a single raw record read from an FXT trace
+type Record struct {
+	// Offset is the position of the record in the trace, in bytes
+	Offset int64
+	// Type is the type of the record, from the lowest 4 bits of the header
+	Type RecordType
+	// Data is the whole record, including the header word
+	Data []byte
+}
+
+// Header returns the first word of the record
+func (r *Record) Header() uint64 {
+	return binary.LittleEndian.Uint64(r.Data)
+}
+
+// Word returns the `index`th 8 byte word of the record. Word 0 is the header
+func (r *Record) Word(index int) uint64 {
+	return binary.LittleEndian.Uint64(r.Data[index*8:])
+}
+
+// Decode decodes the record into the matching record struct, like *InstantEvent or *StringRecord
+// It returns nil for records that don't have a struct, like log records and unknown event types
+func (r *Record) Decode() (encoding.BinaryMarshaler, error) {
+	decoded, err := decodeRecord(r.Type, r.Data)
+	if err != nil || decoded == nil {
+		return nil, err
+	}
+	return decoded.(encoding.BinaryMarshaler), nil
+}
+
+// Payload returns the string of a string record, or the data of a blob or large blob record
+//
+// Unlike Decode, the payload isn't copied, so it's a sub-slice of the record's Data. This avoids copying
+// them when scanning big traces for a few of their records
+func (r *Record) Payload() ([]byte, error) {
+	if r.Type == RecordTypeString {
+		d, header, err := newRecordDecoder(r.Data, r.Type)
+		if err != nil {
+			return nil, err
+		}
+		return d.slice(int((header >> 32) & 0x7FFF))
+	}
+
+	_, payload, err := r.blob()
+	return payload, err
+}
+
+// blobFields are the fields of a blob or large blob record, other than its payload
+type blobFields struct {
+	category StringRef
+	name     StringRef
+	// blobType is only set for blob records
+	blobType BlobType
+	// event is only set for large blob records with metadata
+	event *EventRecord
+}
+
+// blob decodes a blob or large blob record, without copying its payload
+func (r *Record) blob() (blobFields, []byte, error) {
+	header := r.Header()
+
+	switch r.Type {
+	case RecordTypeBlob:
+		d, header, err := newRecordDecoder(r.Data, r.Type)
+		if err != nil {
+			return blobFields{}, nil, err
+		}
+		fields := blobFields{blobType: BlobType((header >> 48) & 0xFF)}
+		if fields.name, err = d.stringRef((header >> 16) & 0xFFFF); err != nil {
+			return blobFields{}, nil, fmt.Errorf("invalid name - %w", err)
+		}
+		payload, err := d.slice(int((header >> 32) & 0x7FFF))
+		return fields, payload, err
+	case RecordTypeLargeBlob:
+		format := largeBlobFormat((header >> 40) & 0xF)
+		d, err := largeBlobHeader(r.Data, format)
+		if err != nil {
+			return blobFields{}, nil, err
+		}
+		formatHeader, err := d.word()
+		if err != nil {
+			return blobFields{}, nil, fmt.Errorf("invalid blob format header - %w", err)
+		}
+
+		var fields blobFields
+		if fields.category, err = d.stringRef(formatHeader & 0xFFFF); err != nil {
+			return blobFields{}, nil, fmt.Errorf("invalid category - %w", err)
+		}
+		if fields.name, err = d.stringRef((formatHeader >> 16) & 0xFFFF); err != nil {
+			return blobFields{}, nil, fmt.Errorf("invalid name - %w", err)
+		}
+		if format == largeBlobFormatMetadata {
+			event := &EventRecord{Category: fields.category, Name: fields.name}
+			if event.Timestamp, err = d.word(); err != nil {
+				return blobFields{}, nil, fmt.Errorf("invalid timestamp - %w", err)
+			}
+			if event.Thread, err = d.threadRef((formatHeader >> 36) & 0xFF); err != nil {
+				return blobFields{}, nil, fmt.Errorf("invalid thread - %w", err)
+			}
+			if event.Arguments, err = d.arguments(int((formatHeader >> 32) & 0xF)); err != nil {
+				return blobFields{}, nil, err
+			}
+			fields.event = event
+		}
+
+		size, err := d.word()
+		if err != nil {
+			return blobFields{}, nil, fmt.Errorf("invalid blob size - %w", err)
+		}
+		if size > uint64(len(d.data)) {
+			return blobFields{}, nil, fmt.Errorf("invalid blob size - %d bytes exceeds the record size", size)
+		}
+		payload, err := d.slice(int(size))
+		return fields, payload, err
+	default:
+		return blobFields{}, nil, fmt.Errorf("%s records don't have a payload", r.Type)
+	}
+}
+
+// NewReader creates a Reader for the FXT trace in `r`, and checks it starts with the FXT magic number record
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{
+		r:           bufio.NewReader(r),
+		stringTable: map[uint16]string{},
+		threadTable: map[uint16]Thread{},
+		symbols:     NewSymbolTable(),
+	}
+
+	magic := make([]byte, len(fxtMagic))
+	if _, err := io.ReadFull(reader.r, magic); err != nil {
+		return nil, fmt.Errorf("failed to read magic number record - %w", err)
+	}
+	version, err := parseMagicNumberRecord(magic)
+	if err != nil {
+		return nil, err
+	}
+	reader.version = version
+	reader.offset = int64(len(magic))
+
+	return reader, nil
+}
+
+// NewReaderFromBytes creates a Reader for an FXT trace that's already in memory
+//
+// The records aren't copied, so the Data of each Record is a sub-slice of `data`, and `data`
+// must not be changed while the records are in use
+//
+// The Reader doesn't depend on the os package, so this is the entry point for parsing traces in places
+// without a file system, like a browser with GOOS=js GOARCH=wasm
+func NewReaderFromBytes(data []byte) (*Reader, error) {
+	reader := &Reader{
+		data:        data,
+		stringTable: map[uint16]string{},
+		threadTable: map[uint16]Thread{},
+		symbols:     NewSymbolTable(),
+	}
+
+	if len(data) < len(fxtMagic) {
+		return nil, fmt.Errorf("failed to read magic number record - %w", io.ErrUnexpectedEOF)
+	}
+	version, err := parseMagicNumberRecord(data[:len(fxtMagic)])
+	if err != nil {
+		return nil, err
+	}
+	reader.version = version
+	reader.offset = int64(len(fxtMagic))
+
+	return reader, nil
+}
+
+// Reader reads the records of an FXT trace one at a time
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md
+//
+// As it reads, the Reader keeps track of the string and thread tables, so references
+// in later records can be resolved with LookupString and LookupThread. It also collects the stacks and
+// symbols written by Writer.AddStack and Writer.AddSymbols, to symbolize arguments with Symbols
+type Reader struct {
+	r *bufio.Reader
+	// data is the whole trace, for Readers created with NewReaderFromBytes. The records are sliced from it,
+	// rather than read from `r`
+	data    []byte
+	offset  int64
+	version FormatVersion
+
+	stringTable map[uint16]string
+	threadTable map[uint16]Thread
+	// symbols are the stacks and symbols from the stack and symbols blobs read so far
+	symbols *SymbolTable
+	// With AllowTruncatedTail, a record cut short by the end of the trace ends it, and truncated is set
+	allowTruncatedTail bool
+	truncated          bool
+	// With VerifyChecksums, the CRC-32C and size of the chunk since the last checksum record, whether there
+	// has been one, and whether the last record was a footer
+	verifyChecksums bool
+	checksum        uint32
+	checksumSize    uint64
+	hasChecksums    bool
+	footer          bool
+
+	stats Stats
+}
+
+// CorruptRecordError describes a record that can't be read, or that isn't valid
+//
+// Offset is the position of the start of the record in the trace, so the producer that wrote it can be tracked down
+type CorruptRecordError struct {
+	Offset int64
+	Type   RecordType
+	Err    error
+}
+
+func (e *CorruptRecordError) Error() string {
+	return fmt.Sprintf("corrupt %s record at offset %d - %v", e.Type, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *CorruptRecordError) Unwrap() error {
+	return e.Err
+}
+
+// minRecordSizeInWords is the smallest valid size of each type of record
+var minRecordSizeInWords = map[RecordType]uint64{
+	RecordTypeMetadata:        1,
+	RecordTypeInitialization:  2,
+	RecordTypeString:          1,
+	RecordTypeThread:          3,
+	RecordTypeEvent:           2,
+	RecordTypeBlob:            1,
+	RecordTypeUserspaceObject: 3,
+	RecordTypeKernelObject:    2,
+	RecordTypeScheduling:      3,
+	RecordTypeLog:             3,
+	RecordTypeLargeBlob:       3,
+}
+
+// Next reads the next record. It returns io.EOF when there are no more records
+//
+// Records that are truncated, have an impossible size, or refer to strings or threads that haven't
+// been defined yet, are reported with a *CorruptRecordError. If the trace ends part way through a record,
+// the returned error wraps io.ErrUnexpectedEOF
+func (r *Reader) Next() (*Record, error) {
+	record, err := r.nextRecord()
+	if err != nil {
+		if r.allowTruncatedTail && errors.Is(err, io.ErrUnexpectedEOF) {
+			r.truncated = true
+			err = io.EOF
+		}
+		if err == io.EOF && r.verifyChecksums && r.hasChecksums && !r.footer {
+			// Only reported once, so the next call returns io.EOF
+			r.hasChecksums = false
+			return nil, &CorruptRecordError{Offset: r.offset, Err: ErrMissingFooter}
+		}
+		return nil, err
+	}
+	if r.verifyChecksums {
+		if err := r.verifyChecksum(record); err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+	}
+	if err := r.updateTables(record); err != nil {
+		return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+	}
+	if err := r.checkReferences(record); err != nil {
+		return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+	}
+	if err := r.updateSymbols(record); err != nil {
+		return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+	}
+
+	return record, nil
+}
+
+// nextRecord reads the next record, without updating the tables or checking its references
+func (r *Reader) nextRecord() (*Record, error) {
+	offset := r.offset
+
+	headerBytes, err := r.readHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read record header at offset %d - %w", offset, err)
+		}
+		return nil, &CorruptRecordError{Offset: offset, Err: fmt.Errorf("truncated record header - %w", err)}
+	}
+	header := binary.LittleEndian.Uint64(headerBytes)
+
+	recordType := RecordType(header & 0xF)
+	var sizeInWords uint64
+	if recordType == RecordTypeLargeBlob {
+		sizeInWords = (header >> 4) & 0xFFFFFFFF
+	} else {
+		sizeInWords = (header >> 4) & 0xFFF
+	}
+	minSizeInWords, ok := minRecordSizeInWords[recordType]
+	if !ok {
+		minSizeInWords = 1
+	}
+	if sizeInWords < minSizeInWords {
+		return nil, &CorruptRecordError{Offset: offset, Type: recordType, Err: fmt.Errorf("size of %d words is smaller than the minimum of %d", sizeInWords, minSizeInWords)}
+	}
+
+	data, err := r.readRecord(headerBytes, sizeInWords)
+	if err != nil {
+		return nil, &CorruptRecordError{Offset: offset, Type: recordType, Err: fmt.Errorf("truncated record - %w", err)}
+	}
+	r.offset += int64(sizeInWords) * 8
+
+	record := &Record{
+		Offset: offset,
+		Type:   recordType,
+		Data:   data,
+	}
+	r.addRecord(record)
+	return record, nil
+}
+
+// readHeader reads the header word of the next record. It returns io.EOF if there are no more records,
+// io.ErrUnexpectedEOF if the trace ends part way through the header, or the error of the underlying reader
+func (r *Reader) readHeader() ([]byte, error) {
+	if r.data != nil {
+		remaining := r.data[r.offset:]
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		}
+		if len(remaining) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return remaining[:8], nil
+	}
+
+	headerBytes := make([]byte, 8)
+	if n, err := io.ReadFull(r.r, headerBytes); err != nil {
+		if errors.Is(err, io.EOF) && n == 0 {
+			return nil, io.EOF
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		// Errors from the underlying reader, like a DecryptingReader failing to authenticate the trace
+		return nil, err
+	}
+	return headerBytes, nil
+}
+
+// readRecord reads the rest of a record after `headerBytes`, and returns the whole record
+func (r *Reader) readRecord(headerBytes []byte, sizeInWords uint64) ([]byte, error) {
+	if r.data != nil {
+		remaining := uint64(len(r.data)) - uint64(r.offset)
+		if sizeInWords*8 > remaining {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return r.data[r.offset : uint64(r.offset)+sizeInWords*8], nil
+	}
+
+	// Read through a buffer, rather than allocating the whole size up front,
+	// so a corrupt size can't allocate more than what's actually in the trace
+	var data bytes.Buffer
+	data.Write(headerBytes)
+	if _, err := io.CopyN(&data, r.r, int64(sizeInWords-1)*8); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// updateTables adds the strings and threads defined by string and thread records to the tables
+func (r *Reader) updateTables(record *Record) error {
+	header := record.Header()
+
+	switch record.Type {
+	case RecordTypeString:
+		index := uint16((header >> 16) & 0x7FFF)
+		if index == 0 {
+			return fmt.Errorf("string index 0 is reserved for the empty string")
+		}
+		length := int((header >> 32) & 0x7FFF)
+		if len(record.Data) < 8+length {
+			return fmt.Errorf("string length %d exceeds the record size", length)
+		}
+		r.stringTable[index] = string(record.Data[8 : 8+length])
+	case RecordTypeThread:
+		index := uint16((header >> 16) & 0xFF)
+		if index == 0 {
+			return fmt.Errorf("thread index 0 is reserved for inline threads")
+		}
+		r.threadTable[index] = Thread{
+			ProcessId: KernelObjectID(record.Word(1)),
+			ThreadId:  KernelObjectID(record.Word(2)),
+		}
+	}
+
+	return nil
+}
+
+// updateSymbols adds the stacks and symbols in stack and symbols blobs to the symbol table
+func (r *Reader) updateSymbols(record *Record) error {
+	if record.Type != RecordTypeBlob {
+		return nil
+	}
+	blobType := BlobType((record.Header() >> 48) & 0xFF)
+	if blobType != BlobTypeStack && blobType != BlobTypeSymbols {
+		return nil
+	}
+
+	fields, payload, err := record.blob()
+	if err != nil {
+		return err
+	}
+	// Other tools may use the same blob types for payloads of their own
+	if name := resolveString(r, fields.name); name != StackBlobName && name != SymbolsBlobName {
+		return nil
+	}
+	return r.symbols.AddBlob(blobType, payload)
+}
+
+// tables looks up entries of the string and thread tables. It's implemented by Reader, and by the table
+// history used to parse provider sections in parallel
+type tables interface {
+	LookupString(index uint16) (string, bool)
+	LookupThread(index uint16) (Thread, bool)
+}
+
+// hasReferences reports whether records of type `recordType` can refer to the string or thread tables
+func hasReferences(recordType RecordType) bool {
+	switch recordType {
+	case RecordTypeEvent, RecordTypeBlob, RecordTypeUserspaceObject, RecordTypeKernelObject, RecordTypeScheduling, RecordTypeLargeBlob:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkReferences ensures the string and thread references in a record refer to entries that have
+// already been added to the tables
+func (r *Reader) checkReferences(record *Record) error {
+	if !hasReferences(record.Type) {
+		return nil
+	}
+	if record.Type == RecordTypeBlob || record.Type == RecordTypeLargeBlob {
+		return r.checkBlobReferences(record)
+	}
+
+	decoded, err := decodeRecord(record.Type, record.Data)
+	if err != nil {
+		return err
+	}
+	return checkDecodedReferences(r, decoded)
+}
+
+// checkBlobReferences is the same as checkReferences, for a blob or large blob record
+// Decoding the record would copy its payload, so only the fields in front of it are decoded, and the
+// payload is left in the record's Data. This keeps readers of memory mapped traces from copying every blob
+func (r *Reader) checkBlobReferences(record *Record) error {
+	if record.Type == RecordTypeLargeBlob {
+		header := record.Header()
+		if largeRecordType((header>>36)&0xF) != largeRecordTypeBlob {
+			return nil
+		}
+		if format := largeBlobFormat((header >> 40) & 0xF); format != largeBlobFormatMetadata && format != largeBlobFormatNoMetadata {
+			return nil
+		}
+	}
+
+	fields, _, err := record.blob()
+	if err != nil {
+		return err
+	}
+
+	var decoded recordAppender
+	switch {
+	case record.Type == RecordTypeBlob:
+		decoded = &BlobRecord{Name: fields.name}
+	case fields.event != nil:
+		decoded = &LargeBlobEventRecord{EventRecord: *fields.event}
+	default:
+		decoded = &LargeBlobRecord{Category: fields.category, Name: fields.name}
+	}
+	return checkDecodedReferences(r, decoded)
+}
+
+// checkDecodedReferences is the same as checkReferences, for a record that has already been decoded
+func checkDecodedReferences(t tables, decoded recordAppender) error {
+	var strs []StringRef
+	var thread ThreadRef
+	var arguments []Argument
+	switch v := decoded.(type) {
+	case nil:
+		return nil
+	case eventRecorder:
+		event := v.event()
+		strs = append(strs, event.Category, event.Name)
+		thread = event.Thread
+		arguments = event.Arguments
+	case *BlobRecord:
+		strs = append(strs, v.Name)
+	case *UserspaceObjectRecord:
+		strs = append(strs, v.Name)
+		arguments = v.Arguments
+	case *KernelObjectRecord:
+		strs = append(strs, v.Name)
+		arguments = v.Arguments
+	case *ContextSwitchRecord:
+		arguments = v.Arguments
+	case *ThreadWakeupRecord:
+		arguments = v.Arguments
+	case *LargeBlobRecord:
+		strs = append(strs, v.Category, v.Name)
+	}
+
+	for _, argument := range arguments {
+		strs = append(strs, argument.Key)
+		if value, ok := argument.Value.(StringRef); ok {
+			strs = append(strs, value)
+		}
+	}
+
+	for _, str := range strs {
+		if str.Index == 0 {
+			continue
+		}
+		if _, ok := t.LookupString(str.Index); !ok {
+			return fmt.Errorf("reference to undefined string index %d", str.Index)
+		}
+	}
+	if thread.Index != 0 {
+		if _, ok := t.LookupThread(uint16(thread.Index)); !ok {
+			return fmt.Errorf("reference to undefined thread index %d", thread.Index)
+		}
+	}
+
+	return nil
+}
+
+// Version returns the format version of the trace, from its magic number record
+func (r *Reader) Version() FormatVersion {
+	return r.version
+}
+
+// Offset returns the position in the trace of the next record, in bytes
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// AllowTruncatedTail makes the Reader treat a final record that's cut short by the end of the trace as the end
+// of the trace, rather than a *CorruptRecordError. Producers that crash, or are killed, part way through writing
+// a record leave one behind, and this keeps the rest of the capture usable
+//
+// Truncated reports whether it happened, and the offset of the partial record
+func (r *Reader) AllowTruncatedTail() {
+	r.allowTruncatedTail = true
+}
+
+// Truncated reports whether the trace ended part way through a record, when AllowTruncatedTail is set. The
+// returned offset is the position of the partial record, which is where the complete records end
+func (r *Reader) Truncated() (int64, bool) {
+	return r.offset, r.truncated
+}
+
+// LookupString returns the string that `index` refers to in the string table
+func (r *Reader) LookupString(index uint16) (string, bool) {
+	str, ok := r.stringTable[index]
+	return str, ok
+}
+
+// LookupThread returns the process / thread that `index` refers to in the thread table
+func (r *Reader) LookupThread(index uint16) (Thread, bool) {
+	thread, ok := r.threadTable[index]
+	return thread, ok
+}
+
+// Symbols returns the stacks and symbols from the stack and symbols blobs read so far, to symbolize the
+// pointer and stack arguments of events
+func (r *Reader) Symbols() *SymbolTable {
+	return r.symbols
+}
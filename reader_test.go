@@ -0,0 +1,259 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 100))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReader(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+
+	var types []fxt.RecordType
+	var event *fxt.Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		types = append(types, record.Type)
+		if record.Type == fxt.RecordTypeEvent {
+			event = record
+		}
+	}
+	require.Equal(t, []fxt.RecordType{
+		fxt.RecordTypeMetadata,
+		fxt.RecordTypeInitialization,
+		fxt.RecordTypeString,
+		fxt.RecordTypeString,
+		fxt.RecordTypeThread,
+		fxt.RecordTypeEvent,
+	}, types)
+	require.Equal(t, int64(buffer.Len()), reader.Offset())
+
+	// The event's references resolve through the tables
+	require.NotNil(t, event)
+	header := event.Header()
+	category, ok := reader.LookupString(uint16((header >> 32) & 0xFFFF))
+	require.True(t, ok)
+	require.Equal(t, "Category", category)
+	name, ok := reader.LookupString(uint16((header >> 48) & 0xFFFF))
+	require.True(t, ok)
+	require.Equal(t, "Event", name)
+	thread, ok := reader.LookupThread(uint16((header >> 24) & 0xFF))
+	require.True(t, ok)
+	require.Equal(t, fxt.Thread{ProcessId: 3, ThreadId: 4}, thread)
+	require.Equal(t, uint64(100), event.Word(1))
+
+	// A truncated trace
+	reader, err = fxt.NewReader(bytes.NewReader(buffer.Bytes()[:buffer.Len()-4]))
+	require.NoError(t, err)
+	for err == nil {
+		_, err = reader.Next()
+	}
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Not a trace at all
+	_, err = fxt.NewReader(bytes.NewReader([]byte("definitely not a trace")))
+	require.Error(t, err)
+}
+
+func TestReaderSampleTrace(t *testing.T) {
+	file, err := os.Open("test_data/trace.fxt")
+	require.NoError(t, err)
+	defer file.Close()
+
+	reader, err := fxt.NewReader(file)
+	require.NoError(t, err)
+
+	numEvents := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		if record.Type == fxt.RecordTypeEvent {
+			numEvents++
+		}
+	}
+	require.Equal(t, 576424, numEvents)
+}
+
+func TestReaderFromBytes(t *testing.T) {
+	trace, err := os.ReadFile("test_data/trace.fxt")
+	require.NoError(t, err)
+
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+	record, err := reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, int64(8), record.Offset)
+
+	_, err = fxt.NewReaderFromBytes(nil)
+	require.Error(t, err)
+}
+
+// The Reader is used in the browser with GOOS=js GOARCH=wasm, so the files it's built from
+// must not use anything that needs an operating system
+func TestReaderHasNoOSDependencies(t *testing.T) {
+	forbidden := map[string]bool{"os": true, "os/exec": true, "syscall": true, "net": true, "unsafe": true}
+
+	for _, file := range []string{"reader.go", "records.go", "unmarshal.go", "version.go", "constants.go", "arguments.go", "clock.go", "resolve.go", "argument_types.go", "parallel.go", "stats.go", "check.go", "blobs.go"} {
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		require.NoError(t, err)
+
+		for _, spec := range parsed.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			require.NoError(t, err)
+			require.False(t, forbidden[path], "%s imports %s", file, path)
+		}
+	}
+}
+
+func TestReaderVersion(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithFormatVersion(fxt.FormatVersion1))
+	require.NoError(t, err)
+	require.Equal(t, fxt.FormatVersion1, writer.FormatVersion())
+
+	reader, err := fxt.NewReader(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, fxt.FormatVersion1, reader.Version())
+
+	// The magic number, with the reserved bits set, as a future version might
+	magic := append([]byte(nil), buffer.Bytes()[:8]...)
+	magic[7] = 0x01
+	_, err = fxt.NewReader(bytes.NewReader(magic))
+	require.ErrorContains(t, err, "unsupported FXT format version")
+
+	_, err = fxt.NewWriterTo(&buffer, fxt.WithFormatVersion(fxt.FormatVersion(2)))
+	require.Error(t, err)
+}
+
+func TestReaderCorruption(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 3, 4, 100))
+	trace := buffer.Bytes()
+
+	readAll := func(data []byte) error {
+		reader, err := fxt.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := reader.Next(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	require.NoError(t, readAll(trace))
+
+	// Wrong endianness
+	bigEndian := append([]byte(nil), trace...)
+	for i := 0; i < 4; i++ {
+		bigEndian[i], bigEndian[7-i] = bigEndian[7-i], bigEndian[i]
+	}
+	require.ErrorContains(t, readAll(bigEndian), "big-endian")
+
+	var corruptErr *fxt.CorruptRecordError
+
+	// A thread record that's too small
+	tooSmall := append([]byte(nil), trace[:8]...)
+	tooSmall = append(tooSmall, 0x13, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00)
+	err = readAll(tooSmall)
+	require.ErrorAs(t, err, &corruptErr)
+	require.Equal(t, int64(8), corruptErr.Offset)
+	require.Equal(t, fxt.RecordTypeThread, corruptErr.Type)
+
+	// Drop the two string records, which are 2 words each, so the event refers to undefined strings
+	undefined := append([]byte(nil), trace[:8]...)
+	undefined = append(undefined, trace[8+2*8+2*8:]...)
+	err = readAll(undefined)
+	require.ErrorAs(t, err, &corruptErr)
+	require.Equal(t, fxt.RecordTypeEvent, corruptErr.Type)
+	require.Equal(t, int64(8+3*8), corruptErr.Offset)
+	require.ErrorContains(t, err, "undefined string index")
+
+	// Truncated trailing record
+	err = readAll(trace[:len(trace)-8])
+	require.ErrorAs(t, err, &corruptErr)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReaderTruncatedTail(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("Category", "First", 3, 4, 100))
+	complete := int64(buffer.Len())
+	require.NoError(t, writer.AddInstantEvent("Category", "Second", 3, 4, 200))
+	trace := buffer.Bytes()
+
+	// Cut off part way through the second event's record, at the end of a word and in the middle of one
+	for _, cut := range []int{8, 3} {
+		for _, newReader := range []func([]byte) (*fxt.Reader, error){
+			func(data []byte) (*fxt.Reader, error) { return fxt.NewReader(bytes.NewReader(data)) },
+			fxt.NewReaderFromBytes,
+		} {
+			reader, err := newReader(trace[:len(trace)-cut])
+			require.NoError(t, err)
+			reader.AllowTruncatedTail()
+
+			var names []string
+			for {
+				event, err := reader.NextEvent()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				names = append(names, event.Name)
+			}
+			require.Equal(t, []string{"First"}, names)
+
+			offset, truncated := reader.Truncated()
+			require.True(t, truncated)
+			// The second event's name is written in a string record before it
+			require.Greater(t, offset, complete)
+		}
+	}
+
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+	reader.AllowTruncatedTail()
+	for {
+		if _, err := reader.Next(); errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	offset, truncated := reader.Truncated()
+	require.False(t, truncated)
+	require.Equal(t, int64(len(trace)), offset)
+}
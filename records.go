@@ -0,0 +1,1021 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxStringIndex is the largest index that fits in a string reference
+const maxStringIndex = 0x7FFF
+
+// maxStringRefLength is the longest inline string that fits in a string reference
+const maxStringRefLength = 0x7FFF
+
+// maxThreadIndex is the largest index that fits in a thread reference
+const maxThreadIndex = 0xFF
+
+// maxNumArgs is the largest number of arguments a record can have
+const maxNumArgs = 0xF
+
+// SplitArgs splits `args` into groups that each fit in the arguments of one record, for writing
+// more values than one event can hold, like the members of a counter, as several events
+//
+// The keys are sorted, so each key stays in the same group as long as the set of keys doesn't change
+func SplitArgs(args map[string]interface{}) []map[string]interface{} {
+	if len(args) <= maxNumArgs {
+		return []map[string]interface{}{args}
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var chunks []map[string]interface{}
+	for start := 0; start < len(keys); start += maxNumArgs {
+		end := start + maxNumArgs
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		chunk := make(map[string]interface{}, end-start)
+		for _, key := range keys[start:end] {
+			chunk[key] = args[key]
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// StringRef refers to a string from within a record. It's either an index into the string table,
+// or a string stored inline in the record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#string-references
+//
+// If Index is zero, the string is Inline. The zero StringRef is the empty string
+type StringRef struct {
+	Index  uint16
+	Inline string
+}
+
+// field returns the value of the string reference field in the record header
+func (r StringRef) field() (uint64, error) {
+	if r.Index != 0 {
+		if r.Index > maxStringIndex {
+			return 0, fmt.Errorf("invalid string index %d - exceeds the maximum of %d", r.Index, maxStringIndex)
+		}
+		return uint64(r.Index), nil
+	}
+
+	if len(r.Inline) > maxStringRefLength {
+		return 0, fmt.Errorf("inline string is too long - %d bytes exceeds the maximum of %d", len(r.Inline), maxStringRefLength)
+	}
+	if len(r.Inline) == 0 {
+		return 0, nil
+	}
+	return uint64(len(r.Inline)) | inlineStringRefFlag, nil
+}
+
+// inlineSizeInWords returns the number of words the inline string takes up in the record
+func (r StringRef) inlineSizeInWords() int {
+	if r.Index != 0 {
+		return 0
+	}
+	return paddedSizeInWords(len(r.Inline))
+}
+
+func (r StringRef) appendInline(dst []byte) []byte {
+	if r.Index != 0 {
+		return dst
+	}
+	return appendPadded(dst, []byte(r.Inline))
+}
+
+// ThreadRef refers to a thread from within a record. It's either an index into the thread table,
+// or a process / thread ID pair stored inline in the record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#thread-references
+//
+// If Index is zero, the thread is Inline
+type ThreadRef struct {
+	Index  uint8
+	Inline Thread
+}
+
+func (r ThreadRef) inlineSizeInWords() int {
+	if r.Index != 0 {
+		return 0
+	}
+	return 2
+}
+
+func (r ThreadRef) appendInline(dst []byte) []byte {
+	if r.Index != 0 {
+		return dst
+	}
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.Inline.ProcessId))
+	return binary.LittleEndian.AppendUint64(dst, uint64(r.Inline.ThreadId))
+}
+
+// Argument is a key / value pair attached to a record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#arguments
+//
+// Value must be nil, int32, uint32, int64, uint64, float64, StringRef, uintptr (a pointer), KernelObjectID, or bool
+type Argument struct {
+	Key   StringRef
+	Value interface{}
+}
+
+func (a Argument) sizeInWords() (int, error) {
+	if _, err := a.Key.field(); err != nil {
+		return 0, fmt.Errorf("invalid argument key - %w", err)
+	}
+	sizeInWords := 1 + a.Key.inlineSizeInWords()
+
+	switch v := a.Value.(type) {
+	case nil, int32, uint32, bool:
+	case int64, uint64, float64, uintptr, KernelObjectID:
+		sizeInWords++
+	case StringRef:
+		if _, err := v.field(); err != nil {
+			return 0, fmt.Errorf("invalid argument value - %w", err)
+		}
+		sizeInWords += v.inlineSizeInWords()
+	default:
+		return 0, fmt.Errorf("invalid value type %T for argument", a.Value)
+	}
+
+	if sizeInWords > maxRecordSizeInWords {
+		return 0, fmt.Errorf("argument is too large - %d words exceeds the maximum of %d", sizeInWords, maxRecordSizeInWords)
+	}
+	return sizeInWords, nil
+}
+
+// appendArgument appends a single argument data record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#argument-types
+func (a Argument) appendArgument(dst []byte) ([]byte, error) {
+	sizeInWords, err := a.sizeInWords()
+	if err != nil {
+		return nil, err
+	}
+
+	keyField, err := a.Key.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument key - %w", err)
+	}
+	header := (keyField << 16) | (uint64(sizeInWords) << 4)
+
+	var value []byte
+	switch v := a.Value.(type) {
+	case nil:
+		header |= uint64(ArgumentTypeNull)
+	case int32:
+		header |= (uint64(uint32(v)) << 32) | uint64(ArgumentTypeInt32)
+	case uint32:
+		header |= (uint64(v) << 32) | uint64(ArgumentTypeUint32)
+	case int64:
+		header |= uint64(ArgumentTypeInt64)
+		value = binary.LittleEndian.AppendUint64(nil, uint64(v))
+	case uint64:
+		header |= uint64(ArgumentTypeUint64)
+		value = binary.LittleEndian.AppendUint64(nil, v)
+	case float64:
+		header |= uint64(ArgumentTypeDouble)
+		value = binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+	case StringRef:
+		valueField, err := v.field()
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument value - %w", err)
+		}
+		header |= (valueField << 32) | uint64(ArgumentTypeString)
+		value = v.appendInline(nil)
+	case uintptr:
+		header |= uint64(ArgumentTypePointer)
+		value = binary.LittleEndian.AppendUint64(nil, uint64(v))
+	case KernelObjectID:
+		header |= uint64(ArgumentTypeKOID)
+		value = binary.LittleEndian.AppendUint64(nil, uint64(v))
+	case bool:
+		valueBit := uint64(0)
+		if v {
+			valueBit = 1
+		}
+		header |= (valueBit << 32) | uint64(ArgumentTypeBool)
+	}
+
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = a.Key.appendInline(dst)
+	return append(dst, value...), nil
+}
+
+// argumentsSizeInWords adds up the size of all the arguments, and checks there aren't too many of them
+func argumentsSizeInWords(arguments []Argument) (int, error) {
+	if len(arguments) > maxNumArgs {
+		return 0, fmt.Errorf("too many arguments - %d exceeds the maximum of %d", len(arguments), maxNumArgs)
+	}
+
+	total := 0
+	for _, argument := range arguments {
+		sizeInWords, err := argument.sizeInWords()
+		if err != nil {
+			return 0, err
+		}
+		total += sizeInWords
+	}
+
+	return total, nil
+}
+
+func appendArguments(dst []byte, arguments []Argument) ([]byte, error) {
+	for _, argument := range arguments {
+		var err error
+		dst, err = argument.appendArgument(dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// paddedSizeInWords returns the number of words needed to hold `size` bytes
+func paddedSizeInWords(size int) int {
+	return (size + 8 - 1) / 8
+}
+
+// appendPadded appends `data`, followed by zeros up to the next word boundary
+func appendPadded(dst []byte, data []byte) []byte {
+	dst = append(dst, data...)
+	if padding := paddedSizeInWords(len(data))*8 - len(data); padding > 0 {
+		var zeros [8]byte
+		dst = append(dst, zeros[:padding]...)
+	}
+	return dst
+}
+
+// checkRecordSize ensures a record fits in the 12 bit size field of a record header
+func checkRecordSize(sizeInWords int) error {
+	if sizeInWords > maxRecordSizeInWords {
+		return fmt.Errorf("record is too large - %d words exceeds the maximum of %d", sizeInWords, maxRecordSizeInWords)
+	}
+	return nil
+}
+
+// recordAppender is implemented by all the record structs, so the Writer can encode them into a reusable buffer
+type recordAppender interface {
+	appendRecord(dst []byte) ([]byte, error)
+}
+
+// ProviderInfoRecord is a provider info metadata record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-info-metadata
+type ProviderInfoRecord struct {
+	ProviderId uint32
+	Name       string
+}
+
+// MarshalBinary encodes the record
+func (r ProviderInfoRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ProviderInfoRecord) appendRecord(dst []byte) ([]byte, error) {
+	nameLen := len(r.Name)
+	if nameLen > math.MaxUint8 {
+		return nil, fmt.Errorf("provider name is too long")
+	}
+
+	sizeInWords := 1 + paddedSizeInWords(nameLen)
+	header := (uint64(nameLen) << 52) | (uint64(r.ProviderId) << 20) | (uint64(metadataTypeProviderInfo) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeMetadata)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	return appendPadded(dst, []byte(r.Name)), nil
+}
+
+// ProviderSectionRecord is a provider section metadata record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-section-metadata
+type ProviderSectionRecord struct {
+	ProviderId uint32
+}
+
+// MarshalBinary encodes the record
+func (r ProviderSectionRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ProviderSectionRecord) appendRecord(dst []byte) ([]byte, error) {
+	sizeInWords := 1
+	header := (uint64(r.ProviderId) << 20) | (uint64(metadataTypeProviderSection) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeMetadata)
+	return binary.LittleEndian.AppendUint64(dst, header), nil
+}
+
+// ProviderEventRecord is a provider event metadata record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-event-metadata
+type ProviderEventRecord struct {
+	ProviderId uint32
+	EventType  ProviderEventType
+}
+
+// MarshalBinary encodes the record
+func (r ProviderEventRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ProviderEventRecord) appendRecord(dst []byte) ([]byte, error) {
+	if r.EventType < 0 || r.EventType > 0xF {
+		return nil, fmt.Errorf("invalid provider event type %d", r.EventType)
+	}
+
+	sizeInWords := 1
+	header := (uint64(r.EventType) << 52) | (uint64(r.ProviderId) << 20) | (uint64(metadataTypeProviderEvent) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeMetadata)
+	return binary.LittleEndian.AppendUint64(dst, header), nil
+}
+
+// InitializationRecord is an initialization record, which sets the tick rate of the following records
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#initialization-record
+type InitializationRecord struct {
+	TicksPerSecond TickRate
+}
+
+// MarshalBinary encodes the record
+func (r InitializationRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r InitializationRecord) appendRecord(dst []byte) ([]byte, error) {
+	sizeInWords := 2
+	header := (uint64(sizeInWords) << 4) | uint64(RecordTypeInitialization)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	return binary.LittleEndian.AppendUint64(dst, uint64(r.TicksPerSecond)), nil
+}
+
+// StringRecord is a string record, which adds a string to the string table
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#string-record
+type StringRecord struct {
+	Index uint16
+	Value string
+}
+
+// MarshalBinary encodes the record
+func (r StringRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r StringRecord) appendRecord(dst []byte) ([]byte, error) {
+	if r.Index == 0 || r.Index > maxStringIndex {
+		return nil, fmt.Errorf("invalid string index %d - must be between 1 and %d", r.Index, maxStringIndex)
+	}
+	strLen := len(r.Value)
+	if strLen > MaxStringLength {
+		return nil, fmt.Errorf("string is too long - %d bytes exceeds the maximum of %d", strLen, MaxStringLength)
+	}
+
+	sizeInWords := 1 + paddedSizeInWords(strLen)
+	header := (uint64(strLen) << 32) | (uint64(r.Index) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeString)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	return appendPadded(dst, []byte(r.Value)), nil
+}
+
+// ThreadRecord is a thread record, which adds a process / thread ID pair to the thread table
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#thread-record
+type ThreadRecord struct {
+	Index     uint8
+	ProcessId KernelObjectID
+	ThreadId  KernelObjectID
+}
+
+// MarshalBinary encodes the record
+func (r ThreadRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ThreadRecord) appendRecord(dst []byte) ([]byte, error) {
+	if r.Index == 0 {
+		return nil, fmt.Errorf("invalid thread index 0")
+	}
+
+	sizeInWords := 3
+	header := (uint64(r.Index) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeThread)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.ProcessId))
+	return binary.LittleEndian.AppendUint64(dst, uint64(r.ThreadId)), nil
+}
+
+// EventRecord holds the fields shared by all the event records
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#event-record
+type EventRecord struct {
+	Category  StringRef
+	Name      StringRef
+	Thread    ThreadRef
+	Timestamp uint64
+	Arguments []Argument
+}
+
+// eventRecorder is implemented by the record structs that embed an EventRecord
+type eventRecorder interface {
+	event() *EventRecord
+}
+
+func (e *EventRecord) event() *EventRecord {
+	return e
+}
+
+// sizeInWords checks the event can be encoded, with `extraWords` words of event type specific data after the
+// arguments, and returns the size of its record
+func (e *EventRecord) sizeInWords(extraWords int) (int, error) {
+	if _, err := e.Category.field(); err != nil {
+		return 0, fmt.Errorf("invalid category - %w", err)
+	}
+	if _, err := e.Name.field(); err != nil {
+		return 0, fmt.Errorf("invalid name - %w", err)
+	}
+
+	argumentSizeInWords, err := argumentsSizeInWords(e.Arguments)
+	if err != nil {
+		return 0, err
+	}
+
+	sizeInWords := /* Header */ 1 + /* inline refs */ e.Thread.inlineSizeInWords() + e.Category.inlineSizeInWords() + e.Name.inlineSizeInWords() +
+		/* timestamp */ 1 + /* argument data */ argumentSizeInWords + /* extra stuff */ extraWords
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return 0, err
+	}
+	return sizeInWords, nil
+}
+
+// appendEvent appends the common event data, followed by the event type specific `extra` words
+func (e *EventRecord) appendEvent(dst []byte, eventType eventType, extra ...uint64) ([]byte, error) {
+	sizeInWords, err := e.sizeInWords(len(extra))
+	if err != nil {
+		return nil, err
+	}
+	// The references were checked by sizeInWords
+	categoryField, _ := e.Category.field()
+	nameField, _ := e.Name.field()
+
+	numArgs := len(e.Arguments)
+	header := (nameField << 48) | (categoryField << 32) | (uint64(e.Thread.Index) << 24) | (uint64(numArgs) << 20) | (uint64(eventType) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeEvent)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = e.Thread.appendInline(dst)
+	dst = e.Category.appendInline(dst)
+	dst = e.Name.appendInline(dst)
+	dst = binary.LittleEndian.AppendUint64(dst, e.Timestamp)
+
+	dst, err = appendArguments(dst, e.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, word := range extra {
+		dst = binary.LittleEndian.AppendUint64(dst, word)
+	}
+	return dst, nil
+}
+
+// InstantEvent is an instant event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#instant-event
+type InstantEvent struct {
+	EventRecord
+}
+
+// MarshalBinary encodes the record
+func (e InstantEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e InstantEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeInstant)
+}
+
+func (e InstantEvent) checkEvent() error {
+	_, err := e.sizeInWords(0)
+	return err
+}
+
+// CounterEvent is a counter event record. The arguments are the values of the counter
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#counter-event
+type CounterEvent struct {
+	EventRecord
+	CounterId uint64
+}
+
+// MarshalBinary encodes the record
+func (e CounterEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e CounterEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeCounter, e.CounterId)
+}
+
+func (e CounterEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// DurationBeginEvent is a duration begin event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#duration-begin-event
+type DurationBeginEvent struct {
+	EventRecord
+}
+
+// MarshalBinary encodes the record
+func (e DurationBeginEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e DurationBeginEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeDurationBegin)
+}
+
+func (e DurationBeginEvent) checkEvent() error {
+	_, err := e.sizeInWords(0)
+	return err
+}
+
+// DurationEndEvent is a duration end event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#duration-end-event
+type DurationEndEvent struct {
+	EventRecord
+}
+
+// MarshalBinary encodes the record
+func (e DurationEndEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e DurationEndEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeDurationEnd)
+}
+
+func (e DurationEndEvent) checkEvent() error {
+	_, err := e.sizeInWords(0)
+	return err
+}
+
+// DurationCompleteEvent is a duration complete event record. Timestamp is the beginning of the duration
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#duration-complete-event
+type DurationCompleteEvent struct {
+	EventRecord
+	EndTimestamp uint64
+}
+
+// MarshalBinary encodes the record
+func (e DurationCompleteEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e DurationCompleteEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeDurationComplete, e.EndTimestamp)
+}
+
+func (e DurationCompleteEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// AsyncBeginEvent is an async begin event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#async-begin-event
+type AsyncBeginEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e AsyncBeginEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e AsyncBeginEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeAsyncBegin, e.CorrelationId)
+}
+
+func (e AsyncBeginEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// AsyncInstantEvent is an async instant event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#async-instant-event
+type AsyncInstantEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e AsyncInstantEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e AsyncInstantEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeAsyncInstant, e.CorrelationId)
+}
+
+func (e AsyncInstantEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// AsyncEndEvent is an async end event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#async-end-event
+type AsyncEndEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e AsyncEndEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e AsyncEndEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeAsyncEnd, e.CorrelationId)
+}
+
+func (e AsyncEndEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// FlowBeginEvent is a flow begin event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#flow-begin-event
+type FlowBeginEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e FlowBeginEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e FlowBeginEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeFlowBegin, e.CorrelationId)
+}
+
+func (e FlowBeginEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// FlowStepEvent is a flow step event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#flow-step-event
+type FlowStepEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e FlowStepEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e FlowStepEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeFlowStep, e.CorrelationId)
+}
+
+func (e FlowStepEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// FlowEndEvent is a flow end event record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#flow-end-event
+type FlowEndEvent struct {
+	EventRecord
+	CorrelationId uint64
+}
+
+// MarshalBinary encodes the record
+func (e FlowEndEvent) MarshalBinary() ([]byte, error) {
+	return e.appendRecord(nil)
+}
+
+func (e FlowEndEvent) appendRecord(dst []byte) ([]byte, error) {
+	return e.appendEvent(dst, eventTypeFlowEnd, e.CorrelationId)
+}
+
+func (e FlowEndEvent) checkEvent() error {
+	_, err := e.sizeInWords(1)
+	return err
+}
+
+// BlobRecord is a blob record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#blob-record
+type BlobRecord struct {
+	Name StringRef
+	Type BlobType
+	Data []byte
+}
+
+// MarshalBinary encodes the record
+func (r BlobRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r BlobRecord) appendRecord(dst []byte) ([]byte, error) {
+	if !r.Type.IsValid() {
+		return nil, fmt.Errorf("invalid blob type %d - must be between 1 and %d", r.Type, MaxBlobType)
+	}
+	nameField, err := r.Name.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name - %w", err)
+	}
+
+	blobSize := len(r.Data)
+	if blobSize > MaxBlobSize {
+		return nil, fmt.Errorf("blob is too large - %d bytes exceeds the maximum of %d", blobSize, MaxBlobSize)
+	}
+
+	sizeInWords := 1 + r.Name.inlineSizeInWords() + paddedSizeInWords(blobSize)
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return nil, err
+	}
+
+	header := (uint64(r.Type) << 48) | (uint64(blobSize) << 32) | (nameField << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeBlob)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = r.Name.appendInline(dst)
+	return appendPadded(dst, r.Data), nil
+}
+
+// UserspaceObjectRecord is a userspace object record, which describes an object in a process's memory
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#userspace-object-record
+type UserspaceObjectRecord struct {
+	Pointer   uintptr
+	ProcessId KernelObjectID
+	Name      StringRef
+	Arguments []Argument
+}
+
+// MarshalBinary encodes the record
+func (r UserspaceObjectRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r UserspaceObjectRecord) appendRecord(dst []byte) ([]byte, error) {
+	nameField, err := r.Name.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name - %w", err)
+	}
+
+	argumentSizeInWords, err := argumentsSizeInWords(r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeInWords := /* Header */ 1 + /* pointer value */ 1 + /* process ID */ 1 + /* name */ r.Name.inlineSizeInWords() + /* argument data */ argumentSizeInWords
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return nil, err
+	}
+
+	// The process is always written inline
+	threadIndex := 0
+	numArgs := len(r.Arguments)
+	header := (uint64(numArgs) << 40) | (nameField << 24) | (uint64(threadIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeUserspaceObject)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.Pointer))
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.ProcessId))
+	dst = r.Name.appendInline(dst)
+	return appendArguments(dst, r.Arguments)
+}
+
+// KernelObjectRecord is a kernel object record, which describes a process, thread, or other kernel object
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#kernel-object-record
+type KernelObjectRecord struct {
+	Type      KernelObjectType
+	Koid      KernelObjectID
+	Name      StringRef
+	Arguments []Argument
+}
+
+// MarshalBinary encodes the record
+func (r KernelObjectRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r KernelObjectRecord) appendRecord(dst []byte) ([]byte, error) {
+	if r.Type < 0 || r.Type > 0xFF {
+		return nil, fmt.Errorf("invalid kernel object type %d", r.Type)
+	}
+	nameField, err := r.Name.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name - %w", err)
+	}
+
+	argumentSizeInWords, err := argumentsSizeInWords(r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeInWords := /* header */ 1 + /* koid */ 1 + /* name */ r.Name.inlineSizeInWords() + /* argument data */ argumentSizeInWords
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return nil, err
+	}
+
+	numArgs := len(r.Arguments)
+	header := (uint64(numArgs) << 40) | (nameField << 24) | (uint64(r.Type) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeKernelObject)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.Koid))
+	dst = r.Name.appendInline(dst)
+	return appendArguments(dst, r.Arguments)
+}
+
+// ContextSwitchRecord is a context switch scheduling record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#context-switch-record-scheduling-event-record-type-1
+type ContextSwitchRecord struct {
+	CPU                 uint16
+	OutgoingThreadState uint8
+	OutgoingThreadId    KernelObjectID
+	IncomingThreadId    KernelObjectID
+	Timestamp           uint64
+	Arguments           []Argument
+}
+
+// MarshalBinary encodes the record
+func (r ContextSwitchRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ContextSwitchRecord) appendRecord(dst []byte) ([]byte, error) {
+	// Sanity check
+	// Ideally we'd find out the actual ENUM of valid states
+	if r.OutgoingThreadState > 0xF {
+		return nil, fmt.Errorf("invalid outgoingThreadState - %d is too large", r.OutgoingThreadState)
+	}
+
+	argumentSizeInWords, err := argumentsSizeInWords(r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeInWords := /* Header */ 1 + /* timestamp */ 1 + /* outgoing thread ID */ 1 + /* incoming thread ID */ 1 + /* argument data */ argumentSizeInWords
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return nil, err
+	}
+
+	numArgs := len(r.Arguments)
+	header := (uint64(schedulingRecordTypeContextSwitch) << 60) | (uint64(r.OutgoingThreadState) << 36) | (uint64(r.CPU) << 20) | (uint64(numArgs) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeScheduling)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = binary.LittleEndian.AppendUint64(dst, r.Timestamp)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.OutgoingThreadId))
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.IncomingThreadId))
+	return appendArguments(dst, r.Arguments)
+}
+
+// ThreadWakeupRecord is a thread wakeup scheduling record
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#thread-wakeup-record-scheduling-event-record-type-2
+type ThreadWakeupRecord struct {
+	CPU            uint16
+	WakingThreadId KernelObjectID
+	Timestamp      uint64
+	Arguments      []Argument
+}
+
+// MarshalBinary encodes the record
+func (r ThreadWakeupRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r ThreadWakeupRecord) appendRecord(dst []byte) ([]byte, error) {
+	argumentSizeInWords, err := argumentsSizeInWords(r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeInWords := /* Header */ 1 + /* timestamp */ 1 + /* waking thread ID */ 1 + /* argument data */ argumentSizeInWords
+	if err := checkRecordSize(sizeInWords); err != nil {
+		return nil, err
+	}
+
+	numArgs := len(r.Arguments)
+	header := (uint64(schedulingRecordTypeThreadWakeup) << 60) | (uint64(r.CPU) << 20) | (uint64(numArgs) << 16) | (uint64(sizeInWords) << 4) | uint64(RecordTypeScheduling)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+	dst = binary.LittleEndian.AppendUint64(dst, r.Timestamp)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(r.WakingThreadId))
+	return appendArguments(dst, r.Arguments)
+}
+
+// LargeBlobRecord is a large blob record without metadata
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#in-band-large-blob-record-no-metadata-blob-format-1
+type LargeBlobRecord struct {
+	Category StringRef
+	Name     StringRef
+	Data     []byte
+}
+
+// MarshalBinary encodes the record
+func (r LargeBlobRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r LargeBlobRecord) appendRecord(dst []byte) ([]byte, error) {
+	categoryField, err := r.Category.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid category - %w", err)
+	}
+	nameField, err := r.Name.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name - %w", err)
+	}
+
+	blobSize := len(r.Data)
+	sizeInWords := /* header */ 1 + /* format header */ 1 + /* inline refs */ r.Category.inlineSizeInWords() + r.Name.inlineSizeInWords() +
+		/* blob size */ 1 + /* payload */ paddedSizeInWords(blobSize)
+	if uint64(sizeInWords) > math.MaxUint32 {
+		return nil, fmt.Errorf("large blob is too large - %d bytes", blobSize)
+	}
+
+	header := (uint64(largeBlobFormatNoMetadata) << 40) | (uint64(largeRecordTypeBlob) << 36) | (uint64(sizeInWords) << 4) | uint64(RecordTypeLargeBlob)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+
+	formatHeader := (nameField << 16) | categoryField
+	dst = binary.LittleEndian.AppendUint64(dst, formatHeader)
+	dst = r.Category.appendInline(dst)
+	dst = r.Name.appendInline(dst)
+
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(blobSize))
+	return appendPadded(dst, r.Data), nil
+}
+
+// LargeBlobEventRecord is a large blob record with metadata, which attaches the blob to a thread and point in time
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#in-band-large-blob-record-with-metadata-blob-format-0
+type LargeBlobEventRecord struct {
+	EventRecord
+	Data []byte
+}
+
+// MarshalBinary encodes the record
+func (r LargeBlobEventRecord) MarshalBinary() ([]byte, error) {
+	return r.appendRecord(nil)
+}
+
+func (r LargeBlobEventRecord) appendRecord(dst []byte) ([]byte, error) {
+	categoryField, err := r.Category.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid category - %w", err)
+	}
+	nameField, err := r.Name.field()
+	if err != nil {
+		return nil, fmt.Errorf("invalid name - %w", err)
+	}
+
+	argumentSizeInWords, err := argumentsSizeInWords(r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	blobSize := len(r.Data)
+	sizeInWords := /* header */ 1 + /* format header */ 1 + /* inline refs */ r.Category.inlineSizeInWords() + r.Name.inlineSizeInWords() +
+		/* timestamp */ 1 + /* thread */ r.Thread.inlineSizeInWords() + /* argument data */ argumentSizeInWords +
+		/* blob size */ 1 + /* payload */ paddedSizeInWords(blobSize)
+	if uint64(sizeInWords) > math.MaxUint32 {
+		return nil, fmt.Errorf("large blob is too large - %d bytes", blobSize)
+	}
+
+	header := (uint64(largeBlobFormatMetadata) << 40) | (uint64(largeRecordTypeBlob) << 36) | (uint64(sizeInWords) << 4) | uint64(RecordTypeLargeBlob)
+	dst = binary.LittleEndian.AppendUint64(dst, header)
+
+	numArgs := len(r.Arguments)
+	formatHeader := (uint64(r.Thread.Index) << 36) | (uint64(numArgs) << 32) | (nameField << 16) | categoryField
+	dst = binary.LittleEndian.AppendUint64(dst, formatHeader)
+	dst = r.Category.appendInline(dst)
+	dst = r.Name.appendInline(dst)
+	dst = binary.LittleEndian.AppendUint64(dst, r.Timestamp)
+	dst = r.Thread.appendInline(dst)
+
+	dst, err = appendArguments(dst, r.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(blobSize))
+	return appendPadded(dst, r.Data), nil
+}
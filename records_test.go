@@ -0,0 +1,171 @@
+package fxt_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordsMatchWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	err = writer.AddInstantEventWithArgs("category", "name", 1, 2, 100, map[string]interface{}{"count": int32(5)})
+	require.NoError(t, err)
+
+	var expected []byte
+	for _, record := range []interface{ MarshalBinary() ([]byte, error) }{
+		fxt.StringRecord{Index: 1, Value: "category"},
+		fxt.StringRecord{Index: 2, Value: "name"},
+		fxt.ThreadRecord{Index: 1, ProcessId: 1, ThreadId: 2},
+		fxt.StringRecord{Index: 3, Value: "count"},
+		fxt.InstantEvent{EventRecord: fxt.EventRecord{
+			Category:  fxt.StringRef{Index: 1},
+			Name:      fxt.StringRef{Index: 2},
+			Thread:    fxt.ThreadRef{Index: 1},
+			Timestamp: 100,
+			Arguments: []fxt.Argument{{Key: fxt.StringRef{Index: 3}, Value: int32(5)}},
+		}},
+	} {
+		data, err := record.MarshalBinary()
+		require.NoError(t, err)
+		expected = append(expected, data...)
+	}
+
+	// Skip the magic number record
+	require.Equal(t, expected, buffer.Bytes()[8:])
+}
+
+func TestRecordInlineRefs(t *testing.T) {
+	event := fxt.InstantEvent{EventRecord: fxt.EventRecord{
+		Category:  fxt.StringRef{Inline: "cat"},
+		Name:      fxt.StringRef{Inline: "a longer name"},
+		Thread:    fxt.ThreadRef{Inline: fxt.Thread{ProcessId: 1, ThreadId: 2}},
+		Timestamp: 100,
+	}}
+
+	data, err := event.MarshalBinary()
+	require.NoError(t, err)
+	// Header, thread (2 words), category, name (2 words), and timestamp
+	require.Len(t, data, 7*8)
+
+	reader, err := fxt.NewReader(bytes.NewReader(append([]byte{0x10, 0x00, 0x04, 0x46, 0x78, 0x54, 0x16, 0x00}, data...)))
+	require.NoError(t, err)
+	record, err := reader.Next()
+	require.NoError(t, err)
+	require.Equal(t, fxt.RecordTypeEvent, record.Type)
+	require.Equal(t, uint64(100), record.Word(6))
+}
+
+func TestRecordErrors(t *testing.T) {
+	_, err := fxt.StringRecord{Index: 0, Value: "test"}.MarshalBinary()
+	require.Error(t, err)
+
+	_, err = fxt.BlobRecord{Name: fxt.StringRef{Index: 1}, Type: 0}.MarshalBinary()
+	require.Error(t, err)
+
+	arguments := make([]fxt.Argument, 16)
+	_, err = fxt.CounterEvent{EventRecord: fxt.EventRecord{Arguments: arguments}}.MarshalBinary()
+	require.Error(t, err)
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	arguments := []fxt.Argument{
+		{Key: fxt.StringRef{Index: 1}, Value: nil},
+		{Key: fxt.StringRef{Inline: "int32"}, Value: int32(-5)},
+		{Key: fxt.StringRef{Index: 2}, Value: uint32(5)},
+		{Key: fxt.StringRef{Index: 3}, Value: int64(-1 << 40)},
+		{Key: fxt.StringRef{Index: 4}, Value: uint64(1 << 40)},
+		{Key: fxt.StringRef{Index: 5}, Value: 3.5},
+		{Key: fxt.StringRef{Index: 6}, Value: fxt.StringRef{Inline: "inline value"}},
+		{Key: fxt.StringRef{Index: 7}, Value: uintptr(0xDEADBEEF)},
+		{Key: fxt.StringRef{Index: 8}, Value: fxt.KernelObjectID(42)},
+		{Key: fxt.StringRef{Index: 9}, Value: true},
+	}
+	event := fxt.EventRecord{
+		Category:  fxt.StringRef{Index: 10},
+		Name:      fxt.StringRef{Inline: "name"},
+		Thread:    fxt.ThreadRef{Inline: fxt.Thread{ProcessId: 1, ThreadId: 2}},
+		Timestamp: 100,
+		Arguments: arguments,
+	}
+
+	type record interface {
+		MarshalBinary() ([]byte, error)
+		UnmarshalBinary(data []byte) error
+	}
+	records := []record{
+		&fxt.ProviderInfoRecord{ProviderId: 1234, Name: "Test Provider"},
+		&fxt.ProviderSectionRecord{ProviderId: 1234},
+		&fxt.ProviderEventRecord{ProviderId: 1234, EventType: fxt.ProviderEventTypeBufferFilledUp},
+		&fxt.InitializationRecord{TicksPerSecond: fxt.TicksNanoseconds},
+		&fxt.StringRecord{Index: 1, Value: "test"},
+		&fxt.ThreadRecord{Index: 1, ProcessId: 3, ThreadId: 45},
+		&fxt.InstantEvent{EventRecord: event},
+		&fxt.CounterEvent{EventRecord: event, CounterId: 7},
+		&fxt.DurationBeginEvent{EventRecord: event},
+		&fxt.DurationEndEvent{EventRecord: event},
+		&fxt.DurationCompleteEvent{EventRecord: event, EndTimestamp: 200},
+		&fxt.AsyncBeginEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.AsyncInstantEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.AsyncEndEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.FlowBeginEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.FlowStepEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.FlowEndEvent{EventRecord: event, CorrelationId: 7},
+		&fxt.BlobRecord{Name: fxt.StringRef{Index: 1}, Type: fxt.BlobTypeData, Data: []byte("some data")},
+		&fxt.UserspaceObjectRecord{Pointer: 0x1000, ProcessId: 3, Name: fxt.StringRef{Index: 1}, Arguments: arguments},
+		&fxt.KernelObjectRecord{Type: fxt.KernelObjectTypeThread, Koid: 45, Name: fxt.StringRef{Inline: "thread"}, Arguments: arguments},
+		&fxt.ContextSwitchRecord{CPU: 3, OutgoingThreadState: 2, OutgoingThreadId: 45, IncomingThreadId: 46, Timestamp: 100, Arguments: arguments},
+		&fxt.ThreadWakeupRecord{CPU: 3, WakingThreadId: 45, Timestamp: 100, Arguments: arguments},
+		&fxt.LargeBlobRecord{Category: fxt.StringRef{Index: 1}, Name: fxt.StringRef{Inline: "blob"}, Data: []byte("large blob data")},
+		&fxt.LargeBlobEventRecord{EventRecord: event, Data: []byte("large blob data")},
+	}
+
+	for _, expected := range records {
+		data, err := expected.MarshalBinary()
+		require.NoError(t, err)
+
+		actual := reflect.New(reflect.TypeOf(expected).Elem()).Interface().(record)
+		require.NoError(t, actual.UnmarshalBinary(data), "%T", expected)
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestRecordUnmarshalWrongType(t *testing.T) {
+	data, err := fxt.InstantEvent{}.MarshalBinary()
+	require.NoError(t, err)
+
+	var counter fxt.CounterEvent
+	require.Error(t, counter.UnmarshalBinary(data))
+
+	var str fxt.StringRecord
+	require.Error(t, str.UnmarshalBinary(data))
+
+	var instant fxt.InstantEvent
+	require.Error(t, instant.UnmarshalBinary(data[:8]))
+}
+
+func TestSplitArgs(t *testing.T) {
+	small := map[string]interface{}{"a": int64(1), "b": int64(2)}
+	require.Equal(t, []map[string]interface{}{small}, fxt.SplitArgs(small))
+
+	args := map[string]interface{}{}
+	for i := 0; i < 40; i++ {
+		args[fmt.Sprintf("key%02d", i)] = int64(i)
+	}
+
+	chunks := fxt.SplitArgs(args)
+	require.Len(t, chunks, 3)
+	require.Len(t, chunks[0], 15)
+	require.Len(t, chunks[1], 15)
+	require.Len(t, chunks[2], 10)
+	require.Equal(t, int64(0), chunks[0]["key00"])
+	require.Equal(t, int64(15), chunks[1]["key15"])
+	require.Equal(t, int64(39), chunks[2]["key39"])
+}
@@ -0,0 +1,196 @@
+package fxt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultRedactedKeys are the argument keys redacted by NewRedactor when no explicit list is given
+var DefaultRedactedKeys = []string{
+	"password",
+	"token",
+	"authorization",
+}
+
+// RedactedValue replaces the values redacted with RedactModeReplace
+const RedactedValue = "[REDACTED]"
+
+// RedactMode is how a Redactor hides the values it redacts
+type RedactMode int
+
+const (
+	// RedactModeReplace replaces the values with RedactedValue
+	RedactModeReplace RedactMode = iota
+	// RedactModeHash replaces the values with "sha256:" and the start of their hash, so equal values can still be
+	// matched up across events, without the values being in the trace
+	RedactModeHash
+)
+
+// RedactOption configures a Redactor
+type RedactOption func(*Redactor)
+
+// WithRedactedKeys sets the argument keys whose values are redacted, replacing DefaultRedactedKeys
+//
+// Keys are split into words at '-', '_', and '.', and where camel case changes to upper case. A string value is
+// redacted if its key contains the words of one of `patterns`, ignoring case, so "token" matches "X-Auth-Token" and
+// "accessToken", but not "tokenizer". Other values, like numbers, are only redacted if their whole key is one of
+// `patterns`, so a "token_count" counter is kept
+func WithRedactedKeys(patterns ...string) RedactOption {
+	return func(r *Redactor) {
+		r.keys = make([][]string, 0, len(patterns))
+		for _, pattern := range patterns {
+			r.keys = append(r.keys, keyWords(pattern))
+		}
+	}
+}
+
+// WithRedactedValues redacts the parts of string argument values that match any of `patterns`, whatever their
+// key, like card numbers or bearer tokens in a log message. The rest of the value is kept
+func WithRedactedValues(patterns ...*regexp.Regexp) RedactOption {
+	return func(r *Redactor) {
+		r.values = append(r.values, patterns...)
+	}
+}
+
+// WithRedactMode sets how the redacted values are hidden. It defaults to RedactModeReplace
+func WithRedactMode(mode RedactMode) RedactOption {
+	return func(r *Redactor) {
+		r.mode = mode
+	}
+}
+
+// WithRedactHashKey makes RedactModeHash use an HMAC with `key`, rather than a plain SHA-256. Without a key,
+// short values like passwords can be found from their hash by trying every likely value
+func WithRedactHashKey(key []byte) RedactOption {
+	return func(r *Redactor) {
+		r.hashKey = key
+	}
+}
+
+// Redactor hides sensitive argument values, like passwords and access tokens, before they're written to the
+// trace. Its Intercept method is an Interceptor, added to a Writer with AddInterceptor
+//
+// Whole values are redacted for the keys set by WithRedactedKeys. The redacted values are always strings, so a
+// redacted counter argument is no longer plotted. Only the matching parts of string values
+// are redacted by WithRedactedValues. Event names and categories aren't redacted
+type Redactor struct {
+	// keys are the words of each pattern given to WithRedactedKeys
+	keys    [][]string
+	values  []*regexp.Regexp
+	mode    RedactMode
+	hashKey []byte
+}
+
+// NewRedactor creates a Redactor. By default, it replaces the values of the keys in DefaultRedactedKeys
+//
+//	writer.AddInterceptor(fxt.NewRedactor(fxt.WithRedactMode(fxt.RedactModeHash)).Intercept)
+func NewRedactor(options ...RedactOption) *Redactor {
+	r := &Redactor{}
+	WithRedactedKeys(DefaultRedactedKeys...)(r)
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Intercept redacts the arguments of `event`. It never drops the event
+func (r *Redactor) Intercept(event *Event) bool {
+	for key, value := range event.Arguments {
+		str, ok := value.(string)
+		if r.redactsKey(key, !ok) {
+			event.Arguments[key] = r.redact(fmt.Sprint(value))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, pattern := range r.values {
+			str = pattern.ReplaceAllStringFunc(str, r.redact)
+		}
+		event.Arguments[key] = str
+	}
+	return true
+}
+
+// redactsKey reports whether the values of `key` are redacted. If `exact` is set, the whole key has to match
+func (r *Redactor) redactsKey(key string, exact bool) bool {
+	words := keyWords(key)
+	for _, pattern := range r.keys {
+		if exact {
+			if len(words) == len(pattern) && containsWords(words, pattern) {
+				return true
+			}
+		} else if containsWords(words, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWords splits a key into lower case words, at '-', '_', and '.', and where camel case changes to upper case
+func keyWords(key string) []string {
+	var words []string
+	start := 0
+	var previous rune
+	for i, c := range key {
+		switch {
+		case c == '-' || c == '_' || c == '.':
+			if i > start {
+				words = append(words, strings.ToLower(key[start:i]))
+			}
+			start = i + 1
+		case unicode.IsUpper(c) && unicode.IsLower(previous) && i > start:
+			words = append(words, strings.ToLower(key[start:i]))
+			start = i
+		}
+		previous = c
+	}
+	if start < len(key) {
+		words = append(words, strings.ToLower(key[start:]))
+	}
+	return words
+}
+
+// containsWords reports whether `pattern` is a run of consecutive words in `words`
+func containsWords(words []string, pattern []string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for i := 0; i+len(pattern) <= len(words); i++ {
+		match := true
+		for j, word := range pattern {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns what `value` is replaced with
+func (r *Redactor) redact(value string) string {
+	if r.mode != RedactModeHash {
+		return RedactedValue
+	}
+
+	var sum []byte
+	if r.hashKey != nil {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		hash := sha256.Sum256([]byte(value))
+		sum = hash[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,73 @@
+package fxt_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	writer.AddInterceptor(fxt.NewRedactor(fxt.WithRedactedValues(regexp.MustCompile(`Bearer \S+`))).Intercept)
+
+	require.NoError(t, writer.AddInstantEventWithArgs("category", "login", 1, 2, 100, map[string]interface{}{
+		"user":          "alice",
+		"Password":      "hunter2",
+		"refresh_token": "xyz789",
+		"accessToken":   "uvw456",
+		"token":         int64(1234),
+		"token_count":   int64(3),
+		"tokenizer":     "bpe",
+		"request":       "GET / Authorization: Bearer abc.def",
+	}))
+	require.NoError(t, writer.Close())
+	require.False(t, bytes.Contains(buffer.Bytes(), []byte("hunter2")))
+	require.False(t, bytes.Contains(buffer.Bytes(), []byte("abc.def")))
+	require.False(t, bytes.Contains(buffer.Bytes(), []byte("xyz789")))
+
+	events := readEvents(t, buffer.Bytes())
+	require.Len(t, events, 1)
+	require.ElementsMatch(t, []fxt.ResolvedArgument{
+		{Key: "user", Value: "alice"},
+		{Key: "Password", Value: fxt.RedactedValue},
+		{Key: "refresh_token", Value: fxt.RedactedValue},
+		{Key: "accessToken", Value: fxt.RedactedValue},
+		// Other values are only redacted if their whole key matches
+		{Key: "token", Value: fxt.RedactedValue},
+		{Key: "token_count", Value: int64(3)},
+		{Key: "tokenizer", Value: "bpe"},
+		{Key: "request", Value: "GET / Authorization: " + fxt.RedactedValue},
+	}, events[0].Arguments)
+}
+
+func TestRedactorHash(t *testing.T) {
+	redactor := fxt.NewRedactor(fxt.WithRedactedKeys("secret"), fxt.WithRedactMode(fxt.RedactModeHash))
+	hash := func(arguments map[string]interface{}) map[string]interface{} {
+		event := &fxt.Event{Arguments: arguments}
+		require.True(t, redactor.Intercept(event))
+		return event.Arguments
+	}
+
+	first := hash(map[string]interface{}{"client_secret": "value", "password": "kept"})
+	second := hash(map[string]interface{}{"SECRET": "value"})
+	other := hash(map[string]interface{}{"secret": "other"})
+
+	// Only the configured keys are redacted, and equal values hash the same
+	require.Equal(t, "kept", first["password"])
+	require.True(t, strings.HasPrefix(first["client_secret"].(string), "sha256:"))
+	require.Equal(t, first["client_secret"], second["SECRET"])
+	require.NotEqual(t, first["client_secret"], other["secret"])
+
+	// A key changes the hashes
+	keyed := fxt.NewRedactor(fxt.WithRedactedKeys("secret"), fxt.WithRedactMode(fxt.RedactModeHash), fxt.WithRedactHashKey([]byte("key")))
+	event := &fxt.Event{Arguments: map[string]interface{}{"secret": "value"}}
+	keyed.Intercept(event)
+	require.NotEqual(t, first["client_secret"], event.Arguments["secret"])
+}
@@ -0,0 +1,50 @@
+package fxt_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReset(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	var first bytes.Buffer
+	writer, err := fxt.NewWriterTo(&first)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddProviderInfoRecord(1, "Provider"))
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksNanoseconds))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+
+	// The second trace gets the same header records, and has to write the strings and threads again
+	var second bytes.Buffer
+	require.NoError(t, writer.ResetTo(&second))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+	require.Equal(t, first.Bytes(), second.Bytes())
+
+	// Reset to a file, after closing the Writer
+	require.NoError(t, writer.Close())
+	filePath := filepath.Join(tempDir, "test.fxt")
+	require.NoError(t, writer.Reset(filePath))
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 100))
+
+	// Resetting closes the previous file
+	require.NoError(t, writer.Reset(filepath.Join(tempDir, "test2.fxt")))
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, first.Bytes(), data)
+}
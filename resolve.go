@@ -0,0 +1,129 @@
+package fxt
+
+import (
+	"encoding"
+)
+
+// ResolvedEvent is an event record with its string and thread references looked up in the Reader's tables
+type ResolvedEvent struct {
+	// Decoded is the decoded record, like *InstantEvent or *DurationCompleteEvent, with the raw references
+	Decoded encoding.BinaryMarshaler
+	// Event is the EventRecord embedded in Decoded, for the raw string and thread indices
+	Event *EventRecord
+
+	Category  string
+	Name      string
+	Thread    Thread
+	Timestamp uint64
+	Arguments []ResolvedArgument
+}
+
+// ResolvedArgument is an argument with its key, and string value if it has one, looked up in the string table
+type ResolvedArgument struct {
+	Key string
+	// Value is one of the types of Argument.Value, except that string references are resolved to a string
+	Value interface{}
+}
+
+// ResolveString returns the string `ref` refers to, looking it up in the string table if needed
+// References to strings that haven't been defined resolve to the empty string
+func (r *Reader) ResolveString(ref StringRef) string {
+	return resolveString(r, ref)
+}
+
+func resolveString(t tables, ref StringRef) string {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	str, _ := t.LookupString(ref.Index)
+	return str
+}
+
+// ResolveThread returns the process / thread `ref` refers to, looking it up in the thread table if needed
+// References to threads that haven't been defined resolve to the zero Thread
+func (r *Reader) ResolveThread(ref ThreadRef) Thread {
+	return resolveThread(r, ref)
+}
+
+func resolveThread(t tables, ref ThreadRef) Thread {
+	if ref.Index == 0 {
+		return ref.Inline
+	}
+	thread, _ := t.LookupThread(uint16(ref.Index))
+	return thread
+}
+
+// ResolveArguments returns `arguments` with their keys and string values looked up in the string table
+func (r *Reader) ResolveArguments(arguments []Argument) []ResolvedArgument {
+	return resolveArguments(r, arguments)
+}
+
+func resolveArguments(t tables, arguments []Argument) []ResolvedArgument {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	resolved := make([]ResolvedArgument, len(arguments))
+	for i, argument := range arguments {
+		resolved[i].Key = resolveString(t, argument.Key)
+		resolved[i].Value = argument.Value
+		if str, ok := argument.Value.(StringRef); ok {
+			resolved[i].Value = resolveString(t, str)
+		}
+	}
+	return resolved
+}
+
+// Resolve decodes an event record, and looks up its references in the Reader's tables
+// It returns nil for records that aren't events
+//
+// The tables change as the trace is read, so this must be called before the next call to Next
+func (r *Reader) Resolve(record *Record) (*ResolvedEvent, error) {
+	if record.Type != RecordTypeEvent && record.Type != RecordTypeLargeBlob {
+		return nil, nil
+	}
+
+	decoded, err := decodeRecord(record.Type, record.Data)
+	if err != nil || decoded == nil {
+		return nil, err
+	}
+	return resolveEvent(r, decoded), nil
+}
+
+// resolveEvent looks up the references of a decoded event record in `t`. It returns nil for other records
+func resolveEvent(t tables, decoded recordAppender) *ResolvedEvent {
+	recorder, ok := decoded.(eventRecorder)
+	if !ok {
+		return nil
+	}
+	event := recorder.event()
+
+	return &ResolvedEvent{
+		Decoded:   decoded.(encoding.BinaryMarshaler),
+		Event:     event,
+		Category:  resolveString(t, event.Category),
+		Name:      resolveString(t, event.Name),
+		Thread:    resolveThread(t, event.Thread),
+		Timestamp: event.Timestamp,
+		Arguments: resolveArguments(t, event.Arguments),
+	}
+}
+
+// NextEvent reads records until the next event, and returns it resolved. It returns io.EOF when there are
+// no more events
+func (r *Reader) NextEvent() (*ResolvedEvent, error) {
+	for {
+		record, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := r.Resolve(record)
+		if err != nil {
+			return nil, &CorruptRecordError{Offset: record.Offset, Type: record.Type, Err: err}
+		}
+		if event != nil {
+			return event, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderNextEvent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.SetProcessName(1, "process"))
+	require.NoError(t, writer.AddInstantEventWithArgs("category", "instant", 1, 2, 100, map[string]interface{}{"count": int32(3), "label": "value"}))
+	require.NoError(t, writer.AddDurationCompleteEvent("category", "complete", 3, 4, 200, 300))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+
+	// The kernel object record is skipped
+	event, err := reader.NextEvent()
+	require.NoError(t, err)
+	require.IsType(t, &fxt.InstantEvent{}, event.Decoded)
+	require.Equal(t, "category", event.Category)
+	require.Equal(t, "instant", event.Name)
+	require.Equal(t, fxt.Thread{ProcessId: 1, ThreadId: 2}, event.Thread)
+	require.Equal(t, uint64(100), event.Timestamp)
+	require.Equal(t, []fxt.ResolvedArgument{{Key: "count", Value: int32(3)}, {Key: "label", Value: "value"}}, event.Arguments)
+
+	// The raw references are still available
+	require.NotZero(t, event.Event.Name.Index)
+	require.Equal(t, "instant", reader.ResolveString(event.Event.Name))
+
+	event, err = reader.NextEvent()
+	require.NoError(t, err)
+	require.Equal(t, uint64(300), event.Decoded.(*fxt.DurationCompleteEvent).EndTimestamp)
+	require.Equal(t, fxt.Thread{ProcessId: 3, ThreadId: 4}, event.Thread)
+	require.Nil(t, event.Arguments)
+
+	_, err = reader.NextEvent()
+	require.True(t, errors.Is(err, io.EOF))
+}
+
+func TestReaderResolveInline(t *testing.T) {
+	magic, err := fxt.MagicNumberRecord(fxt.LatestFormatVersion)
+	require.NoError(t, err)
+	reader, err := fxt.NewReaderFromBytes(magic)
+	require.NoError(t, err)
+
+	require.Equal(t, "inline", reader.ResolveString(fxt.StringRef{Inline: "inline"}))
+	require.Equal(t, fxt.Thread{ProcessId: 5, ThreadId: 6}, reader.ResolveThread(fxt.ThreadRef{Inline: fxt.Thread{ProcessId: 5, ThreadId: 6}}))
+	require.Equal(t, "", reader.ResolveString(fxt.StringRef{Index: 7}))
+}
@@ -0,0 +1,353 @@
+package fxt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	// BlobTypeContinuation is the position of a file in a capture split by WithSizeRotation, in a blob record
+	// named ContinuationBlobName
+	BlobTypeContinuation BlobType = 0xF3
+	// ContinuationBlobName is the name of the blob records holding a Continuation
+	ContinuationBlobName = "fxt.continuation"
+)
+
+// Continuation is the JSON payload of the blob record, with an inline name, that starts each file of a capture split by
+// WithSizeRotation. It links the files, so they can be put back together into one trace with OpenCapture
+type Continuation struct {
+	// CaptureId is the same in all the files of a capture, and different for every capture
+	CaptureId string `json:"capture_id"`
+	// Sequence is the position of the file in the capture, starting from zero
+	Sequence uint64 `json:"sequence"`
+	// Previous is the base name of the file before this one, if it's known
+	Previous string `json:"previous,omitempty"`
+}
+
+// WithSizeRotation makes the Writer continue the trace in a new file, at the path returned by `nextPath`,
+// whenever the current file reaches `maxBytes`. `nextPath` is given the sequence number of the new file, which
+// is 1 for the file after the one the Writer was created with
+//
+// Unlike Reset, the files are parts of the same trace. Each one starts with a blob record of BlobTypeContinuation
+// holding its Continuation. OpenCapture reads the files back as one trace. Reset starts a new capture, with a
+// new ID
+//
+// Each file is also a valid trace on its own, so it can be loaded by itself in a viewer, and the files can be
+// concatenated, like with `cat`. After the continuation, a file repeats the provider info and initialization
+// records written so far, and snapshots the string and thread tables, like EmitTableSnapshot, so the records in
+// it can be resolved without the files before it. If the Writer is in a provider section, it's started again.
+// With WithChecksums, each file ends with its own footer
+//
+// The file is switched at the first record boundary after it reaches `maxBytes`, so files are a little larger.
+// With WithPipeline, records still being encoded can make them larger still. The new files are created like the
+// first, so the Writers of NewMmapWriter and NewEncryptedWriter keep mapping and encrypting them
+func WithSizeRotation(maxBytes int64, nextPath func(sequence uint64) string) WriterOption {
+	return func(w *Writer) {
+		w.rotateBytes = maxBytes
+		w.rotatePath = nextPath
+	}
+}
+
+// chunkSizeWriter counts the bytes written to the current file, for WithSizeRotation. The pipeline writes to it
+// while the Writer's lock is held by other goroutines, so the count is atomic
+type chunkSizeWriter struct {
+	out   io.Writer
+	bytes atomic.Int64
+}
+
+func (c *chunkSizeWriter) Write(data []byte) (int, error) {
+	n, err := c.out.Write(data)
+	c.bytes.Add(int64(n))
+	return n, err
+}
+
+// chunkedOutput wraps the destination in a chunkSizeWriter, if the Writer rotates by size
+func (w *Writer) chunkedOutput() {
+	w.chunkSize = nil
+	if w.rotatePath != nil {
+		w.chunkSize = &chunkSizeWriter{out: w.out}
+		w.out = w.chunkSize
+	}
+}
+
+// newCapture starts a new capture, if the Writer rotates by size
+func (w *Writer) newCapture() {
+	if w.rotatePath == nil {
+		return
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		w.warn(fmt.Errorf("failed to generate a capture ID - %w", err))
+	}
+	w.continuation = Continuation{CaptureId: hex.EncodeToString(id[:])}
+	w.chunkPath = ""
+}
+
+// addContinuation writes the continuation record of the current file, if the Writer rotates by size. The Writer's
+// lock must be held
+func (w *Writer) addContinuation() error {
+	if w.rotatePath == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(w.continuation)
+	if err != nil {
+		return fmt.Errorf("failed to encode the continuation - %w", err)
+	}
+	// The name is inline, so the record can be read before the table snapshot
+	return w.writeRecord(BlobRecord{
+		Name: StringRef{Inline: ContinuationBlobName},
+		Type: BlobTypeContinuation,
+		Data: data,
+	}.appendRecord(w.scratch[:0]))
+}
+
+// maybeRotate switches to the next file, if the current one is full. It's called before writing each record,
+// so the Writer's lock must be held
+func (w *Writer) maybeRotate() error {
+	if w.chunkSize == nil || w.rotating || w.chunkSize.bytes.Load() < w.rotateBytes {
+		return nil
+	}
+	return w.rotate()
+}
+
+// rotate ends the current file, and continues the trace in the next one. The Writer's lock must be held
+func (w *Writer) rotate() error {
+	// The records written while switching files mustn't switch again. They're encoded in a scratch buffer of
+	// their own, since the record that triggered the switch is still in the Writer's
+	w.rotating = true
+	scratch := w.scratch
+	w.scratch = nil
+	defer func() {
+		w.rotating = false
+		w.scratch = scratch
+	}()
+
+	nextPath := w.rotatePath(w.continuation.Sequence + 1)
+	create := createFile
+	if w.createFile != nil {
+		create = w.createFile
+	}
+	file, err := create(nextPath)
+	if err != nil {
+		return fmt.Errorf("failed to open next file %s - %w", nextPath, err)
+	}
+
+	// This can run part way through flushing a ThreadWriter, so only the records already written by the Writer
+	// end the file. The events still buffered by the ThreadWriters go into the next one
+	if err := w.endChunk(); err != nil {
+		file.Close()
+		return err
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			file.Close()
+			return fmt.Errorf("failed to close the previous file - %w", err)
+		}
+	}
+
+	// The summary counts the bytes of the whole capture, not just the last file
+	written := w.counter.bytes
+	w.setOutput(file, file)
+	w.counter.bytes = written
+	w.continuation.Sequence++
+	w.continuation.Previous = ""
+	if w.chunkPath != "" {
+		w.continuation.Previous = filepath.Base(w.chunkPath)
+	}
+	w.chunkPath = nextPath
+
+	return w.startChunk()
+}
+
+// startChunk writes the records that make the current file a valid trace of its own, which are the magic number,
+// continuation, provider info, and initialization records, a table snapshot, and the provider section the Writer
+// is in. The Writer's lock must be held
+func (w *Writer) startChunk() error {
+	if err := w.writeMagicNumberRecord(); err != nil {
+		return err
+	}
+	if err := w.addContinuation(); err != nil {
+		return err
+	}
+	for _, provider := range w.providers {
+		if err := w.addProviderInfoRecord(provider.id, provider.name); err != nil {
+			return err
+		}
+	}
+	if w.tickRate != 0 {
+		if err := w.addInitializationRecord(w.tickRate); err != nil {
+			return err
+		}
+	}
+	if err := w.emitTableSnapshot(); err != nil {
+		return err
+	}
+	// The switch can happen between a ThreadWriter's provider section record and its events
+	if w.hasProviderSection {
+		return w.addProviderSectionRecord(w.providerSection)
+	}
+	return nil
+}
+
+// endChunk writes out everything written to the current file, and ends it with its checksum footer, without
+// flushing the ThreadWriters. The Writer's lock must be held
+func (w *Writer) endChunk() error {
+	if w.pipeline != nil {
+		if err := w.pipeline.drain(); err != nil {
+			return err
+		}
+	}
+	if w.checksums != nil {
+		if err := w.checksums.writeChecksum(ChecksumFlagFooter); err != nil {
+			return err
+		}
+	}
+	if w.buffered != nil {
+		if err := w.buffered.Flush(); err != nil {
+			return fmt.Errorf("failed to write buffered records - %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseContinuation decodes the payload of a continuation blob
+func ParseContinuation(data []byte) (Continuation, error) {
+	var continuation Continuation
+	if err := json.Unmarshal(data, &continuation); err != nil {
+		return Continuation{}, fmt.Errorf("failed to decode continuation - %w", err)
+	}
+	return continuation, nil
+}
+
+// CaptureReader is a Reader for the files of a capture split by WithSizeRotation, read as one trace
+type CaptureReader struct {
+	*Reader
+	files  []*os.File
+	chunks []Continuation
+}
+
+// OpenCapture opens the files of a capture written with WithSizeRotation, given in any order, and reads them as
+// one trace. The files are put in order by their Continuations, and it's an error if they're from different
+// captures, or some are missing
+//
+// Each file starts with its own magic number record, which is read as a metadata record
+func OpenCapture(filePaths ...string) (*CaptureReader, error) {
+	if len(filePaths) == 0 {
+		return nil, errors.New("a capture needs at least one file")
+	}
+
+	reader := &CaptureReader{}
+	type chunk struct {
+		file         *os.File
+		continuation Continuation
+	}
+	chunks := make([]chunk, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		file, continuation, err := openChunk(filePath)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		reader.files = append(reader.files, file)
+		chunks = append(chunks, chunk{file: file, continuation: continuation})
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].continuation.Sequence < chunks[j].continuation.Sequence
+	})
+	for i, chunk := range chunks {
+		reader.files[i] = chunk.file
+		reader.chunks = append(reader.chunks, chunk.continuation)
+	}
+	for i, continuation := range reader.chunks {
+		if continuation.CaptureId != reader.chunks[0].CaptureId {
+			reader.Close()
+			return nil, fmt.Errorf("%s is from capture %s, but %s is from capture %s", reader.files[i].Name(), continuation.CaptureId, reader.files[0].Name(), reader.chunks[0].CaptureId)
+		}
+		if continuation.Sequence != uint64(i) {
+			reader.Close()
+			return nil, fmt.Errorf("file %d of capture %s is missing", i, continuation.CaptureId)
+		}
+	}
+
+	readers := make([]io.Reader, 0, len(reader.files))
+	for _, file := range reader.files {
+		readers = append(readers, file)
+	}
+	trace, err := NewReader(io.MultiReader(readers...))
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	reader.Reader = trace
+
+	return reader, nil
+}
+
+// openChunk opens a file of a capture, and reads its Continuation. The file is left at its start
+func openChunk(filePath string) (*os.File, Continuation, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, Continuation{}, fmt.Errorf("failed to open %s - %w", filePath, err)
+	}
+
+	continuation, err := readContinuation(file)
+	if err != nil {
+		file.Close()
+		return nil, Continuation{}, fmt.Errorf("failed to read the continuation of %s - %w", filePath, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, Continuation{}, fmt.Errorf("failed to seek to the start of %s - %w", filePath, err)
+	}
+	return file, continuation, nil
+}
+
+// readContinuation reads the Continuation from the start of a file of a capture
+func readContinuation(r io.Reader) (Continuation, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return Continuation{}, err
+	}
+	record, err := reader.Next()
+	if err != nil {
+		return Continuation{}, err
+	}
+	if record.Type == RecordTypeBlob {
+		fields, payload, err := record.blob()
+		if err != nil {
+			return Continuation{}, err
+		}
+		if fields.blobType == BlobTypeContinuation && resolveString(reader, fields.name) == ContinuationBlobName {
+			return ParseContinuation(payload)
+		}
+	}
+	return Continuation{}, errors.New("the file doesn't start with a continuation record - it wasn't written with WithSizeRotation")
+}
+
+// Chunks returns the Continuations of the capture's files, in order
+func (r *CaptureReader) Chunks() []Continuation {
+	return r.chunks
+}
+
+// Close closes the capture's files
+func (r *CaptureReader) Close() error {
+	var err error
+	for _, file := range r.files {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	r.files = nil
+	return err
+}
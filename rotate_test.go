@@ -0,0 +1,254 @@
+package fxt_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeRotatedCapture writes a capture that's split into several files, and returns their paths
+func writeRotatedCapture(t *testing.T, options ...fxt.WriterOption) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "trace.0.fxt")}
+	nextPath := func(sequence uint64) string {
+		path := filepath.Join(dir, fmt.Sprintf("trace.%d.fxt", sequence))
+		paths = append(paths, path)
+		return path
+	}
+
+	writer, err := fxt.NewWriter(paths[0], append([]fxt.WriterOption{fxt.WithSizeRotation(1024, nextPath)}, options...)...)
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		require.NoError(t, writer.AddInstantEventWithArgs("category", fmt.Sprintf("instant %d", i%20), 1, 2, uint64(i), map[string]interface{}{"i": i}))
+		// With WithPipeline, the size of the file is only known once the records have been written
+		if i%10 == 9 {
+			require.NoError(t, writer.Flush())
+		}
+	}
+	require.NoError(t, writer.Close())
+
+	return paths
+}
+
+func TestSizeRotation(t *testing.T) {
+	paths := writeRotatedCapture(t)
+	require.Greater(t, len(paths), 3)
+
+	var captureId string
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		if i < len(paths)-1 {
+			require.GreaterOrEqual(t, info.Size(), int64(1024))
+		}
+
+		// Each file starts with its continuation, and can be read on its own
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		reader, err := fxt.NewReaderFromBytes(data)
+		require.NoError(t, err)
+		record, err := reader.Next()
+		require.NoError(t, err)
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		blob, ok := decoded.(*fxt.BlobRecord)
+		require.True(t, ok)
+		require.Equal(t, fxt.BlobTypeContinuation, blob.Type)
+		continuation, err := fxt.ParseContinuation(blob.Data)
+		require.NoError(t, err)
+
+		require.Equal(t, uint64(i), continuation.Sequence)
+		if i == 0 {
+			captureId = continuation.CaptureId
+			require.Empty(t, continuation.Previous)
+		} else {
+			require.Equal(t, captureId, continuation.CaptureId)
+			require.Equal(t, filepath.Base(paths[i-1]), continuation.Previous)
+		}
+
+		for {
+			_, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestOpenCapture(t *testing.T) {
+	paths := writeRotatedCapture(t, fxt.WithPipeline(2))
+	require.Greater(t, len(paths), 2)
+
+	// The files can be given in any order
+	shuffled := append([]string{paths[len(paths)-1]}, paths[:len(paths)-1]...)
+	reader, err := fxt.OpenCapture(shuffled...)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Len(t, reader.Chunks(), len(paths))
+
+	var timestamps []uint64
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("instant %d", event.Timestamp%20), event.Name)
+		timestamps = append(timestamps, event.Timestamp)
+	}
+	require.Len(t, timestamps, 200)
+	for i, timestamp := range timestamps {
+		require.Equal(t, uint64(i), timestamp)
+	}
+
+	// A missing file is detected
+	_, err = fxt.OpenCapture(append(paths[:1:1], paths[2:]...)...)
+	require.ErrorContains(t, err, "missing")
+}
+
+// writeIndependentCapture writes a capture that's split into several files, using providers, an initialization
+// record, ThreadWriters, and checksums, and returns the contents of the files
+func writeIndependentCapture(t *testing.T) [][]byte {
+	t.Helper()
+
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "trace.0.fxt")}
+	nextPath := func(sequence uint64) string {
+		path := filepath.Join(dir, fmt.Sprintf("trace.%d.fxt", sequence))
+		paths = append(paths, path)
+		return path
+	}
+
+	writer, err := fxt.NewWriter(paths[0], fxt.WithSizeRotation(512, nextPath), fxt.WithChecksums(256))
+	require.NoError(t, err)
+	require.NoError(t, writer.AddProviderInfoRecord(7, "provider"))
+	require.NoError(t, writer.AddInitializationRecord(1000))
+	threadWriter := writer.NewThreadWriter(1, 3, fxt.WithProvider(7))
+	for i := 0; i < 200; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", fmt.Sprintf("instant %d", i%20), 1, 2, uint64(i)))
+		require.NoError(t, threadWriter.AddInstantEvent("category", fmt.Sprintf("buffered %d", i%20), uint64(i)))
+		if i%10 == 9 {
+			require.NoError(t, writer.Flush())
+		}
+	}
+	require.NoError(t, writer.Close())
+	require.Greater(t, len(paths), 3)
+
+	files := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		files = append(files, data)
+	}
+	return files
+}
+
+// readIndependently reads a trace, verifying its checksums, and returns the number of events in it. Every
+// event must come after a provider info and initialization record, and the ThreadWriter's after its provider
+// section
+func readIndependently(t *testing.T, data []byte) int {
+	t.Helper()
+
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	reader.VerifyChecksums()
+
+	var hasProvider, hasInitialization bool
+	var section uint32
+	events := 0
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		switch decoded := decoded.(type) {
+		case *fxt.ProviderInfoRecord:
+			hasProvider = true
+		case *fxt.InitializationRecord:
+			hasInitialization = true
+		case *fxt.ProviderSectionRecord:
+			section = decoded.ProviderId
+		case *fxt.InstantEvent:
+			require.True(t, hasProvider)
+			require.True(t, hasInitialization)
+			// The ThreadWriter's events are in its provider's section
+			if reader.ResolveString(decoded.Name)[:8] == "buffered" {
+				require.Equal(t, uint32(7), section)
+			}
+			events++
+		}
+	}
+}
+
+func TestRotatedFilesAreIndependent(t *testing.T) {
+	files := writeIndependentCapture(t)
+
+	// The Reader rejects references to strings and threads that haven't been defined, so each file resolves
+	// on its own
+	total := 0
+	for _, data := range files {
+		total += readIndependently(t, data)
+	}
+	require.Equal(t, 400, total)
+}
+
+func TestRotatedFilesConcatenate(t *testing.T) {
+	files := writeIndependentCapture(t)
+
+	concatenated := bytes.Join(files, nil)
+	require.Equal(t, 400, readIndependently(t, concatenated))
+}
+
+func TestSizeRotationSummary(t *testing.T) {
+	paths := writeRotatedCapture(t, fxt.WithCloseSummary())
+
+	// Every file but the last is counted in full
+	var previous int64
+	for _, path := range paths[:len(paths)-1] {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		previous += info.Size()
+	}
+
+	data, err := os.ReadFile(paths[len(paths)-1])
+	require.NoError(t, err)
+	reader, err := fxt.NewReaderFromBytes(data)
+	require.NoError(t, err)
+	var summaries []fxt.TraceSummary
+	var summaryOffset int64
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok && reader.ResolveString(blob.Name) == fxt.SummaryBlobName {
+			var summary fxt.TraceSummary
+			require.NoError(t, json.Unmarshal(blob.Data, &summary))
+			summaries = append(summaries, summary)
+			summaryOffset = record.Offset
+		}
+	}
+
+	require.Len(t, summaries, 1)
+	require.Greater(t, summaries[0].Bytes, uint64(previous))
+	require.LessOrEqual(t, summaries[0].Bytes, uint64(previous+summaryOffset))
+}
@@ -0,0 +1,131 @@
+package fxt
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+)
+
+// DefaultRuntimeMetrics are the runtime/metrics samples written by a RuntimeMetricsSampler
+// when no explicit list is given
+var DefaultRuntimeMetrics = []string{
+	"/gc/pauses:seconds",
+	"/gc/heap/goal:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/sched/goroutines:goroutines",
+}
+
+// RuntimeMetricsSampler periodically reads metrics from the runtime/metrics package
+// and writes each one to a Writer as a counter event named after the metric
+//
+// Scalar metrics are written with a single "value" argument. Histogram metrics
+// (for example, GC pauses) are written with the number of new observations since the
+// previous sample in "count", and the upper bound of the largest new observation in "max"
+//
+// Unlike runtime.ReadMemStats, reading runtime/metrics does not stop the world
+type RuntimeMetricsSampler struct {
+	*Sampler
+
+	writer  *Writer
+	samples []metrics.Sample
+
+	// The histogram counts from the previous sample, keyed by metric name
+	lastCounts map[string][]uint64
+}
+
+// NewRuntimeMetricsSampler creates a RuntimeMetricsSampler writing `metricNames` to `writer`.
+// If `metricNames` is empty, DefaultRuntimeMetrics is used. Call Start to begin sampling
+//
+// Events are written to the "Runtime" category unless overridden with WithSamplerCategory
+func NewRuntimeMetricsSampler(writer *Writer, metricNames []string, options ...SamplerOption) (*RuntimeMetricsSampler, error) {
+	if len(metricNames) == 0 {
+		metricNames = DefaultRuntimeMetrics
+	}
+
+	supported := map[string]bool{}
+	for _, description := range metrics.All() {
+		supported[description.Name] = true
+	}
+
+	samples := make([]metrics.Sample, len(metricNames))
+	for i, name := range metricNames {
+		if !supported[name] {
+			return nil, fmt.Errorf("runtime metric `%s` is not supported by this version of Go", name)
+		}
+		samples[i].Name = name
+	}
+
+	metricsSampler := &RuntimeMetricsSampler{
+		writer:     writer,
+		samples:    samples,
+		lastCounts: map[string][]uint64{},
+	}
+	metricsSampler.Sampler = &Sampler{
+		config: newSamplerConfig("Runtime", 0, options),
+		sample: metricsSampler.sample,
+	}
+
+	return metricsSampler, nil
+}
+
+func (s *RuntimeMetricsSampler) sample(timestamp uint64) error {
+	metrics.Read(s.samples)
+
+	config := s.config
+	for _, sample := range s.samples {
+		var args map[string]interface{}
+
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			args = map[string]interface{}{"value": sample.Value.Uint64()}
+		case metrics.KindFloat64:
+			args = map[string]interface{}{"value": sample.Value.Float64()}
+		case metrics.KindFloat64Histogram:
+			args = s.histogramArgs(sample.Name, sample.Value.Float64Histogram())
+		default:
+			// The metric is no longer supported by the runtime
+			continue
+		}
+
+		if err := s.writer.AddCounterEvent(config.category, sample.Name, config.processId, config.threadId, timestamp, args, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// histogramArgs converts the observations added to a histogram since the previous sample
+// into counter arguments
+func (s *RuntimeMetricsSampler) histogramArgs(name string, histogram *metrics.Float64Histogram) map[string]interface{} {
+	last := s.lastCounts[name]
+
+	count := uint64(0)
+	max := 0.0
+	for i, bucketCount := range histogram.Counts {
+		if i < len(last) {
+			bucketCount -= last[i]
+		}
+		if bucketCount == 0 {
+			continue
+		}
+
+		count += bucketCount
+
+		// Buckets[i+1] is the upper bound of Counts[i]. The last bucket may be unbounded,
+		// in which case we fall back to its lower bound
+		upper := histogram.Buckets[i+1]
+		if math.IsInf(upper, 1) {
+			upper = histogram.Buckets[i]
+		}
+		max = upper
+	}
+
+	s.lastCounts[name] = append(last[:0], histogram.Counts...)
+
+	return map[string]interface{}{
+		"count": count,
+		"max":   max,
+	}
+}
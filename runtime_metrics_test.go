@@ -0,0 +1,53 @@
+package fxt_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetricsSampler(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "test.fxt"))
+	require.NoError(t, err)
+
+	closed := false
+	defer func() {
+		if !closed {
+			err := writer.Close()
+			require.NoError(t, err)
+		}
+	}()
+
+	// Unknown metrics are rejected up front
+	_, err = fxt.NewRuntimeMetricsSampler(writer, []string{"/does/not/exist:bytes"})
+	require.Error(t, err)
+
+	sampler, err := fxt.NewRuntimeMetricsSampler(writer, nil, fxt.WithSamplerCategory("GoRuntime"))
+	require.NoError(t, err)
+
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	// Force a GC so the pause histogram has new observations
+	runtime.GC()
+
+	err = sampler.Sample()
+	require.NoError(t, err)
+
+	err = writer.Close()
+	closed = true
+	require.NoError(t, err)
+}
@@ -0,0 +1,172 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultSampleInterval = 100 * time.Millisecond
+
+// SamplerOption configures a background sampler
+type SamplerOption func(*samplerConfig)
+
+type samplerConfig struct {
+	category  string
+	processId KernelObjectID
+	threadId  KernelObjectID
+	interval  time.Duration
+	clock     Clock
+}
+
+// WithSamplerCategory sets the category of the events written by the sampler
+func WithSamplerCategory(category string) SamplerOption {
+	return func(c *samplerConfig) {
+		c.category = category
+	}
+}
+
+// WithSamplerThread sets the process / thread the sampler's events are attributed to
+//
+// By default, events are attributed to the current process
+func WithSamplerThread(processId KernelObjectID, threadId KernelObjectID) SamplerOption {
+	return func(c *samplerConfig) {
+		c.processId = processId
+		c.threadId = threadId
+	}
+}
+
+// WithSamplerInterval sets how often the sampler takes a sample
+func WithSamplerInterval(interval time.Duration) SamplerOption {
+	return func(c *samplerConfig) {
+		c.interval = interval
+	}
+}
+
+// WithSamplerClock sets the clock used to timestamp samples. It defaults to WallClock
+func WithSamplerClock(clock Clock) SamplerOption {
+	return func(c *samplerConfig) {
+		c.clock = clock
+	}
+}
+
+func newSamplerConfig(defaultCategory string, defaultThreadId KernelObjectID, options []SamplerOption) samplerConfig {
+	config := samplerConfig{
+		category:  defaultCategory,
+		processId: KernelObjectID(os.Getpid()),
+		threadId:  defaultThreadId,
+		interval:  defaultSampleInterval,
+		clock:     WallClock,
+	}
+	for _, option := range options {
+		option(&config)
+	}
+
+	return config
+}
+
+// SampleFunc takes a single sample, writing its events with the given category, thread, and timestamp
+type SampleFunc func(category string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error
+
+// NewSampler creates a Sampler that calls `sample` on every tick. Call Start to begin sampling
+//
+// It is the building block for custom samplers. Events should be written to the "Samples" category
+// unless overridden with WithSamplerCategory
+func NewSampler(sample SampleFunc, options ...SamplerOption) *Sampler {
+	s := &Sampler{
+		config: newSamplerConfig("Samples", 0, options),
+	}
+	s.sample = func(timestamp uint64) error {
+		return sample(s.config.category, s.config.processId, s.config.threadId, timestamp)
+	}
+
+	return s
+}
+
+// Sampler calls a sample function on a fixed interval from a background goroutine
+// It is the shared plumbing behind all the samplers in this package
+type Sampler struct {
+	config samplerConfig
+	sample func(timestamp uint64) error
+
+	// sampleMu serializes sample calls from the background goroutine and from Sample
+	sampleMu sync.Mutex
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+// Start begins sampling in a background goroutine
+func (s *Sampler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("sampler is already running")
+	}
+	if s.config.interval <= 0 {
+		return fmt.Errorf("invalid sample interval %v", s.config.interval)
+	}
+
+	s.running = true
+	s.err = nil
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+
+	return nil
+}
+
+// Stop halts the background goroutine and waits for it to exit
+// It returns the first error encountered while sampling, if any
+func (s *Sampler) Stop() error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mu.Unlock()
+
+	<-done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Sample takes a single sample immediately
+func (s *Sampler) Sample() error {
+	s.sampleMu.Lock()
+	defer s.sampleMu.Unlock()
+
+	return s.sample(s.config.clock())
+}
+
+func (s *Sampler) run(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.config.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Sample(); err != nil {
+				s.mu.Lock()
+				if s.err == nil {
+					s.err = err
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+}
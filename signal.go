@@ -0,0 +1,75 @@
+package fxt
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// SignalAction is what a Writer does when the process receives one of the signals given to WithSignalAction
+type SignalAction func(w *Writer) error
+
+// FlushOnSignal is a SignalAction that flushes the Writer, so everything traced so far can be read from the
+// destination while the process keeps running
+func FlushOnSignal(w *Writer) error {
+	return w.Flush()
+}
+
+// RotateOnSignal returns a SignalAction that ends the current trace, and starts a new one in the file at the
+// path returned by `nextPath`, like Reset. The previous file is closed, so it's complete and can be collected
+//
+// It can't be used with Writers created with NewWriterTo, since the previous destination is still in use
+func RotateOnSignal(nextPath func() string) SignalAction {
+	return func(w *Writer) error {
+		return w.Reset(nextPath())
+	}
+}
+
+// WithSignalAction makes the Writer run `action` whenever the process receives one of `signals`, like
+// syscall.SIGUSR1 or syscall.SIGHUP, until the Writer is closed. This lets operators grab a trace from a long
+// running process on demand, with `kill -USR1 <pid>`
+//
+// The action runs on a goroutine of its own, and its errors are reported to the warning handler. The option can
+// be given several times, for different signals
+func WithSignalAction(action SignalAction, signals ...os.Signal) WriterOption {
+	return func(w *Writer) {
+		w.signalActions = append(w.signalActions, signalAction{action: action, signals: signals})
+	}
+}
+
+type signalAction struct {
+	action  SignalAction
+	signals []os.Signal
+}
+
+// startSignalHandlers starts a goroutine for each WithSignalAction, which runs the action whenever one of its
+// signals is received
+func (w *Writer) startSignalHandlers() {
+	if len(w.signalActions) == 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	w.stopSignals = stop
+	for _, handler := range w.signalActions {
+		received := make(chan os.Signal, 1)
+		signal.Notify(received, handler.signals...)
+
+		go func(handler signalAction) {
+			defer signal.Stop(received)
+
+			for {
+				select {
+				case sig := <-received:
+					if err := handler.action(w); err != nil {
+						w.mu.Lock()
+						w.warn(fmt.Errorf("action for signal %v failed - %w", sig, err))
+						w.mu.Unlock()
+					}
+				case <-stop:
+					return
+				}
+			}
+		}(handler)
+	}
+}
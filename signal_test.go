@@ -0,0 +1,89 @@
+//go:build unix
+
+package fxt_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignalAction(t *testing.T) {
+	tempDir := t.TempDir()
+
+	rotations := 0
+	rotated := make(chan struct{}, 1)
+	nextPath := func() string {
+		rotations++
+		return filepath.Join(tempDir, fmt.Sprintf("trace-%d.fxt", rotations))
+	}
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "trace-0.fxt"),
+		fxt.WithFlushPolicy(fxt.FlushPolicy{Bytes: 1 << 20}),
+		fxt.WithSignalAction(fxt.FlushOnSignal, syscall.SIGUSR1),
+		fxt.WithSignalAction(func(w *fxt.Writer) error {
+			defer func() { rotated <- struct{}{} }()
+			return fxt.RotateOnSignal(nextPath)(w)
+		}, syscall.SIGHUP),
+	)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "before", 1, 2, 100))
+
+	// The buffered records only reach the file once it's flushed
+	info, err := os.Stat(filepath.Join(tempDir, "trace-0.fxt"))
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(filepath.Join(tempDir, "trace-0.fxt"))
+		return err == nil && info.Size() > 0
+	}, 5*time.Second, time.Millisecond)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case <-rotated:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the trace wasn't rotated")
+	}
+
+	require.NoError(t, writer.AddInstantEvent("category", "after", 1, 2, 200))
+	require.NoError(t, writer.Close())
+
+	for i, name := range []string{"before", "after"} {
+		data, err := os.ReadFile(filepath.Join(tempDir, fmt.Sprintf("trace-%d.fxt", i)))
+		require.NoError(t, err)
+		reader, err := fxt.NewReaderFromBytes(data)
+		require.NoError(t, err)
+		event, err := reader.NextEvent()
+		require.NoError(t, err)
+		require.Equal(t, name, event.Name)
+	}
+}
+
+func TestSignalActionAfterReset(t *testing.T) {
+	var first, second lockedBuffer
+	writer, err := fxt.NewWriterTo(&first,
+		fxt.WithFlushPolicy(fxt.FlushPolicy{Bytes: 1 << 20}),
+		fxt.WithSignalAction(fxt.FlushOnSignal, syscall.SIGUSR1),
+	)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	// Close stops the signal handlers, and ResetTo starts them again
+	require.NoError(t, writer.ResetTo(&second))
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.Zero(t, second.Len())
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		return second.Len() > 0
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,281 @@
+package fxt
+
+import "fmt"
+
+// WithCloseOpenSpans makes the Writer keep track of the duration begin events on each thread that haven't
+// been ended yet. When the Writer is closed or reset, an end event is written for each of them at the latest
+// timestamp in the trace, so a crash or early shutdown doesn't leave slices that never end
+//
+// The begin events of ThreadWriters are tracked too. Their open spans are also ended when they're closed
+func WithCloseOpenSpans() WriterOption {
+	return func(w *Writer) {
+		w.closeOpenSpans = true
+	}
+}
+
+// WithSpanCheck makes the Writer keep track of the duration begin events on each thread, and report a
+// warning for each duration end event that doesn't match the category and name of the innermost open one
+//
+// Viewers match end events to begin events by nesting, not by name, so a missing or misplaced end event
+// silently shifts the nesting of the spans after it on the thread. The end event is still written
+func WithSpanCheck() WriterOption {
+	return func(w *Writer) {
+		w.checkSpans = true
+	}
+}
+
+// tracksSpans reports whether the Writer keeps track of the open duration begin events
+func (w *Writer) tracksSpans() bool {
+	return w.closeOpenSpans || w.checkSpans || w.checkFlows
+}
+
+// checkSpanEnd returns a warning if `stack` is empty, or its innermost span isn't the one being ended
+func checkSpanEnd(stack []openDuration, category string, name string, thread Thread, timestamp uint64) error {
+	if len(stack) == 0 {
+		return fmt.Errorf("end of %s/%s on thread %d/%d at %d has no matching begin", category, name, thread.ProcessId, thread.ThreadId, timestamp)
+	}
+	if span := stack[len(stack)-1]; span.category != category || span.name != name {
+		return fmt.Errorf("end of %s/%s on thread %d/%d at %d doesn't match the innermost begin of %s/%s at %d", category, name, thread.ProcessId, thread.ThreadId, timestamp, span.category, span.name, span.timestamp)
+	}
+	return nil
+}
+
+// beginSpan records a duration begin event written directly with the Writer. The Writer's lock must be held
+func (w *Writer) beginSpan(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) {
+	if !w.tracksSpans() {
+		return
+	}
+
+	w.openSpans.push(Thread{ProcessId: processId, ThreadId: threadId}, category, name, timestamp)
+}
+
+// endSpan removes the innermost open span of a thread, when a duration end event is written directly with
+// the Writer. The Writer's lock must be held
+func (w *Writer) endSpan(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) {
+	if !w.tracksSpans() {
+		return
+	}
+
+	thread := Thread{ProcessId: processId, ThreadId: threadId}
+	stack := w.openSpans[thread]
+	if w.checkSpans {
+		if err := checkSpanEnd(stack, category, name, thread, timestamp); err != nil {
+			w.warn(err)
+		}
+	}
+	if len(stack) > 0 {
+		w.openSpans.set(thread, stack[:len(stack)-1])
+	}
+}
+
+// updateLatestTimestamp records the latest timestamp written to the trace, for ending the open spans
+func (w *Writer) updateLatestTimestamp(timestamp uint64) {
+	if w.closeOpenSpans && timestamp > w.latestTimestamp {
+		w.latestTimestamp = timestamp
+	}
+}
+
+// endOpenSpans writes the buffered events of the ThreadWriters, followed by an end event for every span that's
+// still open, at the latest timestamp in the trace. The Writer's lock must be held
+func (w *Writer) endOpenSpans() error {
+	if !w.closeOpenSpans {
+		return nil
+	}
+	if err := w.flushThreadWriters(); err != nil {
+		return err
+	}
+
+	spans := w.openSpans
+	latest := w.latestTimestamp
+	for _, t := range w.threadWriters {
+		t.mu.Lock()
+		if len(t.openSpans) > 0 {
+			spans[t.thread] = append(spans[t.thread], t.openSpans...)
+		}
+		t.openSpans = nil
+		if t.latestTimestamp > latest {
+			latest = t.latestTimestamp
+		}
+		t.latestTimestamp = 0
+		t.mu.Unlock()
+	}
+	w.openSpans = spanStacks{}
+
+	for _, thread := range spans.threads() {
+		if err := w.endSpans(thread, spans[thread], latest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSpans writes end events for the open spans of a thread, innermost first. The Writer's lock must be held
+func (w *Writer) endSpans(thread Thread, stack []openDuration, timestamp uint64) error {
+	for i := len(stack) - 1; i >= 0; i-- {
+		span := stack[i]
+		event, err := w.internEventRecord(span.category, span.name, thread.ProcessId, thread.ThreadId, timestamp, nil)
+		if err != nil {
+			return fmt.Errorf("failed to end %s/%s on thread %d/%d - %w", span.category, span.name, thread.ProcessId, thread.ThreadId, err)
+		}
+		if err := writeEvent(w, DurationEndEvent{EventRecord: event}); err != nil {
+			return fmt.Errorf("failed to end %s/%s on thread %d/%d - %w", span.category, span.name, thread.ProcessId, thread.ThreadId, err)
+		}
+	}
+	return nil
+}
+
+// beginSpan records a duration begin event written with the ThreadWriter
+func (t *ThreadWriter) beginSpan(category string, name string, timestamp uint64) {
+	if !t.writer.tracksSpans() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// The begin event isn't in the trace if the DropPolicy dropped it
+	if !t.lastBuffered {
+		return
+	}
+	t.openSpans = append(t.openSpans, openDuration{category: category, name: name, timestamp: timestamp, record: t.records - 1})
+}
+
+// endSpan removes the innermost open span of the ThreadWriter, when a duration end event is written with it
+func (t *ThreadWriter) endSpan(category string, name string, timestamp uint64) {
+	if !t.writer.tracksSpans() {
+		return
+	}
+
+	t.mu.Lock()
+	// If the DropPolicy dropped the end event, the span is still open in the trace
+	if !t.lastBuffered {
+		t.mu.Unlock()
+		return
+	}
+	var err error
+	if t.writer.checkSpans {
+		err = checkSpanEnd(t.openSpans, category, name, t.thread, timestamp)
+	}
+	if len(t.openSpans) > 0 {
+		t.openSpans = t.openSpans[:len(t.openSpans)-1]
+	}
+	t.mu.Unlock()
+
+	// Warning takes the Writer's lock, which can't be taken while holding the ThreadWriter's
+	if err != nil {
+		t.warn(err)
+	}
+}
+
+// updateLatestTimestamp records the latest timestamp the ThreadWriter has written, for ending its open spans
+func (t *ThreadWriter) updateLatestTimestamp(timestamp uint64) {
+	if !t.writer.closeOpenSpans {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timestamp > t.latestTimestamp {
+		t.latestTimestamp = timestamp
+	}
+}
+
+// endOpenSpans writes end events for the ThreadWriter's open spans, at the latest timestamp it has written
+// Its buffer must already have been written. The Writer's lock must be held
+func (t *ThreadWriter) endOpenSpans() error {
+	if !t.writer.closeOpenSpans {
+		return nil
+	}
+
+	t.mu.Lock()
+	stack, latest := t.openSpans, t.latestTimestamp
+	t.openSpans = nil
+	t.mu.Unlock()
+
+	return t.writer.endSpans(t.thread, stack, latest)
+}
+
+// WithAsyncCheck makes the Writer keep track of the async begin events that haven't been ended, and report a
+// warning for each async instant or end event whose correlation ID doesn't have an open async begin event
+// in the same category
+//
+// Viewers show these orphaned events as markers that aren't attached to any async slice. The events are still written
+func WithAsyncCheck() WriterOption {
+	return func(w *Writer) {
+		w.checkAsync = true
+	}
+}
+
+// correlatedSpan identifies an async span or flow, which all its events share
+type correlatedSpan struct {
+	category      string
+	correlationId uint64
+}
+
+// beginAsync records an async begin event. The Writer's lock must be held
+func (w *Writer) beginAsync(category string, correlationId uint64) {
+	if w.checkAsync {
+		w.openAsync[correlatedSpan{category: category, correlationId: correlationId}] = struct{}{}
+	}
+}
+
+// checkAsyncEvent warns if an async instant or end event doesn't have an open async begin event, and forgets
+// the begin event if `end` is set. The Writer's lock must be held
+func (w *Writer) checkAsyncEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, correlationId uint64, end bool) {
+	if !w.checkAsync {
+		return
+	}
+
+	span := correlatedSpan{category: category, correlationId: correlationId}
+	if _, ok := w.openAsync[span]; !ok {
+		kind := "instant"
+		if end {
+			kind = "end"
+		}
+		w.warn(fmt.Errorf("async %s %s/%s on thread %d/%d at %d has correlation ID %d, which has no open async begin in the same category", kind, category, name, processId, threadId, timestamp, correlationId))
+		return
+	}
+	if end {
+		delete(w.openAsync, span)
+	}
+}
+
+// WithFlowCheck makes the Writer report a warning for each flow step or end event whose correlation ID
+// doesn't have a flow begin event in the same category, and for each flow event that isn't inside a
+// duration on its thread
+//
+// Viewers attach the flow arrows to the duration that encloses each flow event, so flow events outside of
+// one aren't shown. Only the durations written with begin and end events are known to the Writer when the
+// flow event is written, so flow events must be written between a begin and an end event to pass the check.
+// The events are still written
+func WithFlowCheck() WriterOption {
+	return func(w *Writer) {
+		w.checkFlows = true
+	}
+}
+
+// checkFlowEvent warns if a flow event isn't inside a duration on its thread, or if a flow step or end event
+// doesn't have an open flow. It records the flow as open for a begin event, and forgets it for an end event
+// The Writer's lock must be held
+func (w *Writer) checkFlowEvent(kind string, category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, correlationId uint64) {
+	if !w.checkFlows {
+		return
+	}
+
+	thread := Thread{ProcessId: processId, ThreadId: threadId}
+	if len(w.openSpans[thread]) == 0 {
+		w.warn(fmt.Errorf("flow %s %s/%s on thread %d/%d at %d isn't inside a duration on its thread, so it can't be attached to one", kind, category, name, processId, threadId, timestamp))
+	}
+
+	flow := correlatedSpan{category: category, correlationId: correlationId}
+	if kind == "begin" {
+		w.openFlows[flow] = struct{}{}
+		return
+	}
+	if _, ok := w.openFlows[flow]; !ok {
+		w.warn(fmt.Errorf("flow %s %s/%s on thread %d/%d at %d has correlation ID %d, which has no flow begin in the same category", kind, category, name, processId, threadId, timestamp, correlationId))
+		return
+	}
+	if kind == "end" {
+		delete(w.openFlows, flow)
+	}
+}
@@ -0,0 +1,173 @@
+package fxt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterCloseOpenSpans(t *testing.T) {
+	for _, closeOpenSpans := range []bool{false, true} {
+		var buffer bytes.Buffer
+		var options []fxt.WriterOption
+		if closeOpenSpans {
+			options = append(options, fxt.WithCloseOpenSpans())
+		}
+		writer, err := fxt.NewWriterTo(&buffer, options...)
+		require.NoError(t, err)
+
+		require.NoError(t, writer.AddDurationBeginEvent("category", "outer", 1, 2, 1))
+		require.NoError(t, writer.AddDurationBeginEvent("category", "inner", 1, 2, 2))
+		require.NoError(t, writer.AddDurationEndEvent("category", "inner", 1, 2, 3))
+		require.NoError(t, writer.AddDurationBeginEvent("category", "other", 1, 3, 4))
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 3, 5))
+
+		threadWriter := writer.NewThreadWriter(1, 4)
+		require.NoError(t, threadWriter.AddDurationBeginEvent("category", "buffered", 6))
+		require.NoError(t, writer.Close())
+
+		durations := readDurations(t, buffer.Bytes())
+		if !closeOpenSpans {
+			require.Equal(t, []string{"+outer", "+inner", "-inner@3", "+other", "+buffered"}, durations)
+			continue
+		}
+		// The spans are ended at the latest timestamp, after the ThreadWriter's events
+		require.Equal(t, []string{"+outer", "+inner", "-inner@3", "+other", "+buffered", "-outer@6", "-other@6", "-buffered@6"}, durations)
+	}
+}
+
+func TestThreadWriterCloseOpenSpans(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithCloseOpenSpans())
+	require.NoError(t, err)
+
+	threadWriter := writer.NewThreadWriter(1, 2)
+	require.NoError(t, threadWriter.AddDurationBeginEvent("category", "outer", 1))
+	require.NoError(t, threadWriter.AddDurationBeginEvent("category", "inner", 2))
+	require.NoError(t, threadWriter.AddDurationEndEvent("category", "inner", 3))
+	require.NoError(t, threadWriter.Close())
+
+	// Ending the spans of another thread doesn't change the ThreadWriter's, which were ended when it was closed
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 3, 9))
+	require.NoError(t, writer.Close())
+
+	require.Equal(t, []string{"+outer", "+inner", "-inner@3", "-outer@3"}, readDurations(t, buffer.Bytes()))
+}
+
+func TestThreadWriterCloseOpenSpansDropped(t *testing.T) {
+	tests := []struct {
+		policy    fxt.DropPolicy
+		durations []string
+	}{
+		{policy: fxt.DropPolicyNewest, durations: []string{"+a", "+b", "-b@3", "-a@3"}},
+		{policy: fxt.DropPolicyOldest, durations: []string{"+b", "+c", "-c@3", "-b@3"}},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		writer, err := fxt.NewWriterTo(&buffer, fxt.WithCloseOpenSpans())
+		require.NoError(t, err)
+
+		// Duration events without arguments are 2 words, so the buffer holds 2 of them
+		threadWriter := writer.NewThreadWriter(1, 2, fxt.WithBufferSize(2*16), fxt.WithDropPolicy(test.policy))
+		require.NoError(t, threadWriter.AddDurationBeginEvent("category", "a", 1))
+		require.NoError(t, threadWriter.AddDurationBeginEvent("category", "b", 2))
+		require.NoError(t, threadWriter.AddDurationBeginEvent("category", "c", 3))
+		require.NoError(t, threadWriter.Close())
+		require.NoError(t, writer.Close())
+
+		// Only the spans whose begin events are in the trace are ended
+		require.Equal(t, test.durations, readDurations(t, buffer.Bytes()))
+	}
+}
+
+func TestWriterSpanCheck(t *testing.T) {
+	var buffer bytes.Buffer
+	var warnings []string
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithSpanCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddDurationBeginEvent("category", "outer", 1, 2, 1))
+	require.NoError(t, writer.AddDurationBeginEvent("category", "inner", 1, 2, 2))
+	require.NoError(t, writer.AddDurationEndEvent("category", "inner", 1, 2, 3))
+	require.Empty(t, warnings)
+
+	// The end of the outer span is missing, so the next end is for the wrong span
+	require.NoError(t, writer.AddDurationBeginEvent("category", "next", 1, 2, 4))
+	require.NoError(t, writer.AddDurationEndEvent("other", "next", 1, 2, 5))
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "doesn't match the innermost begin of category/next")
+
+	require.NoError(t, writer.AddDurationEndEvent("category", "outer", 1, 2, 6))
+	require.NoError(t, writer.AddDurationEndEvent("category", "outer", 1, 2, 7))
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[1], "has no matching begin")
+
+	// ThreadWriters are checked the same way
+	threadWriter := writer.NewThreadWriter(1, 3)
+	require.NoError(t, threadWriter.AddDurationBeginEvent("category", "buffered", 8))
+	require.NoError(t, threadWriter.AddDurationEndEvent("category", "mismatched", 9))
+	require.Len(t, warnings, 3)
+	require.NoError(t, writer.Close())
+
+	// The events are written regardless
+	require.Len(t, readDurations(t, buffer.Bytes()), 9)
+}
+
+func TestWriterAsyncCheck(t *testing.T) {
+	var warnings []string
+	writer, err := fxt.NewWriterTo(&bytes.Buffer{}, fxt.WithAsyncCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddAsyncBeginEvent("category", "request", 1, 2, 1, 7))
+	require.NoError(t, writer.AddAsyncInstantEvent("category", "step", 1, 3, 2, 7))
+	require.NoError(t, writer.AddAsyncEndEvent("category", "request", 1, 2, 3, 7))
+	require.Empty(t, warnings)
+
+	// Correlation IDs are scoped to the category, and can't be used once they've ended
+	require.NoError(t, writer.AddAsyncInstantEvent("other", "step", 1, 3, 4, 7))
+	require.NoError(t, writer.AddAsyncEndEvent("category", "request", 1, 2, 5, 7))
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[0], "async instant other/step")
+	require.Contains(t, warnings[1], "async end category/request")
+
+	// Resetting starts a new trace, without any open async spans
+	require.NoError(t, writer.AddAsyncBeginEvent("category", "request", 1, 2, 6, 8))
+	require.NoError(t, writer.ResetTo(&bytes.Buffer{}))
+	require.NoError(t, writer.AddAsyncEndEvent("category", "request", 1, 2, 7, 8))
+	require.Len(t, warnings, 3)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterFlowCheck(t *testing.T) {
+	var warnings []string
+	writer, err := fxt.NewWriterTo(&bytes.Buffer{}, fxt.WithFlowCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err.Error())
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddDurationBeginEvent("category", "send", 1, 2, 1))
+	require.NoError(t, writer.AddFlowBeginEvent("category", "message", 1, 2, 2, 9))
+	require.NoError(t, writer.AddDurationEndEvent("category", "send", 1, 2, 3))
+	require.NoError(t, writer.AddDurationBeginEvent("category", "receive", 1, 3, 4))
+	require.NoError(t, writer.AddFlowStepEvent("category", "message", 1, 3, 5, 9))
+	require.NoError(t, writer.AddFlowEndEvent("category", "message", 1, 3, 6, 9))
+	require.Empty(t, warnings)
+
+	// The flow has ended, and the duration on thread 3 is still open
+	require.NoError(t, writer.AddFlowEndEvent("category", "message", 1, 3, 7, 9))
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "has no flow begin")
+
+	// Thread 2 has no open duration to attach the flow to
+	require.NoError(t, writer.AddFlowBeginEvent("category", "message", 1, 2, 8, 10))
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[1], "isn't inside a duration")
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,193 @@
+package fxt
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StackSampler periodically captures the stacks of all the goroutines, and writes them as duration events, so
+// a trace carries a rough CPU profile without any external tools
+//
+// Each goroutine's stack is written on the goroutine's thread, using its ID from GoroutineId as the thread ID,
+// with a duration event for every frame, outermost first. A frame's duration spans the samples it was seen in,
+// so long running functions show up as long durations, in a flame chart of each goroutine. Threads are named
+// "goroutine-<id>", like with WithGoroutineThreads, unless they were already named
+//
+// The durations are only as precise as the sampling interval, and functions that run between two samples
+// aren't seen at all. Frames are compared by function name, so a function calling the same function from a
+// different line looks like one long call. The goroutine taking the sample is skipped
+//
+// The goroutines' frames are written on their threads in the sampler's process, so they must not be mixed with
+// other duration events on those threads. To keep them apart, put them in their own process with
+// WithSamplerThread. The thread ID given to it is ignored
+//
+// Capturing the stacks stops the world for a time proportional to the number of goroutines, so very short
+// intervals should be avoided
+type StackSampler struct {
+	*Sampler
+
+	writer *Writer
+
+	buffer []byte
+	// open is the frames that have begun on each goroutine's thread, outermost first
+	open map[KernelObjectID][]string
+}
+
+// NewStackSampler creates a StackSampler writing to `writer`. Call Start to begin sampling
+//
+// Events are written to the "Stacks" category unless overridden with WithSamplerCategory
+func NewStackSampler(writer *Writer, options ...SamplerOption) *StackSampler {
+	stackSampler := &StackSampler{
+		writer: writer,
+		buffer: make([]byte, 64*1024),
+		open:   map[KernelObjectID][]string{},
+	}
+	stackSampler.Sampler = &Sampler{
+		config: newSamplerConfig("Stacks", 0, options),
+		sample: stackSampler.sample,
+	}
+
+	return stackSampler
+}
+
+// Stop halts the background goroutine, and ends the frames that are still open
+// It returns the first error encountered while sampling, if any
+func (s *StackSampler) Stop() error {
+	err := s.Sampler.Stop()
+
+	s.sampleMu.Lock()
+	defer s.sampleMu.Unlock()
+	if endErr := s.write(s.config.clock(), nil); err == nil {
+		err = endErr
+	}
+	return err
+}
+
+func (s *StackSampler) sample(timestamp uint64) error {
+	for {
+		n := runtime.Stack(s.buffer, true)
+		if n < len(s.buffer) {
+			stacks := parseGoroutineStacks(s.buffer[:n])
+			delete(stacks, GoroutineId())
+			return s.write(timestamp, stacks)
+		}
+		s.buffer = make([]byte, 2*len(s.buffer))
+	}
+}
+
+// write ends the frames that are no longer on the goroutines' stacks, and begins the new ones
+func (s *StackSampler) write(timestamp uint64, stacks map[KernelObjectID][]string) error {
+	goroutines := make([]KernelObjectID, 0, len(s.open)+len(stacks))
+	for goroutineId := range s.open {
+		goroutines = append(goroutines, goroutineId)
+	}
+	for goroutineId := range stacks {
+		if _, ok := s.open[goroutineId]; !ok {
+			goroutines = append(goroutines, goroutineId)
+		}
+	}
+	sort.Slice(goroutines, func(i, j int) bool { return goroutines[i] < goroutines[j] })
+
+	config := s.config
+	for _, goroutineId := range goroutines {
+		open := s.open[goroutineId]
+		frames := stacks[goroutineId]
+
+		common := 0
+		for common < len(open) && common < len(frames) && open[common] == frames[common] {
+			common++
+		}
+		for i := len(open) - 1; i >= common; i-- {
+			if err := s.writer.AddDurationEndEvent(config.category, open[i], config.processId, goroutineId, timestamp); err != nil {
+				return err
+			}
+		}
+
+		if len(open) == 0 && len(frames) > 0 {
+			s.writer.mu.Lock()
+			err := s.writer.nameGoroutineThread(config.processId, goroutineId)
+			s.writer.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		for i := common; i < len(frames); i++ {
+			if err := s.writer.AddDurationBeginEvent(config.category, frames[i], config.processId, goroutineId, timestamp); err != nil {
+				return err
+			}
+		}
+
+		if len(frames) == 0 {
+			delete(s.open, goroutineId)
+		} else {
+			s.open[goroutineId] = frames
+		}
+	}
+
+	return nil
+}
+
+// parseGoroutineStacks parses the output of runtime.Stack into the function names of each goroutine's frames,
+// outermost first. Each goroutine's stack looks like:
+//
+//	goroutine 7 [chan receive]:
+//	main.worker(0xc000012345)
+//		/src/main.go:20 +0x2a
+//	created by main.main in goroutine 1
+//		/src/main.go:12 +0x4c
+//
+// with the innermost frame first, and a blank line between goroutines
+func parseGoroutineStacks(data []byte) map[KernelObjectID][]string {
+	stacks := map[KernelObjectID][]string{}
+
+	var goroutineId KernelObjectID
+	var frames []string
+	finish := func() {
+		if len(frames) == 0 {
+			return
+		}
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+		stacks[goroutineId] = frames
+		frames = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			finish()
+			header := strings.TrimPrefix(line, "goroutine ")
+			if end := strings.IndexByte(header, ' '); end >= 0 {
+				header = header[:end]
+			}
+			id, err := strconv.ParseUint(header, 10, 64)
+			if err != nil {
+				// Not the header of a goroutine, so skip to the next one
+				goroutineId = 0
+				continue
+			}
+			goroutineId = KernelObjectID(id)
+		case goroutineId == 0, line == "", strings.HasPrefix(line, "\t"), strings.HasPrefix(line, "created by "), strings.HasPrefix(line, "..."):
+			// File locations, the goroutine's creator, and elided frames
+		default:
+			// Strip the arguments, which are in the last parentheses, like "main.(*T).Run(0x1, ...)"
+			if strings.HasSuffix(line, ")") {
+				if start := strings.LastIndexByte(line, '('); start > 0 {
+					line = line[:start]
+				}
+			}
+			frames = append(frames, line)
+		}
+	}
+	finish()
+
+	return stacks
+}
@@ -0,0 +1,37 @@
+package fxt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const goroutineStacks = `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+
+goroutine 7 [chan receive]:
+main.(*worker).run(0xc000012345, {0x1, 0x2})
+	/src/worker.go:20 +0x2a
+main.start.func1()
+	/src/main.go:30 +0x10
+created by main.start in goroutine 1
+	/src/main.go:28 +0x4c
+
+goroutine 9 [select]:
+main.deep(...)
+	/src/deep.go:5
+...additional frames elided...
+created by main.main
+	/src/main.go:12 +0x4c
+`
+
+func TestParseGoroutineStacks(t *testing.T) {
+	require.Equal(t, map[KernelObjectID][]string{
+		1: {"main.main"},
+		7: {"main.start.func1", "main.(*worker).run"},
+		9: {"main.deep"},
+	}, parseGoroutineStacks([]byte(goroutineStacks)))
+
+	require.Empty(t, parseGoroutineStacks(nil))
+}
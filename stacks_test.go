@@ -0,0 +1,68 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+//go:noinline
+func blockedInStackSamplerTest(started chan<- fxt.KernelObjectID, release <-chan struct{}) {
+	started <- fxt.GoroutineId()
+	<-release
+}
+
+func TestStackSampler(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	started := make(chan fxt.KernelObjectID)
+	release := make(chan struct{})
+	go blockedInStackSamplerTest(started, release)
+	blockedId := <-started
+
+	sampler := fxt.NewStackSampler(writer, fxt.WithSamplerThread(1, 0))
+	require.NoError(t, sampler.Sample())
+	require.NoError(t, sampler.Sample())
+	close(release)
+	require.NoError(t, sampler.Stop())
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	depths := map[fxt.KernelObjectID]int{}
+	begins := map[string]int{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "Stacks", event.Category)
+		require.Equal(t, fxt.KernelObjectID(1), event.Thread.ProcessId)
+
+		switch event.Decoded.(type) {
+		case *fxt.DurationBeginEvent:
+			depths[event.Thread.ThreadId]++
+			if event.Thread.ThreadId == blockedId {
+				begins[event.Name]++
+			}
+		case *fxt.DurationEndEvent:
+			depths[event.Thread.ThreadId]--
+			require.GreaterOrEqual(t, depths[event.Thread.ThreadId], 0)
+		}
+	}
+
+	// The blocked goroutine's frames only begin once, since they didn't change between the samples,
+	// and Stop ended every frame
+	require.Equal(t, 1, begins["github.com/richiesams/fxt_test.blockedInStackSamplerTest"])
+	for threadId, depth := range depths {
+		require.Zero(t, depth, "goroutine %d", threadId)
+	}
+}
@@ -0,0 +1,118 @@
+package fxt
+
+import (
+	"time"
+)
+
+// Stats summarizes the records a Reader has read so far
+type Stats struct {
+	// Records is the number of records read, not counting the magic number record, and Bytes their total
+	// size, including it
+	Records int
+	Bytes   int64
+	// RecordTypes is the number of records, and their total size, of each type of record
+	RecordTypes map[RecordType]RecordTypeStats
+
+	// HasTimestamps is set if any of the records had a timestamp. FirstTimestamp and LastTimestamp are the
+	// earliest and latest timestamps of the event, scheduling, and log records, in ticks
+	HasTimestamps  bool
+	FirstTimestamp uint64
+	LastTimestamp  uint64
+	// TicksPerSecond is the tick rate from the last initialization record, or zero if there wasn't one
+	TicksPerSecond TickRate
+
+	// Providers are the providers with a provider info or provider section record, in the order they first appeared
+	Providers []Provider
+
+	// Strings and Threads are the number of entries in the string and thread tables
+	Strings int
+	Threads int
+}
+
+// RecordTypeStats is the number of records of one type, and their total size in bytes
+type RecordTypeStats struct {
+	Count int
+	Bytes int64
+}
+
+// Provider is a trace provider. Name is empty for providers that only have provider section records
+type Provider struct {
+	Id   uint32
+	Name string
+}
+
+// Duration returns the time between the first and last timestamps. It's zero if the tick rate isn't known
+func (s Stats) Duration() time.Duration {
+	if !s.HasTimestamps || s.TicksPerSecond == 0 {
+		return 0
+	}
+	return s.TicksPerSecond.ToDuration(s.LastTimestamp - s.FirstTimestamp)
+}
+
+// Stats returns the statistics of the records read so far
+// Counting the records only needs their headers, so it's cheap enough to sanity-check captures as they're read
+func (r *Reader) Stats() Stats {
+	stats := r.stats
+	stats.RecordTypes = make(map[RecordType]RecordTypeStats, len(r.stats.RecordTypes))
+	for recordType, typeStats := range r.stats.RecordTypes {
+		stats.RecordTypes[recordType] = typeStats
+	}
+	stats.Providers = append([]Provider(nil), r.stats.Providers...)
+	stats.Bytes = r.offset
+	stats.Strings = len(r.stringTable)
+	stats.Threads = len(r.threadTable)
+	return stats
+}
+
+// addRecord adds `record` to the Reader's statistics
+func (r *Reader) addRecord(record *Record) {
+	s := &r.stats
+	if s.RecordTypes == nil {
+		s.RecordTypes = map[RecordType]RecordTypeStats{}
+	}
+	s.Records++
+	typeStats := s.RecordTypes[record.Type]
+	typeStats.Count++
+	typeStats.Bytes += int64(len(record.Data))
+	s.RecordTypes[record.Type] = typeStats
+
+	header := record.Header()
+	switch record.Type {
+	case RecordTypeInitialization:
+		s.TicksPerSecond = TickRate(record.Word(1))
+	case RecordTypeEvent, RecordTypeScheduling, RecordTypeLog:
+		// These all have the timestamp in the word after the header, and are at least 2 words long
+		timestamp := record.Word(1)
+		if !s.HasTimestamps || timestamp < s.FirstTimestamp {
+			s.FirstTimestamp = timestamp
+		}
+		if !s.HasTimestamps || timestamp > s.LastTimestamp {
+			s.LastTimestamp = timestamp
+		}
+		s.HasTimestamps = true
+	case RecordTypeMetadata:
+		switch metadataType((header >> 16) & 0xF) {
+		case metadataTypeProviderInfo:
+			name := ""
+			if length := int((header >> 52) & 0xFF); len(record.Data) >= 8+length {
+				name = string(record.Data[8 : 8+length])
+			}
+			s.addProvider(uint32(header>>20), name)
+		case metadataTypeProviderSection:
+			s.addProvider(uint32(header>>20), "")
+		}
+	}
+}
+
+// addProvider adds a provider to the list, or sets the name of one that's already in it
+func (s *Stats) addProvider(id uint32, name string) {
+	for i := range s.Providers {
+		if s.Providers[i].Id == id {
+			if name != "" {
+				s.Providers[i].Name = name
+			}
+			return
+		}
+	}
+	s.Providers = append(s.Providers, Provider{Id: id, Name: name})
+}
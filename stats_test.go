@@ -0,0 +1,58 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderStats(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddTickRateRecord(fxt.TicksMicroseconds))
+	require.NoError(t, writer.AddProviderInfoRecord(3, "provider"))
+	require.NoError(t, writer.AddInstantEvent("category", "event", 1, 2, 500))
+	require.NoError(t, writer.AddProviderSectionRecord(4))
+	require.NoError(t, writer.AddDurationCompleteEvent("category", "complete", 1, 3, 200, 2_000_200))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, int64(8), reader.Stats().Bytes)
+
+	for {
+		_, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	stats := reader.Stats()
+	require.Equal(t, int64(buffer.Len()), stats.Bytes)
+	// The instant event is a header and a timestamp, and the complete event also has an end timestamp
+	require.Equal(t, fxt.RecordTypeStats{Count: 2, Bytes: (2 + 3) * 8}, stats.RecordTypes[fxt.RecordTypeEvent])
+	require.Equal(t, 1, stats.RecordTypes[fxt.RecordTypeInitialization].Count)
+	total := 0
+	for _, typeStats := range stats.RecordTypes {
+		total += typeStats.Count
+	}
+	require.Equal(t, stats.Records, total)
+
+	// The end timestamp of the complete event isn't counted, only the timestamp of each record
+	require.True(t, stats.HasTimestamps)
+	require.Equal(t, uint64(200), stats.FirstTimestamp)
+	require.Equal(t, uint64(500), stats.LastTimestamp)
+	require.Equal(t, 300*time.Microsecond, stats.Duration())
+
+	require.Equal(t, []fxt.Provider{{Id: 3, Name: "provider"}, {Id: 4}}, stats.Providers)
+	require.Equal(t, 3, stats.Strings)
+	require.Equal(t, 2, stats.Threads)
+}
@@ -0,0 +1,71 @@
+package fxt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SummaryBlobName is the name of the blob record written by WithCloseSummary
+const SummaryBlobName = "fxt.summary"
+
+// TraceSummary is the JSON payload of the blob record written by WithCloseSummary
+type TraceSummary struct {
+	// DurationNanoseconds is the wall clock time between the trace being started, by creating the Writer or
+	// Reset, and Close
+	DurationNanoseconds uint64 `json:"duration_ns"`
+	// Bytes is the size of the trace before the summary. With WithSizeRotation, it's the size of all the files
+	// of the capture, including the records repeated at the start of each one
+	Bytes uint64 `json:"bytes"`
+	// DroppedEvents is the number of events the Writer's ThreadWriters dropped because of their DropPolicy,
+	// since the Writer was created
+	DroppedEvents uint64 `json:"dropped_events"`
+}
+
+// WithCloseSummary makes Close end the trace with a blob record of BlobTypeData named SummaryBlobName, holding
+// a TraceSummary as JSON, so a capture records how long it ran for and whether events were lost
+func WithCloseSummary() WriterOption {
+	return func(w *Writer) {
+		w.closeSummary = true
+	}
+}
+
+// addSummary writes the summary blob record. The Writer's lock must be held, and the Writer flushed
+func (w *Writer) addSummary() error {
+	summary := TraceSummary{
+		DurationNanoseconds: uint64(time.Since(w.traceStart)),
+		Bytes:               w.counter.bytes,
+		DroppedEvents:       w.droppedEvents,
+	}
+	for _, t := range w.threadWriters {
+		summary.DroppedEvents += t.Dropped()
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode the trace summary - %w", err)
+	}
+	return w.addBlobRecord(SummaryBlobName, data, BlobTypeData)
+}
+
+// countingWriter counts the bytes written to the trace. It's below the pipeline, so it sees every record,
+// however it was written
+type countingWriter struct {
+	out   io.Writer
+	bytes uint64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.out.Write(data)
+	c.bytes += uint64(n)
+	return n, err
+}
+
+// countedOutput wraps the destination in a countingWriter, if the Writer writes a summary
+func (w *Writer) countedOutput() {
+	w.counter = &countingWriter{out: w.out}
+	if w.closeSummary {
+		w.out = w.counter
+	}
+}
@@ -0,0 +1,104 @@
+package fxt_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseSummary(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithCloseSummary(), fxt.WithFlushPolicy(fxt.FlushPolicy{}))
+	require.NoError(t, err)
+
+	threadWriter := writer.NewThreadWriter(1, 3, fxt.WithBufferSize(64), fxt.WithDropPolicy(fxt.DropPolicyNewest))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, threadWriter.AddInstantEvent("category", "buffered", uint64(i)))
+	}
+	require.NotZero(t, threadWriter.Dropped())
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+
+	require.NoError(t, writer.Close())
+	size := buffer.Len()
+
+	// Closing again doesn't write anything more
+	require.NoError(t, writer.Close())
+	require.Equal(t, size, buffer.Len())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var summaries []fxt.TraceSummary
+	var summaryOffset int64
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok && reader.ResolveString(blob.Name) == fxt.SummaryBlobName {
+			var summary fxt.TraceSummary
+			require.NoError(t, json.Unmarshal(blob.Data, &summary))
+			summaries = append(summaries, summary)
+			summaryOffset = record.Offset
+		}
+	}
+
+	require.Len(t, summaries, 1)
+	require.Equal(t, threadWriter.Dropped(), summaries[0].DroppedEvents)
+	require.NotZero(t, summaries[0].DurationNanoseconds)
+	// The summary is written last, after everything else, but its name may need a string record first
+	require.LessOrEqual(t, summaries[0].Bytes, uint64(summaryOffset))
+	require.Greater(t, summaries[0].Bytes, uint64(0))
+}
+
+func TestCloseTwice(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := fxt.NewWriter(filepath.Join(dir, "first.fxt"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, writer.Close())
+
+	// Reset starts a new trace, which can be closed again
+	require.NoError(t, writer.Reset(filepath.Join(dir, "second.fxt")))
+	require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+	require.NoError(t, writer.Close())
+	require.NoError(t, writer.Close())
+}
+
+func TestCloseSummaryWithPipeline(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithCloseSummary(), fxt.WithPipeline(2))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, uint64(i)))
+	}
+	require.NoError(t, writer.Close())
+
+	// The summary is the last record. Its name is written first, in a string record
+	var summary fxt.TraceSummary
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok {
+			require.NoError(t, json.Unmarshal(blob.Data, &summary))
+		}
+	}
+	require.Greater(t, summary.Bytes, uint64(100*8))
+	require.Less(t, summary.Bytes, uint64(buffer.Len()))
+}
@@ -0,0 +1,336 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+)
+
+// Stacks and the symbols of the addresses in them are written to the trace in blob records, so a trace can be
+// symbolized without the binary that produced it, by any tool that reads the blobs. Integers are uvarints or
+// varints, as encoded by encoding/binary, unless noted otherwise
+//
+// A stack blob, of BlobTypeStack and named StackBlobName, holds one stack:
+//   - the stack's ID, as 8 little endian bytes
+//   - the number of addresses in the stack, innermost first
+//   - the first address, then the difference from the previous address, as a varint, for each of the rest
+//
+// A symbols blob, of BlobTypeSymbols and named SymbolsBlobName, holds a sequence of symbols, each with:
+//   - the address
+//   - the number of frames at the address. There's one for each function inlined at it, innermost first
+//   - for each frame, the length and bytes of its function name, the length and bytes of its file, and its line
+//
+// A trace can have any number of symbols blobs, and each address is described once. The addresses in a stack
+// are return addresses, like those from runtime.Callers, and are looked up as is. Writers using other kinds of
+// addresses, like the instruction pointers in samples from a profiler, should write the symbols of exactly the
+// addresses they write
+const (
+	// BlobTypeStack is a call stack, in a blob record named StackBlobName
+	BlobTypeStack BlobType = 0xF0
+	// BlobTypeSymbols is the symbols of a set of addresses, in a blob record named SymbolsBlobName
+	BlobTypeSymbols BlobType = 0xF1
+)
+
+const (
+	// StackBlobName is the name of the blob records holding the stacks written by AddStack
+	StackBlobName = "fxt.stack"
+	// SymbolsBlobName is the name of the blob records holding the symbols of addresses
+	SymbolsBlobName = "fxt.symbols"
+	// StackArgumentKey is the argument the ID of an event's stack is attached as, by the WithStack methods
+	StackArgumentKey = "stack"
+)
+
+// Frame is a function call at an address
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Symbol describes the code at an address. There's a frame for each function inlined at it, innermost first
+type Symbol struct {
+	Address uint64
+	Frames  []Frame
+}
+
+// AddSymbols writes the symbols of a set of addresses to the trace, in as many blob records of BlobTypeSymbols as
+// they need. This lets tools writing the addresses of other programs, like pointer arguments or stacks sampled
+// by a profiler, make them resolvable with a SymbolTable. Addresses already described in the trace are skipped
+func (w *Writer) AddSymbols(symbols []Symbol) error {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var unwritten []Symbol
+	for _, symbol := range symbols {
+		if _, ok := w.symbolized[symbol.Address]; !ok {
+			unwritten = append(unwritten, symbol)
+		}
+	}
+	return w.writeSymbols(unwritten)
+}
+
+// writeSymbols writes `symbols` in as many blob records as they need. The Writer's lock must be held
+func (w *Writer) writeSymbols(symbols []Symbol) error {
+	var data []byte
+	for _, symbol := range symbols {
+		entry := appendSymbol(nil, symbol)
+		if len(entry) > MaxBlobSize {
+			return fmt.Errorf("symbol of address 0x%x is too large - %d bytes exceeds the maximum of %d", symbol.Address, len(entry), MaxBlobSize)
+		}
+		if len(data)+len(entry) > MaxBlobSize {
+			if err := w.addBlobRecord(SymbolsBlobName, data, BlobTypeSymbols); err != nil {
+				return err
+			}
+			data = nil
+		}
+		data = append(data, entry...)
+		w.symbolized[symbol.Address] = struct{}{}
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	return w.addBlobRecord(SymbolsBlobName, data, BlobTypeSymbols)
+}
+
+// symbolize looks up the frames at a PC in this process
+func symbolize(pc uintptr) Symbol {
+	symbol := Symbol{Address: uint64(pc)}
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := frames.Next()
+		symbol.Frames = append(symbol.Frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			return symbol
+		}
+	}
+}
+
+// stackId hashes the PCs of a stack
+func stackId(stack []uintptr) uint64 {
+	hash := fnv.New64a()
+	var buffer [8]byte
+	for _, pc := range stack {
+		binary.LittleEndian.PutUint64(buffer[:], uint64(pc))
+		hash.Write(buffer[:])
+	}
+	return hash.Sum64()
+}
+
+// appendStack encodes a stack blob
+func appendStack(dst []byte, id uint64, stack []uintptr) []byte {
+	dst = binary.LittleEndian.AppendUint64(dst, id)
+	dst = binary.AppendUvarint(dst, uint64(len(stack)))
+	var previous uint64
+	for i, pc := range stack {
+		if i == 0 {
+			dst = binary.AppendUvarint(dst, uint64(pc))
+		} else {
+			dst = binary.AppendVarint(dst, int64(uint64(pc)-previous))
+		}
+		previous = uint64(pc)
+	}
+	return dst
+}
+
+// appendSymbol encodes a symbol for a symbols blob
+func appendSymbol(dst []byte, symbol Symbol) []byte {
+	dst = binary.AppendUvarint(dst, symbol.Address)
+	dst = binary.AppendUvarint(dst, uint64(len(symbol.Frames)))
+	for _, frame := range symbol.Frames {
+		dst = binary.AppendUvarint(dst, uint64(len(frame.Function)))
+		dst = append(dst, frame.Function...)
+		dst = binary.AppendUvarint(dst, uint64(len(frame.File)))
+		dst = append(dst, frame.File...)
+		dst = binary.AppendUvarint(dst, uint64(frame.Line))
+	}
+	return dst
+}
+
+// ParseStack decodes the payload of a stack blob into the stack's ID and addresses
+func ParseStack(data []byte) (uint64, []uint64, error) {
+	if len(data) < 8 {
+		return 0, nil, errors.New("stack blob is too short for the stack ID")
+	}
+	id := binary.LittleEndian.Uint64(data)
+	d := symbolDecoder{data: data[8:]}
+
+	count, err := d.uvarint("number of addresses")
+	if err != nil {
+		return 0, nil, err
+	}
+	if count > uint64(len(d.data)) {
+		return 0, nil, fmt.Errorf("stack of %d addresses exceeds the blob size", count)
+	}
+	stack := make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if i == 0 {
+			address, err := d.uvarint("address")
+			if err != nil {
+				return 0, nil, err
+			}
+			stack = append(stack, address)
+			continue
+		}
+		delta, n := binary.Varint(d.data)
+		if n <= 0 {
+			return 0, nil, errors.New("invalid address")
+		}
+		d.data = d.data[n:]
+		stack = append(stack, stack[i-1]+uint64(delta))
+	}
+
+	return id, stack, nil
+}
+
+// ParseSymbols decodes the payload of a symbols blob
+func ParseSymbols(data []byte) ([]Symbol, error) {
+	d := symbolDecoder{data: data}
+
+	var symbols []Symbol
+	for len(d.data) > 0 {
+		address, err := d.uvarint("address")
+		if err != nil {
+			return nil, err
+		}
+		count, err := d.uvarint("number of frames")
+		if err != nil {
+			return nil, err
+		}
+		if count > uint64(len(d.data)) {
+			return nil, fmt.Errorf("%d frames at address 0x%x exceed the blob size", count, address)
+		}
+
+		symbol := Symbol{Address: address, Frames: make([]Frame, 0, count)}
+		for i := uint64(0); i < count; i++ {
+			var frame Frame
+			if frame.Function, err = d.string("function"); err != nil {
+				return nil, err
+			}
+			if frame.File, err = d.string("file"); err != nil {
+				return nil, err
+			}
+			line, err := d.uvarint("line")
+			if err != nil {
+				return nil, err
+			}
+			frame.Line = int(line)
+			symbol.Frames = append(symbol.Frames, frame)
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, nil
+}
+
+// symbolDecoder reads the fields of stack and symbols blobs
+type symbolDecoder struct {
+	data []byte
+}
+
+func (d *symbolDecoder) uvarint(what string) (uint64, error) {
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid %s", what)
+	}
+	d.data = d.data[n:]
+	return value, nil
+}
+
+func (d *symbolDecoder) string(what string) (string, error) {
+	length, err := d.uvarint(what + " length")
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(len(d.data)) {
+		return "", fmt.Errorf("%s length %d exceeds the blob size", what, length)
+	}
+	value := string(d.data[:length])
+	d.data = d.data[length:]
+	return value, nil
+}
+
+// SymbolTable maps the addresses in a trace to their symbols, and the IDs of its stacks to their addresses,
+// from the stack and symbols blobs. Reader keeps one up to date as it reads, so arguments can be symbolized
+// with Reader.Symbols
+type SymbolTable struct {
+	symbols map[uint64][]Frame
+	stacks  map[uint64][]uint64
+}
+
+// NewSymbolTable creates an empty SymbolTable
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		symbols: map[uint64][]Frame{},
+		stacks:  map[uint64][]uint64{},
+	}
+}
+
+// AddBlob adds the stack or symbols in the payload of a blob of BlobTypeStack or BlobTypeSymbols. Other blob
+// types are ignored
+func (t *SymbolTable) AddBlob(blobType BlobType, data []byte) error {
+	switch blobType {
+	case BlobTypeStack:
+		id, stack, err := ParseStack(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse stack - %w", err)
+		}
+		t.stacks[id] = stack
+	case BlobTypeSymbols:
+		symbols, err := ParseSymbols(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse symbols - %w", err)
+		}
+		for _, symbol := range symbols {
+			t.symbols[symbol.Address] = symbol.Frames
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the frames at `address`
+func (t *SymbolTable) Lookup(address uint64) ([]Frame, bool) {
+	frames, ok := t.symbols[address]
+	return frames, ok
+}
+
+// Stack returns the symbols of the addresses in the stack with ID `id`, innermost first. Addresses without
+// symbols have no frames
+func (t *SymbolTable) Stack(id uint64) ([]Symbol, bool) {
+	stack, ok := t.stacks[id]
+	if !ok {
+		return nil, false
+	}
+
+	symbols := make([]Symbol, 0, len(stack))
+	for _, address := range stack {
+		symbols = append(symbols, Symbol{Address: address, Frames: t.symbols[address]})
+	}
+	return symbols, true
+}
+
+// SymbolizeArgument returns the symbols an event argument refers to: the symbol of a pointer argument's
+// address, or the symbols of the stack of a StackArgumentKey argument. Other arguments have no symbols
+func (t *SymbolTable) SymbolizeArgument(argument ResolvedArgument) ([]Symbol, bool) {
+	switch value := argument.Value.(type) {
+	case uintptr:
+		frames, ok := t.Lookup(uint64(value))
+		if !ok {
+			return nil, false
+		}
+		return []Symbol{{Address: uint64(value), Frames: frames}}, true
+	case uint64:
+		if argument.Key != StackArgumentKey {
+			return nil, false
+		}
+		return t.Stack(value)
+	}
+	return nil, false
+}
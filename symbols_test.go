@@ -0,0 +1,91 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolizeArguments(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	_, file, line, _ := runtime.Caller(0)
+	require.NoError(t, writer.AddInstantEventWithStack("category", "stack", 1, 2, 100, nil))
+
+	// Addresses from elsewhere can be described too
+	require.NoError(t, writer.AddSymbols([]fxt.Symbol{{Address: 0x1234, Frames: []fxt.Frame{{Function: "kernel.read", File: "fs.c", Line: 7}}}}))
+	require.NoError(t, writer.AddInstantEventWithArgs("category", "pointer", 1, 2, 200, map[string]interface{}{"ip": uintptr(0x1234), "other": uintptr(0x5678)}))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	symbolized := map[string][]fxt.Symbol{}
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		for _, argument := range event.Arguments {
+			if symbols, ok := reader.Symbols().SymbolizeArgument(argument); ok {
+				symbolized[argument.Key] = symbols
+			}
+		}
+	}
+
+	// The stack starts at the code that wrote the event
+	stack := symbolized[fxt.StackArgumentKey]
+	require.NotEmpty(t, stack)
+	require.Equal(t, fxt.Frame{Function: "github.com/richiesams/fxt_test.TestSymbolizeArguments", File: file, Line: line + 1}, stack[0].Frames[0])
+	for _, symbol := range stack {
+		require.NotEmpty(t, symbol.Frames)
+	}
+
+	require.Equal(t, []fxt.Symbol{{Address: 0x1234, Frames: []fxt.Frame{{Function: "kernel.read", File: "fs.c", Line: 7}}}}, symbolized["ip"])
+	require.NotContains(t, symbolized, "other")
+}
+
+func TestParseSymbols(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	symbols := []fxt.Symbol{
+		{Address: 0x10, Frames: []fxt.Frame{{Function: "inlined", File: "a.go", Line: 1}, {Function: "outer", File: "a.go", Line: 9}}},
+		{Address: 0x20, Frames: []fxt.Frame{}},
+	}
+	require.NoError(t, writer.AddSymbols(symbols))
+	require.NoError(t, writer.Close())
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	var data []byte
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		if blob, ok := decoded.(*fxt.BlobRecord); ok && blob.Type == fxt.BlobTypeSymbols {
+			data = blob.Data
+		}
+	}
+
+	parsed, err := fxt.ParseSymbols(data)
+	require.NoError(t, err)
+	require.Equal(t, symbols, parsed)
+
+	_, err = fxt.ParseSymbols(data[:len(data)-1])
+	require.Error(t, err)
+	_, _, err = fxt.ParseStack([]byte{1, 2, 3})
+	require.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package fxt
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+)
+
+// maxStringsPerRecord is the most strings a single record can refer to: a category, a name, and a key
+// and string value for each argument
+const maxStringsPerRecord = 2 + 2*maxNumArgs
+
+// TableLimits caps the memory the Writer's string and thread tables can use
+//
+// The Writer keeps every string and thread it has added to the trace's tables, so it can refer to them
+// by index. A service that traces unbounded dynamic strings, like request paths, would otherwise grow
+// the tables until the format's limits of 32767 strings and 255 threads. Once a limit is reached, new
+// strings and threads are written inline in each record that uses them instead. This makes the trace
+// bigger, but the memory used by the Writer stays fixed
+//
+// Zero fields have no limit, other than the format's
+type TableLimits struct {
+	// Strings is the most strings the string table holds
+	Strings int
+	// StringBytes is the most bytes of strings the string table holds
+	StringBytes int
+	// Threads is the most threads the thread table holds
+	Threads int
+
+	// Recycle makes the Writer reuse the indices of the least recently used strings once the string table
+	// is full, rather than writing new strings inline. Each reused index is bound to its new string by
+	// writing another string record, so this suits traces where the same few strings are used repeatedly,
+	// but change over time
+	//
+	// Events buffered by ThreadWriters are written to the trace before an index is reused, so readers
+	// resolve them with the string they were added with
+	Recycle bool
+}
+
+// WithTableLimits caps the memory used by the string and thread tables
+// This also makes strings and threads that don't fit in the format's limits be written inline, rather
+// than failing with an error
+func WithTableLimits(limits TableLimits) WriterOption {
+	return func(w *Writer) {
+		w.tableLimits = limits
+		w.hasTableLimits = true
+	}
+}
+
+// EmitTableSnapshot writes a string record for every entry of the string table, and a thread record
+// for every entry of the thread table
+//
+// Consumers that start reading a live trace part way through, like a client connecting to a socket
+// the trace is streamed to, or a reader of one of a set of rotated files, never see the records that
+// first defined the entries. Once they've seen a snapshot, they can resolve all the references after it
+func (w *Writer) EmitTableSnapshot() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.emitTableSnapshot()
+}
+
+// emitTableSnapshot writes the table snapshot. The Writer's lock must be held
+func (w *Writer) emitTableSnapshot() error {
+	strings := make([]StringRecord, 0, len(w.stringTable))
+	for value, index := range w.stringTable {
+		strings = append(strings, StringRecord{Index: index, Value: value})
+	}
+	sort.Slice(strings, func(i, j int) bool {
+		return strings[i].Index < strings[j].Index
+	})
+	for _, record := range strings {
+		if err := w.writeRecord(record.appendRecord(w.scratch[:0])); err != nil {
+			return fmt.Errorf("failed to write string record for `%s` - %w", record.Value, err)
+		}
+	}
+
+	threads := make([]ThreadRecord, 0, len(w.threadTable))
+	for thread, index := range w.threadTable {
+		threads = append(threads, ThreadRecord{Index: uint8(index), ProcessId: thread.ProcessId, ThreadId: thread.ThreadId})
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		return threads[i].Index < threads[j].Index
+	})
+	for _, record := range threads {
+		if err := w.writeRecord(record.appendRecord(w.scratch[:0])); err != nil {
+			return fmt.Errorf("failed to write thread record - %w", err)
+		}
+	}
+
+	return nil
+}
+
+// stringEntry is an entry of the string table, in the order the entries were last used
+type stringEntry struct {
+	index   uint16
+	value   string
+	lastUse uint64
+}
+
+// stringsFull reports whether a string of `length` bytes can't be added to the string table without
+// going over the table limits
+func (w *Writer) stringsFull(length int) bool {
+	if w.nextStringIndex > maxStringIndex && len(w.freeStringIndices) == 0 {
+		return true
+	}
+	return w.tableLimits.stringsExceeded(len(w.stringTable), w.stringBytes+length)
+}
+
+// nextFreeStringIndex returns the index for the next string added to the string table
+func (w *Writer) nextFreeStringIndex() uint16 {
+	if n := len(w.freeStringIndices); n > 0 {
+		return w.freeStringIndices[n-1]
+	}
+	return w.nextStringIndex
+}
+
+// useString records that string table entry `index` was used, so it's the last to be recycled
+func (w *Writer) useString(index uint16, value string) {
+	if !w.tableLimits.Recycle {
+		return
+	}
+
+	w.stringUses++
+	if element, ok := w.stringEntries[index]; ok {
+		element.Value.(*stringEntry).lastUse = w.stringUses
+		w.stringLRU.MoveToFront(element)
+		return
+	}
+	w.stringEntries[index] = w.stringLRU.PushFront(&stringEntry{index: index, value: value, lastUse: w.stringUses})
+}
+
+// recycleStrings removes the least recently used strings from the string table, until a string of `length`
+// bytes fits. It returns false if that isn't possible without removing a string the record being written
+// may already refer to
+//
+// The events buffered by the ThreadWriters are written first, since they may refer to the removed strings,
+// and the ThreadWriters drop their cached indices
+func (w *Writer) recycleStrings(length int) (bool, error) {
+	if !w.tableLimits.Recycle || (w.tableLimits.StringBytes > 0 && length > w.tableLimits.StringBytes) {
+		return false, nil
+	}
+
+	flushed := false
+	for w.stringsFull(length) {
+		element := w.stringLRU.Back()
+		if element == nil {
+			return false, nil
+		}
+		entry := element.Value.(*stringEntry)
+		if entry.lastUse+maxStringsPerRecord > w.stringUses {
+			return false, nil
+		}
+
+		if !flushed && len(w.threadWriters) > 0 {
+			// The generation changes first, so that no more events using the old index can be buffered
+			w.generation.Add(1)
+			if err := w.flushThreadWriters(); err != nil {
+				return false, fmt.Errorf("failed to write buffered events before recycling string indices - %w", err)
+			}
+			flushed = true
+		}
+
+		w.stringLRU.Remove(element)
+		delete(w.stringEntries, entry.index)
+		delete(w.stringTable, entry.value)
+		w.stringBytes -= len(entry.value)
+		w.freeStringIndices = append(w.freeStringIndices, entry.index)
+	}
+
+	return true, nil
+}
+
+// resetStringEntries clears the recycling state of the string table
+func (w *Writer) resetStringEntries() {
+	w.stringLRU = list.New()
+	w.stringEntries = map[uint16]*list.Element{}
+	w.freeStringIndices = nil
+	w.stringUses = 0
+}
+
+// stringsExceeded reports whether a string table with `count` strings, and `bytes` bytes of them after
+// adding the next one, is over the limits
+func (l TableLimits) stringsExceeded(count int, bytes int) bool {
+	return (l.Strings > 0 && count >= l.Strings) || (l.StringBytes > 0 && bytes > l.StringBytes)
+}
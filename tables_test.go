@@ -0,0 +1,185 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableLimits(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithTableLimits(fxt.TableLimits{Strings: 3, StringBytes: 100, Threads: 1}))
+	require.NoError(t, err)
+
+	// The category and name fill two of the strings, and the first thread fills the thread table
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	threadWriter := writer.NewThreadWriter(1, 3)
+	for i := 0; i < 3; i++ {
+		path := fmt.Sprintf("/request/%d", i)
+		require.NoError(t, writer.AddInstantEventWithArgs("category", path, 1, 2, 200, map[string]interface{}{"path": path}))
+		require.NoError(t, threadWriter.AddInstantEvent("category", path, 300))
+	}
+	require.NoError(t, writer.Close())
+
+	var strings, threads int
+	var names []fxt.StringRef
+	var inlineThreads int
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		switch v := record.(type) {
+		case *fxt.StringRecord:
+			strings++
+		case *fxt.ThreadRecord:
+			threads++
+		case *fxt.InstantEvent:
+			names = append(names, v.Name)
+			if v.Thread.Index == 0 {
+				inlineThreads++
+			}
+		}
+	}
+	require.Equal(t, 3, strings)
+	require.Equal(t, 1, threads)
+	require.Equal(t, 3, inlineThreads)
+
+	// "/request/0" is the last string that fits, the rest are written inline. The ThreadWriter's events come last
+	require.Equal(t, fxt.StringRef{Index: 3}, names[1])
+	require.Equal(t, fxt.StringRef{Inline: "/request/1"}, names[2])
+	require.Equal(t, fxt.StringRef{Inline: "/request/2"}, names[3])
+	require.Equal(t, fxt.StringRef{Inline: "/request/2"}, names[6])
+}
+
+func TestTableLimitsStringBytes(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithTableLimits(fxt.TableLimits{StringBytes: 12}))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddInstantEvent("category", "long name", 1, 2, 100))
+	require.NoError(t, writer.AddInstantEvent("category", "name", 1, 2, 100))
+	require.NoError(t, writer.Close())
+
+	records := readRecords(t, buffer.Bytes())
+	require.Equal(t, fxt.StringRef{Inline: "long name"}, records[2].(*fxt.InstantEvent).Name)
+	require.Equal(t, fxt.StringRef{Index: 2}, records[4].(*fxt.InstantEvent).Name)
+}
+
+// readResolvedNames reads a trace, and returns the names of its instant events, resolved with the string
+// table as it was when each event was read
+func readResolvedNames(t *testing.T, trace []byte) []string {
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+
+	var names []string
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		event, ok := decoded.(*fxt.InstantEvent)
+		if !ok {
+			continue
+		}
+
+		name := event.Name.Inline
+		if event.Name.Index != 0 {
+			var ok bool
+			name, ok = reader.LookupString(event.Name.Index)
+			require.True(t, ok)
+		}
+		names = append(names, name)
+	}
+}
+
+func TestTableLimitsRecycle(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithTableLimits(fxt.TableLimits{Strings: 40, Recycle: true}))
+	require.NoError(t, err)
+
+	// The ThreadWriter's event is buffered, and its name is recycled before it's written
+	threadWriter := writer.NewThreadWriter(1, 3)
+	require.NoError(t, threadWriter.AddInstantEvent("category", "buffered", 50))
+
+	var expected []string
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("name-%d", i)
+		require.NoError(t, writer.AddInstantEvent("category", name, 1, 2, 100))
+		expected = append(expected, name)
+	}
+	require.NoError(t, threadWriter.AddInstantEvent("category", "buffered", 200))
+	require.NoError(t, writer.Close())
+
+	// The table fills up with "category", "buffered", and the first 38 names. The buffered event is written
+	// before the first index is reused
+	expected = append(expected[:38], append([]string{"buffered"}, expected[38:]...)...)
+	expected = append(expected, "buffered")
+	require.Equal(t, expected, readResolvedNames(t, buffer.Bytes()))
+
+	// None of the strings were written inline, and the indices stayed within the limit
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		switch v := record.(type) {
+		case *fxt.StringRecord:
+			require.LessOrEqual(t, v.Index, uint16(40))
+		case *fxt.InstantEvent:
+			require.NotZero(t, v.Name.Index)
+		}
+	}
+}
+
+func TestTableLimitsRecycleKeepsRecentStrings(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithTableLimits(fxt.TableLimits{Strings: 8, Recycle: true}))
+	require.NoError(t, err)
+
+	// The strings of this event were all just used, so they can't be recycled for each other
+	arguments := map[string]interface{}{}
+	for i := 0; i < 10; i++ {
+		arguments[fmt.Sprintf("key-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+	require.NoError(t, writer.AddInstantEventWithArgs("category", "name", 1, 2, 100, arguments))
+	require.NoError(t, writer.Close())
+
+	records := readRecords(t, buffer.Bytes())
+	event := records[len(records)-1].(*fxt.InstantEvent)
+	indices := map[uint16]bool{}
+	for _, argument := range event.Arguments {
+		if argument.Key.Index != 0 {
+			require.False(t, indices[argument.Key.Index])
+			indices[argument.Key.Index] = true
+		}
+	}
+	require.Equal(t, fxt.StringRef{Inline: "key-9"}, event.Arguments[9].Key)
+}
+
+func TestEmitTableSnapshot(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.AddInstantEvent("category", "before", 1, 2, 100))
+	require.NoError(t, writer.AddInstantEvent("category", "before", 3, 4, 100))
+
+	// A consumer that starts reading after the first events only sees the snapshot
+	start := buffer.Len()
+	require.NoError(t, writer.EmitTableSnapshot())
+	require.NoError(t, writer.AddInstantEvent("category", "before", 3, 4, 200))
+	require.NoError(t, writer.Close())
+
+	magic, err := fxt.MagicNumberRecord(fxt.LatestFormatVersion)
+	require.NoError(t, err)
+	records := readRecords(t, append(magic, buffer.Bytes()[start:]...))
+	require.Equal(t, []interface{}{
+		&fxt.StringRecord{Index: 1, Value: "category"},
+		&fxt.StringRecord{Index: 2, Value: "before"},
+		&fxt.ThreadRecord{Index: 1, ProcessId: 1, ThreadId: 2},
+		&fxt.ThreadRecord{Index: 2, ProcessId: 3, ThreadId: 4},
+	}, records[:4])
+	require.Equal(t, []string{"before"}, readResolvedNames(t, append(magic, buffer.Bytes()[start:]...)))
+}
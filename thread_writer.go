@@ -0,0 +1,623 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultThreadWriterBufferSize is how many bytes a ThreadWriter buffers before it writes them to the trace
+const defaultThreadWriterBufferSize = 64 * 1024
+
+// errStaleTables means the Writer's tables were cleared by Reset while an event was being encoded,
+// so the event has to be encoded again with the new indices
+var errStaleTables = errors.New("the string and thread tables were reset")
+
+// ThreadWriterOption configures a ThreadWriter
+type ThreadWriterOption func(*ThreadWriter)
+
+// WithProvider makes the ThreadWriter's events part of the provider section of `providerId`
+// When the buffered events are written, they're preceded by a provider section record if needed
+func WithProvider(providerId uint32) ThreadWriterOption {
+	return func(t *ThreadWriter) {
+		t.providerId = providerId
+		t.hasProvider = true
+	}
+}
+
+// WithBufferSize sets how many bytes of events a ThreadWriter buffers before it writes them to the trace
+// It defaults to 64 KiB
+func WithBufferSize(size int) ThreadWriterOption {
+	return func(t *ThreadWriter) {
+		t.bufferSize = size
+	}
+}
+
+// DropPolicy is what a ThreadWriter does when its buffer is full
+type DropPolicy int
+
+const (
+	// DropPolicyNone writes the buffer to the trace when it's full, which takes the Writer's lock
+	DropPolicyNone DropPolicy = iota
+	// DropPolicyNewest drops the event that doesn't fit in the buffer
+	DropPolicyNewest
+	// DropPolicyOldest drops the oldest events in the buffer, to make room for the new one
+	DropPolicyOldest
+)
+
+// WithDropPolicy makes the ThreadWriter drop events when its buffer is full, rather than writing it to the trace,
+// so adding an event never waits for the Writer's lock once the event's strings have been added to the
+// string table. The buffer is only written by Writer.Flush, or ThreadWriter.Flush, so this is usually
+// combined with a FlushPolicy.Interval
+//
+// Like a trace provider, the ThreadWriter writes a provider event record of ProviderEventTypeBufferFilledUp
+// before its buffered events if it had to drop any. The record is for the provider set by WithProvider,
+// or provider 0 if there isn't one
+func WithDropPolicy(policy DropPolicy) ThreadWriterOption {
+	return func(t *ThreadWriter) {
+		t.dropPolicy = policy
+	}
+}
+
+// ThreadWriter writes the events of a single thread to its own buffer, for tracing at very high event rates
+//
+// Events are encoded and buffered without taking the Writer's lock, so ThreadWriters on different goroutines
+// don't contend with each other. The lock is only taken when a string is seen for the first time, and when
+// the buffer is written to the trace. That happens when it fills up, and on Writer.Flush, Writer.Close,
+// and Writer.Reset, which write the buffers of all the Writer's ThreadWriters, grouped by provider
+//
+// The events of a ThreadWriter are kept in order, but they aren't interleaved with the events written
+// by other ThreadWriters, or directly with the Writer. Trace viewers sort events by timestamp, so this
+// doesn't change how the trace is displayed
+//
+// A ThreadWriter must only be used by one goroutine at a time. Timestamp checks only compare the events of
+// the ThreadWriter with each other
+type ThreadWriter struct {
+	writer      *Writer
+	thread      Thread
+	providerId  uint32
+	hasProvider bool
+	bufferSize  int
+	dropPolicy  DropPolicy
+	// dropped is the number of events dropped because the buffer was full
+	dropped atomic.Uint64
+
+	// The references to the Writer's tables, as of `generation`
+	generation   uint64
+	stringRefs   map[string]StringRef
+	threadRef    ThreadRef
+	hasThreadRef bool
+
+	timestampOffset    uint64
+	hasTimestampOffset bool
+	lastTimestamp      uint64
+	hasLastTimestamp   bool
+	argumentScratch    argumentScratch
+
+	// mu guards the buffer, which is written to the trace by other goroutines on Flush
+	// Lock order: the Writer's lock is always taken before this one
+	mu     sync.Mutex
+	buffer []byte
+	closed bool
+	// filledUp is set when events have been dropped since the buffer was last written
+	filledUp bool
+	// With WithCloseOpenSpans, WithSpanCheck, or WithFlowCheck, the duration begin events that haven't
+	// been ended, and the latest timestamp written
+	openSpans       []openDuration
+	latestTimestamp uint64
+	// records is the number of events that have been buffered, and bufferStart the number as of the oldest
+	// one still in the buffer, so the open spans whose begin events are dropped by DropPolicyOldest are known
+	records     uint64
+	bufferStart uint64
+	// lastBuffered is set if the last event was buffered, rather than dropped by the DropPolicy
+	lastBuffered bool
+}
+
+// NewThreadWriter creates a ThreadWriter for the events of thread `threadId` in process `processId`
+//
+// Its buffered events are written to the trace by Writer.Flush, or when the buffer is full. Call Close
+// once the thread is finished with, to write its remaining events and detach it from the Writer
+//
+// With WithGoroutineThreads, `threadId` can be CurrentGoroutine to write the events on the calling
+// goroutine's thread. The goroutine ID is looked up once, so the ThreadWriter must only be used by that goroutine
+func (w *Writer) NewThreadWriter(processId KernelObjectID, threadId KernelObjectID, options ...ThreadWriterOption) *ThreadWriter {
+	t := &ThreadWriter{
+		writer:     w,
+		bufferSize: defaultThreadWriterBufferSize,
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if resolved, err := w.goroutineThread(processId, threadId, goroutineId); err != nil {
+		w.warn(err)
+	} else {
+		threadId = resolved
+	}
+	t.thread = Thread{ProcessId: processId, ThreadId: threadId}
+	w.threadWriters = append(w.threadWriters, t)
+
+	return t
+}
+
+// Flush writes the ThreadWriter's buffered events to the trace
+func (t *ThreadWriter) Flush() error {
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	return t.writer.flushThreadWriter(t)
+}
+
+// Dropped returns the number of events the ThreadWriter has dropped because of its DropPolicy
+func (t *ThreadWriter) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Close writes the ThreadWriter's buffered events to the trace, and detaches it from the Writer
+// Events written after Close return an error
+func (t *ThreadWriter) Close() error {
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	err := t.writer.flushThreadWriter(t)
+	if err == nil {
+		err = t.endOpenSpans()
+	}
+
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	for i, threadWriter := range t.writer.threadWriters {
+		if threadWriter == t {
+			t.writer.threadWriters = append(t.writer.threadWriters[:i], t.writer.threadWriters[i+1:]...)
+			t.writer.droppedEvents += t.Dropped()
+			break
+		}
+	}
+
+	return err
+}
+
+// flushThreadWriters writes the buffers of all the ThreadWriters to the trace. The ThreadWriters
+// of each provider are written together, so each provider only needs one provider section record
+//
+// The Writer's lock must be held
+func (w *Writer) flushThreadWriters() error {
+	threadWriters := append([]*ThreadWriter(nil), w.threadWriters...)
+	sort.SliceStable(threadWriters, func(i, j int) bool {
+		a, b := threadWriters[i], threadWriters[j]
+		if a.hasProvider != b.hasProvider {
+			return !a.hasProvider
+		}
+		return a.providerId < b.providerId
+	})
+
+	for _, t := range threadWriters {
+		if err := w.flushThreadWriter(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushThreadWriter writes the buffer of `t` to the trace. The Writer's lock must be held
+func (w *Writer) flushThreadWriter(t *ThreadWriter) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.buffer) == 0 && !t.filledUp {
+		return nil
+	}
+	if t.hasProvider && (!w.hasProviderSection || w.providerSection != t.providerId) {
+		if err := w.addProviderSectionRecord(t.providerId); err != nil {
+			return err
+		}
+	}
+	if t.filledUp {
+		if err := w.writeRecord(ProviderEventRecord{ProviderId: t.providerId, EventType: ProviderEventTypeBufferFilledUp}.appendRecord(w.scratch[:0])); err != nil {
+			return err
+		}
+		t.filledUp = false
+	}
+
+	if err := w.write(t.buffer, "buffered events"); err != nil {
+		return err
+	}
+	t.buffer = t.buffer[:0]
+	t.bufferStart = t.records
+
+	return nil
+}
+
+// syncTables drops the cached table indices if the Writer's tables have been cleared since they were cached
+func (t *ThreadWriter) syncTables() {
+	generation := t.writer.generation.Load()
+	if generation == t.generation && t.stringRefs != nil {
+		return
+	}
+
+	t.generation = generation
+	t.stringRefs = map[string]StringRef{}
+	t.hasThreadRef = false
+	t.hasTimestampOffset = false
+}
+
+func (t *ThreadWriter) getOrCreateStringRef(str string) (StringRef, error) {
+	if ref, ok := t.stringRefs[str]; ok {
+		return ref, nil
+	}
+
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	if t.writer.generation.Load() != t.generation {
+		return StringRef{}, errStaleTables
+	}
+	ref, err := t.writer.getOrCreateStringRef(str)
+	if err != nil {
+		return StringRef{}, err
+	}
+	// Inline strings aren't cached, so a ThreadWriter can't hold on to more strings than the table limits allow
+	if ref.Index != 0 {
+		t.stringRefs[str] = ref
+	}
+
+	return ref, nil
+}
+
+func (t *ThreadWriter) getThreadRef() (ThreadRef, error) {
+	if t.hasThreadRef {
+		return t.threadRef, nil
+	}
+
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	if t.writer.generation.Load() != t.generation {
+		return ThreadRef{}, errStaleTables
+	}
+	ref, err := t.writer.getOrCreateThreadRef(t.thread.ProcessId, t.thread.ThreadId)
+	if err != nil {
+		return ThreadRef{}, err
+	}
+	t.threadRef = ref
+	t.hasThreadRef = true
+
+	return ref, nil
+}
+
+func (t *ThreadWriter) handleLongString(key string, value string) (interface{}, error) {
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	return t.writer.handleLongString(key, value)
+}
+
+// normalizeTimestamp is the same as Writer.normalizeTimestamp, but it only takes the Writer's lock
+// until the offset is known
+func (t *ThreadWriter) normalizeTimestamp(timestamp uint64) uint64 {
+	if !t.writer.normalizeTimestamps {
+		return timestamp
+	}
+
+	if !t.hasTimestampOffset {
+		t.writer.mu.Lock()
+		defer t.writer.mu.Unlock()
+
+		timestamp = t.writer.normalizeTimestamp(timestamp)
+		t.timestampOffset = t.writer.timestampOffset
+		t.hasTimestampOffset = t.writer.generation.Load() == t.generation
+		return timestamp
+	}
+
+	if timestamp < t.timestampOffset {
+		return 0
+	}
+	return timestamp - t.timestampOffset
+}
+
+// checkTimestamp is the same as Writer.checkTimestamp, but it only compares against the ThreadWriter's events
+func (t *ThreadWriter) checkTimestamp(timestamp uint64) error {
+	if t.writer.timestampCheck == TimestampCheckOff {
+		return nil
+	}
+
+	if t.hasLastTimestamp && timestamp < t.lastTimestamp {
+		err := fmt.Errorf("timestamp %d on thread %d/%d is earlier than the previous timestamp %d", timestamp, t.thread.ProcessId, t.thread.ThreadId, t.lastTimestamp)
+		if t.writer.timestampCheck == TimestampCheckError {
+			return err
+		}
+		t.warn(err)
+	}
+
+	t.lastTimestamp = timestamp
+	t.hasLastTimestamp = true
+	return nil
+}
+
+func (t *ThreadWriter) warn(err error) {
+	t.writer.mu.Lock()
+	defer t.writer.mu.Unlock()
+
+	t.writer.warn(err)
+}
+
+// writeEvent interns the common data of an event, and buffers the record made by `record`
+// If the Writer's tables are cleared part way through, the event is encoded again with the new indices
+//
+// `appendRecord` encodes the event's record onto the end of `dst`
+func (t *ThreadWriter) writeEvent(category string, name string, timestamp uint64, arguments map[string]interface{}, appendRecord func(event EventRecord, dst []byte) ([]byte, error)) error {
+	for {
+		t.syncTables()
+
+		event, err := t.internEventRecord(category, name, timestamp, arguments)
+		if err == nil {
+			err = t.bufferRecord(event, appendRecord)
+		}
+		if !errors.Is(err, errStaleTables) {
+			return err
+		}
+	}
+}
+
+func (t *ThreadWriter) internEventRecord(category string, name string, timestamp uint64, arguments map[string]interface{}) (EventRecord, error) {
+	categoryRef, err := t.getOrCreateStringRef(category)
+	if err != nil {
+		return EventRecord{}, err
+	}
+
+	nameRef, err := t.getOrCreateStringRef(name)
+	if err != nil {
+		return EventRecord{}, err
+	}
+
+	threadRef, err := t.getThreadRef()
+	if err != nil {
+		return EventRecord{}, err
+	}
+
+	args, err := prepareArguments(t, &t.argumentScratch, arguments)
+	if err != nil {
+		return EventRecord{}, err
+	}
+
+	return EventRecord{
+		Category:  categoryRef,
+		Name:      nameRef,
+		Thread:    threadRef,
+		Timestamp: timestamp,
+		Arguments: args,
+	}, nil
+}
+
+// bufferRecord encodes an event's record into the buffer, and writes the buffer to the trace if it's full
+func (t *ThreadWriter) bufferRecord(event EventRecord, appendRecord func(event EventRecord, dst []byte) ([]byte, error)) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return fmt.Errorf("the ThreadWriter is closed")
+	}
+	// Checked with the buffer locked, since Reset writes the buffers after changing the generation
+	if t.writer.generation.Load() != t.generation {
+		t.mu.Unlock()
+		return errStaleTables
+	}
+
+	length := len(t.buffer)
+	data, err := appendRecord(event, t.buffer)
+	if err != nil {
+		t.buffer = t.buffer[:length]
+		t.mu.Unlock()
+		return err
+	}
+	t.records++
+	t.lastBuffered = true
+	if t.dropPolicy != DropPolicyNone && len(data) > t.bufferSize {
+		data = t.drop(data, length)
+	}
+	t.buffer = data
+	full := t.dropPolicy == DropPolicyNone && len(t.buffer) >= t.bufferSize
+	t.mu.Unlock()
+
+	if full {
+		return t.Flush()
+	}
+	return nil
+}
+
+// drop removes events from `data` until it fits in the buffer, following the ThreadWriter's DropPolicy
+// The newest event starts at `newest`. The ThreadWriter's buffer lock must be held
+func (t *ThreadWriter) drop(data []byte, newest int) []byte {
+	t.filledUp = true
+	if t.dropPolicy == DropPolicyNewest {
+		t.dropped.Add(1)
+		t.records--
+		t.lastBuffered = false
+		return data[:newest]
+	}
+
+	// Only event records are buffered, so the size is always in the 12 bits after the type
+	offset, dropped := 0, uint64(0)
+	for len(data)-offset > t.bufferSize {
+		header := binary.LittleEndian.Uint64(data[offset:])
+		offset += int((header>>4)&0xFFF) * 8
+		dropped++
+	}
+	t.dropped.Add(dropped)
+
+	// The spans begun by the dropped events aren't open in the trace
+	start := t.bufferStart
+	t.bufferStart += dropped
+	t.lastBuffered = t.records > t.bufferStart
+	spans := t.openSpans[:0]
+	for _, span := range t.openSpans {
+		if span.record < start || span.record >= t.bufferStart {
+			spans = append(spans, span)
+		}
+	}
+	t.openSpans = spans
+
+	return data[:copy(data, data[offset:])]
+}
+
+// AddInstantEvent is the same as Writer.AddInstantEvent, for the ThreadWriter's thread
+func (t *ThreadWriter) AddInstantEvent(category string, name string, timestamp uint64) error {
+	return t.AddInstantEventWithArgs(category, name, timestamp, nil)
+}
+
+// AddInstantEventWithArgs is the same as Writer.AddInstantEventWithArgs, for the ThreadWriter's thread
+func (t *ThreadWriter) AddInstantEventWithArgs(category string, name string, timestamp uint64, arguments map[string]interface{}) error {
+	if t.writer.disabled.Load() {
+		return nil
+	}
+	category, name, arguments, ok := t.intercept(EventKindInstant, category, name, timestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	timestamp, err := t.prepareTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	return t.writeEvent(category, name, timestamp, arguments, func(event EventRecord, dst []byte) ([]byte, error) {
+		return InstantEvent{EventRecord: event}.appendRecord(dst)
+	})
+}
+
+// AddCounterEvent is the same as Writer.AddCounterEvent, for the ThreadWriter's thread
+func (t *ThreadWriter) AddCounterEvent(category string, name string, timestamp uint64, arguments map[string]interface{}, counterId uint64) error {
+	if t.writer.disabled.Load() {
+		return nil
+	}
+	category, name, arguments, ok := t.intercept(EventKindCounter, category, name, timestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	timestamp, err := t.prepareTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	return t.writeEvent(category, name, timestamp, arguments, func(event EventRecord, dst []byte) ([]byte, error) {
+		return CounterEvent{EventRecord: event, CounterId: counterId}.appendRecord(dst)
+	})
+}
+
+// AddDurationBeginEvent is the same as Writer.AddDurationBeginEvent, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationBeginEvent(category string, name string, timestamp uint64) error {
+	return t.AddDurationBeginEventWithArgs(category, name, timestamp, nil)
+}
+
+// AddDurationBeginEventWithArgs is the same as Writer.AddDurationBeginEventWithArgs, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationBeginEventWithArgs(category string, name string, timestamp uint64, arguments map[string]interface{}) error {
+	if t.writer.disabled.Load() {
+		return nil
+	}
+	category, name, arguments, ok := t.intercept(EventKindDurationBegin, category, name, timestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	timestamp, err := t.prepareTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	err = t.writeEvent(category, name, timestamp, arguments, func(event EventRecord, dst []byte) ([]byte, error) {
+		return DurationBeginEvent{EventRecord: event}.appendRecord(dst)
+	})
+	if err != nil {
+		return err
+	}
+	t.beginSpan(category, name, timestamp)
+	return nil
+}
+
+// AddDurationEndEvent is the same as Writer.AddDurationEndEvent, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationEndEvent(category string, name string, timestamp uint64) error {
+	return t.AddDurationEndEventWithArgs(category, name, timestamp, nil)
+}
+
+// AddDurationEndEventWithArgs is the same as Writer.AddDurationEndEventWithArgs, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationEndEventWithArgs(category string, name string, timestamp uint64, arguments map[string]interface{}) error {
+	if t.writer.disabled.Load() {
+		return nil
+	}
+	category, name, arguments, ok := t.intercept(EventKindDurationEnd, category, name, timestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	timestamp, err := t.prepareTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+
+	err = t.writeEvent(category, name, timestamp, arguments, func(event EventRecord, dst []byte) ([]byte, error) {
+		return DurationEndEvent{EventRecord: event}.appendRecord(dst)
+	})
+	if err != nil {
+		return err
+	}
+	t.endSpan(category, name, timestamp)
+	return nil
+}
+
+// AddDurationCompleteEvent is the same as Writer.AddDurationCompleteEvent, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationCompleteEvent(category string, name string, beginTimestamp uint64, endTimestamp uint64) error {
+	return t.AddDurationCompleteEventWithArgs(category, name, beginTimestamp, endTimestamp, nil)
+}
+
+// AddDurationCompleteEventWithArgs is the same as Writer.AddDurationCompleteEventWithArgs, for the ThreadWriter's thread
+func (t *ThreadWriter) AddDurationCompleteEventWithArgs(category string, name string, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
+	if t.writer.disabled.Load() {
+		return nil
+	}
+	category, name, arguments, ok := t.intercept(EventKindDurationComplete, category, name, beginTimestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	t.syncTables()
+
+	// Complete events are checked against their end timestamp, since they're usually written when the duration ends
+	beginTimestamp = t.normalizeTimestamp(beginTimestamp)
+	endTimestamp, err := t.prepareTimestamp(endTimestamp)
+	if err != nil {
+		return err
+	}
+	if t.writer.timestampCheck != TimestampCheckOff && endTimestamp < beginTimestamp {
+		err := fmt.Errorf("end timestamp %d is earlier than the begin timestamp %d", endTimestamp, beginTimestamp)
+		if t.writer.timestampCheck == TimestampCheckError {
+			return err
+		}
+		t.warn(err)
+	}
+
+	return t.writeEvent(category, name, beginTimestamp, arguments, func(event EventRecord, dst []byte) ([]byte, error) {
+		return DurationCompleteEvent{EventRecord: event, EndTimestamp: endTimestamp}.appendRecord(dst)
+	})
+}
+
+// prepareTimestamp normalizes and checks an event timestamp. It returns the timestamp to write
+func (t *ThreadWriter) prepareTimestamp(timestamp uint64) (uint64, error) {
+	t.syncTables()
+
+	timestamp = t.normalizeTimestamp(timestamp)
+	if err := t.checkTimestamp(timestamp); err != nil {
+		return 0, err
+	}
+	t.updateLatestTimestamp(timestamp)
+
+	return timestamp, nil
+}
@@ -0,0 +1,175 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readRecords reads a trace, and returns the decoded records after checking all their references are defined
+func readRecords(t *testing.T, trace []byte) []interface{} {
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+
+	var records []interface{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+
+		decoded, err := record.Decode()
+		require.NoError(t, err)
+		records = append(records, decoded)
+	}
+}
+
+func TestThreadWriterConcurrent(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	const numThreads = 8
+	const numEvents = 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		threadWriter := writer.NewThreadWriter(1, fxt.KernelObjectID(i+1), fxt.WithBufferSize(512))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numEvents; j++ {
+				require.NoError(t, threadWriter.AddDurationCompleteEventWithArgs("category", "event", uint64(j), uint64(j+1), map[string]interface{}{"index": j}))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, writer.Close())
+
+	// The events of each thread are in order
+	counts := map[uint8]int{}
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		if event, ok := record.(*fxt.DurationCompleteEvent); ok {
+			require.Equal(t, uint64(counts[event.Thread.Index]), event.Timestamp)
+			counts[event.Thread.Index]++
+		}
+	}
+	require.Len(t, counts, numThreads)
+	for _, count := range counts {
+		require.Equal(t, numEvents, count)
+	}
+}
+
+func TestThreadWriterProviders(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	first := writer.NewThreadWriter(1, 1, fxt.WithProvider(2))
+	second := writer.NewThreadWriter(1, 2, fxt.WithProvider(1))
+	third := writer.NewThreadWriter(1, 3, fxt.WithProvider(2))
+	for _, threadWriter := range []*fxt.ThreadWriter{first, second, third} {
+		require.NoError(t, threadWriter.AddInstantEvent("category", "event", 100))
+	}
+
+	// Only the string and thread records are written until the buffers are flushed
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		_, isEvent := record.(*fxt.InstantEvent)
+		require.False(t, isEvent)
+	}
+	require.NoError(t, writer.Flush())
+
+	var order []string
+	for _, record := range readRecords(t, buffer.Bytes()) {
+		switch v := record.(type) {
+		case *fxt.ProviderSectionRecord:
+			order = append(order, "section "+string(rune('0'+v.ProviderId)))
+		case *fxt.InstantEvent:
+			order = append(order, "event")
+		}
+	}
+	require.Equal(t, []string{"section 1", "event", "section 2", "event", "event"}, order)
+
+	// Closed ThreadWriters can't be used
+	require.NoError(t, first.Close())
+	require.Error(t, first.AddInstantEvent("category", "event", 200))
+}
+
+func TestThreadWriterReset(t *testing.T) {
+	var before, after bytes.Buffer
+	writer, err := fxt.NewWriterTo(&before, fxt.WithTimestampCheck(fxt.TimestampCheckError))
+	require.NoError(t, err)
+
+	threadWriter := writer.NewThreadWriter(1, 2)
+	require.NoError(t, threadWriter.AddDurationBeginEvent("category", "before", 100))
+	require.Error(t, threadWriter.AddDurationEndEvent("category", "before", 50))
+
+	// The buffered event is written to the previous trace, and the tables are defined again in the new one
+	require.NoError(t, writer.ResetTo(&after))
+	require.NoError(t, threadWriter.AddDurationEndEvent("category", "before", 200))
+	require.NoError(t, writer.Flush())
+
+	require.IsType(t, &fxt.DurationBeginEvent{}, readRecords(t, before.Bytes())[3])
+	records := readRecords(t, after.Bytes())
+	require.Len(t, records, 4)
+	require.IsType(t, &fxt.DurationEndEvent{}, records[3])
+}
+
+func TestThreadWriterBufferSize(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithTimestampNormalization())
+	require.NoError(t, err)
+
+	// A buffer this small is written after every event
+	threadWriter := writer.NewThreadWriter(1, 2, fxt.WithBufferSize(1))
+	require.NoError(t, threadWriter.AddInstantEvent("category", "event", 1000))
+	require.NoError(t, threadWriter.AddInstantEvent("category", "event", 1500))
+
+	records := readRecords(t, buffer.Bytes())
+	require.Len(t, records, 5)
+	require.Equal(t, uint64(0), records[3].(*fxt.InstantEvent).Timestamp)
+	require.Equal(t, uint64(500), records[4].(*fxt.InstantEvent).Timestamp)
+}
+
+func TestThreadWriterDropPolicy(t *testing.T) {
+	tests := []struct {
+		policy     fxt.DropPolicy
+		timestamps []uint64
+	}{
+		{policy: fxt.DropPolicyNewest, timestamps: []uint64{1, 2, 3}},
+		{policy: fxt.DropPolicyOldest, timestamps: []uint64{3, 4, 5}},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		writer, err := fxt.NewWriterTo(&buffer)
+		require.NoError(t, err)
+
+		// Instant events without arguments are 2 words, so the buffer holds 3 of them
+		threadWriter := writer.NewThreadWriter(1, 2, fxt.WithProvider(7), fxt.WithBufferSize(3*16), fxt.WithDropPolicy(test.policy))
+		for timestamp := uint64(1); timestamp <= 5; timestamp++ {
+			require.NoError(t, threadWriter.AddInstantEvent("category", "event", timestamp))
+		}
+		require.Equal(t, uint64(2), threadWriter.Dropped())
+		require.NoError(t, writer.Flush())
+
+		// The strings and thread, then the provider section, the buffer filled up event, and the events that were kept
+		records := readRecords(t, buffer.Bytes())
+		require.Len(t, records, 3+2+3)
+		require.Equal(t, &fxt.ProviderEventRecord{ProviderId: 7, EventType: fxt.ProviderEventTypeBufferFilledUp}, records[4])
+		for i, timestamp := range test.timestamps {
+			require.Equal(t, timestamp, records[5+i].(*fxt.InstantEvent).Timestamp)
+		}
+
+		// The provider event is only written again after more events are dropped
+		require.NoError(t, threadWriter.AddInstantEvent("category", "event", 6))
+		require.NoError(t, writer.Flush())
+		require.Len(t, readRecords(t, buffer.Bytes()), 3+2+3+1)
+	}
+}
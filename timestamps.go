@@ -0,0 +1,87 @@
+package fxt
+
+import (
+	"fmt"
+)
+
+// TimestampCheck controls whether the Writer verifies that the timestamps on each thread never go backwards
+//
+// Timestamps going backwards usually means different clock sources are being mixed, which produces
+// scrambled traces. Duration complete events are checked using their end timestamp, since they're
+// usually written when the duration ends
+type TimestampCheck int
+
+const (
+	// TimestampCheckOff disables timestamp checking. This is the default
+	TimestampCheckOff TimestampCheck = iota
+	// TimestampCheckWarn reports out of order timestamps to the Writer's warning handler,
+	// and still writes the event
+	TimestampCheckWarn
+	// TimestampCheckError fails events with out of order timestamps, without writing them
+	TimestampCheckError
+)
+
+// checkTimestamp verifies `timestamp` isn't earlier than the previous timestamp on the thread, according
+// to the Writer's TimestampCheck, and records it as the latest timestamp
+func (w *Writer) checkTimestamp(processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	if w.timestampCheck == TimestampCheckOff {
+		return nil
+	}
+
+	thread := Thread{ProcessId: processId, ThreadId: threadId}
+	if last, ok := w.lastTimestamps[thread]; ok && timestamp < last {
+		err := fmt.Errorf("timestamp %d on thread %d/%d is earlier than the previous timestamp %d", timestamp, processId, threadId, last)
+		if w.timestampCheck == TimestampCheckError {
+			return err
+		}
+		w.warn(err)
+	}
+
+	w.lastTimestamps[thread] = timestamp
+	return nil
+}
+
+// checkDuration verifies a duration doesn't end before it begins, according to the Writer's TimestampCheck
+func (w *Writer) checkDuration(beginTimestamp uint64, endTimestamp uint64) error {
+	if w.timestampCheck == TimestampCheckOff || endTimestamp >= beginTimestamp {
+		return nil
+	}
+
+	err := fmt.Errorf("end timestamp %d is earlier than the begin timestamp %d", endTimestamp, beginTimestamp)
+	if w.timestampCheck == TimestampCheckError {
+		return err
+	}
+	w.warn(err)
+	return nil
+}
+
+// normalizeTimestamp subtracts the Writer's timestamp offset from `timestamp`, if normalization is enabled.
+// When the offset comes from the first timestamp, it's captured on the first call
+//
+// Timestamps earlier than the offset are clamped to zero
+func (w *Writer) normalizeTimestamp(timestamp uint64) uint64 {
+	if !w.normalizeTimestamps {
+		return timestamp
+	}
+
+	if !w.hasTimestampOffset {
+		w.timestampOffset = timestamp
+		w.hasTimestampOffset = true
+	}
+
+	if timestamp < w.timestampOffset {
+		return 0
+	}
+	return timestamp - w.timestampOffset
+}
+
+// prepareTimestamp normalizes and checks an event timestamp. It returns the timestamp to write
+func (w *Writer) prepareTimestamp(processId KernelObjectID, threadId KernelObjectID, timestamp uint64) (uint64, error) {
+	timestamp = w.normalizeTimestamp(timestamp)
+	if err := w.checkTimestamp(processId, threadId, timestamp); err != nil {
+		return 0, err
+	}
+	w.updateLatestTimestamp(timestamp)
+
+	return timestamp, nil
+}
@@ -0,0 +1,106 @@
+package fxt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampCheck(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	var warnings []error
+	writer, err := fxt.NewWriter(filepath.Join(tempDir, "warn.fxt"),
+		fxt.WithTimestampCheck(fxt.TimestampCheckWarn),
+		fxt.WithWarningHandler(func(err error) {
+			warnings = append(warnings, err)
+		}),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 200))
+	// Other threads are tracked separately
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 3, 100))
+	require.Empty(t, warnings)
+
+	// Warnings still write the event
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 150))
+	require.Len(t, warnings, 1)
+
+	// Nested complete events are checked by their end timestamp
+	require.NoError(t, writer.AddDurationCompleteEvent("Category", "Inner", 1, 3, 120, 130))
+	require.NoError(t, writer.AddDurationCompleteEvent("Category", "Outer", 1, 3, 110, 140))
+	require.Len(t, warnings, 1)
+
+	require.NoError(t, writer.AddDurationCompleteEvent("Category", "Backwards", 1, 3, 160, 150))
+	require.Len(t, warnings, 2)
+
+	require.NoError(t, writer.Close())
+
+	writer, err = fxt.NewWriter(filepath.Join(tempDir, "error.fxt"), fxt.WithTimestampCheck(fxt.TimestampCheckError))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 200))
+	require.Error(t, writer.AddInstantEvent("Category", "Event", 1, 2, 150))
+	// The rejected event doesn't move the thread's clock
+	require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, 200))
+
+	require.NoError(t, writer.Close())
+}
+
+func TestTimestampNormalization(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+
+	defer func() {
+		err := os.RemoveAll(tempDir)
+		require.NoError(t, err)
+	}()
+
+	write := func(fileName string, base uint64, options ...fxt.WriterOption) []byte {
+		filePath := filepath.Join(tempDir, fileName)
+		writer, err := fxt.NewWriter(filePath, options...)
+		require.NoError(t, err)
+
+		require.NoError(t, writer.AddInstantEvent("Category", "First", 1, 2, base+100))
+		require.NoError(t, writer.AddDurationCompleteEvent("Category", "Complete", 1, 2, base+150, base+200))
+		require.NoError(t, writer.AddThreadWakeupRecord(0, 2, base+300))
+		require.NoError(t, writer.Close())
+
+		data, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		return data
+	}
+
+	const base = 1_700_000_000_000_000_000
+	expected := write("expected.fxt", 0)
+
+	require.Equal(t, expected, write("offset.fxt", base, fxt.WithTimestampOffset(base)))
+	// The first timestamp becomes zero
+	require.Equal(t, write("first.fxt", 0, fxt.WithTimestampOffset(100)), write("normalized.fxt", base, fxt.WithTimestampNormalization()))
+
+	// Timestamps before the offset are clamped to zero
+	instant := func(fileName string, timestamp uint64, options ...fxt.WriterOption) []byte {
+		filePath := filepath.Join(tempDir, fileName)
+		writer, err := fxt.NewWriter(filePath, options...)
+		require.NoError(t, err)
+
+		require.NoError(t, writer.AddInstantEvent("Category", "Event", 1, 2, timestamp))
+		require.NoError(t, writer.Close())
+
+		data, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		return data
+	}
+	require.Equal(t, instant("zero.fxt", 0), instant("clamped.fxt", 100, fxt.WithTimestampOffset(200)))
+}
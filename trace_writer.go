@@ -0,0 +1,172 @@
+package fxt
+
+// TraceWriter is the interface for writing events, counters, and blobs to a trace
+//
+// Writer implements it, as does NopWriter. Code which emits trace events can accept a TraceWriter, so it can be
+// given a NopWriter when tracing is disabled, or a custom implementation which records the calls in tests
+type TraceWriter interface {
+	SetProcessName(processId KernelObjectID, name string) error
+	SetThreadName(processId KernelObjectID, threadId KernelObjectID, name string) error
+	AddInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error
+	AddInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error
+	AddCounterEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}, counterId uint64) error
+	AddDurationBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error
+	AddDurationBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error
+	AddDurationEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error
+	AddDurationEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error
+	AddDurationCompleteEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64) error
+	AddDurationCompleteEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error
+	AddAsyncBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error
+	AddAsyncBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error
+	AddAsyncInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error
+	AddAsyncInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error
+	AddAsyncEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error
+	AddAsyncEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error
+	AddFlowBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error
+	AddFlowBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error
+	AddFlowStepEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error
+	AddFlowStepEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error
+	AddFlowEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error
+	AddFlowEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error
+	AddBlobRecord(name string, data []byte, blobType BlobType) error
+	AddLargeBlobRecord(category string, name string, data []byte) error
+	AddLargeBlobEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte) error
+	AddLargeBlobEventRecordWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte, arguments map[string]interface{}) error
+}
+
+var (
+	_ TraceWriter = (*Writer)(nil)
+	_ TraceWriter = NopWriter{}
+)
+
+// NopWriter is a TraceWriter which discards everything written to it
+// All of its methods do nothing, and return nil
+//
+// It has no fields, so storing it in a TraceWriter doesn't allocate, and its methods are small enough to be
+// inlined, so instrumentation left in production code costs an interface call per event when tracing is disabled.
+// Events without arguments don't allocate at all. The maps passed to the WithArgs methods and AddCounterEvent are
+// still built by the caller though, and escape to the heap when called through a TraceWriter, since the compiler
+// can't know they're discarded. Guard building them with IsNop, or Enabled for the package-level functions, like:
+//
+//	if !fxt.IsNop(w) {
+//		w.AddInstantEventWithArgs("net", "request", pid, tid, timestamp, map[string]interface{}{"url": url})
+//	}
+type NopWriter struct{}
+
+// IsNop reports whether `w` discards everything written to it, so callers can skip building the arguments of
+// their events
+func IsNop(w TraceWriter) bool {
+	switch w.(type) {
+	case nil, NopWriter, *NopWriter:
+		return true
+	}
+	return false
+}
+
+func (NopWriter) SetProcessName(processId KernelObjectID, name string) error {
+	return nil
+}
+
+func (NopWriter) SetThreadName(processId KernelObjectID, threadId KernelObjectID, name string) error {
+	return nil
+}
+
+func (NopWriter) AddInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	return nil
+}
+
+func (NopWriter) AddInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddCounterEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}, counterId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddDurationBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	return nil
+}
+
+func (NopWriter) AddDurationBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddDurationEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	return nil
+}
+
+func (NopWriter) AddDurationEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddDurationCompleteEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64) error {
+	return nil
+}
+
+func (NopWriter) AddDurationCompleteEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncInstantEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddAsyncEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddFlowBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddFlowBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddFlowStepEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddFlowStepEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddFlowEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64) error {
+	return nil
+}
+
+func (NopWriter) AddFlowEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (NopWriter) AddBlobRecord(name string, data []byte, blobType BlobType) error {
+	return nil
+}
+
+func (NopWriter) AddLargeBlobRecord(category string, name string, data []byte) error {
+	return nil
+}
+
+func (NopWriter) AddLargeBlobEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte) error {
+	return nil
+}
+
+func (NopWriter) AddLargeBlobEventRecordWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, data []byte, arguments map[string]interface{}) error {
+	return nil
+}
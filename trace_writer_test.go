@@ -0,0 +1,73 @@
+package fxt_test
+
+import (
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records the names of the instant events written to it
+// Embedding NopWriter means it only has to implement the methods it cares about
+type recordingWriter struct {
+	fxt.NopWriter
+	instants []string
+}
+
+func (w *recordingWriter) AddInstantEvent(category string, name string, processId fxt.KernelObjectID, threadId fxt.KernelObjectID, timestamp uint64) error {
+	w.instants = append(w.instants, category+"/"+name)
+	return nil
+}
+
+func instrumentedWork(writer fxt.TraceWriter) error {
+	if err := writer.AddDurationBeginEvent("Work", "Process", 1, 2, 100); err != nil {
+		return err
+	}
+	if err := writer.AddInstantEvent("Work", "Checkpoint", 1, 2, 150); err != nil {
+		return err
+	}
+	return writer.AddDurationEndEvent("Work", "Process", 1, 2, 200)
+}
+
+func TestTraceWriter(t *testing.T) {
+	require.NoError(t, instrumentedWork(fxt.NopWriter{}))
+
+	recorder := &recordingWriter{}
+	require.NoError(t, instrumentedWork(recorder))
+	require.Equal(t, []string{"Work/Checkpoint"}, recorder.instants)
+}
+
+// logRequest stands in for instrumented code, taking the TraceWriter through the interface so the calls aren't
+// devirtualized
+//
+//go:noinline
+func logRequest(writer fxt.TraceWriter, url string) {
+	writer.AddInstantEvent("net", "request", 1, 2, 100)
+	if !fxt.IsNop(writer) {
+		writer.AddInstantEventWithArgs("net", "request", 1, 2, 100, map[string]interface{}{"url": url})
+	}
+}
+
+func TestNopWriterAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+
+	require.True(t, fxt.IsNop(fxt.NopWriter{}))
+	require.True(t, fxt.IsNop(&fxt.NopWriter{}))
+	require.True(t, fxt.IsNop(nil))
+	require.False(t, fxt.IsNop(&recordingWriter{}))
+
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		logRequest(fxt.NopWriter{}, "https://example.com")
+	}))
+
+	// The package-level functions do nothing without a default
+	require.False(t, fxt.Enabled())
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		fxt.BeginDuration("net", "request", nil)
+		fxt.Default().AddInstantEvent("net", "request", 1, 2, 100)
+		fxt.EndDuration("net", "request", nil)
+	}))
+}
@@ -0,0 +1,121 @@
+package fxt
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// TraceparentHeader is the name of the W3C Trace Context propagation header
+//
+// https://www.w3.org/TR/trace-context/#traceparent-header
+const TraceparentHeader = "traceparent"
+
+// TraceContext identifies a span in a distributed trace, as propagated by W3C Trace Context
+// and OpenTelemetry
+//
+// An OpenTelemetry trace.SpanContext can be converted with:
+//
+//	fxt.TraceContext{TraceId: sc.TraceID(), SpanId: sc.SpanID()}
+type TraceContext struct {
+	TraceId [16]byte
+	SpanId  [8]byte
+}
+
+// ParseTraceparent parses the value of a W3C traceparent header
+// The span ID of the result is the caller's span, i.e. the parent of the span handling the request
+func ParseTraceparent(header string) (TraceContext, error) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - expected 4 fields", header)
+	}
+	if len(parts[0]) != 2 || !isLowerHex(parts[0]) || parts[0] == "ff" {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad version", header)
+	}
+	// Version 00 has exactly 4 fields. Future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - expected 4 fields", header)
+	}
+
+	var tc TraceContext
+	if len(parts[1]) != 2*len(tc.TraceId) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad trace ID length", header)
+	}
+	if !isLowerHex(parts[1]) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - trace ID must be lowercase hex", header)
+	}
+	if _, err := hex.Decode(tc.TraceId[:], []byte(parts[1])); err != nil {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad trace ID - %w", header, err)
+	}
+	if len(parts[2]) != 2*len(tc.SpanId) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad parent ID length", header)
+	}
+	if !isLowerHex(parts[2]) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - parent ID must be lowercase hex", header)
+	}
+	if _, err := hex.Decode(tc.SpanId[:], []byte(parts[2])); err != nil {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad parent ID - %w", header, err)
+	}
+	if len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - bad trace flags", header)
+	}
+
+	if tc.TraceId == [16]byte{} || tc.SpanId == [8]byte{} {
+		return TraceContext{}, fmt.Errorf("invalid traceparent `%s` - IDs must not be all zeros", header)
+	}
+
+	return tc, nil
+}
+
+// isLowerHex reports whether `str` is made only of the lowercase hex digits the spec requires
+func isLowerHex(str string) bool {
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Traceparent formats the trace context as a version 00, sampled, traceparent header value
+func (tc TraceContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(tc.TraceId[:]), hex.EncodeToString(tc.SpanId[:]))
+}
+
+// FlowId derives a deterministic flow correlation ID from the trace context
+//
+// The client writes a flow begin event with the ID of the span it propagates, and the server
+// derives the same ID from the traceparent header it receives. So the two sides are connected
+// by a flow without exchanging anything beyond the standard header
+func (tc TraceContext) FlowId() uint64 {
+	hash := fnv.New64a()
+	hash.Write(tc.TraceId[:])
+	hash.Write(tc.SpanId[:])
+	return hash.Sum64()
+}
+
+// AddTraceFlowBegin adds a flow begin event whose correlation ID is derived from `tc` with FlowId
+//
+// The client side of a request writes it inside the duration event for the request, after propagating `tc`
+// in the traceparent header
+func (w *Writer) AddTraceFlowBegin(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, tc TraceContext) error {
+	return w.AddFlowBeginEvent(category, name, processId, threadId, timestamp, tc.FlowId())
+}
+
+// AddTraceFlowStep adds a flow step event whose correlation ID is derived from `tc` with FlowId
+//
+// The server side of a request writes it inside the duration event for the request, with the trace context
+// parsed from the traceparent header it received
+func (w *Writer) AddTraceFlowStep(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, tc TraceContext) error {
+	return w.AddFlowStepEvent(category, name, processId, threadId, timestamp, tc.FlowId())
+}
+
+// AddTraceFlowEnd adds a flow end event whose correlation ID is derived from `tc` with FlowId
+//
+// The client side of a request writes it inside the duration event for the request, once the response arrives
+func (w *Writer) AddTraceFlowEnd(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, tc TraceContext) error {
+	return w.AddFlowEndEvent(category, name, processId, threadId, timestamp, tc.FlowId())
+}
@@ -0,0 +1,82 @@
+package fxt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tc, err := fxt.ParseTraceparent(header)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x4b), tc.TraceId[0])
+	require.Equal(t, byte(0xb7), tc.SpanId[7])
+	require.Equal(t, header, tc.Traceparent())
+
+	// The flow ID is deterministic, and depends on both IDs
+	again, err := fxt.ParseTraceparent(header)
+	require.NoError(t, err)
+	require.Equal(t, tc.FlowId(), again.FlowId())
+
+	otherSpan, err := fxt.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b8-01")
+	require.NoError(t, err)
+	require.NotEqual(t, tc.FlowId(), otherSpan.FlowId())
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01",
+		"0A-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0A",
+	}
+	for _, header := range invalid {
+		_, err := fxt.ParseTraceparent(header)
+		require.Error(t, err, header)
+	}
+}
+
+func TestTraceFlowEvents(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithFlowCheck(), fxt.WithWarningHandler(func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	}))
+	require.NoError(t, err)
+
+	tc, err := fxt.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+
+	require.NoError(t, writer.AddDurationBeginEvent("http", "client", 1, 2, 100))
+	require.NoError(t, writer.AddTraceFlowBegin("http", "request", 1, 2, 110, tc))
+	require.NoError(t, writer.AddDurationBeginEvent("http", "server", 1, 3, 120))
+	require.NoError(t, writer.AddTraceFlowStep("http", "request", 1, 3, 130, tc))
+	require.NoError(t, writer.AddDurationEndEvent("http", "server", 1, 3, 140))
+	require.NoError(t, writer.AddTraceFlowEnd("http", "request", 1, 2, 150, tc))
+	require.NoError(t, writer.AddDurationEndEvent("http", "client", 1, 2, 160))
+	require.NoError(t, writer.Close())
+
+	var correlationIds []uint64
+	for _, event := range readEvents(t, buffer.Bytes()) {
+		switch decoded := event.Decoded.(type) {
+		case *fxt.FlowBeginEvent:
+			correlationIds = append(correlationIds, decoded.CorrelationId)
+		case *fxt.FlowStepEvent:
+			correlationIds = append(correlationIds, decoded.CorrelationId)
+		case *fxt.FlowEndEvent:
+			correlationIds = append(correlationIds, decoded.CorrelationId)
+		}
+	}
+	require.Equal(t, []uint64{tc.FlowId(), tc.FlowId(), tc.FlowId()}, correlationIds)
+}
@@ -0,0 +1,199 @@
+package fxt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var _ TraceWriter = (*Tracer)(nil)
+
+// TracerOption configures a Tracer
+type TracerOption func(*Tracer)
+
+// WithTracerWarningHandler sets the function unbalanced duration events are reported to. By default,
+// they're logged with the log package
+//
+// The handler is called while the Tracer's lock is held, so it must not call back into the Tracer
+func WithTracerWarningHandler(handler WarningHandler) TracerOption {
+	return func(t *Tracer) {
+		t.warningHandler = handler
+	}
+}
+
+// openDuration is a duration begin event that hasn't been ended yet
+type openDuration struct {
+	category  string
+	name      string
+	timestamp uint64
+	// record is the position of the begin event among the events buffered by a ThreadWriter
+	record uint64
+}
+
+// spanStacks is the open duration begin events on each thread, innermost last. It's used by both the Tracer,
+// and the Writer's span tracking
+type spanStacks map[Thread][]openDuration
+
+// push records a duration begin event as open on its thread
+func (s spanStacks) push(thread Thread, category string, name string, timestamp uint64) {
+	s[thread] = append(s[thread], openDuration{category: category, name: name, timestamp: timestamp})
+}
+
+// set sets the open begin events of a thread, removing it once there are none
+func (s spanStacks) set(thread Thread, stack []openDuration) {
+	if len(stack) == 0 {
+		delete(s, thread)
+		return
+	}
+	s[thread] = stack
+}
+
+// threads returns the threads with open begin events, sorted by process and thread ID
+func (s spanStacks) threads() []Thread {
+	threads := make([]Thread, 0, len(s))
+	for thread := range s {
+		threads = append(threads, thread)
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		if threads[i].ProcessId != threads[j].ProcessId {
+			return threads[i].ProcessId < threads[j].ProcessId
+		}
+		return threads[i].ThreadId < threads[j].ThreadId
+	})
+	return threads
+}
+
+// Tracer is a TraceWriter that keeps track of the open duration begin events on each thread, so every
+// slice in the trace it writes is closed
+//
+// Viewers match duration end events to begin events by nesting, so a missing or extra end event shifts
+// every slice after it on the thread. The Tracer checks each end event against the begin events that
+// are still open on its thread:
+//   - An end event for the innermost open begin event is written as is
+//   - An end event for an outer begin event ends the inner ones at the same timestamp first
+//   - An end event that doesn't match any open begin event is dropped
+//
+// Each problem is reported to the warning handler. Call Close to end the begin events that are still
+// open when tracing stops
+//
+// Begin and end events are matched by their category and name. Complete events don't need matching,
+// and all the other methods are passed through to the underlying TraceWriter
+type Tracer struct {
+	TraceWriter
+
+	warningHandler WarningHandler
+
+	mu   sync.Mutex
+	open spanStacks
+}
+
+// NewTracer creates a Tracer that writes to `w`
+func NewTracer(w TraceWriter, options ...TracerOption) *Tracer {
+	t := &Tracer{
+		TraceWriter:    w,
+		warningHandler: defaultWarningHandler,
+		open:           spanStacks{},
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	return t
+}
+
+func (t *Tracer) warn(err error) {
+	if t.warningHandler != nil {
+		t.warningHandler(err)
+	}
+}
+
+// AddDurationBeginEvent writes a duration begin event, and records it as open on its thread
+func (t *Tracer) AddDurationBeginEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	return t.AddDurationBeginEventWithArgs(category, name, processId, threadId, timestamp, nil)
+}
+
+// AddDurationBeginEventWithArgs is the same as AddDurationBeginEvent, but it allows you to additionally include
+// arguments within the record
+func (t *Tracer) AddDurationBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.TraceWriter.AddDurationBeginEventWithArgs(category, name, processId, threadId, timestamp, arguments); err != nil {
+		return err
+	}
+
+	t.open.push(Thread{ProcessId: processId, ThreadId: threadId}, category, name, timestamp)
+	return nil
+}
+
+// AddDurationEndEvent writes a duration end event, if it matches a begin event that's open on its thread
+func (t *Tracer) AddDurationEndEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64) error {
+	return t.AddDurationEndEventWithArgs(category, name, processId, threadId, timestamp, nil)
+}
+
+// AddDurationEndEventWithArgs is the same as AddDurationEndEvent, but it allows you to additionally include
+// arguments within the record
+func (t *Tracer) AddDurationEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	thread := Thread{ProcessId: processId, ThreadId: threadId}
+	stack := t.open[thread]
+	match := len(stack) - 1
+	for match >= 0 && (stack[match].category != category || stack[match].name != name) {
+		match--
+	}
+	if match < 0 {
+		t.warn(fmt.Errorf("dropped end of %s/%s on thread %d/%d at %d - it has no matching begin", category, name, processId, threadId, timestamp))
+		return nil
+	}
+
+	// End the begin events nested inside the matching one, innermost first
+	for len(stack)-1 > match {
+		inner := stack[len(stack)-1]
+		t.warn(fmt.Errorf("%s/%s on thread %d/%d began at %d, but wasn't ended before the end of %s/%s - ending it at %d", inner.category, inner.name, processId, threadId, inner.timestamp, category, name, timestamp))
+		if err := t.TraceWriter.AddDurationEndEvent(inner.category, inner.name, processId, threadId, timestamp); err != nil {
+			return err
+		}
+		stack = stack[:len(stack)-1]
+		t.open.set(thread, stack)
+	}
+
+	if err := t.TraceWriter.AddDurationEndEventWithArgs(category, name, processId, threadId, timestamp, arguments); err != nil {
+		return err
+	}
+	t.open.set(thread, stack[:match])
+	return nil
+}
+
+// OpenDurations returns the number of duration begin events on a thread that haven't been ended
+func (t *Tracer) OpenDurations(processId KernelObjectID, threadId KernelObjectID) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.open[Thread{ProcessId: processId, ThreadId: threadId}])
+}
+
+// Close writes end events at `timestamp` for all the duration begin events that are still open, reporting
+// each of them to the warning handler
+//
+// The underlying TraceWriter isn't closed, and the Tracer can still be used afterwards
+func (t *Tracer) Close(timestamp uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, thread := range t.open.threads() {
+		stack := t.open[thread]
+		for len(stack) > 0 {
+			inner := stack[len(stack)-1]
+			t.warn(fmt.Errorf("%s/%s on thread %d/%d began at %d, but was never ended - ending it at %d", inner.category, inner.name, thread.ProcessId, thread.ThreadId, inner.timestamp, timestamp))
+			if err := t.TraceWriter.AddDurationEndEvent(inner.category, inner.name, thread.ProcessId, thread.ThreadId, timestamp); err != nil {
+				return fmt.Errorf("failed to end %s/%s on thread %d/%d - %w", inner.category, inner.name, thread.ProcessId, thread.ThreadId, err)
+			}
+			stack = stack[:len(stack)-1]
+			t.open.set(thread, stack)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readDurations returns the names of the duration begin and end events in a trace, as "+name" and "-name@timestamp"
+func readDurations(t *testing.T, trace []byte) []string {
+	reader, err := fxt.NewReaderFromBytes(trace)
+	require.NoError(t, err)
+
+	var durations []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			return durations
+		}
+		require.NoError(t, err)
+
+		switch event.Decoded.(type) {
+		case *fxt.DurationBeginEvent:
+			durations = append(durations, "+"+event.Name)
+		case *fxt.DurationEndEvent:
+			durations = append(durations, fmt.Sprintf("-%s@%d", event.Name, event.Timestamp))
+		}
+	}
+}
+
+func TestTracerPairing(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := fxt.NewWriterTo(&buffer)
+	require.NoError(t, err)
+
+	var warnings []error
+	tracer := fxt.NewTracer(writer, fxt.WithTracerWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+
+	// Balanced begins and ends are written as is
+	require.NoError(t, tracer.AddDurationBeginEvent("category", "outer", 1, 2, 1))
+	require.NoError(t, tracer.AddDurationBeginEvent("category", "inner", 1, 2, 2))
+	require.Equal(t, 2, tracer.OpenDurations(1, 2))
+	require.NoError(t, tracer.AddDurationEndEvent("category", "inner", 1, 2, 3))
+	require.Empty(t, warnings)
+
+	// An end without a begin is dropped
+	require.NoError(t, tracer.AddDurationEndEvent("category", "stray", 1, 2, 4))
+	require.Len(t, warnings, 1)
+
+	// Ending the outer duration ends the ones nested in it first
+	require.NoError(t, tracer.AddDurationBeginEvent("category", "forgotten", 1, 2, 5))
+	require.NoError(t, tracer.AddDurationEndEventWithArgs("category", "outer", 1, 2, 6, map[string]interface{}{"count": int32(1)}))
+	require.Len(t, warnings, 2)
+	require.Equal(t, 0, tracer.OpenDurations(1, 2))
+
+	// Close ends everything that's still open, on every thread
+	require.NoError(t, tracer.AddDurationBeginEvent("category", "first", 1, 2, 7))
+	require.NoError(t, tracer.AddDurationBeginEvent("category", "second", 1, 3, 7))
+	require.NoError(t, tracer.Close(9))
+	require.Len(t, warnings, 4)
+	require.Equal(t, 0, tracer.OpenDurations(1, 3))
+
+	// Other events go straight to the Writer
+	require.NoError(t, tracer.AddInstantEvent("category", "instant", 1, 2, 9))
+	require.NoError(t, writer.Close())
+
+	require.Equal(t, []string{
+		"+outer", "+inner", "-inner@3",
+		"+forgotten", "-forgotten@6", "-outer@6",
+		"+first", "+second", "-first@9", "-second@9",
+	}, readDurations(t, buffer.Bytes()))
+}
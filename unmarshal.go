@@ -0,0 +1,795 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// recordDecoder reads the words of a single encoded record, in order
+type recordDecoder struct {
+	data   []byte
+	offset int
+}
+
+// newRecordDecoder checks `data` holds exactly one record of type `recordType`, and returns a decoder
+// positioned after the header, along with the header itself
+func newRecordDecoder(data []byte, recordType RecordType) (*recordDecoder, uint64, error) {
+	if len(data) < 8 || len(data)%8 != 0 {
+		return nil, 0, fmt.Errorf("invalid record - %d bytes is not a whole number of words", len(data))
+	}
+
+	header := binary.LittleEndian.Uint64(data)
+	if actualType := RecordType(header & 0xF); actualType != recordType {
+		return nil, 0, fmt.Errorf("expected a %s record, but found a %s record", recordType, actualType)
+	}
+
+	var sizeInWords uint64
+	if recordType == RecordTypeLargeBlob {
+		sizeInWords = (header >> 4) & 0xFFFFFFFF
+	} else {
+		sizeInWords = (header >> 4) & 0xFFF
+	}
+	if sizeInWords != uint64(len(data)/8) {
+		return nil, 0, fmt.Errorf("invalid %s record - the header size of %d words doesn't match the data size of %d words", recordType, sizeInWords, len(data)/8)
+	}
+
+	return &recordDecoder{data: data, offset: 8}, header, nil
+}
+
+func (d *recordDecoder) word() (uint64, error) {
+	if d.offset+8 > len(d.data) {
+		return 0, fmt.Errorf("record is truncated at byte %d", d.offset)
+	}
+	value := binary.LittleEndian.Uint64(d.data[d.offset:])
+	d.offset += 8
+	return value, nil
+}
+
+// padded reads `size` bytes, followed by the padding up to the next word boundary
+func (d *recordDecoder) padded(size int) ([]byte, error) {
+	value, err := d.slice(size)
+	if err != nil {
+		return nil, err
+	}
+	// Copy the data, so the decoded record doesn't alias the caller's buffer
+	return append([]byte(nil), value...), nil
+}
+
+// slice is the same as padded, but it returns a sub-slice of the record data instead of a copy
+func (d *recordDecoder) slice(size int) ([]byte, error) {
+	end := d.offset + paddedSizeInWords(size)*8
+	if size < 0 || end > len(d.data) {
+		return nil, fmt.Errorf("record is truncated at byte %d - expected %d bytes of data", d.offset, size)
+	}
+	value := d.data[d.offset : d.offset+size : d.offset+size]
+	d.offset = end
+	return value, nil
+}
+
+// stringRef decodes the string reference `field`, reading the string data if it's inline
+func (d *recordDecoder) stringRef(field uint64) (StringRef, error) {
+	if field&inlineStringRefFlag == 0 {
+		return StringRef{Index: uint16(field)}, nil
+	}
+
+	value, err := d.padded(int(field & maxStringRefLength))
+	if err != nil {
+		return StringRef{}, err
+	}
+	return StringRef{Inline: string(value)}, nil
+}
+
+// threadRef decodes the thread reference `index`, reading the process / thread IDs if it's inline
+func (d *recordDecoder) threadRef(index uint64) (ThreadRef, error) {
+	if index != 0 {
+		return ThreadRef{Index: uint8(index)}, nil
+	}
+
+	processId, err := d.word()
+	if err != nil {
+		return ThreadRef{}, err
+	}
+	threadId, err := d.word()
+	if err != nil {
+		return ThreadRef{}, err
+	}
+	return ThreadRef{Inline: Thread{ProcessId: KernelObjectID(processId), ThreadId: KernelObjectID(threadId)}}, nil
+}
+
+// arguments decodes `numArgs` argument data records
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#argument-types
+func (d *recordDecoder) arguments(numArgs int) ([]Argument, error) {
+	if numArgs == 0 {
+		return nil, nil
+	}
+
+	arguments := make([]Argument, 0, numArgs)
+	for i := 0; i < numArgs; i++ {
+		start := d.offset
+		header, err := d.word()
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d - %w", i, err)
+		}
+		sizeInWords := int((header >> 4) & 0xFFF)
+		end := start + sizeInWords*8
+		if sizeInWords == 0 || end > len(d.data) {
+			return nil, fmt.Errorf("invalid argument %d - size of %d words doesn't fit in the record", i, sizeInWords)
+		}
+
+		key, err := d.stringRef((header >> 16) & 0xFFFF)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d key - %w", i, err)
+		}
+
+		var value interface{}
+		switch ArgumentType(header & 0xF) {
+		case ArgumentTypeNull:
+		case ArgumentTypeInt32:
+			value = int32(uint32(header >> 32))
+		case ArgumentTypeUint32:
+			value = uint32(header >> 32)
+		case ArgumentTypeInt64:
+			var word uint64
+			word, err = d.word()
+			value = int64(word)
+		case ArgumentTypeUint64:
+			value, err = d.word()
+		case ArgumentTypeDouble:
+			var word uint64
+			word, err = d.word()
+			value = math.Float64frombits(word)
+		case ArgumentTypeString:
+			value, err = d.stringRef((header >> 32) & 0xFFFF)
+		case ArgumentTypePointer:
+			var word uint64
+			word, err = d.word()
+			value = uintptr(word)
+		case ArgumentTypeKOID:
+			var word uint64
+			word, err = d.word()
+			value = KernelObjectID(word)
+		case ArgumentTypeBool:
+			value = (header>>32)&1 == 1
+		default:
+			return nil, fmt.Errorf("invalid argument %d - unknown argument type %d", i, header&0xF)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d value - %w", i, err)
+		}
+		if d.offset > end {
+			return nil, fmt.Errorf("invalid argument %d - data overruns the argument size of %d words", i, sizeInWords)
+		}
+		// Skip anything after the value, in case a newer version of the spec adds fields
+		d.offset = end
+
+		arguments = append(arguments, Argument{Key: key, Value: value})
+	}
+
+	return arguments, nil
+}
+
+// metadataHeader checks a metadata record has the expected metadata type
+func metadataHeader(data []byte, expected metadataType) (*recordDecoder, uint64, error) {
+	d, header, err := newRecordDecoder(data, RecordTypeMetadata)
+	if err != nil {
+		return nil, 0, err
+	}
+	if actual := metadataType((header >> 16) & 0xF); actual != expected {
+		return nil, 0, fmt.Errorf("unexpected metadata type %d - expected %d", actual, expected)
+	}
+	return d, header, nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ProviderInfoRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := metadataHeader(data, metadataTypeProviderInfo)
+	if err != nil {
+		return err
+	}
+
+	name, err := d.padded(int((header >> 52) & 0xFF))
+	if err != nil {
+		return fmt.Errorf("invalid provider name - %w", err)
+	}
+
+	*r = ProviderInfoRecord{
+		ProviderId: uint32(header >> 20),
+		Name:       string(name),
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ProviderSectionRecord) UnmarshalBinary(data []byte) error {
+	_, header, err := metadataHeader(data, metadataTypeProviderSection)
+	if err != nil {
+		return err
+	}
+
+	*r = ProviderSectionRecord{ProviderId: uint32(header >> 20)}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ProviderEventRecord) UnmarshalBinary(data []byte) error {
+	_, header, err := metadataHeader(data, metadataTypeProviderEvent)
+	if err != nil {
+		return err
+	}
+
+	*r = ProviderEventRecord{
+		ProviderId: uint32(header >> 20),
+		EventType:  ProviderEventType((header >> 52) & 0xF),
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *InitializationRecord) UnmarshalBinary(data []byte) error {
+	d, _, err := newRecordDecoder(data, RecordTypeInitialization)
+	if err != nil {
+		return err
+	}
+
+	ticksPerSecond, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid initialization record - %w", err)
+	}
+
+	*r = InitializationRecord{TicksPerSecond: TickRate(ticksPerSecond)}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *StringRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := newRecordDecoder(data, RecordTypeString)
+	if err != nil {
+		return err
+	}
+
+	value, err := d.padded(int((header >> 32) & 0x7FFF))
+	if err != nil {
+		return fmt.Errorf("invalid string record - %w", err)
+	}
+
+	*r = StringRecord{
+		Index: uint16((header >> 16) & 0x7FFF),
+		Value: string(value),
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ThreadRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := newRecordDecoder(data, RecordTypeThread)
+	if err != nil {
+		return err
+	}
+
+	processId, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid thread record - %w", err)
+	}
+	threadId, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid thread record - %w", err)
+	}
+
+	*r = ThreadRecord{
+		Index:     uint8(header >> 16),
+		ProcessId: KernelObjectID(processId),
+		ThreadId:  KernelObjectID(threadId),
+	}
+	return nil
+}
+
+// decodeEvent decodes the common event data of an event of type `expected`, followed by
+// `numExtra` event type specific words
+func decodeEvent(data []byte, expected eventType, numExtra int) (EventRecord, []uint64, error) {
+	d, header, err := newRecordDecoder(data, RecordTypeEvent)
+	if err != nil {
+		return EventRecord{}, nil, err
+	}
+	if actual := eventType((header >> 16) & 0xF); actual != expected {
+		return EventRecord{}, nil, fmt.Errorf("unexpected event type %d - expected %d", actual, expected)
+	}
+
+	var event EventRecord
+	if event.Thread, err = d.threadRef((header >> 24) & 0xFF); err != nil {
+		return EventRecord{}, nil, fmt.Errorf("invalid thread - %w", err)
+	}
+	if event.Category, err = d.stringRef((header >> 32) & 0xFFFF); err != nil {
+		return EventRecord{}, nil, fmt.Errorf("invalid category - %w", err)
+	}
+	if event.Name, err = d.stringRef((header >> 48) & 0xFFFF); err != nil {
+		return EventRecord{}, nil, fmt.Errorf("invalid name - %w", err)
+	}
+	if event.Timestamp, err = d.word(); err != nil {
+		return EventRecord{}, nil, fmt.Errorf("invalid timestamp - %w", err)
+	}
+	if event.Arguments, err = d.arguments(int((header >> 20) & 0xF)); err != nil {
+		return EventRecord{}, nil, err
+	}
+
+	extra := make([]uint64, numExtra)
+	for i := range extra {
+		if extra[i], err = d.word(); err != nil {
+			return EventRecord{}, nil, fmt.Errorf("invalid event data - %w", err)
+		}
+	}
+
+	return event, extra, nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *InstantEvent) UnmarshalBinary(data []byte) error {
+	event, _, err := decodeEvent(data, eventTypeInstant, 0)
+	if err != nil {
+		return err
+	}
+
+	*e = InstantEvent{EventRecord: event}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *CounterEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeCounter, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = CounterEvent{EventRecord: event, CounterId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *DurationBeginEvent) UnmarshalBinary(data []byte) error {
+	event, _, err := decodeEvent(data, eventTypeDurationBegin, 0)
+	if err != nil {
+		return err
+	}
+
+	*e = DurationBeginEvent{EventRecord: event}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *DurationEndEvent) UnmarshalBinary(data []byte) error {
+	event, _, err := decodeEvent(data, eventTypeDurationEnd, 0)
+	if err != nil {
+		return err
+	}
+
+	*e = DurationEndEvent{EventRecord: event}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *DurationCompleteEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeDurationComplete, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = DurationCompleteEvent{EventRecord: event, EndTimestamp: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *AsyncBeginEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeAsyncBegin, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = AsyncBeginEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *AsyncInstantEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeAsyncInstant, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = AsyncInstantEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *AsyncEndEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeAsyncEnd, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = AsyncEndEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *FlowBeginEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeFlowBegin, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = FlowBeginEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *FlowStepEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeFlowStep, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = FlowStepEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (e *FlowEndEvent) UnmarshalBinary(data []byte) error {
+	event, extra, err := decodeEvent(data, eventTypeFlowEnd, 1)
+	if err != nil {
+		return err
+	}
+
+	*e = FlowEndEvent{EventRecord: event, CorrelationId: extra[0]}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *BlobRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := newRecordDecoder(data, RecordTypeBlob)
+	if err != nil {
+		return err
+	}
+
+	name, err := d.stringRef((header >> 16) & 0xFFFF)
+	if err != nil {
+		return fmt.Errorf("invalid name - %w", err)
+	}
+	payload, err := d.padded(int((header >> 32) & 0x7FFF))
+	if err != nil {
+		return fmt.Errorf("invalid blob payload - %w", err)
+	}
+
+	*r = BlobRecord{
+		Name: name,
+		Type: BlobType((header >> 48) & 0xFF),
+		Data: payload,
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *UserspaceObjectRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := newRecordDecoder(data, RecordTypeUserspaceObject)
+	if err != nil {
+		return err
+	}
+	if threadIndex := (header >> 16) & 0xFF; threadIndex != 0 {
+		return fmt.Errorf("unsupported process reference %d - only inline processes can be decoded", threadIndex)
+	}
+
+	pointer, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid pointer value - %w", err)
+	}
+	processId, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid process ID - %w", err)
+	}
+	name, err := d.stringRef((header >> 24) & 0xFFFF)
+	if err != nil {
+		return fmt.Errorf("invalid name - %w", err)
+	}
+	arguments, err := d.arguments(int((header >> 40) & 0xF))
+	if err != nil {
+		return err
+	}
+
+	*r = UserspaceObjectRecord{
+		Pointer:   uintptr(pointer),
+		ProcessId: KernelObjectID(processId),
+		Name:      name,
+		Arguments: arguments,
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *KernelObjectRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := newRecordDecoder(data, RecordTypeKernelObject)
+	if err != nil {
+		return err
+	}
+
+	koid, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid koid - %w", err)
+	}
+	name, err := d.stringRef((header >> 24) & 0xFFFF)
+	if err != nil {
+		return fmt.Errorf("invalid name - %w", err)
+	}
+	arguments, err := d.arguments(int((header >> 40) & 0xF))
+	if err != nil {
+		return err
+	}
+
+	*r = KernelObjectRecord{
+		Type:      KernelObjectType((header >> 16) & 0xFF),
+		Koid:      KernelObjectID(koid),
+		Name:      name,
+		Arguments: arguments,
+	}
+	return nil
+}
+
+// schedulingHeader checks a scheduling record has the expected scheduling record type
+func schedulingHeader(data []byte, expected schedulingRecordType) (*recordDecoder, uint64, error) {
+	d, header, err := newRecordDecoder(data, RecordTypeScheduling)
+	if err != nil {
+		return nil, 0, err
+	}
+	if actual := schedulingRecordType(header >> 60); actual != expected {
+		return nil, 0, fmt.Errorf("unexpected scheduling record type %d - expected %d", actual, expected)
+	}
+	return d, header, nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ContextSwitchRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := schedulingHeader(data, schedulingRecordTypeContextSwitch)
+	if err != nil {
+		return err
+	}
+
+	var words [3]uint64
+	for i := range words {
+		if words[i], err = d.word(); err != nil {
+			return fmt.Errorf("invalid context switch record - %w", err)
+		}
+	}
+	arguments, err := d.arguments(int((header >> 16) & 0xF))
+	if err != nil {
+		return err
+	}
+
+	*r = ContextSwitchRecord{
+		CPU:                 uint16(header >> 20),
+		OutgoingThreadState: uint8((header >> 36) & 0xF),
+		Timestamp:           words[0],
+		OutgoingThreadId:    KernelObjectID(words[1]),
+		IncomingThreadId:    KernelObjectID(words[2]),
+		Arguments:           arguments,
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *ThreadWakeupRecord) UnmarshalBinary(data []byte) error {
+	d, header, err := schedulingHeader(data, schedulingRecordTypeThreadWakeup)
+	if err != nil {
+		return err
+	}
+
+	timestamp, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid timestamp - %w", err)
+	}
+	wakingThreadId, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid waking thread ID - %w", err)
+	}
+	arguments, err := d.arguments(int((header >> 16) & 0xF))
+	if err != nil {
+		return err
+	}
+
+	*r = ThreadWakeupRecord{
+		CPU:            uint16(header >> 20),
+		WakingThreadId: KernelObjectID(wakingThreadId),
+		Timestamp:      timestamp,
+		Arguments:      arguments,
+	}
+	return nil
+}
+
+// largeBlobHeader checks a large blob record has the expected blob format
+func largeBlobHeader(data []byte, expected largeBlobFormat) (*recordDecoder, error) {
+	d, header, err := newRecordDecoder(data, RecordTypeLargeBlob)
+	if err != nil {
+		return nil, err
+	}
+	if actual := largeRecordType((header >> 36) & 0xF); actual != largeRecordTypeBlob {
+		return nil, fmt.Errorf("unexpected large record type %d", actual)
+	}
+	if actual := largeBlobFormat((header >> 40) & 0xF); actual != expected {
+		return nil, fmt.Errorf("unexpected large blob format %d - expected %d", actual, expected)
+	}
+	return d, nil
+}
+
+// largeBlobPayload reads the payload size word, followed by the padded payload
+func (d *recordDecoder) largeBlobPayload() ([]byte, error) {
+	size, err := d.word()
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob size - %w", err)
+	}
+	if size > uint64(len(d.data)) {
+		return nil, fmt.Errorf("invalid blob size - %d bytes exceeds the record size", size)
+	}
+	payload, err := d.padded(int(size))
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob payload - %w", err)
+	}
+	return payload, nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *LargeBlobRecord) UnmarshalBinary(data []byte) error {
+	d, err := largeBlobHeader(data, largeBlobFormatNoMetadata)
+	if err != nil {
+		return err
+	}
+
+	formatHeader, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid blob format header - %w", err)
+	}
+	category, err := d.stringRef(formatHeader & 0xFFFF)
+	if err != nil {
+		return fmt.Errorf("invalid category - %w", err)
+	}
+	name, err := d.stringRef((formatHeader >> 16) & 0xFFFF)
+	if err != nil {
+		return fmt.Errorf("invalid name - %w", err)
+	}
+	payload, err := d.largeBlobPayload()
+	if err != nil {
+		return err
+	}
+
+	*r = LargeBlobRecord{
+		Category: category,
+		Name:     name,
+		Data:     payload,
+	}
+	return nil
+}
+
+// UnmarshalBinary decodes a record encoded by MarshalBinary
+func (r *LargeBlobEventRecord) UnmarshalBinary(data []byte) error {
+	d, err := largeBlobHeader(data, largeBlobFormatMetadata)
+	if err != nil {
+		return err
+	}
+
+	formatHeader, err := d.word()
+	if err != nil {
+		return fmt.Errorf("invalid blob format header - %w", err)
+	}
+
+	var event EventRecord
+	if event.Category, err = d.stringRef(formatHeader & 0xFFFF); err != nil {
+		return fmt.Errorf("invalid category - %w", err)
+	}
+	if event.Name, err = d.stringRef((formatHeader >> 16) & 0xFFFF); err != nil {
+		return fmt.Errorf("invalid name - %w", err)
+	}
+	if event.Timestamp, err = d.word(); err != nil {
+		return fmt.Errorf("invalid timestamp - %w", err)
+	}
+	if event.Thread, err = d.threadRef((formatHeader >> 36) & 0xFF); err != nil {
+		return fmt.Errorf("invalid thread - %w", err)
+	}
+	if event.Arguments, err = d.arguments(int((formatHeader >> 32) & 0xF)); err != nil {
+		return err
+	}
+	payload, err := d.largeBlobPayload()
+	if err != nil {
+		return err
+	}
+
+	*r = LargeBlobEventRecord{EventRecord: event, Data: payload}
+	return nil
+}
+
+// decodeRecord decodes a record into the matching record struct
+// It returns nil for records that don't have a struct, like log records and unknown event types
+func decodeRecord(recordType RecordType, data []byte) (recordAppender, error) {
+	header := binary.LittleEndian.Uint64(data)
+
+	var record interface {
+		recordAppender
+		UnmarshalBinary(data []byte) error
+	}
+	switch recordType {
+	case RecordTypeMetadata:
+		switch metadataType((header >> 16) & 0xF) {
+		case metadataTypeProviderInfo:
+			record = &ProviderInfoRecord{}
+		case metadataTypeProviderSection:
+			record = &ProviderSectionRecord{}
+		case metadataTypeProviderEvent:
+			record = &ProviderEventRecord{}
+		default:
+			return nil, nil
+		}
+	case RecordTypeInitialization:
+		record = &InitializationRecord{}
+	case RecordTypeString:
+		record = &StringRecord{}
+	case RecordTypeThread:
+		record = &ThreadRecord{}
+	case RecordTypeEvent:
+		switch eventType((header >> 16) & 0xF) {
+		case eventTypeInstant:
+			record = &InstantEvent{}
+		case eventTypeCounter:
+			record = &CounterEvent{}
+		case eventTypeDurationBegin:
+			record = &DurationBeginEvent{}
+		case eventTypeDurationEnd:
+			record = &DurationEndEvent{}
+		case eventTypeDurationComplete:
+			record = &DurationCompleteEvent{}
+		case eventTypeAsyncBegin:
+			record = &AsyncBeginEvent{}
+		case eventTypeAsyncInstant:
+			record = &AsyncInstantEvent{}
+		case eventTypeAsyncEnd:
+			record = &AsyncEndEvent{}
+		case eventTypeFlowBegin:
+			record = &FlowBeginEvent{}
+		case eventTypeFlowStep:
+			record = &FlowStepEvent{}
+		case eventTypeFlowEnd:
+			record = &FlowEndEvent{}
+		default:
+			return nil, nil
+		}
+	case RecordTypeBlob:
+		record = &BlobRecord{}
+	case RecordTypeUserspaceObject:
+		record = &UserspaceObjectRecord{}
+	case RecordTypeKernelObject:
+		record = &KernelObjectRecord{}
+	case RecordTypeScheduling:
+		switch schedulingRecordType(header >> 60) {
+		case schedulingRecordTypeContextSwitch:
+			record = &ContextSwitchRecord{}
+		case schedulingRecordTypeThreadWakeup:
+			record = &ThreadWakeupRecord{}
+		default:
+			return nil, nil
+		}
+	case RecordTypeLargeBlob:
+		if largeRecordType((header>>36)&0xF) != largeRecordTypeBlob {
+			return nil, nil
+		}
+		switch largeBlobFormat((header >> 40) & 0xF) {
+		case largeBlobFormatMetadata:
+			record = &LargeBlobEventRecord{}
+		case largeBlobFormatNoMetadata:
+			record = &LargeBlobRecord{}
+		default:
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	if err := record.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,85 @@
+package fxt
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FormatVersion identifies a revision of the FXT spec, and the record layouts it defines
+type FormatVersion int
+
+const (
+	// FormatVersion1 is the format described by the current revision of the spec
+	//
+	// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md
+	FormatVersion1 FormatVersion = 1
+
+	// LatestFormatVersion is the newest version the Writer can write. It's the default
+	LatestFormatVersion = FormatVersion1
+)
+
+// traceInfoMagic is the magic number stored in the magic number record
+const traceInfoMagic = 0x16547846
+
+// metadataTypeTraceInfo is the metadata type of the magic number record
+const metadataTypeTraceInfo metadataType = 4
+
+// magicNumberRecords holds the magic number record that starts a trace of each supported version
+var magicNumberRecords = map[FormatVersion][]byte{
+	FormatVersion1: fxtMagic,
+}
+
+// IsSupported reports whether the version can be read and written by this package
+func (v FormatVersion) IsSupported() bool {
+	_, ok := magicNumberRecords[v]
+	return ok
+}
+
+// MagicNumberRecord returns the magic number record that starts a trace of the given format version
+func MagicNumberRecord(version FormatVersion) ([]byte, error) {
+	record, ok := magicNumberRecords[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported FXT format version %s", version)
+	}
+	return append([]byte(nil), record...), nil
+}
+
+// String returns the version as "v<number>"
+func (v FormatVersion) String() string {
+	return fmt.Sprintf("v%d", int(v))
+}
+
+// parseMagicNumberRecord checks `magic` is a magic number record, and returns the format version it identifies
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#magic-number-record
+func parseMagicNumberRecord(magic []byte) (FormatVersion, error) {
+	for version, record := range magicNumberRecords {
+		if string(magic) == string(record) {
+			return version, nil
+		}
+	}
+
+	for _, record := range magicNumberRecords {
+		if string(reverseBytes(magic)) == string(record) {
+			return 0, fmt.Errorf("the trace is big-endian - FXT traces must be little-endian")
+		}
+	}
+
+	header := binary.LittleEndian.Uint64(magic)
+	isTraceInfo := RecordType(header&0xF) == RecordTypeMetadata && metadataType((header>>16)&0xF) == metadataTypeTraceInfo
+	if isTraceInfo && (header>>24)&0xFFFFFFFF == traceInfoMagic {
+		// The magic number matches, but the rest of the record doesn't. The reserved bits are assumed to
+		// be used by a newer revision of the spec
+		return 0, fmt.Errorf("unsupported FXT format version - unrecognized magic number record %x", magic)
+	}
+
+	return 0, fmt.Errorf("not an FXT trace - invalid magic number record %x", magic)
+}
+
+func reverseBytes(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
@@ -0,0 +1,166 @@
+package fxt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// VirtualThreadIdBase is the first thread ID handed out by NewVirtualThreadId
+//
+// It's well above the thread IDs of Linux and the goroutine IDs used by SetGoroutineName, so virtual threads
+// don't share a track with real ones. It's below 2^32, since some viewers truncate thread IDs to 32 bits
+const VirtualThreadIdBase KernelObjectID = 1 << 30
+
+// nextVirtualThreadId is the last thread ID handed out to a virtual thread
+var nextVirtualThreadId = uint64(VirtualThreadIdBase) - 1
+
+// NewVirtualThreadId returns a process-unique thread ID for a virtual thread, starting at VirtualThreadIdBase
+func NewVirtualThreadId() KernelObjectID {
+	return KernelObjectID(atomic.AddUint64(&nextVirtualThreadId, 1))
+}
+
+// VirtualThread is a synthetic thread, for modeling execution that doesn't map 1:1 to OS threads, like
+// fibers, the queues of a job system's workers, or GPU queues. Each gets its own track, named after it
+//
+// The VirtualThread keeps track of the duration begin events that are open on it, so they can be ended
+// without repeating their names, and moved to another VirtualThread with MoveSpans when the work they
+// measure migrates, like a fiber resuming on a different worker
+//
+// All methods are safe to call from multiple goroutines
+type VirtualThread struct {
+	writer    TraceWriter
+	processId KernelObjectID
+	threadId  KernelObjectID
+	name      string
+
+	mu   sync.Mutex
+	open []openDuration
+}
+
+// NewVirtualThread creates a VirtualThread in process `processId` with a new thread ID from NewVirtualThreadId,
+// and names it `name`
+func NewVirtualThread(w TraceWriter, processId KernelObjectID, name string) (*VirtualThread, error) {
+	v := &VirtualThread{
+		writer:    w,
+		processId: processId,
+		threadId:  NewVirtualThreadId(),
+		name:      name,
+	}
+	if err := w.SetThreadName(processId, v.threadId, name); err != nil {
+		return nil, fmt.Errorf("failed to name virtual thread %s - %w", name, err)
+	}
+
+	return v, nil
+}
+
+// Thread returns the process and thread ID of the VirtualThread, for writing other events on its track
+func (v *VirtualThread) Thread() Thread {
+	return Thread{ProcessId: v.processId, ThreadId: v.threadId}
+}
+
+// Name returns the name of the VirtualThread
+func (v *VirtualThread) Name() string {
+	return v.name
+}
+
+// Begin writes a duration begin event on the VirtualThread, and records it as open
+func (v *VirtualThread) Begin(category string, name string, timestamp uint64, arguments map[string]interface{}) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.writer.AddDurationBeginEventWithArgs(category, name, v.processId, v.threadId, timestamp, arguments); err != nil {
+		return err
+	}
+	v.open = append(v.open, openDuration{category: category, name: name, timestamp: timestamp})
+	return nil
+}
+
+// End writes a duration end event for the innermost open duration begin event on the VirtualThread
+func (v *VirtualThread) End(timestamp uint64, arguments map[string]interface{}) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.open) == 0 {
+		return fmt.Errorf("virtual thread %s has no open duration to end", v.name)
+	}
+
+	span := v.open[len(v.open)-1]
+	if err := v.writer.AddDurationEndEventWithArgs(span.category, span.name, v.processId, v.threadId, timestamp, arguments); err != nil {
+		return err
+	}
+	v.open = v.open[:len(v.open)-1]
+	return nil
+}
+
+// Complete writes a duration complete event on the VirtualThread
+func (v *VirtualThread) Complete(category string, name string, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
+	return v.writer.AddDurationCompleteEventWithArgs(category, name, v.processId, v.threadId, beginTimestamp, endTimestamp, arguments)
+}
+
+// Instant writes an instant event on the VirtualThread
+func (v *VirtualThread) Instant(category string, name string, timestamp uint64, arguments map[string]interface{}) error {
+	return v.writer.AddInstantEventWithArgs(category, name, v.processId, v.threadId, timestamp, arguments)
+}
+
+// OpenSpans returns the number of duration begin events on the VirtualThread that haven't been ended
+func (v *VirtualThread) OpenSpans() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.open)
+}
+
+// MoveSpans moves the innermost `count` open durations of the VirtualThread to `to`, at `timestamp`
+//
+// They're ended on this VirtualThread, innermost first, and begun again on `to` in the same order they were
+// begun, nested inside the durations that are open there. The innermost one is connected across the move
+// by a flow, so the viewer shows where the work continued
+func (v *VirtualThread) MoveSpans(to *VirtualThread, count int, timestamp uint64) error {
+	if to == v {
+		return nil
+	}
+
+	// Lock both VirtualThreads in the same order, whichever direction spans are being moved in
+	first, second := v, to
+	if second.threadId < first.threadId {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	if count < 0 || count > len(v.open) {
+		return fmt.Errorf("can't move %d durations from virtual thread %s, which has %d open", count, v.name, len(v.open))
+	}
+	if count == 0 {
+		return nil
+	}
+
+	moved := v.open[len(v.open)-count:]
+	innermost := moved[len(moved)-1]
+	flowId := NewCorrelationId()
+	if err := v.writer.AddFlowBeginEvent(innermost.category, innermost.name, v.processId, v.threadId, timestamp, flowId); err != nil {
+		return fmt.Errorf("failed to move %s/%s from virtual thread %s - %w", innermost.category, innermost.name, v.name, err)
+	}
+	for i := len(moved) - 1; i >= 0; i-- {
+		span := moved[i]
+		if err := v.writer.AddDurationEndEvent(span.category, span.name, v.processId, v.threadId, timestamp); err != nil {
+			return fmt.Errorf("failed to move %s/%s from virtual thread %s - %w", span.category, span.name, v.name, err)
+		}
+	}
+	for _, span := range moved {
+		if err := to.writer.AddDurationBeginEvent(span.category, span.name, to.processId, to.threadId, timestamp); err != nil {
+			return fmt.Errorf("failed to move %s/%s to virtual thread %s - %w", span.category, span.name, to.name, err)
+		}
+	}
+	if err := to.writer.AddFlowEndEvent(innermost.category, innermost.name, to.processId, to.threadId, timestamp, flowId); err != nil {
+		return fmt.Errorf("failed to move %s/%s to virtual thread %s - %w", innermost.category, innermost.name, to.name, err)
+	}
+
+	// The begin timestamps are kept, so the spans still remember when the work started
+	to.open = append(to.open, moved...)
+	v.open = v.open[:len(v.open)-count]
+	return nil
+}
@@ -0,0 +1,82 @@
+package fxt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/richiesams/fxt"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualThread(t *testing.T) {
+	var buffer bytes.Buffer
+	var warnings []error
+	writer, err := fxt.NewWriterTo(&buffer, fxt.WithSpanCheck(), fxt.WithFlowCheck(), fxt.WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	require.NoError(t, err)
+
+	first, err := fxt.NewVirtualThread(writer, 1, "Worker 1")
+	require.NoError(t, err)
+	second, err := fxt.NewVirtualThread(writer, 1, "Worker 2")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, first.Thread().ThreadId, fxt.VirtualThreadIdBase)
+	require.NotEqual(t, first.Thread(), second.Thread())
+	require.Equal(t, "Worker 2", second.Name())
+
+	require.NoError(t, first.Begin("jobs", "Loop", 1, nil))
+	require.NoError(t, first.Begin("jobs", "Fiber", 2, map[string]interface{}{"id": int32(7)}))
+	require.NoError(t, second.Begin("jobs", "Loop", 3, nil))
+
+	// The fiber resumes on the second worker
+	require.Error(t, first.MoveSpans(second, 3, 4))
+	require.NoError(t, first.MoveSpans(second, 1, 4))
+	require.Equal(t, 1, first.OpenSpans())
+	require.Equal(t, 2, second.OpenSpans())
+
+	require.NoError(t, second.Instant("jobs", "Yield", 5, nil))
+	require.NoError(t, second.End(6, nil))
+	require.NoError(t, second.End(7, nil))
+	require.NoError(t, first.End(8, nil))
+	require.Error(t, first.End(9, nil))
+	require.NoError(t, first.Complete("jobs", "Steal", 9, 10, nil))
+	require.NoError(t, writer.Close())
+	require.Empty(t, warnings)
+
+	reader, err := fxt.NewReaderFromBytes(buffer.Bytes())
+	require.NoError(t, err)
+	lanes := map[fxt.KernelObjectID]string{first.Thread().ThreadId: "1", second.Thread().ThreadId: "2"}
+	var events []string
+	for {
+		event, err := reader.NextEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		lane := lanes[event.Thread.ThreadId]
+		switch event.Decoded.(type) {
+		case *fxt.DurationBeginEvent:
+			events = append(events, fmt.Sprintf("%s+%s@%d", lane, event.Name, event.Timestamp))
+		case *fxt.DurationEndEvent:
+			events = append(events, fmt.Sprintf("%s-%s@%d", lane, event.Name, event.Timestamp))
+		case *fxt.FlowBeginEvent:
+			events = append(events, fmt.Sprintf("%s>%s@%d", lane, event.Name, event.Timestamp))
+		case *fxt.FlowEndEvent:
+			events = append(events, fmt.Sprintf("%s<%s@%d", lane, event.Name, event.Timestamp))
+		default:
+			events = append(events, fmt.Sprintf("%s %s@%d", lane, event.Name, event.Timestamp))
+		}
+	}
+
+	require.Equal(t, []string{
+		"1+Loop@1", "1+Fiber@2", "2+Loop@3",
+		"1>Fiber@4", "1-Fiber@4", "2+Fiber@4", "2<Fiber@4",
+		"2 Yield@5", "2-Fiber@6", "2-Loop@7", "1-Loop@8",
+		"1 Steal@9",
+	}, events)
+}
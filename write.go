@@ -1,11 +1,15 @@
 package fxt
 
 import (
-	"encoding/binary"
+	"bufio"
+	"container/list"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // KernelObjectID is a unique identifier for a kernel object
@@ -20,97 +24,441 @@ type Thread struct {
 
 // NewWriter creates a new FXT file at `filePath` and initializes it with the FXT header
 // It returns a Writer instance which can be used to add records to the file
-func NewWriter(filePath string) (*Writer, error) {
-	file, err := os.Create(filePath)
+//
+// The behavior of the Writer can be customized with WriterOptions
+func NewWriter(filePath string, options ...WriterOption) (*Writer, error) {
+	file, err := createFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open dest file %s - %w", filePath, err)
 	}
 
+	writer, err := newWriter(file, file, filePath, options)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// NewWriterTo creates a Writer which writes an FXT trace to `dst`, starting with the FXT header
+// This can be used to write traces to a network connection, a buffer, etc
+//
+// Close doesn't close `dst`. That's the responsibility of the caller
+func NewWriterTo(dst io.Writer, options ...WriterOption) (*Writer, error) {
+	return newWriter(dst, nil, "", options)
+}
+
+// newWriter creates a Writer writing to `out`. `filePath` is the path of the file it is, if it's known
+func newWriter(out io.Writer, closer io.Closer, filePath string, options []WriterOption) (*Writer, error) {
 	writer := &Writer{
-		file:            file,
-		stringTable:     map[string]uint16{},
-		nextStringIndex: 1,
-		threadTable:     map[Thread]uint16{},
-		nextThreadIndex: 1,
+		warningHandler: defaultWarningHandler,
+		formatVersion:  LatestFormatVersion,
+	}
+	for _, option := range options {
+		option(writer)
+	}
+
+	if !writer.formatVersion.IsSupported() {
+		return nil, fmt.Errorf("unsupported FXT format version %s", writer.formatVersion)
 	}
 
+	writer.resetState(out, closer)
+	writer.chunkPath = filePath
 	if err := writer.writeMagicNumberRecord(); err != nil {
 		return nil, err
 	}
+	if err := writer.addContinuation(); err != nil {
+		return nil, err
+	}
+	writer.startFlusher()
+	writer.startSignalHandlers()
 
 	return writer, nil
 }
 
 // Writer is a struct for writing an FXT file. It has methods for adding records to the file
+//
+// All methods are safe to call from multiple goroutines
 type Writer struct {
-	file *os.File
+	mu  sync.Mutex
+	out io.Writer
+	// dst is the destination the trace is written to, under the buffering and checksumming wrapped around it
+	dst io.Writer
+	// disabled is set by SetEnabled(false), and makes the methods adding events return without writing anything
+	disabled atomic.Bool
+	// closer is the file the Writer opened, if any
+	closer io.Closer
+	// createFile, if set, replaces os.Create for the files opened by Reset
+	createFile func(filePath string) (io.WriteCloser, error)
+
+	// buffered is the buffer `out` writes to, when the Writer has a flush policy
+	buffered     *bufio.Writer
+	bufferOutput bool
+	flushPolicy  FlushPolicy
+	// stopFlusher stops the goroutine doing timed flushes, if there is one
+	stopFlusher chan struct{}
+	// signalActions are the actions set with WithSignalAction, and stopSignals stops the goroutines running them
+	signalActions []signalAction
+	stopSignals   chan struct{}
+	// scratch is reused to encode each record, so writing a record doesn't allocate
+	scratch         []byte
+	argumentScratch argumentScratch
+	// pipeline encodes and writes the records on other goroutines, if pipelineWorkers is set by WithPipeline
+	pipeline        *pipeline
+	pipelineWorkers int
+	// formatVersion is the revision of the spec the records are written in
+	formatVersion FormatVersion
 
 	stringTable     map[string]uint16
 	nextStringIndex uint16
+	// stringBytes is the total length of the strings in the string table
+	stringBytes int
+	// With TableLimits.Recycle, the string table entries from most to least recently used, and the indices
+	// that were freed to make room for a longer string
+	stringLRU         *list.List
+	stringEntries     map[uint16]*list.Element
+	freeStringIndices []uint16
+	stringUses        uint64
+
 	threadTable     map[Thread]uint16
 	nextThreadIndex uint16
+
+	tableLimits    TableLimits
+	hasTableLimits bool
+
+	longStringPolicy    LongStringPolicy
+	nextSpilledStringId uint64
+
+	warningHandler WarningHandler
+	timestampCheck TimestampCheck
+	lastTimestamps map[Thread]uint64
+
+	// With WithCloseOpenSpans, WithSpanCheck, or WithFlowCheck, the duration begin events on each thread
+	// that haven't been ended, and the latest timestamp written, to end them at
+	closeOpenSpans  bool
+	checkSpans      bool
+	openSpans       spanStacks
+	latestTimestamp uint64
+	// With WithAsyncCheck, the async spans that have begun, but not ended
+	checkAsync bool
+	openAsync  map[correlatedSpan]struct{}
+	// With WithFlowCheck, the flows that have begun, but not ended
+	checkFlows bool
+	openFlows  map[correlatedSpan]struct{}
+	// With WithGoroutineThreads, the threads that have been named in the trace, so each goroutine is only named once
+	goroutineThreads bool
+	namedThreads     map[Thread]struct{}
+	goroutineNames   map[Thread]struct{}
+	// callerLocations is set by WithCallerLocations
+	callerLocations bool
+	// interceptors are the functions added by AddInterceptor. ThreadWriters run them without the lock, so the
+	// slice is replaced, rather than appended to
+	interceptors atomic.Pointer[[]Interceptor]
+	// closed is set by Close, which returns closeErr when it's called again. Reset starts a new trace
+	closed   bool
+	closeErr error
+	// With WithCloseSummary, Close writes a summary of the trace, from when it started, the bytes written to it,
+	// and the events dropped by closed ThreadWriters
+	closeSummary  bool
+	traceStart    time.Time
+	counter       *countingWriter
+	droppedEvents uint64
+	// With WithChecksums, the size of the checksummed chunks, and the writer checksumming them
+	checksumInterval int
+	checksums        *checksumWriter
+	// With WithSizeRotation, the size files are rotated at, the paths of the files after the first, and the
+	// size of the current file. continuation and chunkPath are the current file's, and rotating is set while
+	// switching files
+	rotateBytes  int64
+	rotatePath   func(sequence uint64) string
+	chunkSize    *chunkSizeWriter
+	continuation Continuation
+	chunkPath    string
+	rotating     bool
+	// The IDs of the stacks written by AddStack, and the PCs whose symbols have been written
+	stacks     map[uint64]struct{}
+	symbolized map[uint64]struct{}
+
+	// The provider info and initialization records written so far, so Reset can write them again
+	providers []providerInfo
+	tickRate  TickRate
+
+	normalizeTimestamps bool
+	timestampOffset     uint64
+	hasTimestampOffset  bool
+	// fixedTimestampOffset is true when the offset was set with WithTimestampOffset, rather than
+	// taken from the first timestamp
+	fixedTimestampOffset bool
+
+	// threadWriters are the ThreadWriters whose buffers are merged into the trace on Flush
+	threadWriters []*ThreadWriter
+	// generation changes every time the string and thread tables are cleared, so ThreadWriters know
+	// to drop the indices they've cached. It's only changed with the lock held
+	generation atomic.Uint64
+	// providerSection is the provider the records currently being written belong to, if hasProviderSection is set
+	providerSection    uint32
+	hasProviderSection bool
 }
 
-// Close closes the underlying file
-func (w *Writer) Close() error {
-	return w.file.Close()
+type providerInfo struct {
+	id   uint32
+	name string
 }
 
-func (w *Writer) writeMagicNumberRecord() error {
-	if _, err := w.file.Write(fxtMagic); err != nil {
-		return fmt.Errorf("failed to write magic number record - %w", err)
-	}
-	return nil
+// resetState points the Writer at a new destination, and clears all the state tied to the trace
+// being written. Options and the provider / tick rate metadata are kept
+func (w *Writer) resetState(out io.Writer, closer io.Closer) {
+	w.setOutput(out, closer)
+	w.newCapture()
+	w.stringTable = map[string]uint16{}
+	w.nextStringIndex = 1
+	w.stringBytes = 0
+	w.resetStringEntries()
+	w.threadTable = map[Thread]uint16{}
+	w.nextThreadIndex = 1
+	w.nextSpilledStringId = 0
+	w.lastTimestamps = map[Thread]uint64{}
+	w.openSpans = spanStacks{}
+	w.latestTimestamp = 0
+	w.openAsync = map[correlatedSpan]struct{}{}
+	w.openFlows = map[correlatedSpan]struct{}{}
+	w.namedThreads = map[Thread]struct{}{}
+	w.goroutineNames = map[Thread]struct{}{}
+	w.closed = false
+	w.closeErr = nil
+	w.traceStart = time.Now()
+	w.stacks = map[uint64]struct{}{}
+	w.symbolized = map[uint64]struct{}{}
+	if !w.fixedTimestampOffset {
+		w.hasTimestampOffset = false
+	}
+	w.hasProviderSection = false
+	w.generation.Add(1)
 }
 
-// AddProviderInfoRecord adds a provider info metadata record to the file
+// setOutput points the Writer at a new destination, wrapping it in the buffering, counting, and checksumming
+// the options ask for. The pipeline, if there is one, must be drained
+func (w *Writer) setOutput(out io.Writer, closer io.Closer) {
+	w.out = out
+	w.dst = out
+	w.closer = closer
+	w.bufferedOutput()
+	w.countedOutput()
+	w.checksummedOutput()
+	w.chunkedOutput()
+	w.pipelinedOutput()
+}
+
+// Reset closes the current file, and starts a new trace in a new file at `filePath`
 //
-// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-info-metadata
-func (w *Writer) AddProviderInfoRecord(providerId uint32, providerName string) error {
-	nameBytes := []byte(providerName)
-	nameLen := len(nameBytes)
-	if nameLen > math.MaxUint8 {
-		return fmt.Errorf("provider name is too long")
+// The string and thread tables are cleared, and the magic number, provider info, and initialization
+// records that were written to the previous trace are written again. This allows long-running processes
+// to take repeated captures with the same Writer
+//
+// If the previous trace can't be finished, like when its file can't be written, the error is returned, but
+// the Writer still starts the new trace
+func (w *Writer) Reset(filePath string) error {
+	create := createFile
+	if w.createFile != nil {
+		create = w.createFile
+	}
+	file, err := create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open dest file %s - %w", filePath, err)
 	}
 
-	paddedNameLen := (nameLen + 8 - 1) & (-8)
-	diff := paddedNameLen - nameLen
+	// The Writer switches to the new file even if it returns an error, so the file is closed with the Writer
+	return w.reset(file, file, filePath)
+}
+
+// ResetTo is the same as Reset, but it starts the new trace in `dst`
+// As with NewWriterTo, `dst` isn't closed by the Writer
+func (w *Writer) ResetTo(dst io.Writer) error {
+	return w.reset(dst, nil, "")
+}
 
-	sizeInWords := 1 + (paddedNameLen / 8)
+func (w *Writer) reset(out io.Writer, closer io.Closer, filePath string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	header := (uint64(nameLen) << 52) | (uint64(providerId) << 20) | (uint64(metadataTypeProviderInfo) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeMetadata)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+	// The buffered events refer to the old tables, so they belong in the previous trace. The generation
+	// changes first, so that no more events using the old tables can be buffered after they're written
+	w.generation.Add(1)
+	// The previous trace is finished as far as it can be. If its destination failed, the Writer still switches
+	// to the new one, and the error is returned once it has
+	finishErr := w.endOpenSpans()
+	if finishErr == nil && !w.closed {
+		finishErr = w.addFooter()
+	}
+	if err := w.flush(); finishErr == nil {
+		finishErr = err
+	}
+
+	if w.closer != nil {
+		// The previous file may have been closed with Close already
+		if err := w.closer.Close(); err != nil && !errors.Is(err, os.ErrClosed) && finishErr == nil {
+			finishErr = fmt.Errorf("failed to close the previous file - %w", err)
+		}
+	}
+	if w.pipeline != nil {
+		// The pipeline has to be drained to switch destinations, even if flush stopped early. The errors writing
+		// to the previous destination don't carry over to the new one
+		w.pipeline.drain()
+		w.pipeline.clearErr()
 	}
 
-	if _, err := w.file.Write(nameBytes); err != nil {
-		return fmt.Errorf("failed to write provider name data - %w", err)
+	w.resetState(out, closer)
+	w.chunkPath = filePath
+	// Close stops the timed flushes and signal handlers, so they're started again for the new trace
+	if w.stopFlusher == nil {
+		w.startFlusher()
+	}
+	if w.stopSignals == nil {
+		w.startSignalHandlers()
 	}
-	if diff > 0 {
-		buffer := make([]byte, diff)
-		if _, err := w.file.Write(buffer); err != nil {
-			return fmt.Errorf("failed to write provider name padding - %w", err)
+	if err := w.writeMagicNumberRecord(); err != nil {
+		return err
+	}
+	if err := w.addContinuation(); err != nil {
+		return err
+	}
+	for _, provider := range w.providers {
+		if err := w.addProviderInfoRecord(provider.id, provider.name); err != nil {
+			return err
+		}
+	}
+	if w.tickRate != 0 {
+		if err := w.addInitializationRecord(w.tickRate); err != nil {
+			return err
 		}
 	}
 
-	n, err := w.file.Seek(0, io.SeekCurrent)
+	if finishErr != nil {
+		return fmt.Errorf("failed to finish the previous trace - %w", finishErr)
+	}
+	return nil
+}
+
+// FormatVersion returns the format version of the trace being written
+func (w *Writer) FormatVersion() FormatVersion {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.formatVersion
+}
+
+// TickRate returns the number of ticks per second from the last initialization record,
+// or zero if none has been written
+func (w *Writer) TickRate() TickRate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.tickRate
+}
+
+// Flush writes the events buffered by the Writer's ThreadWriters to the trace, and then writes
+// any records buffered because of WithFlushPolicy to the destination
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.flush()
+}
+
+// Close flushes the Writer, including the buffers of WithFlushPolicy, WithPipeline, and the ThreadWriters,
+// and closes the underlying file. Writers created with NewWriterTo don't close their destination
+//
+// With WithCloseSummary, the trace ends with a summary record, and with WithChecksums, a footer checksum. Close can be called more than once, and later
+// calls return the result of the first, until Reset starts a new trace
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return w.closeErr
+	}
+	w.closed = true
+	w.closeErr = w.close()
+	return w.closeErr
+}
+
+// close ends the trace. The Writer's lock must be held
+func (w *Writer) close() error {
+	if w.stopFlusher != nil {
+		close(w.stopFlusher)
+		w.stopFlusher = nil
+	}
+	if w.stopSignals != nil {
+		close(w.stopSignals)
+		w.stopSignals = nil
+	}
+	err := w.endOpenSpans()
+	if w.closeSummary && err == nil {
+		// Everything before the summary has to be written, to be counted
+		if err = w.flush(); err == nil {
+			err = w.addSummary()
+		}
+	}
+	if err == nil {
+		err = w.addFooter()
+	}
+	if flushErr := w.flush(); err == nil {
+		err = flushErr
+	}
+	if w.pipeline != nil {
+		// The error is the same one flush returned. Reset starts a new pipeline, if the Writer is reused
+		w.pipeline.stop()
+		w.pipeline = nil
+	}
 	if err != nil {
 		return err
 	}
-	fmt.Print(n)
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+func (w *Writer) writeMagicNumberRecord() error {
+	return w.write(magicNumberRecords[w.formatVersion], "magic number record")
+}
+
+// AddProviderInfoRecord adds a provider info metadata record to the file
+//
+// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-info-metadata
+func (w *Writer) AddProviderInfoRecord(providerId uint32, providerName string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.addProviderInfoRecord(providerId, providerName); err != nil {
+		return err
+	}
+	w.providers = append(w.providers, providerInfo{id: providerId, name: providerName})
 
 	return nil
 }
 
+func (w *Writer) addProviderInfoRecord(providerId uint32, providerName string) error {
+	return w.writeRecord(ProviderInfoRecord{ProviderId: providerId, Name: providerName}.appendRecord(w.scratch[:0]))
+}
+
 // AddProviderSectionRecord adds a provider section metadata record to the file
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-section-metadata
 func (w *Writer) AddProviderSectionRecord(providerId uint32) error {
-	sizeInWords := 1
-	header := (uint64(providerId) << 20) | (uint64(metadataTypeProviderSection) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeMetadata)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.addProviderSectionRecord(providerId)
+}
+
+func (w *Writer) addProviderSectionRecord(providerId uint32) error {
+	if err := w.writeRecord(ProviderSectionRecord{ProviderId: providerId}.appendRecord(w.scratch[:0])); err != nil {
+		return err
 	}
+	w.providerSection = providerId
+	w.hasProviderSection = true
 
 	return nil
 }
@@ -118,14 +466,11 @@ func (w *Writer) AddProviderSectionRecord(providerId uint32) error {
 // AddProviderEventRecord adds a provider event metadata record to the file
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#provider-event-metadata
-func (w *Writer) AddProviderEventRecord(providerId uint32, eventType providerEventType) error {
-	sizeInWords := 1
-	header := (uint64(eventType) << 52) | (uint64(providerId) << 20) | (uint64(metadataTypeProviderEvent) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeMetadata)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
+func (w *Writer) AddProviderEventRecord(providerId uint32, eventType ProviderEventType) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	return nil
+	return w.writeRecord(ProviderEventRecord{ProviderId: providerId, EventType: eventType}.appendRecord(w.scratch[:0]))
 }
 
 // AddInitializationRecord adds an initialization record to the file
@@ -134,63 +479,59 @@ func (w *Writer) AddProviderEventRecord(providerId uint32, eventType providerEve
 //
 // This specifies the number of ticks per second for all event records after this
 func (w *Writer) AddInitializationRecord(numTicksPerSecond uint64) error {
-	sizeInWords := 2
-	header := (uint64(sizeInWords) << 4) | uint64(recordTypeInitialization)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+	return w.AddTickRateRecord(TickRate(numTicksPerSecond))
+}
+
+// AddTickRateRecord is the same as AddInitializationRecord, but it takes a TickRate. It can be one of the
+// presets, like TicksNanoseconds, or a custom rate, like the TSC frequency
+func (w *Writer) AddTickRateRecord(rate TickRate) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if rate == 0 {
+		return fmt.Errorf("invalid number of ticks per second - must be greater than zero")
 	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, numTicksPerSecond); err != nil {
-		return fmt.Errorf("failed to write number of ticks per second - %w", err)
+	if err := w.addInitializationRecord(rate); err != nil {
+		return err
 	}
+	w.tickRate = rate
 
 	return nil
 }
 
-func (w *Writer) addStringRecord(stringIndex uint16, str string) error {
-	strBytes := []byte(str)
-	strLen := len(strBytes)
-	if strLen > math.MaxUint8 {
-		return fmt.Errorf("string is too long")
-	}
-
-	paddedStrLen := (strLen + 8 - 1) & (-8)
-	diff := paddedStrLen - strLen
-
-	sizeInWords := 1 + (paddedStrLen / 8)
-	header := (uint64(strLen) << 32) | (uint64(stringIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeString)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
+func (w *Writer) addInitializationRecord(numTicksPerSecond TickRate) error {
+	return w.writeRecord(InitializationRecord{TicksPerSecond: numTicksPerSecond}.appendRecord(w.scratch[:0]))
+}
 
-	if _, err := w.file.Write(strBytes); err != nil {
-		return fmt.Errorf("failed to write string data - %w", err)
-	}
-	if diff > 0 {
-		buffer := make([]byte, diff)
-		if _, err := w.file.Write(buffer); err != nil {
-			return fmt.Errorf("failed to write string padding - %w", err)
-		}
+// writeRecord writes a record that was encoded into the scratch buffer, like so:
+//
+//	w.writeRecord(InstantEvent{...}.appendRecord(w.scratch[:0]))
+//
+// appendRecord is called on the record struct directly, rather than through the recordAppender interface,
+// so the struct doesn't escape to the heap
+func (w *Writer) writeRecord(data []byte, err error) error {
+	if err != nil {
+		return err
 	}
+	// Keep the buffer around, so the next record can reuse it
+	w.scratch = data[:0]
 
-	return nil
+	return w.write(data, "record")
 }
 
-func (w *Writer) addThreadRecord(threadIndex uint16, processId KernelObjectID, threadId KernelObjectID) error {
-	sizeInWords := 3
-	header := (uint64(threadIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeThread)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+// write writes encoded records to the trace, or queues a copy of them in the pipeline, if there is one
+// `what` describes the records, for errors
+func (w *Writer) write(data []byte, what string) error {
+	if err := w.maybeRotate(); err != nil {
+		return err
 	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, processId); err != nil {
-		return fmt.Errorf("failed to write process ID - %w", err)
+	if w.pipeline != nil {
+		return w.pipeline.submitData(data)
 	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, threadId); err != nil {
-		return fmt.Errorf("failed to write thread ID - %w", err)
+	if _, err := w.out.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s - %w", what, err)
 	}
-
 	return nil
 }
 
@@ -203,332 +544,279 @@ func (w *Writer) getStringIndex(str string) (uint16, error) {
 	return index, nil
 }
 
-func (w *Writer) getOrCreateStringIndex(str string) (uint16, error) {
+// getOrCreateStringRef returns a reference to `str` in the string table, adding it if needed
+// With WithTableLimits, strings that don't fit in the table are written inline instead
+func (w *Writer) getOrCreateStringRef(str string) (StringRef, error) {
 	index, ok := w.stringTable[str]
 	if !ok {
-		index = w.nextStringIndex
-		w.nextStringIndex++
-		w.stringTable[str] = index
-		if err := w.addStringRecord(index, str); err != nil {
-			return 0, fmt.Errorf("failed to add string record for `%s` - %w", str, err)
+		if w.stringsFull(len(str)) {
+			recycled, err := w.recycleStrings(len(str))
+			if err != nil {
+				return StringRef{}, err
+			}
+			if !recycled && w.hasTableLimits {
+				return StringRef{Inline: str}, nil
+			}
+			if !recycled {
+				return StringRef{}, fmt.Errorf("failed to add `%s` to the string table - the table is full", str)
+			}
+		}
+		index = w.nextFreeStringIndex()
+		if err := w.writeRecord(StringRecord{Index: index, Value: str}.appendRecord(w.scratch[:0])); err != nil {
+			return StringRef{}, fmt.Errorf("failed to add string record for `%s` - %w", str, err)
 		}
+		if n := len(w.freeStringIndices); n > 0 {
+			w.freeStringIndices = w.freeStringIndices[:n-1]
+		} else {
+			w.nextStringIndex++
+		}
+		w.stringTable[str] = index
+		w.stringBytes += len(str)
 	}
+	w.useString(index, str)
 
-	return index, nil
+	return StringRef{Index: index}, nil
 }
 
-func (w *Writer) getOrCreateThreadIndex(processId KernelObjectID, threadId KernelObjectID) (uint16, error) {
+// getOrCreateThreadRef returns a reference to a thread in the thread table, adding it if needed
+// With WithTableLimits or WithGoroutineThreads, threads that don't fit in the table are written inline instead
+func (w *Writer) getOrCreateThreadRef(processId KernelObjectID, threadId KernelObjectID) (ThreadRef, error) {
 	thread := Thread{ProcessId: processId, ThreadId: threadId}
 	threadIndex, ok := w.threadTable[thread]
 	if !ok {
+		if w.nextThreadIndex > maxThreadIndex || (w.tableLimits.Threads > 0 && len(w.threadTable) >= w.tableLimits.Threads) {
+			if w.hasTableLimits || w.goroutineThreads {
+				return ThreadRef{Inline: thread}, nil
+			}
+			return ThreadRef{}, fmt.Errorf("failed to add thread %d/%d to the thread table - the table is full", processId, threadId)
+		}
 		threadIndex = w.nextThreadIndex
+		if err := w.writeRecord(ThreadRecord{Index: uint8(threadIndex), ProcessId: processId, ThreadId: threadId}.appendRecord(w.scratch[:0])); err != nil {
+			return ThreadRef{}, fmt.Errorf("failed to add thread record - %w", err)
+		}
 		w.nextThreadIndex++
 		w.threadTable[thread] = threadIndex
-		if err := w.addThreadRecord(threadIndex, processId, threadId); err != nil {
-			return 0, fmt.Errorf("failed to add thread record - %w", err)
-		}
 	}
 
-	return threadIndex, nil
+	return ThreadRef{Index: uint8(threadIndex)}, nil
 }
 
 // SetProcessName adds a kernel object record to give a human-readable name to a process ID
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#kernel-object-record
 func (w *Writer) SetProcessName(processId KernelObjectID, name string) error {
-	nameIndex, err := w.getOrCreateStringIndex(name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nameRef, err := w.getOrCreateStringRef(name)
 	if err != nil {
 		return err
 	}
 
-	sizeInWords := /* header */ 1 + /* processID */ 1
-	numArgs := 0
-	header := (uint64(numArgs) << 40) | (uint64(nameIndex) << 24) | (uint64(koidTypeProcess) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeKernelObject)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, processId); err != nil {
-		return fmt.Errorf("failed to write process ID - %w", err)
-	}
-
-	return nil
+	return w.writeRecord(KernelObjectRecord{
+		Type: KernelObjectTypeProcess,
+		Koid: processId,
+		Name: nameRef,
+	}.appendRecord(w.scratch[:0]))
 }
 
 // SetThreadName adds a kernel object record
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#kernel-object-record
+//
+// With WithGoroutineThreads, `threadId` can be CurrentGoroutine to name the calling goroutine
 func (w *Writer) SetThreadName(processId KernelObjectID, threadId KernelObjectID, name string) error {
-	nameIndex, err := w.getOrCreateStringIndex(name)
-	if err != nil {
-		return err
+	if threadId == CurrentGoroutine {
+		if !w.goroutineThreads {
+			return errCurrentGoroutine
+		}
+		threadId = GoroutineId()
 	}
 
-	processIndex, err := w.getOrCreateStringIndex("process")
-	if err != nil {
-		return err
-	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	argumentSizeInWords := 2
+	return w.setThreadName(processId, threadId, name)
+}
 
-	sizeInWords := /* header */ 1 + /* threadID */ 1 + /* argument data */ argumentSizeInWords
-	numArgs := 1
-	header := (uint64(numArgs) << 40) | (uint64(nameIndex) << 24) | (uint64(koidTypeThread) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeKernelObject)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+// setThreadName writes the kernel object record naming a thread. The Writer's lock must be held
+func (w *Writer) setThreadName(processId KernelObjectID, threadId KernelObjectID, name string) error {
+	nameRef, err := w.getOrCreateStringRef(name)
+	if err != nil {
+		return err
 	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, threadId); err != nil {
-		return fmt.Errorf("failed to write thread ID - %w", err)
+	if err := w.writeThreadName(processId, threadId, nameRef); err != nil {
+		return err
 	}
 
-	// Write KIOD Argument to reference the process ID
-	argHeader := (uint64(processIndex) << 16) | (uint64(argumentSizeInWords) << 4) | uint64(argumentTypeKOID)
-	if err := binary.Write(w.file, binary.LittleEndian, argHeader); err != nil {
-		return fmt.Errorf("failed to write argument header - %w", err)
+	if w.goroutineThreads {
+		w.namedThreads[Thread{ProcessId: processId, ThreadId: threadId}] = struct{}{}
 	}
+	return nil
+}
 
-	if err := binary.Write(w.file, binary.LittleEndian, processId); err != nil {
-		return fmt.Errorf("failed to write process ID - %w", err)
+// writeThreadName writes the kernel object record naming a thread. The Writer's lock must be held
+func (w *Writer) writeThreadName(processId KernelObjectID, threadId KernelObjectID, nameRef StringRef) error {
+	processRef, err := w.getOrCreateStringRef("process")
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return w.writeRecord(KernelObjectRecord{
+		Type: KernelObjectTypeThread,
+		Koid: threadId,
+		Name: nameRef,
+		// KOID Argument to reference the process ID
+		Arguments: []Argument{{Key: processRef, Value: processId}},
+	}.appendRecord(w.scratch[:0]))
 }
 
-// writeEventHeaderAndGenericData is a helper function for all event record methods
+// newEventRecord is a helper function for all event record methods
 // All events share the same basic header and initial data sections
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#event-record
 //
-// This function writes the header and the common data
-func (w *Writer) writeEventHeaderAndGenericData(eventType eventType, category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}, extraSizeInWords int) error {
-	categoryIndex, err := w.getOrCreateStringIndex(category)
+// This function prepares the timestamp, and interns the strings and thread of the common data
+func (w *Writer) newEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) (EventRecord, error) {
+	timestamp, err := w.prepareTimestamp(processId, threadId, timestamp)
 	if err != nil {
-		return err
+		return EventRecord{}, err
 	}
 
-	nameIndex, err := w.getOrCreateStringIndex(name)
+	return w.internEventRecord(category, name, processId, threadId, timestamp, arguments)
+}
+
+// internEventRecord is the same as newEventRecord, but it uses `timestamp` as is
+func (w *Writer) internEventRecord(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) (EventRecord, error) {
+	categoryRef, err := w.getOrCreateStringRef(category)
 	if err != nil {
-		return err
+		return EventRecord{}, err
 	}
 
-	threadIndex, err := w.getOrCreateThreadIndex(processId, threadId)
+	nameRef, err := w.getOrCreateStringRef(name)
 	if err != nil {
-		return err
+		return EventRecord{}, err
 	}
 
-	// Add up the argument word size
-	// And ensure the argument keys (and string values) are in the string table
-	argumentSizeInWords := 0
-	for key, value := range arguments {
-		size, err := getArgumentSizeInWords(value)
-		if err != nil {
-			return err
-		}
-		argumentSizeInWords += size
-
-		if err := w.addArgumentStringsToTable(key, value); err != nil {
-			return err
-		}
+	threadRef, err := w.getOrCreateThreadRef(processId, threadId)
+	if err != nil {
+		return EventRecord{}, err
 	}
 
-	sizeInWords := /* Header */ 1 + /* timestamp */ 1 + /* argument data */ argumentSizeInWords + /* extra stuff */ extraSizeInWords
-	numArgs := len(arguments)
-	header := (uint64(nameIndex) << 48) | (uint64(categoryIndex) << 32) | (uint64(threadIndex) << 24) | (uint64(numArgs) << 20) | (uint64(eventType) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeEvent)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+	// Ensure the argument keys (and string values) are in the string table
+	args, err := w.prepareArguments(arguments)
+	if err != nil {
+		return EventRecord{}, err
 	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, timestamp); err != nil {
-		return fmt.Errorf("failed to write timestamp - %w", err)
-	}
+	return EventRecord{
+		Category:  categoryRef,
+		Name:      nameRef,
+		Thread:    threadRef,
+		Timestamp: timestamp,
+		Arguments: args,
+	}, nil
+}
 
-	wordsWritten := 0
-	for key, value := range arguments {
-		size, err := w.writeArgument(key, value)
-		if err != nil {
-			return err
-		}
-		wordsWritten += size
-	}
-	if wordsWritten != argumentSizeInWords {
-		return fmt.Errorf("Expected to write %d words of argument data, but actually wrote %d", argumentSizeInWords, wordsWritten)
+// stringInterner adds strings to the Writer's string table. It's implemented by the Writer, and by
+// ThreadWriter, which caches the indices
+type stringInterner interface {
+	getOrCreateStringRef(str string) (StringRef, error)
+	handleLongString(key string, value string) (interface{}, error)
+}
+
+// prepareArguments converts the argument values to the types that can be encoded, and ensures the
+// argument keys (and string values) are in the string table
+//
+// The arguments are sorted by key, so the output doesn't depend on map iteration order
+//
+// The returned slice is reused by the next call, so it must be encoded before then. With a pipeline,
+// the events are encoded later, so each call gets a new slice instead
+func (w *Writer) prepareArguments(arguments map[string]interface{}) ([]Argument, error) {
+	if w.pipeline != nil {
+		return prepareArguments(w, &argumentScratch{}, arguments)
 	}
+	return prepareArguments(w, &w.argumentScratch, arguments)
+}
 
-	return nil
+// argumentScratch holds the slices prepareArguments reuses, so preparing arguments doesn't allocate
+type argumentScratch struct {
+	keys     []string
+	prepared []Argument
+	// stringValues caches StringRefs converted to interface{} by index, since the conversion allocates
+	stringValues map[uint16]interface{}
 }
 
-func getArgumentSizeInWords(value interface{}) (int, error) {
-	if value == nil {
-		return 1, nil
-	}
-
-	switch value.(type) {
-	case int32:
-		return 1, nil
-	case uint32:
-		return 1, nil
-	case int64:
-		return 2, nil
-	case uint64:
-		return 2, nil
-	case float64:
-		return 2, nil
-	case string:
-		return 1, nil
-	case uintptr:
-		return 2, nil
-	case KernelObjectID:
-		return 2, nil
-	case bool:
-		return 1, nil
-	default:
-		return 0, fmt.Errorf("invalid value type `%v` for argument", value)
-	}
-}
-
-func (w *Writer) addArgumentStringsToTable(key string, value interface{}) error {
-	_, err := w.getOrCreateStringIndex(key)
-	if err != nil {
-		return err
+// stringValue returns `ref` as an argument value. References to the string table are cached
+func (s *argumentScratch) stringValue(ref StringRef) interface{} {
+	if ref.Index == 0 {
+		return ref
 	}
 
-	if v, ok := value.(string); ok {
-		_, err := w.getOrCreateStringIndex(v)
-		if err != nil {
-			return err
+	index := ref.Index
+	value, ok := s.stringValues[index]
+	if !ok {
+		if s.stringValues == nil {
+			s.stringValues = map[uint16]interface{}{}
 		}
+		value = StringRef{Index: index}
+		s.stringValues[index] = value
 	}
-
-	return nil
+	return value
 }
 
-// writeArgument will write out a single argument data record
-//
-// https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#argument-types
-func (w *Writer) writeArgument(key string, value interface{}) (numWordsWritten int, err error) {
-	keyIndex, err := w.getStringIndex(key)
-	if err != nil {
-		return 0, err
+func prepareArguments(interner stringInterner, scratch *argumentScratch, arguments map[string]interface{}) ([]Argument, error) {
+	if len(arguments) == 0 {
+		return nil, nil
 	}
 
-	// Check for nil. That will create a null argument
-	if value == nil {
-		sizeInWords := 1
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeNull)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		return sizeInWords, nil
+	keys := scratch.keys[:0]
+	for key := range arguments {
+		keys = append(keys, key)
 	}
-
-	switch v := value.(type) {
-	case int32:
-		sizeInWords := 1
-		header := (uint64(v) << 32) | (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeInt32)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		return sizeInWords, nil
-	case uint32:
-		sizeInWords := 1
-		header := (uint64(v) << 32) | (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeUInt32)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		return sizeInWords, nil
-	case int64:
-		sizeInWords := 2
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeInt64)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		if err := binary.Write(w.file, binary.LittleEndian, v); err != nil {
-			return 0, fmt.Errorf("failed to write argument value - %w", err)
-		}
-
-		return sizeInWords, nil
-	case uint64:
-		sizeInWords := 2
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeUInt64)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		if err := binary.Write(w.file, binary.LittleEndian, v); err != nil {
-			return 0, fmt.Errorf("failed to write argument value - %w", err)
-		}
-
-		return sizeInWords, nil
-	case float64:
-		sizeInWords := 2
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeDouble)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		if err := binary.Write(w.file, binary.LittleEndian, v); err != nil {
-			return 0, fmt.Errorf("failed to write argument value - %w", err)
+	// There are at most 15 arguments, so an insertion sort is plenty. Unlike sort.Strings, it doesn't allocate
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
 		}
+	}
+	scratch.keys = keys
 
-		return sizeInWords, nil
-	case string:
-		valueIndex, err := w.getStringIndex(v)
+	prepared := scratch.prepared[:0]
+	for _, key := range keys {
+		value, err := normalizeArgumentValue(arguments[key])
 		if err != nil {
-			return 0, err
-		}
-
-		sizeInWords := 1
-		header := (uint64(valueIndex) << 32) | (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeString)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		return sizeInWords, nil
-	case uintptr:
-		sizeInWords := 2
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypePointer)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
-		}
-
-		if err := binary.Write(w.file, binary.LittleEndian, uint64(v)); err != nil {
-			return 0, fmt.Errorf("failed to write argument value - %w", err)
-		}
-
-		return sizeInWords, nil
-	case KernelObjectID:
-		sizeInWords := 2
-		header := (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeKOID)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
+			return nil, fmt.Errorf("invalid argument `%s` - %w", key, err)
 		}
-
-		if err := binary.Write(w.file, binary.LittleEndian, v); err != nil {
-			return 0, fmt.Errorf("failed to write argument value - %w", err)
+		if str, ok := value.(string); ok && len(str) > MaxStringLength {
+			value, err = interner.handleLongString(key, str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument `%s` - %w", key, err)
+			}
 		}
 
-		return sizeInWords, nil
-	case bool:
-		valueBit := 0
-		if v {
-			valueBit = 1
+		keyRef, err := interner.getOrCreateStringRef(key)
+		if err != nil {
+			return nil, err
 		}
 
-		sizeInWords := 1
-		header := (uint64(valueBit) << 32) | (uint64(keyIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(argumentTypeBool)
-		if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-			return 0, fmt.Errorf("failed to write argument header - %w", err)
+		switch v := value.(type) {
+		case string:
+			valueRef, err := interner.getOrCreateStringRef(v)
+			if err != nil {
+				return nil, err
+			}
+			value = scratch.stringValue(valueRef)
+		case inlineString:
+			value = StringRef{Inline: string(v)}
 		}
 
-		return sizeInWords, nil
-	default:
-		return 0, fmt.Errorf("invalid value type `%v` for argument `%s`", value, key)
+		prepared = append(prepared, Argument{Key: keyRef, Value: value})
 	}
+	scratch.prepared = prepared
+
+	return prepared, nil
 }
 
 // AddInstantEvent adds an instant event record to the file
@@ -548,12 +836,33 @@ func (w *Writer) AddInstantEvent(category string, name string, processId KernelO
 // AddInstantEventWithArgs is the same as AddInstantEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 0
-	if err := w.writeEventHeaderAndGenericData(eventTypeInstant, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+	if w.callerLocations {
+		arguments = withCallerLocation(arguments)
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindInstant, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	return nil
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
+	}
+
+	return writeEvent(w, InstantEvent{EventRecord: event})
 }
 
 // AddCounterEvent adds a counter event record to the file
@@ -567,16 +876,30 @@ func (w *Writer) AddInstantEventWithArgs(category string, name string, processId
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#string-record
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#thread-record
 func (w *Writer) AddCounterEvent(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}, counterId uint64) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeCounter, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindCounter, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, counterId); err != nil {
-		return fmt.Errorf("failed to write counter ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return writeEvent(w, CounterEvent{EventRecord: event, CounterId: counterId})
 }
 
 // AddDurationBeginEvent adds a duration begin event record to the file
@@ -596,11 +919,36 @@ func (w *Writer) AddDurationBeginEvent(category string, name string, processId K
 // AddDurationBeginEventWithArgs is the same as AddDurationBeginEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddDurationBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 0
-	if err := w.writeEventHeaderAndGenericData(eventTypeDurationBegin, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+	if w.callerLocations {
+		arguments = withCallerLocation(arguments)
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
+		return err
+	}
+	category, name, arguments, ok := w.intercept(EventKindDurationBegin, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
+
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
 		return err
 	}
 
+	if err := writeEvent(w, DurationBeginEvent{EventRecord: event}); err != nil {
+		return err
+	}
+	w.beginSpan(category, name, processId, threadId, event.Timestamp)
 	return nil
 }
 
@@ -621,11 +969,33 @@ func (w *Writer) AddDurationEndEvent(category string, name string, processId Ker
 // AddDurationEndEventWithArgs is the same as AddDurationEndEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddDurationEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 0
-	if err := w.writeEventHeaderAndGenericData(eventTypeDurationEnd, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindDurationEnd, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
+	}
+
+	if err := writeEvent(w, DurationEndEvent{EventRecord: event}); err != nil {
+		return err
+	}
+	w.endSpan(category, name, processId, threadId, event.Timestamp)
 	return nil
 }
 
@@ -646,16 +1016,43 @@ func (w *Writer) AddDurationCompleteEvent(category string, name string, processI
 // AddDurationCompleteEventWithArgs is the same as AddDurationCompleteEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddDurationCompleteEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, beginTimestamp uint64, endTimestamp uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeDurationComplete, category, name, processId, threadId, beginTimestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+	if w.callerLocations {
+		arguments = withCallerLocation(arguments)
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindDurationComplete, category, name, processId, threadId, beginTimestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, endTimestamp); err != nil {
-		return fmt.Errorf("failed to write end timestamp - %w", err)
+	// Complete events are checked against their end timestamp, since they're usually written when the duration ends
+	beginTimestamp = w.normalizeTimestamp(beginTimestamp)
+	endTimestamp, err = w.prepareTimestamp(processId, threadId, endTimestamp)
+	if err != nil {
+		return err
+	}
+	if err := w.checkDuration(beginTimestamp, endTimestamp); err != nil {
+		return err
 	}
 
-	return nil
+	event, err := w.internEventRecord(category, name, processId, threadId, beginTimestamp, arguments)
+	if err != nil {
+		return err
+	}
+
+	return writeEvent(w, DurationCompleteEvent{EventRecord: event, EndTimestamp: endTimestamp})
 }
 
 // AddAsyncBeginEvent adds an async begin event record to the file
@@ -675,15 +1072,33 @@ func (w *Writer) AddAsyncBeginEvent(category string, name string, processId Kern
 // AddAsyncBeginEventWithArgs is the same as AddAsyncBeginEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddAsyncBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeAsyncBegin, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindAsyncBegin, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, asyncCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
+	if err := writeEvent(w, AsyncBeginEvent{EventRecord: event, CorrelationId: asyncCorrelationId}); err != nil {
+		return err
+	}
+	w.beginAsync(category, asyncCorrelationId)
 	return nil
 }
 
@@ -704,16 +1119,31 @@ func (w *Writer) AddAsyncInstantEvent(category string, name string, processId Ke
 // AddAsyncInstantEventWithArgs is the same as AddAsyncInstantEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddAsyncInstantEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeAsyncInstant, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindAsyncInstant, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, asyncCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	w.checkAsyncEvent(category, name, processId, threadId, event.Timestamp, asyncCorrelationId, false)
+	return writeEvent(w, AsyncInstantEvent{EventRecord: event, CorrelationId: asyncCorrelationId})
 }
 
 // AddAsyncEndEvent adds an async end event record to the file
@@ -733,16 +1163,31 @@ func (w *Writer) AddAsyncEndEvent(category string, name string, processId Kernel
 // AddAsyncEndEventWithArgs is the same as AddAsyncEndEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddAsyncEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, asyncCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeAsyncEnd, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindAsyncEnd, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, asyncCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	w.checkAsyncEvent(category, name, processId, threadId, event.Timestamp, asyncCorrelationId, true)
+	return writeEvent(w, AsyncEndEvent{EventRecord: event, CorrelationId: asyncCorrelationId})
 }
 
 // AddFlowBeginEvent adds an flow begin event record to the file
@@ -762,16 +1207,31 @@ func (w *Writer) AddFlowBeginEvent(category string, name string, processId Kerne
 // AddFlowBeginEventWithArgs is the same as AddFlowBeginEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddFlowBeginEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeFlowBegin, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindFlowBegin, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, flowCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	w.checkFlowEvent("begin", category, name, processId, threadId, event.Timestamp, flowCorrelationId)
+	return writeEvent(w, FlowBeginEvent{EventRecord: event, CorrelationId: flowCorrelationId})
 }
 
 // AddFlowStepEvent adds an flow step event record to the file
@@ -791,16 +1251,31 @@ func (w *Writer) AddFlowStepEvent(category string, name string, processId Kernel
 // AddFlowStepEventWithArgs is the same as AddFlowStepEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddFlowStepEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeFlowStep, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindFlowStep, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, flowCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	w.checkFlowEvent("step", category, name, processId, threadId, event.Timestamp, flowCorrelationId)
+	return writeEvent(w, FlowStepEvent{EventRecord: event, CorrelationId: flowCorrelationId})
 }
 
 // AddFlowEndEvent adds an flow end event record to the file
@@ -820,104 +1295,87 @@ func (w *Writer) AddFlowEndEvent(category string, name string, processId KernelO
 // AddFlowEndEventWithArgs is the same as AddFlowEndEvent, but it allows you to additionally include
 // arguments within the event record
 func (w *Writer) AddFlowEndEventWithArgs(category string, name string, processId KernelObjectID, threadId KernelObjectID, timestamp uint64, flowCorrelationId uint64, arguments map[string]interface{}) error {
-	extraSizeInWords := 1
-	if err := w.writeEventHeaderAndGenericData(eventTypeFlowEnd, category, name, processId, threadId, timestamp, arguments, extraSizeInWords); err != nil {
+	if w.disabled.Load() {
+		return nil
+	}
+
+	goroutineId := w.callingGoroutine(threadId)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threadId, err := w.goroutineThread(processId, threadId, goroutineId)
+	if err != nil {
 		return err
 	}
+	category, name, arguments, ok := w.intercept(EventKindFlowEnd, category, name, processId, threadId, timestamp, arguments)
+	if !ok {
+		return nil
+	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, flowCorrelationId); err != nil {
-		return fmt.Errorf("failed to write async correlation ID - %w", err)
+	event, err := w.newEventRecord(category, name, processId, threadId, timestamp, arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	w.checkFlowEvent("end", category, name, processId, threadId, event.Timestamp, flowCorrelationId)
+	return writeEvent(w, FlowEndEvent{EventRecord: event, CorrelationId: flowCorrelationId})
 }
 
 // AddBlobRecord adds a blob record to the file
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#blob-record
+//
+// `data` must be at most MaxBlobSize bytes. `blobType` can be one of the types defined by the spec,
+// or a custom type for tooling-specific payloads
 func (w *Writer) AddBlobRecord(name string, data []byte, blobType BlobType) error {
-	nameIndex, err := w.getOrCreateStringIndex(name)
-	if err != nil {
-		return err
+	if w.disabled.Load() {
+		return nil
 	}
 
-	blobSize := len(data)
-	paddedSize := (blobSize + 8 - 1) & (-8)
-	diff := paddedSize - blobSize
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	sizeInWords := 1 + (paddedSize / 8)
-	header := (uint64(blobType) << 48) | (uint64(blobSize) << 32) | (uint64(nameIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeBlob)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
+	return w.addBlobRecord(name, data, blobType)
+}
 
-	if _, err := w.file.Write(data); err != nil {
-		return fmt.Errorf("failed to write blob data - %w", err)
+func (w *Writer) addBlobRecord(name string, data []byte, blobType BlobType) error {
+	if !blobType.IsValid() {
+		return fmt.Errorf("invalid blob type %d - must be between 1 and %d", blobType, MaxBlobType)
 	}
 
-	if diff > 0 {
-		buffer := make([]byte, diff)
-		if _, err := w.file.Write(buffer); err != nil {
-			return fmt.Errorf("failed to write blob data padding - %w", err)
-		}
+	nameRef, err := w.getOrCreateStringRef(name)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return w.writeRecord(BlobRecord{Name: nameRef, Type: blobType, Data: data}.appendRecord(w.scratch[:0]))
 }
 
 // AddUserspaceObjectRecord adds a userspace object record to the file
 //
 // https://fuchsia.googlesource.com/fuchsia/+/refs/heads/main/docs/reference/tracing/trace-format.md#userspace-object-record
 func (w *Writer) AddUserspaceObjectRecord(name string, processId KernelObjectID, pointerValue uintptr, arguments map[string]interface{}) error {
-	nameIndex, err := w.getOrCreateStringIndex(name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nameRef, err := w.getOrCreateStringRef(name)
 	if err != nil {
 		return err
 	}
 
-	// Add up the argument word size
-	// And ensure the argument keys (and string values) are in the string table
-	argumentSizeInWords := 0
-	for key, value := range arguments {
-		size, err := getArgumentSizeInWords(value)
-		if err != nil {
-			return err
-		}
-		argumentSizeInWords += size
-
-		if err := w.addArgumentStringsToTable(key, value); err != nil {
-			return err
-		}
-	}
-
-	sizeInWords := /* Header */ 1 + /* pointer value */ 1 + /* process ID */ 1 + /* argument data */ argumentSizeInWords
-	threadIndex := 0
-	numArgs := len(arguments)
-	header := (uint64(numArgs) << 40) | (uint64(nameIndex) << 24) | (uint64(threadIndex) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeUserspaceObject)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, uint64(pointerValue)); err != nil {
-		return fmt.Errorf("failed to write pointer value - %w", err)
-	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, processId); err != nil {
-		return fmt.Errorf("failed to write process ID - %w", err)
-	}
-
-	wordsWritten := 0
-	for key, value := range arguments {
-		size, err := w.writeArgument(key, value)
-		if err != nil {
-			return err
-		}
-		wordsWritten += size
-	}
-	if wordsWritten != argumentSizeInWords {
-		return fmt.Errorf("Expected to write %d words of argument data, but actually wrote %d", argumentSizeInWords, wordsWritten)
+	// Ensure the argument keys (and string values) are in the string table
+	args, err := w.prepareArguments(arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return w.writeRecord(UserspaceObjectRecord{
+		Pointer:   pointerValue,
+		ProcessId: processId,
+		Name:      nameRef,
+		Arguments: args,
+	}.appendRecord(w.scratch[:0]))
 }
 
 // AddContextSwitchRecord adds a context switch scheduling record to the file
@@ -930,59 +1388,29 @@ func (w *Writer) AddContextSwitchRecord(cpuNumber uint16, outgoingThreadState ui
 // AddContextSwitchRecordWithArgs is the same as AddContextSwitchRecord, but it allows you to additionally include
 // arguments within the scheduling record
 func (w *Writer) AddContextSwitchRecordWithArgs(cpuNumber uint16, outgoingThreadState uint8, outgoingThreadId KernelObjectID, incomingThreadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
-	// Sanity check
-	// Ideally we'd find out the actual ENUM of valid states
-	if outgoingThreadState > 0xF {
-		return fmt.Errorf("invalid outgoingThreadState - %d is too large", outgoingThreadState)
+	if w.disabled.Load() {
+		return nil
 	}
 
-	// Add up the argument word size
-	// And ensure the argument keys (and string values) are in the string table
-	argumentSizeInWords := 0
-	for key, value := range arguments {
-		size, err := getArgumentSizeInWords(value)
-		if err != nil {
-			return err
-		}
-		argumentSizeInWords += size
-
-		if err := w.addArgumentStringsToTable(key, value); err != nil {
-			return err
-		}
-	}
-
-	sizeInWords := /* Header */ 1 + /* timestamp */ 1 + /* outgoing thread ID */ 1 + /* incoming thread ID */ 1 + /* argument data */ argumentSizeInWords
-	numArgs := len(arguments)
-	header := (uint64(schedulingRecordTypeContextSwitch) << 60) | (uint64(outgoingThreadState) << 36) | (uint64(cpuNumber) << 20) | (uint64(numArgs) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeScheduling)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
-	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, timestamp); err != nil {
-		return fmt.Errorf("failed to write timestamp - %w", err)
-	}
-
-	if err := binary.Write(w.file, binary.LittleEndian, outgoingThreadId); err != nil {
-		return fmt.Errorf("failed to write outgoing thread ID - %w", err)
-	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	if err := binary.Write(w.file, binary.LittleEndian, incomingThreadId); err != nil {
-		return fmt.Errorf("failed to write incoming thread ID - %w", err)
-	}
+	timestamp = w.normalizeTimestamp(timestamp)
 
-	wordsWritten := 0
-	for key, value := range arguments {
-		size, err := w.writeArgument(key, value)
-		if err != nil {
-			return err
-		}
-		wordsWritten += size
-	}
-	if wordsWritten != argumentSizeInWords {
-		return fmt.Errorf("Expected to write %d words of argument data, but actually wrote %d", argumentSizeInWords, wordsWritten)
+	// Ensure the argument keys (and string values) are in the string table
+	args, err := w.prepareArguments(arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return w.writeRecord(ContextSwitchRecord{
+		CPU:                 cpuNumber,
+		OutgoingThreadState: outgoingThreadState,
+		OutgoingThreadId:    outgoingThreadId,
+		IncomingThreadId:    incomingThreadId,
+		Timestamp:           timestamp,
+		Arguments:           args,
+	}.appendRecord(w.scratch[:0]))
 }
 
 // AddContextSwitchRecord adds a thread wakeup scheduling record to the file
@@ -995,47 +1423,25 @@ func (w *Writer) AddThreadWakeupRecord(cpuNumber uint16, wakingThreadId KernelOb
 // AddThreadWakeupRecordWithArgs is the same as AddThreadWakeupRecord, but it allows you to additionally include
 // arguments within the scheduling record
 func (w *Writer) AddThreadWakeupRecordWithArgs(cpuNumber uint16, wakingThreadId KernelObjectID, timestamp uint64, arguments map[string]interface{}) error {
-	// Add up the argument word size
-	// And ensure the argument keys (and string values) are in the string table
-	argumentSizeInWords := 0
-	for key, value := range arguments {
-		size, err := getArgumentSizeInWords(value)
-		if err != nil {
-			return err
-		}
-		argumentSizeInWords += size
-
-		if err := w.addArgumentStringsToTable(key, value); err != nil {
-			return err
-		}
-	}
-
-	sizeInWords := /* Header */ 1 + /* timestamp */ 1 + /* waking thread ID */ 1 + /* argument data */ argumentSizeInWords
-	numArgs := len(arguments)
-	header := (uint64(schedulingRecordTypeThreadWakeup) << 60) | (uint64(cpuNumber) << 20) | (uint64(numArgs) << 16) | (uint64(sizeInWords) << 4) | uint64(recordTypeScheduling)
-	if err := binary.Write(w.file, binary.LittleEndian, header); err != nil {
-		return fmt.Errorf("failed to write record header - %w", err)
+	if w.disabled.Load() {
+		return nil
 	}
 
-	if err := binary.Write(w.file, binary.LittleEndian, timestamp); err != nil {
-		return fmt.Errorf("failed to write timestamp - %w", err)
-	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	if err := binary.Write(w.file, binary.LittleEndian, wakingThreadId); err != nil {
-		return fmt.Errorf("failed to write waking thread ID - %w", err)
-	}
+	timestamp = w.normalizeTimestamp(timestamp)
 
-	wordsWritten := 0
-	for key, value := range arguments {
-		size, err := w.writeArgument(key, value)
-		if err != nil {
-			return err
-		}
-		wordsWritten += size
-	}
-	if wordsWritten != argumentSizeInWords {
-		return fmt.Errorf("Expected to write %d words of argument data, but actually wrote %d", argumentSizeInWords, wordsWritten)
+	// Ensure the argument keys (and string values) are in the string table
+	args, err := w.prepareArguments(arguments)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return w.writeRecord(ThreadWakeupRecord{
+		CPU:            cpuNumber,
+		WakingThreadId: wakingThreadId,
+		Timestamp:      timestamp,
+		Arguments:      args,
+	}.appendRecord(w.scratch[:0]))
 }
@@ -33,7 +33,7 @@ func TestStringTableFetchNonExistantEntryFails(t *testing.T) {
 	require.Error(t, err)
 
 	// If we add to the table and try again, it should succeed
-	_, err = writer.getOrCreateStringIndex("test")
+	_, err = writer.getOrCreateStringRef("test")
 	require.NoError(t, err)
 
 	index, err := writer.getStringIndex("test")
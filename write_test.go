@@ -1,6 +1,7 @@
 package fxt_test
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -198,3 +199,29 @@ func TestWrite(t *testing.T) {
 	closed = true
 	require.NoError(t, err)
 }
+
+func TestWriterSteadyStateAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+
+	writer, err := fxt.NewWriterTo(io.Discard)
+	require.NoError(t, err)
+	threadWriter := writer.NewThreadWriter(1, 3)
+
+	arguments := map[string]interface{}{"int": int32(1), "string": "value", "float": 1.5}
+	data := []byte("blob data")
+	writeEvents := func() {
+		require.NoError(t, writer.AddInstantEvent("category", "instant", 1, 2, 100))
+		require.NoError(t, writer.AddDurationCompleteEventWithArgs("category", "complete", 1, 2, 100, 200, arguments))
+		require.NoError(t, writer.AddCounterEvent("category", "counter", 1, 2, 100, arguments, 1))
+		require.NoError(t, writer.AddBlobRecord("blob", data, fxt.BlobTypeData))
+		require.NoError(t, writer.AddLargeBlobEventRecordWithArgs("category", "large", 1, 2, 100, data, arguments))
+		require.NoError(t, writer.AddContextSwitchRecord(1, 2, 3, 4, 100))
+		require.NoError(t, threadWriter.AddInstantEventWithArgs("category", "instant", 100, arguments))
+	}
+
+	// The first events fill in the string and thread tables
+	writeEvents()
+	require.Zero(t, testing.AllocsPerRun(100, writeEvents))
+}